@@ -0,0 +1,418 @@
+package annot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// ReadFile reads a list of annotations from a JSON file using the layout written by Export.
+func ReadFile(fileName string) (annots []Annotation, err error) {
+
+	bb, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ReadFile: can't read %s", fileName)
+	}
+
+	var af annotationFile
+
+	err = json.Unmarshal(bb, &af)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ReadFile: invalid JSON in %s", fileName)
+	}
+
+	return af.Annotations, nil
+}
+
+func floatArray(ff []float64) types.PDFArray {
+
+	a := types.PDFArray{}
+	for _, f := range ff {
+		a = append(a, types.PDFFloat(f))
+	}
+
+	return a
+}
+
+func validColor(c []float64) bool {
+	l := len(c)
+	return l == 0 || l == 1 || l == 3 || l == 4
+}
+
+// normalizedRect returns a rectangle given by its lower left and upper right corner.
+func normalizedRect(r []float64) []float64 {
+
+	llx, lly, urx, ury := r[0], r[1], r[2], r[3]
+
+	if llx > urx {
+		llx, urx = urx, llx
+	}
+
+	if lly > ury {
+		lly, ury = ury, lly
+	}
+
+	return []float64{llx, lly, urx, ury}
+}
+
+// quadPointsRect returns the bounding box of a list of quadrilaterals.
+func quadPointsRect(qp []float64) []float64 {
+
+	r := []float64{qp[0], qp[1], qp[0], qp[1]}
+
+	for i := 0; i < len(qp); i += 2 {
+		x, y := qp[i], qp[i+1]
+		if x < r[0] {
+			r[0] = x
+		}
+		if y < r[1] {
+			r[1] = y
+		}
+		if x > r[2] {
+			r[2] = x
+		}
+		if y > r[3] {
+			r[3] = y
+		}
+	}
+
+	return r
+}
+
+func checkAnnotation(a *Annotation, pageCount int) error {
+
+	if a.Page < 1 || a.Page > pageCount {
+		return errors.Errorf("annotation %s: invalid page number: %d", a.Subtype, a.Page)
+	}
+
+	switch a.Subtype {
+
+	case "Highlight", "Underline", "StrikeOut":
+		if len(a.QuadPoints) == 0 || len(a.QuadPoints)%8 > 0 {
+			return errors.Errorf("annotation %s on page %d: quadPoints must contain 8 numbers per quadrilateral", a.Subtype, a.Page)
+		}
+		if len(a.Rect) == 0 {
+			a.Rect = quadPointsRect(a.QuadPoints)
+		}
+
+	case "Link":
+		if a.URI == "" && a.DestPage == 0 {
+			return errors.Errorf("annotation Link on page %d: missing uri or destPage", a.Page)
+		}
+		if a.DestPage < 0 || a.DestPage > pageCount {
+			return errors.Errorf("annotation Link on page %d: invalid destPage: %d", a.Page, a.DestPage)
+		}
+
+	case "FileAttachment":
+		if a.FileName == "" {
+			return errors.Errorf("annotation FileAttachment on page %d: missing fileName", a.Page)
+		}
+
+	case "Text", "Square", "Circle", "FreeText":
+
+	default:
+		return errors.Errorf("annotation on page %d: unsupported subtype: %s", a.Page, a.Subtype)
+	}
+
+	if len(a.Rect) != 4 {
+		return errors.Errorf("annotation %s on page %d: rect must contain 4 numbers", a.Subtype, a.Page)
+	}
+	a.Rect = normalizedRect(a.Rect)
+
+	if !validColor(a.Color) || !validColor(a.InteriorCol) {
+		return errors.Errorf("annotation %s on page %d: colors must contain 1, 3 or 4 components", a.Subtype, a.Page)
+	}
+
+	return nil
+}
+
+// fileSpecDict embeds fileName and returns a file specification dict referring to the embedded file stream.
+func fileSpecDict(ctx *types.PDFContext, fileName string) (*types.PDFDict, error) {
+
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read attachment %s", fileName)
+	}
+
+	sd, err := ctx.InsertPDFStreamDict(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	sd.Insert("Type", types.PDFName("EmbeddedFile"))
+
+	err = filter.EncodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	objNr, err := ctx.InsertObject(*sd)
+	if err != nil {
+		return nil, err
+	}
+
+	indRef := types.NewPDFIndirectRef(objNr, 0)
+
+	_, name := filepath.Split(fileName)
+
+	efDict := types.NewPDFDict()
+	efDict.Insert("F", indRef)
+	efDict.Insert("UF", indRef)
+
+	d := types.NewPDFDict()
+	d.Insert("Type", types.PDFName("Filespec"))
+	d.Insert("F", types.TextStringLiteral(name))
+	d.Insert("UF", types.TextStringLiteral(name))
+	d.Insert("EF", efDict)
+
+	return &d, nil
+}
+
+func addMarkupEntries(d *types.PDFDict, a Annotation, now string) {
+
+	if a.Author != "" {
+		d.Insert("T", types.TextStringLiteral(a.Author))
+	}
+
+	if a.Subject != "" {
+		d.Insert("Subj", types.TextStringLiteral(a.Subject))
+	}
+
+	creationDate := a.CreationDate
+	if creationDate == "" {
+		creationDate = now
+	}
+	d.Insert("CreationDate", types.TextStringLiteral(creationDate))
+}
+
+func addSubtypeEntries(ctx *types.PDFContext, d *types.PDFDict, a Annotation, pages []types.PDFIndirectRef) error {
+
+	switch a.Subtype {
+
+	case "Text":
+		icon := a.Icon
+		if icon == "" {
+			icon = "Note"
+		}
+		d.Insert("Name", types.PDFName(icon))
+		if a.Open {
+			d.Insert("Open", types.PDFBoolean(true))
+		}
+
+	case "Link":
+		d.Insert("Border", types.PDFArray{types.PDFInteger(0), types.PDFInteger(0), types.PDFFloat(a.BorderWidth)})
+		if a.URI != "" {
+			action := types.NewPDFDict()
+			action.Insert("S", types.PDFName("URI"))
+			// URIs are 7-bit ASCII strings and must not be UTF16 encoded.
+			uri, _ := types.Escape(a.URI)
+			action.Insert("URI", types.PDFStringLiteral(*uri))
+			d.Insert("A", action)
+			break
+		}
+		d.Insert("Dest", types.PDFArray{pages[a.DestPage-1], types.PDFName("Fit")})
+
+	case "Highlight", "Underline", "StrikeOut":
+		d.Insert("QuadPoints", floatArray(a.QuadPoints))
+
+	case "Square", "Circle":
+		if len(a.InteriorCol) > 0 {
+			d.Insert("IC", floatArray(a.InteriorCol))
+		}
+		bs := types.NewPDFDict()
+		bs.Insert("W", types.PDFFloat(borderWidth(a)))
+		d.Insert("BS", bs)
+
+	case "FreeText":
+		d.Insert("DA", types.TextStringLiteral(defaultAppearance(a)))
+		if a.Quadding > 0 {
+			d.Insert("Q", types.PDFInteger(a.Quadding))
+		}
+
+	case "FileAttachment":
+		fs, err := fileSpecDict(ctx, a.FileName)
+		if err != nil {
+			return err
+		}
+		objNr, err := ctx.InsertObject(*fs)
+		if err != nil {
+			return err
+		}
+		d.Insert("FS", types.NewPDFIndirectRef(objNr, 0))
+		icon := a.Icon
+		if icon == "" {
+			icon = "PushPin"
+		}
+		d.Insert("Name", types.PDFName(icon))
+	}
+
+	return nil
+}
+
+func annotDict(ctx *types.PDFContext, a Annotation, pages []types.PDFIndirectRef, now string) (*types.PDFDict, error) {
+
+	d := types.NewPDFDict()
+	d.Insert("Type", types.PDFName("Annot"))
+	d.Insert("Subtype", types.PDFName(a.Subtype))
+	d.Insert("Rect", floatArray(a.Rect))
+	d.Insert("P", pages[a.Page-1])
+
+	if a.Contents != "" {
+		d.Insert("Contents", types.TextStringLiteral(a.Contents))
+	}
+
+	if a.Name != "" {
+		d.Insert("NM", types.TextStringLiteral(a.Name))
+	}
+
+	modDate := a.ModDate
+	if modDate == "" {
+		modDate = now
+	}
+	d.Insert("M", types.TextStringLiteral(modDate))
+
+	// Print the annotation unless flags are specified.
+	flags := a.Flags
+	if flags == 0 {
+		flags = 4
+	}
+	d.Insert("F", types.PDFInteger(flags))
+
+	if len(a.Color) > 0 {
+		d.Insert("C", floatArray(a.Color))
+	}
+
+	if a.Subtype != "Link" {
+		addMarkupEntries(&d, a, now)
+	}
+
+	err := addSubtypeEntries(ctx, &d, a, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	ap, err := appearanceStream(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	apDict := types.NewPDFDict()
+	apDict.Insert("N", *ap)
+	d.Insert("AP", apDict)
+
+	return &d, nil
+}
+
+// appendToPage appends an annotation to the Annots array of a page.
+func appendToPage(ctx *types.PDFContext, pageDict *types.PDFDict, indRef types.PDFIndirectRef) (err error) {
+
+	obj, found := pageDict.Find("Annots")
+	if !found || obj == nil {
+		pageDict.Update("Annots", types.PDFArray{indRef})
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil {
+		return
+	}
+
+	a := types.PDFArray{}
+	if arr != nil {
+		a = append(a, *arr...)
+	}
+	a = append(a, indRef)
+
+	if ir, ok := obj.(types.PDFIndirectRef); ok {
+		entry, found := ctx.FindTableEntryForIndRef(&ir)
+		if !found {
+			return errors.Errorf("appendToPage: missing Annots obj#%d", ir.ObjectNumber)
+		}
+		entry.Object = a
+		return
+	}
+
+	pageDict.Update("Annots", a)
+
+	return
+}
+
+// Add creates annotations including their appearance streams and attaches them to their pages.
+// Supported subtypes are Text, Link, Highlight, Underline, StrikeOut, Square, Circle, FreeText and FileAttachment.
+func Add(ctx *types.PDFContext, annots []Annotation) (err error) {
+
+	logDebugAnnot.Println("Add begin")
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	now := types.DateString(time.Now())
+
+	for i := range annots {
+
+		a := annots[i]
+
+		err = checkAnnotation(&a, len(pages))
+		if err != nil {
+			return
+		}
+
+		d, err := annotDict(ctx, a, pages, now)
+		if err != nil {
+			return err
+		}
+
+		objNr, err := ctx.InsertObject(*d)
+		if err != nil {
+			return err
+		}
+
+		pageDict, err := ctx.DereferenceDict(pages[a.Page-1])
+		if err != nil {
+			return err
+		}
+
+		err = appendToPage(ctx, pageDict, types.NewPDFIndirectRef(objNr, 0))
+		if err != nil {
+			return err
+		}
+
+		logDebugAnnot.Printf("Add: page %d: %s obj#%d\n", a.Page, a.Subtype, objNr)
+	}
+
+	logDebugAnnot.Println("Add end")
+
+	return
+}
+
+func borderWidth(a Annotation) float64 {
+	if a.BorderWidth > 0 {
+		return a.BorderWidth
+	}
+	return 1
+}
+
+func fontSize(a Annotation) float64 {
+	if a.FontSize > 0 {
+		return a.FontSize
+	}
+	return 12
+}
+
+// defaultAppearance returns the default appearance string of a FreeText annotation.
+func defaultAppearance(a Annotation) string {
+
+	if a.DA != "" {
+		return a.DA
+	}
+
+	return fmt.Sprintf("/Helv %s Tf 0 g", fmtNum(fontSize(a)))
+}
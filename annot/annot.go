@@ -0,0 +1,804 @@
+// Package annot provides management code for page annotations.
+package annot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugAnnot, logInfoAnnot, logErrorAnnot *log.Logger
+
+func init() {
+	logDebugAnnot = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfoAnnot = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logErrorAnnot = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugAnnot = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugAnnot = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Annotation represents the exportable attributes of an annotation dict.
+type Annotation struct {
+	Page         int         `json:"page"`
+	ObjNr        int         `json:"objNr,omitempty"`
+	Subtype      string      `json:"subtype"`
+	Rect         []float64   `json:"rect"`
+	Contents     string      `json:"contents,omitempty"`
+	Author       string      `json:"author,omitempty"`
+	Subject      string      `json:"subject,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	ModDate      string      `json:"modDate,omitempty"`
+	CreationDate string      `json:"creationDate,omitempty"`
+	Flags        int         `json:"flags,omitempty"`
+	Color        []float64   `json:"color,omitempty"`
+	Popup        int         `json:"popup,omitempty"`
+	InReplyTo    int         `json:"inReplyTo,omitempty"`
+	Parent       int         `json:"parent,omitempty"`
+	Open         bool        `json:"open,omitempty"`
+	Icon         string      `json:"icon,omitempty"`
+	URI          string      `json:"uri,omitempty"`
+	DestPage     int         `json:"destPage,omitempty"`
+	DestName     string      `json:"destName,omitempty"`
+	DA           string      `json:"da,omitempty"`
+	Quadding     int         `json:"quadding,omitempty"`
+	InteriorCol  []float64   `json:"interiorColor,omitempty"`
+	Line         []float64   `json:"line,omitempty"`
+	Vertices     []float64   `json:"vertices,omitempty"`
+	QuadPoints   []float64   `json:"quadPoints,omitempty"`
+	InkList      [][]float64 `json:"inkList,omitempty"`
+	FileName     string      `json:"fileName,omitempty"`
+	FieldName    string      `json:"fieldName,omitempty"`
+	FontSize     float64     `json:"fontSize,omitempty"`
+	BorderWidth  float64     `json:"borderWidth,omitempty"`
+}
+
+// annotationFile is the JSON layout used for export and import.
+type annotationFile struct {
+	Annotations []Annotation `json:"annotations"`
+}
+
+func marshal(annots []Annotation) ([]byte, error) {
+	return json.MarshalIndent(annotationFile{Annotations: annots}, "", "\t")
+}
+
+// annotEntry is an annotation dict as found in some page's Annots array.
+type annotEntry struct {
+	pageNr int
+	objNr  int // 0 for direct annotation dicts.
+	dict   *types.PDFDict
+}
+
+func (ae annotEntry) subtype() string {
+	if s := ae.dict.Subtype(); s != nil {
+		return *s
+	}
+	return ""
+}
+
+func needsPage(selectedPages types.IntSet, pageNr int) bool {
+	return selectedPages == nil || len(selectedPages) == 0 || selectedPages[pageNr]
+}
+
+func needsSubtype(subtypes types.StringSet, subtype string) bool {
+	return subtypes == nil || len(subtypes) == 0 || subtypes[subtype]
+}
+
+func objNr(obj interface{}) int {
+	if indRef, ok := obj.(types.PDFIndirectRef); ok {
+		return indRef.ObjectNumber.Value()
+	}
+	return 0
+}
+
+func textString(ctx *types.PDFContext, obj interface{}) (s string) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch str := obj.(type) {
+
+	case types.PDFStringLiteral:
+		s, _ = types.StringLiteralToString(str.Value())
+
+	case types.PDFHexLiteral:
+		s, _ = types.HexLiteralToString(str.Value())
+
+	case types.PDFName:
+		s = str.Value()
+	}
+
+	return
+}
+
+func textEntry(ctx *types.PDFContext, dict *types.PDFDict, key string) string {
+
+	obj, found := dict.Find(key)
+	if !found {
+		return ""
+	}
+
+	return textString(ctx, obj)
+}
+
+func number(ctx *types.PDFContext, obj interface{}) (f float64, ok bool) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch n := obj.(type) {
+
+	case types.PDFInteger:
+		return float64(n.Value()), true
+
+	case types.PDFFloat:
+		return n.Value(), true
+	}
+
+	return
+}
+
+func numberArray(ctx *types.PDFContext, obj interface{}) (a []float64) {
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return
+	}
+
+	for _, o := range *arr {
+		if f, ok := number(ctx, o); ok {
+			a = append(a, f)
+		}
+	}
+
+	return
+}
+
+func numberArrayEntry(ctx *types.PDFContext, dict *types.PDFDict, key string) []float64 {
+
+	obj, found := dict.Find(key)
+	if !found {
+		return nil
+	}
+
+	return numberArray(ctx, obj)
+}
+
+// pageNumbers maps page dict object numbers to page numbers.
+func pageNumbers(pages []types.PDFIndirectRef) map[int]int {
+
+	m := map[int]int{}
+
+	for i, indRef := range pages {
+		m[indRef.ObjectNumber.Value()] = i + 1
+	}
+
+	return m
+}
+
+func pageAnnots(ctx *types.PDFContext, pageDict *types.PDFDict) (arr *types.PDFArray, err error) {
+
+	obj, found := pageDict.Find("Annots")
+	if !found || obj == nil {
+		return
+	}
+
+	return ctx.DereferenceArray(obj)
+}
+
+// annotEntries returns all annotation dicts of selected pages in page order.
+func annotEntries(ctx *types.PDFContext, pages []types.PDFIndirectRef, selectedPages types.IntSet) (entries []annotEntry, err error) {
+
+	for i, indRef := range pages {
+
+		pageNr := i + 1
+
+		if !needsPage(selectedPages, pageNr) {
+			continue
+		}
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		arr, err := pageAnnots(ctx, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		if arr == nil {
+			continue
+		}
+
+		for _, obj := range *arr {
+
+			if obj == nil {
+				continue
+			}
+
+			d, err := ctx.DereferenceDict(obj)
+			if err != nil {
+				return nil, err
+			}
+
+			if d == nil {
+				continue
+			}
+
+			entries = append(entries, annotEntry{pageNr: pageNr, objNr: objNr(obj), dict: d})
+		}
+
+	}
+
+	return
+}
+
+func linkDest(ctx *types.PDFContext, a *Annotation, obj interface{}, pageNrs map[int]int) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch dest := obj.(type) {
+
+	case types.PDFName:
+		a.DestName = dest.Value()
+
+	case types.PDFStringLiteral, types.PDFHexLiteral:
+		a.DestName = textString(ctx, dest)
+
+	case types.PDFArray:
+		if len(dest) > 0 {
+			a.DestPage = pageNrs[objNr(dest[0])]
+		}
+	}
+}
+
+func linkAction(ctx *types.PDFContext, a *Annotation, obj interface{}, pageNrs map[int]int) {
+
+	d, err := ctx.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return
+	}
+
+	s := d.NameEntry("S")
+	if s == nil {
+		return
+	}
+
+	switch *s {
+
+	case "URI":
+		a.URI = textEntry(ctx, d, "URI")
+
+	case "GoTo":
+		if dest, found := d.Find("D"); found {
+			linkDest(ctx, a, dest, pageNrs)
+		}
+	}
+}
+
+func fieldName(ctx *types.PDFContext, dict *types.PDFDict) string {
+
+	var parts []string
+
+	for d := dict; d != nil; {
+
+		if t := textEntry(ctx, d, "T"); t != "" {
+			parts = append([]string{t}, parts...)
+		}
+
+		obj, found := d.Find("Parent")
+		if !found {
+			break
+		}
+
+		var err error
+		d, err = ctx.DereferenceDict(obj)
+		if err != nil {
+			break
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func annotation(ctx *types.PDFContext, ae annotEntry, pageNrs map[int]int) Annotation {
+
+	d := ae.dict
+
+	a := Annotation{
+		Page:         ae.pageNr,
+		ObjNr:        ae.objNr,
+		Subtype:      ae.subtype(),
+		Rect:         numberArrayEntry(ctx, d, "Rect"),
+		Contents:     textEntry(ctx, d, "Contents"),
+		Subject:      textEntry(ctx, d, "Subj"),
+		Name:         textEntry(ctx, d, "NM"),
+		ModDate:      textEntry(ctx, d, "M"),
+		CreationDate: textEntry(ctx, d, "CreationDate"),
+		Color:        numberArrayEntry(ctx, d, "C"),
+		Popup:        objNr(d.Dict["Popup"]),
+		InReplyTo:    objNr(d.Dict["IRT"]),
+	}
+
+	if f, ok := number(ctx, d.Dict["F"]); ok {
+		a.Flags = int(f)
+	}
+
+	if o, found := d.Find("Open"); found {
+		if b, ok := o.(types.PDFBoolean); ok {
+			a.Open = b.Value()
+		}
+	}
+
+	switch a.Subtype {
+
+	case "Widget":
+		a.FieldName = fieldName(ctx, d)
+
+	default:
+		a.Author = textEntry(ctx, d, "T")
+	}
+
+	switch a.Subtype {
+
+	case "Text", "Stamp", "FileAttachment", "Sound":
+		a.Icon = textEntry(ctx, d, "Name")
+
+	case "Link":
+		if obj, found := d.Find("A"); found {
+			linkAction(ctx, &a, obj, pageNrs)
+		}
+		if obj, found := d.Find("Dest"); found {
+			linkDest(ctx, &a, obj, pageNrs)
+		}
+
+	case "FreeText":
+		a.DA = textEntry(ctx, d, "DA")
+		if q, ok := number(ctx, d.Dict["Q"]); ok {
+			a.Quadding = int(q)
+		}
+
+	case "Line":
+		a.Line = numberArrayEntry(ctx, d, "L")
+		a.InteriorCol = numberArrayEntry(ctx, d, "IC")
+
+	case "Square", "Circle":
+		a.InteriorCol = numberArrayEntry(ctx, d, "IC")
+		if bs, err := ctx.DereferenceDict(d.Dict["BS"]); err == nil && bs != nil {
+			a.BorderWidth, _ = number(ctx, bs.Dict["W"])
+		}
+
+	case "Polygon", "PolyLine":
+		a.Vertices = numberArrayEntry(ctx, d, "Vertices")
+		a.InteriorCol = numberArrayEntry(ctx, d, "IC")
+
+	case "Highlight", "Underline", "Squiggly", "StrikeOut", "Redact":
+		a.QuadPoints = numberArrayEntry(ctx, d, "QuadPoints")
+
+	case "Ink":
+		if arr, err := ctx.DereferenceArray(d.Dict["InkList"]); err == nil && arr != nil {
+			for _, obj := range *arr {
+				a.InkList = append(a.InkList, numberArray(ctx, obj))
+			}
+		}
+
+	case "Popup":
+		a.Parent = objNr(d.Dict["Parent"])
+
+	}
+
+	if a.Subtype == "FileAttachment" {
+		if fs, err := ctx.DereferenceDict(d.Dict["FS"]); err == nil && fs != nil {
+			a.FileName = textEntry(ctx, fs, "UF")
+			if a.FileName == "" {
+				a.FileName = textEntry(ctx, fs, "F")
+			}
+		} else {
+			a.FileName = textEntry(ctx, d, "FS")
+		}
+	}
+
+	return a
+}
+
+// Annotations returns the annotations of selected pages filtered by subtype.
+func Annotations(ctx *types.PDFContext, selectedPages types.IntSet, subtypes types.StringSet) (annots []Annotation, err error) {
+
+	logDebugAnnot.Println("Annotations begin")
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	entries, err := annotEntries(ctx, pages, selectedPages)
+	if err != nil {
+		return
+	}
+
+	pageNrs := pageNumbers(pages)
+
+	for _, ae := range entries {
+		if needsSubtype(subtypes, ae.subtype()) {
+			annots = append(annots, annotation(ctx, ae, pageNrs))
+		}
+	}
+
+	logDebugAnnot.Println("Annotations end")
+
+	return
+}
+
+func rectString(r []float64) string {
+
+	ss := make([]string, len(r))
+	for i, f := range r {
+		ss[i] = fmt.Sprintf("%.2f", f)
+	}
+
+	return "[" + strings.Join(ss, " ") + "]"
+}
+
+// List returns a list of annotations for selected pages filtered by subtype.
+func List(ctx *types.PDFContext, selectedPages types.IntSet, subtypes types.StringSet) (list []string, err error) {
+
+	logDebugAnnot.Println("List begin")
+
+	annots, err := Annotations(ctx, selectedPages, subtypes)
+	if err != nil {
+		return
+	}
+
+	for _, a := range annots {
+
+		s := fmt.Sprintf("page %d: %s obj#%d %s", a.Page, a.Subtype, a.ObjNr, rectString(a.Rect))
+
+		switch {
+		case a.Contents != "":
+			s += fmt.Sprintf(" %q", a.Contents)
+		case a.URI != "":
+			s += " " + a.URI
+		case a.FieldName != "":
+			s += " " + a.FieldName
+		}
+
+		list = append(list, s)
+	}
+
+	logDebugAnnot.Println("List end")
+
+	return
+}
+
+func removeFieldRefs(ctx *types.PDFContext, arr types.PDFArray, removed types.IntSet) (types.PDFArray, error) {
+
+	var a types.PDFArray
+
+	for _, obj := range arr {
+
+		if removed[objNr(obj)] {
+			continue
+		}
+
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if d != nil {
+			if kids := d.PDFArrayEntry("Kids"); kids != nil {
+				k, err := removeFieldRefs(ctx, *kids, removed)
+				if err != nil {
+					return nil, err
+				}
+				if len(k) == 0 {
+					// A field whose widgets are all gone is gone, too.
+					continue
+				}
+				d.Update("Kids", k)
+			}
+		}
+
+		a = append(a, obj)
+	}
+
+	return a, nil
+}
+
+// removeFormFields drops references to removed widget annotations from the AcroForm field tree.
+func removeFormFields(ctx *types.PDFContext, removed types.IntSet) (err error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found {
+		return
+	}
+
+	d, err := ctx.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return
+	}
+
+	obj, found = d.Find("Fields")
+	if !found {
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return
+	}
+
+	fields, err := removeFieldRefs(ctx, *arr, removed)
+	if err != nil {
+		return
+	}
+
+	if indRef, ok := obj.(types.PDFIndirectRef); ok {
+		entry, found := ctx.FindTableEntryForIndRef(&indRef)
+		if !found {
+			return errors.Errorf("removeFormFields: missing Fields obj#%d", indRef.ObjectNumber)
+		}
+		entry.Object = fields
+		return
+	}
+
+	d.Update("Fields", fields)
+
+	return
+}
+
+// markForRemoval returns the object numbers of all annotations that need to go
+// including popups of removed annotations.
+func markForRemoval(all []annotEntry, selected func(annotEntry) bool) (removed types.IntSet, count int) {
+
+	removed = types.IntSet{}
+
+	for _, ae := range all {
+		if !selected(ae) {
+			continue
+		}
+		count++
+		if ae.objNr > 0 {
+			removed[ae.objNr] = true
+		}
+		if popup := objNr(ae.dict.Dict["Popup"]); popup > 0 {
+			removed[popup] = true
+		}
+	}
+
+	// Popups belonging to a removed parent are removed, too.
+	for _, ae := range all {
+		if ae.subtype() == "Popup" && removed[objNr(ae.dict.Dict["Parent"])] && ae.objNr > 0 {
+			removed[ae.objNr] = true
+		}
+	}
+
+	return
+}
+
+func removeAnnotsFromPage(ctx *types.PDFContext, pageDict *types.PDFDict, removed types.IntSet, selected func(*types.PDFDict) bool) (err error) {
+
+	obj, found := pageDict.Find("Annots")
+	if !found {
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return
+	}
+
+	var a types.PDFArray
+
+	for _, o := range *arr {
+
+		if removed[objNr(o)] {
+			continue
+		}
+
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		// Direct annotation dicts have no object number and are checked individually.
+		if objNr(o) == 0 && selected(d) {
+			continue
+		}
+
+		// Clean up references to removed annotations.
+		if removed[objNr(d.Dict["IRT"])] {
+			d.Delete("IRT")
+			d.Delete("RT")
+		}
+		if removed[objNr(d.Dict["Popup"])] {
+			d.Delete("Popup")
+		}
+
+		a = append(a, o)
+	}
+
+	indRef, isIndRef := obj.(types.PDFIndirectRef)
+
+	if len(a) == 0 {
+		pageDict.Delete("Annots")
+		if isIndRef {
+			err = ctx.DeleteObject(indRef.ObjectNumber.Value())
+		}
+		return
+	}
+
+	if isIndRef {
+		entry, found := ctx.FindTableEntryForIndRef(&indRef)
+		if !found {
+			return errors.Errorf("removeAnnotsFromPage: missing Annots obj#%d", indRef.ObjectNumber)
+		}
+		entry.Object = a
+		return
+	}
+
+	pageDict.Update("Annots", a)
+
+	return
+}
+
+// Remove deletes the annotations of selected pages filtered by subtype.
+// Popups attached to removed annotations and references to removed annotations are cleaned up.
+// ok returns true if at least one annotation was removed.
+func Remove(ctx *types.PDFContext, selectedPages types.IntSet, subtypes types.StringSet) (ok bool, err error) {
+
+	logDebugAnnot.Println("Remove begin")
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	all, err := annotEntries(ctx, pages, nil)
+	if err != nil {
+		return
+	}
+
+	selected := func(ae annotEntry) bool {
+		return needsPage(selectedPages, ae.pageNr) && needsSubtype(subtypes, ae.subtype())
+	}
+
+	removed, count := markForRemoval(all, selected)
+	if count == 0 {
+		return false, nil
+	}
+
+	for i, indRef := range pages {
+
+		pageNr := i + 1
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return false, err
+		}
+
+		err = removeAnnotsFromPage(ctx, pageDict, removed, func(d *types.PDFDict) bool {
+			return selected(annotEntry{pageNr: pageNr, dict: d})
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = removeFormFields(ctx, removed)
+	if err != nil {
+		return
+	}
+
+	for objNr := range removed {
+		err = ctx.DeleteObject(objNr)
+		if err != nil {
+			return
+		}
+	}
+
+	logDebugAnnot.Println("Remove end")
+
+	return true, nil
+}
+
+// RemoveWidgets deletes the widget annotations and form fields identified by object number
+// from all pages and from the AcroForm field tree.
+// Fields without remaining widgets are removed, too, and so is the AcroForm dict once it has no fields left.
+func RemoveWidgets(ctx *types.PDFContext, removed types.IntSet) (err error) {
+
+	logDebugAnnot.Println("RemoveWidgets begin")
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	for _, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		err = removeAnnotsFromPage(ctx, pageDict, removed, func(d *types.PDFDict) bool { return false })
+		if err != nil {
+			return err
+		}
+	}
+
+	err = removeFormFields(ctx, removed)
+	if err != nil {
+		return
+	}
+
+	err = removeEmptyAcroForm(ctx)
+	if err != nil {
+		return
+	}
+
+	for objNr := range removed {
+		err = ctx.DeleteObject(objNr)
+		if err != nil {
+			return
+		}
+	}
+
+	logDebugAnnot.Println("RemoveWidgets end")
+
+	return
+}
+
+// Export writes the annotations of selected pages filtered by subtype as JSON to fileName.
+func Export(ctx *types.PDFContext, fileName string, selectedPages types.IntSet, subtypes types.StringSet) (err error) {
+
+	logDebugAnnot.Println("Export begin")
+
+	annots, err := Annotations(ctx, selectedPages, subtypes)
+	if err != nil {
+		return
+	}
+
+	bb, err := marshal(annots)
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(fileName, bb, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "Export: can't write %s", fileName)
+	}
+
+	logDebugAnnot.Println("Export end")
+
+	return
+}
@@ -0,0 +1,516 @@
+package annot
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// The standard font resource names used in default appearance strings.
+var daFonts = map[string]string{
+	"Helv": "Helvetica",
+	"HeBo": "Helvetica-Bold",
+	"Cour": "Courier",
+	"TiRo": "Times-Roman",
+	"ZaDb": "ZapfDingbats",
+}
+
+// Bezier control point distance for approximating a quarter circle.
+const kappa = 0.5523
+
+func fmtNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ColorOp returns the operator setting c as fill or stroke color.
+func ColorOp(c []float64, stroke bool) string {
+
+	var op string
+
+	switch len(c) {
+	case 1:
+		op = "g"
+	case 3:
+		op = "rg"
+	case 4:
+		op = "k"
+	default:
+		return ""
+	}
+
+	if stroke {
+		op = strings.ToUpper(op)
+	}
+
+	ss := make([]string, len(c))
+	for i, f := range c {
+		ss[i] = fmtNum(f)
+	}
+
+	return strings.Join(ss, " ") + " " + op + "\n"
+}
+
+// DA represents the parsed default appearance string of a variable text annotation or form field.
+type DA struct {
+	FontName string // The font resource name.
+	FontSize float64
+	Color    []float64
+}
+
+// ParseDA parses a default appearance string like "/Helv 12 Tf 0 g".
+func ParseDA(s string) (da DA, err error) {
+
+	var operands []string
+
+	for _, t := range strings.Fields(s) {
+
+		switch t {
+
+		case "Tf":
+			if len(operands) < 2 {
+				return da, errors.Errorf("ParseDA: corrupt Tf in %q", s)
+			}
+			da.FontName = strings.TrimPrefix(operands[len(operands)-2], "/")
+			da.FontSize, err = strconv.ParseFloat(operands[len(operands)-1], 64)
+			if err != nil {
+				return da, errors.Errorf("ParseDA: corrupt font size in %q", s)
+			}
+
+		case "g", "rg", "k":
+			n := map[string]int{"g": 1, "rg": 3, "k": 4}[t]
+			if len(operands) < n {
+				return da, errors.Errorf("ParseDA: corrupt %s in %q", t, s)
+			}
+			da.Color = nil
+			for _, o := range operands[len(operands)-n:] {
+				f, err := strconv.ParseFloat(o, 64)
+				if err != nil {
+					return da, errors.Errorf("ParseDA: corrupt color in %q", s)
+				}
+				da.Color = append(da.Color, f)
+			}
+
+		default:
+			operands = append(operands, t)
+			continue
+		}
+
+		operands = nil
+	}
+
+	return da, nil
+}
+
+// BaseFont returns the name of the standard font for a font resource name used in a default appearance string.
+func BaseFont(fontName string) string {
+
+	if font.IsStandardFont(fontName) {
+		return fontName
+	}
+
+	if bf, ok := daFonts[fontName]; ok {
+		return bf
+	}
+
+	return "Helvetica"
+}
+
+// FormXObject inserts a form XObject for content and returns its indirect reference.
+func FormXObject(ctx *types.PDFContext, bbox []float64, content []byte, resources *types.PDFDict) (*types.PDFIndirectRef, error) {
+
+	sd, err := ctx.InsertPDFStreamDict(content)
+	if err != nil {
+		return nil, err
+	}
+
+	sd.Insert("Type", types.PDFName("XObject"))
+	sd.Insert("Subtype", types.PDFName("Form"))
+	sd.Insert("BBox", floatArray(bbox))
+
+	if resources != nil {
+		sd.Insert("Resources", *resources)
+	}
+
+	err = filter.EncodeStream(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	objNr, err := ctx.InsertObject(*sd)
+	if err != nil {
+		return nil, err
+	}
+
+	indRef := types.NewPDFIndirectRef(objNr, 0)
+
+	return &indRef, nil
+}
+
+// FontResources inserts a standard font dict and returns a resource dict referring to it by fontName.
+func FontResources(ctx *types.PDFContext, fontName string) (*types.PDFDict, error) {
+
+	objNr, err := ctx.InsertObject(font.NewStandardFontDict(BaseFont(fontName)))
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := types.NewPDFDict()
+	fonts.Insert(fontName, types.NewPDFIndirectRef(objNr, 0))
+
+	d := types.NewPDFDict()
+	d.Insert("Font", fonts)
+
+	return &d, nil
+}
+
+func rectOp(b *bytes.Buffer, x, y, w, h float64) {
+	fmt.Fprintf(b, "%s %s %s %s re\n", fmtNum(x), fmtNum(y), fmtNum(w), fmtNum(h))
+}
+
+func textIcon(b *bytes.Buffer, a Annotation) {
+
+	r := a.Rect
+	w, h := r[2]-r[0], r[3]-r[1]
+
+	c := a.Color
+	if len(c) == 0 {
+		c = []float64{1, 1, 0}
+	}
+
+	b.WriteString(ColorOp(c, false))
+	b.WriteString("0 G 1 w\n")
+	rectOp(b, r[0]+0.5, r[1]+0.5, w-1, h-1)
+	b.WriteString("B\n")
+
+	// Some lines of text.
+	for i := 1; i <= 3; i++ {
+		y := r[3] - float64(i)*h/4
+		fmt.Fprintf(b, "%s %s m %s %s l\n", fmtNum(r[0]+w/5), fmtNum(y), fmtNum(r[2]-w/5), fmtNum(y))
+	}
+	b.WriteString("S\n")
+}
+
+func fileAttachmentIcon(b *bytes.Buffer, a Annotation) {
+
+	r := a.Rect
+	d := (r[2] - r[0]) / 3
+
+	c := a.Color
+	if len(c) == 0 {
+		c = []float64{0.5, 0.5, 1}
+	}
+
+	// A sheet of paper with a dog-ear.
+	b.WriteString(ColorOp(c, false))
+	b.WriteString("0 G 1 w\n")
+	fmt.Fprintf(b, "%s %s m %s %s l %s %s l %s %s l %s %s l h B\n",
+		fmtNum(r[0]+0.5), fmtNum(r[1]+0.5),
+		fmtNum(r[2]-0.5), fmtNum(r[1]+0.5),
+		fmtNum(r[2]-0.5), fmtNum(r[3]-d),
+		fmtNum(r[2]-d), fmtNum(r[3]-0.5),
+		fmtNum(r[0]+0.5), fmtNum(r[3]-0.5))
+	fmt.Fprintf(b, "%s %s m %s %s l %s %s l S\n",
+		fmtNum(r[2]-d), fmtNum(r[3]-0.5),
+		fmtNum(r[2]-d), fmtNum(r[3]-d),
+		fmtNum(r[2]-0.5), fmtNum(r[3]-d))
+}
+
+func textMarkup(b *bytes.Buffer, a Annotation) {
+
+	qp := a.QuadPoints
+
+	c := a.Color
+	if len(c) == 0 {
+		c = []float64{1, 1, 0}
+		if a.Subtype != "Highlight" {
+			c = []float64{1, 0, 0}
+		}
+	}
+
+	if a.Subtype == "Highlight" {
+		b.WriteString("/GS0 gs\n")
+		b.WriteString(ColorOp(c, false))
+	} else {
+		b.WriteString(ColorOp(c, true))
+	}
+
+	for i := 0; i < len(qp); i += 8 {
+
+		// The corners of a quadrilateral: upper left, upper right, lower left, lower right.
+		x1, y1, x2, y2, x3, y3, x4, y4 := qp[i], qp[i+1], qp[i+2], qp[i+3], qp[i+4], qp[i+5], qp[i+6], qp[i+7]
+
+		switch a.Subtype {
+
+		case "Highlight":
+			fmt.Fprintf(b, "%s %s m %s %s l %s %s l %s %s l h f\n",
+				fmtNum(x1), fmtNum(y1), fmtNum(x2), fmtNum(y2), fmtNum(x4), fmtNum(y4), fmtNum(x3), fmtNum(y3))
+
+		case "Underline":
+			lw := (y1 - y3) / 14
+			if lw < 1 {
+				lw = 1
+			}
+			fmt.Fprintf(b, "%s w %s %s m %s %s l S\n",
+				fmtNum(lw), fmtNum(x3), fmtNum(y3+lw), fmtNum(x4), fmtNum(y4+lw))
+
+		case "StrikeOut":
+			lw := (y1 - y3) / 14
+			if lw < 1 {
+				lw = 1
+			}
+			fmt.Fprintf(b, "%s w %s %s m %s %s l S\n",
+				fmtNum(lw), fmtNum((x1+x3)/2), fmtNum((y1+y3)/2), fmtNum((x2+x4)/2), fmtNum((y2+y4)/2))
+		}
+	}
+}
+
+// paintOp returns the path painting operator for a shape with optional interior color and border.
+func paintOp(a Annotation, bw float64) string {
+
+	fill := len(a.InteriorCol) > 0
+
+	switch {
+	case fill && bw > 0:
+		return "B\n"
+	case fill:
+		return "f\n"
+	}
+
+	return "S\n"
+}
+
+func square(b *bytes.Buffer, a Annotation) {
+
+	r := a.Rect
+	bw := borderWidth(a)
+
+	b.WriteString(ColorOp(a.InteriorCol, false))
+	b.WriteString(ColorOp(a.Color, true))
+	fmt.Fprintf(b, "%s w\n", fmtNum(bw))
+	rectOp(b, r[0]+bw/2, r[1]+bw/2, r[2]-r[0]-bw, r[3]-r[1]-bw)
+	b.WriteString(paintOp(a, bw))
+}
+
+func circle(b *bytes.Buffer, a Annotation) {
+
+	r := a.Rect
+	bw := borderWidth(a)
+
+	cx, cy := (r[0]+r[2])/2, (r[1]+r[3])/2
+	rx, ry := (r[2]-r[0]-bw)/2, (r[3]-r[1]-bw)/2
+	kx, ky := rx*kappa, ry*kappa
+
+	b.WriteString(ColorOp(a.InteriorCol, false))
+	b.WriteString(ColorOp(a.Color, true))
+	fmt.Fprintf(b, "%s w\n", fmtNum(bw))
+
+	fmt.Fprintf(b, "%s %s m\n", fmtNum(cx+rx), fmtNum(cy))
+	curve := func(x1, y1, x2, y2, x3, y3 float64) {
+		fmt.Fprintf(b, "%s %s %s %s %s %s c\n", fmtNum(x1), fmtNum(y1), fmtNum(x2), fmtNum(y2), fmtNum(x3), fmtNum(y3))
+	}
+	curve(cx+rx, cy+ky, cx+kx, cy+ry, cx, cy+ry)
+	curve(cx-kx, cy+ry, cx-rx, cy+ky, cx-rx, cy)
+	curve(cx-rx, cy-ky, cx-kx, cy-ry, cx, cy-ry)
+	curve(cx+kx, cy-ry, cx+rx, cy-ky, cx+rx, cy)
+	b.WriteString("h\n")
+	b.WriteString(paintOp(a, bw))
+}
+
+func linkBorder(b *bytes.Buffer, a Annotation) {
+
+	if a.BorderWidth <= 0 {
+		return
+	}
+
+	r := a.Rect
+	bw := a.BorderWidth
+
+	c := a.Color
+	if len(c) == 0 {
+		c = []float64{0}
+	}
+
+	b.WriteString(ColorOp(c, true))
+	fmt.Fprintf(b, "%s w\n", fmtNum(bw))
+	rectOp(b, r[0]+bw/2, r[1]+bw/2, r[2]-r[0]-bw, r[3]-r[1]-bw)
+	b.WriteString("S\n")
+}
+
+// WrapText breaks s into lines fitting into width measured by textWidth.
+func WrapText(s string, width float64, textWidth func(string) float64) []string {
+
+	var lines []string
+
+	for _, para := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n") {
+
+		line := ""
+
+		for _, word := range strings.Fields(para) {
+
+			if line == "" {
+				line = word
+				continue
+			}
+
+			if textWidth(line+" "+word) > width {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+
+			line += " " + word
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// textLines writes a text object rendering lines into the box x, y, w, h using the quadding q (0=left, 1=centered, 2=right).
+func textLines(b *bytes.Buffer, lines []string, da DA, x, y, w, h float64, q int) {
+
+	baseFont := BaseFont(da.FontName)
+	fs := da.FontSize
+
+	b.WriteString("BT\n")
+	fmt.Fprintf(b, "/%s %s Tf\n", da.FontName, fmtNum(fs))
+	b.WriteString(ColorOp(da.Color, false))
+
+	ty := y + h - fs
+
+	for _, line := range lines {
+
+		s := font.EncodeWinAnsi(line)
+
+		tx := x
+		switch q {
+		case 1:
+			tx = x + (w-font.TextWidth(s, baseFont, fs))/2
+		case 2:
+			tx = x + w - font.TextWidth(s, baseFont, fs)
+		}
+
+		esc, _ := types.Escape(s)
+		fmt.Fprintf(b, "1 0 0 1 %s %s Tm (%s) Tj\n", fmtNum(tx), fmtNum(ty), *esc)
+
+		ty -= fs * 1.2
+	}
+
+	b.WriteString("ET\n")
+}
+
+func freeText(ctx *types.PDFContext, b *bytes.Buffer, a Annotation) (*types.PDFDict, error) {
+
+	da, err := ParseDA(defaultAppearance(a))
+	if err != nil {
+		return nil, err
+	}
+
+	if da.FontName == "" {
+		da.FontName = "Helv"
+	}
+
+	if da.FontSize == 0 {
+		da.FontSize = fontSize(a)
+	}
+
+	r := a.Rect
+	w, h := r[2]-r[0], r[3]-r[1]
+
+	if len(a.Color) > 0 {
+		b.WriteString(ColorOp(a.Color, false))
+		rectOp(b, r[0], r[1], w, h)
+		b.WriteString("f\n")
+	}
+
+	if a.BorderWidth > 0 {
+		bw := a.BorderWidth
+		fmt.Fprintf(b, "0 G %s w\n", fmtNum(bw))
+		rectOp(b, r[0]+bw/2, r[1]+bw/2, w-bw, h-bw)
+		b.WriteString("S\n")
+	}
+
+	pad := 2 + a.BorderWidth
+
+	rectOp(b, r[0]+pad, r[1]+pad, w-2*pad, h-2*pad)
+	b.WriteString("W n\n")
+
+	textWidth := func(s string) float64 {
+		return font.TextWidth(font.EncodeWinAnsi(s), BaseFont(da.FontName), da.FontSize)
+	}
+
+	lines := WrapText(a.Contents, w-2*pad, textWidth)
+
+	textLines(b, lines, da, r[0]+pad, r[1]+pad, w-2*pad, h-2*pad, a.Quadding)
+
+	return FontResources(ctx, da.FontName)
+}
+
+func extGStateMultiply() *types.PDFDict {
+
+	gs := types.NewPDFDict()
+	gs.Insert("Type", types.PDFName("ExtGState"))
+	gs.Insert("BM", types.PDFName("Multiply"))
+
+	extGState := types.NewPDFDict()
+	extGState.Insert("GS0", gs)
+
+	d := types.NewPDFDict()
+	d.Insert("ExtGState", extGState)
+
+	return &d
+}
+
+// appearanceStream generates the normal appearance of a.
+// The bounding box equals the annotation rectangle so page coordinates may be used.
+func appearanceStream(ctx *types.PDFContext, a Annotation) (*types.PDFIndirectRef, error) {
+
+	var (
+		b         bytes.Buffer
+		resources *types.PDFDict
+		err       error
+	)
+
+	b.WriteString("q\n")
+
+	switch a.Subtype {
+
+	case "Text":
+		textIcon(&b, a)
+
+	case "Link":
+		linkBorder(&b, a)
+
+	case "Highlight", "Underline", "StrikeOut":
+		textMarkup(&b, a)
+		if a.Subtype == "Highlight" {
+			resources = extGStateMultiply()
+		}
+
+	case "Square":
+		square(&b, a)
+
+	case "Circle":
+		circle(&b, a)
+
+	case "FreeText":
+		resources, err = freeText(ctx, &b, a)
+		if err != nil {
+			return nil, err
+		}
+
+	case "FileAttachment":
+		fileAttachmentIcon(&b, a)
+	}
+
+	b.WriteString("Q\n")
+
+	return FormXObject(ctx, a.Rect, b.Bytes(), resources)
+}
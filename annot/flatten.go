@@ -0,0 +1,390 @@
+package annot
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// Annotation flags relevant for flattening.
+const (
+	flagHidden = 1 << 1
+	flagNoView = 1 << 5
+)
+
+// flattenable returns true for annotations whose appearance belongs to the page content.
+// Links stay interactive and are therefore kept.
+func flattenable(ae annotEntry) bool {
+	return ae.subtype() != "Link"
+}
+
+func visible(d *types.PDFDict) bool {
+
+	f := d.IntEntry("F")
+	if f == nil {
+		return true
+	}
+
+	return *f&(flagHidden|flagNoView) == 0
+}
+
+// normalAppearance returns the normal appearance stream of an annotation
+// taking into account the appearance state AS of appearance subdicts.
+func normalAppearance(ctx *types.PDFContext, d *types.PDFDict) (*types.PDFIndirectRef, *types.PDFStreamDict, error) {
+
+	ap, err := ctx.DereferenceDict(d.Dict["AP"])
+	if err != nil || ap == nil {
+		return nil, nil, err
+	}
+
+	obj, found := ap.Find("N")
+	if !found {
+		return nil, nil, nil
+	}
+
+	o, err := ctx.Dereference(obj)
+	if err != nil || o == nil {
+		return nil, nil, err
+	}
+
+	if sub, ok := o.(types.PDFDict); ok {
+
+		// An appearance subdict mapping appearance states to appearance streams.
+		as := d.NameEntry("AS")
+		if as == nil {
+			return nil, nil, nil
+		}
+
+		obj, found = sub.Find(*as)
+		if !found {
+			return nil, nil, nil
+		}
+	}
+
+	indRef, ok := obj.(types.PDFIndirectRef)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	sd, err := ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return nil, nil, err
+	}
+
+	return &indRef, sd, nil
+}
+
+// transform returns the matrix mapping the appearance bounding box transformed by matrix onto rect.
+// See 12.5.5 Appearance Streams, Algorithm: Appearance streams.
+func transform(rect, bbox, matrix []float64) (m []float64, ok bool) {
+
+	if len(matrix) != 6 {
+		matrix = []float64{1, 0, 0, 1, 0, 0}
+	}
+
+	a, b, c, d, e, f := matrix[0], matrix[1], matrix[2], matrix[3], matrix[4], matrix[5]
+
+	var xx, yy []float64
+	for _, p := range [][2]float64{{bbox[0], bbox[1]}, {bbox[2], bbox[1]}, {bbox[2], bbox[3]}, {bbox[0], bbox[3]}} {
+		xx = append(xx, a*p[0]+c*p[1]+e)
+		yy = append(yy, b*p[0]+d*p[1]+f)
+	}
+
+	minX, maxX := xx[0], xx[0]
+	minY, maxY := yy[0], yy[0]
+	for i := 1; i < 4; i++ {
+		if xx[i] < minX {
+			minX = xx[i]
+		}
+		if xx[i] > maxX {
+			maxX = xx[i]
+		}
+		if yy[i] < minY {
+			minY = yy[i]
+		}
+		if yy[i] > maxY {
+			maxY = yy[i]
+		}
+	}
+
+	if maxX == minX || maxY == minY {
+		return nil, false
+	}
+
+	r := normalizedRect(rect)
+
+	sx := (r[2] - r[0]) / (maxX - minX)
+	sy := (r[3] - r[1]) / (maxY - minY)
+
+	return []float64{sx, 0, 0, sy, r[0] - minX*sx, r[1] - minY*sy}, true
+}
+
+// pageXObjectDict returns the XObject resources of a page.
+// Inherited resources are copied into the page dict first.
+func pageXObjectDict(ctx *types.PDFContext, pageDict *types.PDFDict) (*types.PDFDict, error) {
+
+	if _, found := pageDict.Find("Resources"); !found {
+
+		d := types.NewPDFDict()
+
+		obj, err := ctx.InheritedPageAttr(pageDict, "Resources")
+		if err != nil {
+			return nil, err
+		}
+
+		inherited, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if inherited != nil {
+			for k, v := range inherited.Dict {
+				d.Insert(k, v)
+			}
+		}
+
+		pageDict.Insert("Resources", d)
+	}
+
+	resDict, err := ctx.DereferenceDict(pageDict.Dict["Resources"])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := resDict.Find("XObject"); !found {
+		resDict.Insert("XObject", types.NewPDFDict())
+	}
+
+	return ctx.DereferenceDict(resDict.Dict["XObject"])
+}
+
+func contentStream(ctx *types.PDFContext, content []byte) (indRef types.PDFIndirectRef, err error) {
+
+	sd, err := ctx.InsertPDFStreamDict(content)
+	if err != nil {
+		return
+	}
+
+	err = filter.EncodeStream(sd)
+	if err != nil {
+		return
+	}
+
+	objNr, err := ctx.InsertObject(*sd)
+	if err != nil {
+		return
+	}
+
+	return types.NewPDFIndirectRef(objNr, 0), nil
+}
+
+// appendPageContent wraps the existing page content into q/Q and appends content.
+func appendPageContent(ctx *types.PDFContext, pageDict *types.PDFDict, content []byte) (err error) {
+
+	pre, err := contentStream(ctx, []byte("q\n"))
+	if err != nil {
+		return
+	}
+
+	post, err := contentStream(ctx, append([]byte("Q\n"), content...))
+	if err != nil {
+		return
+	}
+
+	arr := types.PDFArray{pre}
+
+	if obj, found := pageDict.Find("Contents"); found && obj != nil {
+
+		o, err := ctx.Dereference(obj)
+		if err != nil {
+			return err
+		}
+
+		if a, ok := o.(types.PDFArray); ok {
+			arr = append(arr, a...)
+		} else {
+			arr = append(arr, obj)
+		}
+	}
+
+	arr = append(arr, post)
+
+	pageDict.Update("Contents", arr)
+
+	return
+}
+
+// flattenPage draws the normal appearances of selected annotations into the page content.
+func flattenPage(ctx *types.PDFContext, pageDict *types.PDFDict, entries []annotEntry) (err error) {
+
+	var (
+		b        bytes.Buffer
+		xObjDict *types.PDFDict
+	)
+
+	for _, ae := range entries {
+
+		// Popups are only shown on demand.
+		if ae.subtype() == "Popup" || !visible(ae.dict) {
+			continue
+		}
+
+		indRef, sd, err := normalAppearance(ctx, ae.dict)
+		if err != nil {
+			return err
+		}
+
+		if sd == nil {
+			continue
+		}
+
+		rect := numberArrayEntry(ctx, ae.dict, "Rect")
+		bbox := numberArrayEntry(ctx, &sd.PDFDict, "BBox")
+		if len(rect) != 4 || len(bbox) != 4 {
+			continue
+		}
+
+		m, ok := transform(rect, bbox, numberArrayEntry(ctx, &sd.PDFDict, "Matrix"))
+		if !ok {
+			continue
+		}
+
+		// Appearance streams are form XObjects but some writers omit Subtype.
+		sd.Insert("Type", types.PDFName("XObject"))
+		sd.Insert("Subtype", types.PDFName("Form"))
+
+		if xObjDict == nil {
+			xObjDict, err = pageXObjectDict(ctx, pageDict)
+			if err != nil {
+				return err
+			}
+		}
+
+		name := fmt.Sprintf("Fm%d", indRef.ObjectNumber.Value())
+		xObjDict.Update(name, *indRef)
+
+		fmt.Fprintf(&b, "q %s %s %s %s %s %s cm /%s Do Q\n",
+			fmtNum(m[0]), fmtNum(m[1]), fmtNum(m[2]), fmtNum(m[3]), fmtNum(m[4]), fmtNum(m[5]), name)
+
+		logDebugAnnot.Printf("flattenPage: page %d: %s obj#%d\n", ae.pageNr, ae.subtype(), ae.objNr)
+	}
+
+	if b.Len() == 0 {
+		return
+	}
+
+	return appendPageContent(ctx, pageDict, b.Bytes())
+}
+
+// removeEmptyAcroForm deletes the AcroForm dict once there are no fields left.
+func removeEmptyAcroForm(ctx *types.PDFContext) (err error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	d, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || d == nil {
+		return
+	}
+
+	fields, err := ctx.DereferenceArray(d.Dict["Fields"])
+	if err != nil {
+		return
+	}
+
+	if fields == nil || len(*fields) == 0 {
+		rootDict.Delete("AcroForm")
+	}
+
+	return
+}
+
+// Flatten draws the normal appearance of the annotations of selected pages into the page content.
+// Flattened annotations, their popups and corresponding form fields are removed.
+// Link annotations are kept.
+// ok returns true if at least one annotation was flattened.
+func Flatten(ctx *types.PDFContext, selectedPages types.IntSet) (ok bool, err error) {
+
+	logDebugAnnot.Println("Flatten begin")
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	all, err := annotEntries(ctx, pages, nil)
+	if err != nil {
+		return
+	}
+
+	selected := func(ae annotEntry) bool {
+		return needsPage(selectedPages, ae.pageNr) && flattenable(ae)
+	}
+
+	removed, count := markForRemoval(all, selected)
+	if count == 0 {
+		return false, nil
+	}
+
+	for i, indRef := range pages {
+
+		pageNr := i + 1
+
+		if !needsPage(selectedPages, pageNr) {
+			continue
+		}
+
+		var entries []annotEntry
+		for _, ae := range all {
+			if ae.pageNr == pageNr && flattenable(ae) {
+				entries = append(entries, ae)
+			}
+		}
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return false, err
+		}
+
+		err = flattenPage(ctx, pageDict, entries)
+		if err != nil {
+			return false, err
+		}
+
+		err = removeAnnotsFromPage(ctx, pageDict, removed, func(d *types.PDFDict) bool {
+			return flattenable(annotEntry{pageNr: pageNr, dict: d})
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = removeFormFields(ctx, removed)
+	if err != nil {
+		return
+	}
+
+	err = removeEmptyAcroForm(ctx)
+	if err != nil {
+		return
+	}
+
+	for objNr := range removed {
+		err = ctx.DeleteObject(objNr)
+		if err != nil {
+			return
+		}
+	}
+
+	logDebugAnnot.Println("Flatten end")
+
+	return true, nil
+}
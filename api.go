@@ -11,11 +11,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hhrutter/pdfcpu/annot"
 	"github.com/hhrutter/pdfcpu/attach"
 	"github.com/hhrutter/pdfcpu/extract"
+	"github.com/hhrutter/pdfcpu/fdf"
+	"github.com/hhrutter/pdfcpu/form"
+	"github.com/hhrutter/pdfcpu/info"
+	"github.com/hhrutter/pdfcpu/layer"
 	"github.com/hhrutter/pdfcpu/merge"
+	"github.com/hhrutter/pdfcpu/object"
 	"github.com/hhrutter/pdfcpu/optimize"
 	"github.com/hhrutter/pdfcpu/read"
+	"github.com/hhrutter/pdfcpu/split"
 	"github.com/hhrutter/pdfcpu/types"
 	"github.com/hhrutter/pdfcpu/validate"
 	"github.com/hhrutter/pdfcpu/write"
@@ -24,9 +31,10 @@ import (
 )
 
 var (
-	logInfoAPI  *log.Logger
-	logStatsAPI *log.Logger
-	logErrorAPI *log.Logger
+	logInfoAPI    *log.Logger
+	logStatsAPI   *log.Logger
+	logWarningAPI *log.Logger
+	logErrorAPI   *log.Logger
 
 	selectedPagesRegExp *regexp.Regexp
 )
@@ -60,6 +68,7 @@ func setupRegExpForPageSelection() *regexp.Regexp {
 func init() {
 	logInfoAPI = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 	logStatsAPI = log.New(ioutil.Discard, "STATS: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logWarningAPI = log.New(os.Stdout, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
 	logErrorAPI = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	selectedPagesRegExp = setupRegExpForPageSelection()
@@ -234,7 +243,8 @@ func readValidateAndOptimize(fileIn string, config *types.Configuration, from1 t
 }
 
 // Optimize reads in fileIn, does validation, optimization and writes the result to fileOut.
-func Optimize(fileIn, fileOut string, config *types.Configuration) (err error) {
+// The returned report is also written as JSON to config.ReportFileName if set.
+func Optimize(fileIn, fileOut string, config *types.Configuration) (report *types.OptimizeReport, err error) {
 
 	fromStart := time.Now()
 
@@ -259,6 +269,16 @@ func Optimize(fileIn, fileOut string, config *types.Configuration) (err error) {
 	durWrite := time.Since(fromWrite).Seconds()
 	durTotal := time.Since(fromStart).Seconds()
 
+	report = ctx.Optimize.Report
+	report.SetDestination(ctx)
+
+	if config.ReportFileName != "" {
+		err = writeOptimizeReport(report, config.ReportFileName)
+		if err != nil {
+			return
+		}
+	}
+
 	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
 	logStatsAPI.Println("Timing:")
 	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
@@ -272,6 +292,21 @@ func Optimize(fileIn, fileOut string, config *types.Configuration) (err error) {
 	return
 }
 
+func writeOptimizeReport(report *types.OptimizeReport, fileName string) error {
+
+	bb, err := report.JSON()
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(fileName, bb, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "can't write report %s", fileName)
+	}
+
+	return nil
+}
+
 // ParsePageSelection ensures a correct page selection expression.
 func ParsePageSelection(s string) (ps []string, err error) {
 
@@ -506,13 +541,295 @@ func Split(fileIn, dirOut string, config *types.Configuration) (err error) {
 	return
 }
 
+// spanFileName generates a filename for a span of pages.
+// Spans starting with a bookmark are named after its title.
+func spanFileName(fileIn string, span split.Span, used types.StringSet) string {
+
+	fileName := strings.TrimSuffix(filepath.Base(fileIn), ".pdf")
+
+	if span.From == span.Thru {
+		fileName += "_" + strconv.Itoa(span.From)
+	} else {
+		fileName += "_" + strconv.Itoa(span.From) + "-" + strconv.Itoa(span.Thru)
+	}
+
+	title := strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(span.Title))
+
+	if title != "" {
+		fileName = title
+	}
+
+	// Ensure unique filenames for bookmarks with the same title.
+	name := fileName
+	for i := 2; used[name]; i++ {
+		name = fileName + "_" + strconv.Itoa(i)
+	}
+	used[name] = true
+
+	return name + ".pdf"
+}
+
+// writeSpan writes the pages of span to dirOut/fileName keeping only the resources used.
+// ctx remains unchanged.
+func writeSpan(ctx *types.PDFContext, dirOut, fileName string, span split.Span) (size int64, err error) {
+
+	ctx = ctx.Copy()
+
+	err = split.Reduce(ctx, span)
+	if err != nil {
+		return
+	}
+
+	// ctx has been reduced already, the Split write command would also drop annotations and layers.
+	w := ctx.Write
+	w.DirName = dirOut + "/"
+	w.FileName = fileName
+
+	err = write.PDFFile(ctx)
+	if err != nil {
+		return
+	}
+
+	fi, err := os.Stat(w.DirName + w.FileName)
+	if err != nil {
+		return
+	}
+
+	return fi.Size(), nil
+}
+
+// splitSpans splits fileIn into the files for the spans returned by plan.
+func splitSpans(fileIn, dirOut string, config *types.Configuration, plan func(ctx *types.PDFContext) ([]split.Span, error)) (err error) {
+
+	fromStart := time.Now()
+
+	fmt.Printf("splitting %s into %s ...\n", fileIn, dirOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	spans, err := plan(ctx)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	used := types.StringSet{}
+
+	for _, span := range spans {
+		fileName := spanFileName(fileIn, span, used)
+		fmt.Printf("writing %s ...\n", dirOut+"/"+fileName)
+		_, err = writeSpan(ctx, dirOut, fileName, span)
+		if err != nil {
+			return
+		}
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("split                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// SplitSpan splits fileIn into files of span pages each in dirOut.
+// Each file keeps only the resources used by its pages.
+func SplitSpan(fileIn, dirOut string, span int, config *types.Configuration) (err error) {
+
+	return splitSpans(fileIn, dirOut, config, func(ctx *types.PDFContext) ([]split.Span, error) {
+		return split.EveryN(ctx.PageCount, span)
+	})
+}
+
+// SplitAtPages splits fileIn into files in dirOut starting a new file at each of pageNrs.
+// Each file keeps only the resources used by its pages.
+func SplitAtPages(fileIn, dirOut string, pageNrs []int, config *types.Configuration) (err error) {
+
+	return splitSpans(fileIn, dirOut, config, func(ctx *types.PDFContext) ([]split.Span, error) {
+		return split.AtPages(ctx.PageCount, pageNrs)
+	})
+}
+
+// SplitByBookmarks splits fileIn into files in dirOut starting a new file at each outline item up to level.
+// Files are named after the bookmark title, each file keeps only the resources used by its pages.
+func SplitByBookmarks(fileIn, dirOut string, level int, config *types.Configuration) (err error) {
+
+	return splitSpans(fileIn, dirOut, config, func(ctx *types.PDFContext) ([]split.Span, error) {
+		return split.ByBookmarks(ctx, level)
+	})
+}
+
+// SplitBySize splits fileIn into files in dirOut not exceeding maxSize bytes.
+// A single page exceeding maxSize is written to a file of its own.
+// Each file keeps only the resources used by its pages.
+func SplitBySize(fileIn, dirOut string, maxSize int64, config *types.Configuration) (err error) {
+
+	if maxSize <= 0 {
+		return errors.Errorf("invalid maximum file size: %d", maxSize)
+	}
+
+	return splitSpans(fileIn, dirOut, config, func(ctx *types.PDFContext) ([]split.Span, error) {
+		return spansBySize(ctx, maxSize)
+	})
+}
+
+// spansBySize determines the largest spans not exceeding maxSize by writing trial files.
+func spansBySize(ctx *types.PDFContext, maxSize int64) (spans []split.Span, err error) {
+
+	tmpDir, err := ioutil.TempDir("", "pdfcpu")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fits := func(from, thru int) (bool, error) {
+		size, err := writeSpan(ctx, tmpDir, "span.pdf", split.Span{From: from, Thru: thru})
+		logStatsAPI.Printf("spansBySize: pages %d-%d: %d bytes\n", from, thru, size)
+		return size <= maxSize, err
+	}
+
+	pageCount := ctx.PageCount
+
+	for from := 1; from <= pageCount; {
+
+		// Find the first span exceeding maxSize by doubling, then bisect.
+		ok, fail := from, pageCount+1
+		for n := 2; ; n *= 2 {
+			thru := from + n - 1
+			if thru > pageCount {
+				thru = pageCount
+			}
+			if thru == ok {
+				break
+			}
+			fit, err := fits(from, thru)
+			if err != nil {
+				return nil, err
+			}
+			if !fit {
+				fail = thru
+				break
+			}
+			ok = thru
+		}
+
+		for fail-ok > 1 {
+			thru := (ok + fail) / 2
+			fit, err := fits(from, thru)
+			if err != nil {
+				return nil, err
+			}
+			if fit {
+				ok = thru
+			} else {
+				fail = thru
+			}
+		}
+
+		if ok == from {
+			fit, err := fits(from, from)
+			if err != nil {
+				return nil, err
+			}
+			if !fit {
+				logWarningAPI.Printf("page %d exceeds the maximum file size of %d bytes\n", from, maxSize)
+			}
+		}
+
+		spans = append(spans, split.Span{From: from, Thru: ok})
+		from = ok + 1
+	}
+
+	return spans, nil
+}
+
+// mergeInput splits a merge input of the form fileName:pageSelection, eg. "in.pdf:1-3,5".
+// A page selection prefixed with r reverses the order of the selected pages, eg. "in.pdf:r" or "in.pdf:r1-3".
+// An existing file is taken as is even if its name contains a colon.
+func mergeInput(s string) (fileName string, pageSelection []string, reverse bool, err error) {
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, nil, false, nil
+	}
+
+	if _, err = os.Stat(s); err == nil {
+		return s, nil, false, nil
+	}
+
+	sel := s[i+1:]
+	if strings.HasPrefix(sel, "r") {
+		reverse = true
+		sel = sel[1:]
+	}
+
+	pageSelection, err = ParsePageSelection(sel)
+	if err != nil {
+		return "", nil, false, errors.Wrapf(err, "invalid merge input: %s", s)
+	}
+
+	return s[:i], pageSelection, reverse, nil
+}
+
+// readMergeInput reads and validates a merge input reduced to its selected pages.
+// For zip merges the page order gets reversed (again).
+func readMergeInput(s string, zip bool, config *types.Configuration) (ctx *types.PDFContext, err error) {
+
+	fileIn, pageSelection, reverse, err := mergeInput(s)
+	if err != nil {
+		return
+	}
+
+	ctx, _, _, err = readAndValidate(fileIn, config, time.Now())
+	if err != nil {
+		return
+	}
+
+	if zip {
+		reverse = !reverse
+	}
+
+	if pageSelection == nil {
+		if !reverse {
+			return
+		}
+		pageSelection = []string{"1-"}
+	}
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	err = merge.SelectPages(ctx, pages, reverse)
+	if err != nil {
+		err = errors.Wrapf(err, "merge input: %s", s)
+	}
+
+	return
+}
+
 // appendTo appends fileIn to ctxDest's page tree.
 func appendTo(fileIn string, ctxDest *types.PDFContext) (err error) {
 
 	logStatsAPI.Printf("appendTo: appending %s to %s\n", fileIn, ctxDest.Read.FileName)
 
 	// Build a PDFContext for fileIn.
-	ctxSource, _, _, err := readAndValidate(fileIn, ctxDest.Configuration, time.Now())
+	ctxSource, err := readMergeInput(fileIn, ctxDest.MergeMode == types.MergeZip, ctxDest.Configuration)
 	if err != nil {
 		return
 	}
@@ -525,12 +842,15 @@ func appendTo(fileIn string, ctxDest *types.PDFContext) (err error) {
 // Merge some PDF files together and write the result to fileOut.
 // This corresponds to concatenating these files in the order specified by filesIn.
 // The first entry of filesIn serves as the destination xRefTable where all the remaining files gets merged into.
+// Each entry may select pages using the page selection syntax, eg. "in.pdf:1-3,5".
+// For config.MergeMode interleave and zip the pages of the files get interleaved page by page,
+// zip reverses the pages of all files but the first, eg. for merging the front and back sides of a duplex scan.
 func Merge(filesIn []string, fileOut string, config *types.Configuration) (err error) {
 
-	fmt.Printf("merging into %s: %v\n", fileOut, filesIn)
+	fmt.Printf("merging into %s (%s): %v\n", fileOut, config.MergeModeString(), filesIn)
 	//logErrorAPI.Printf("Merge: filesIn: %v\n", filesIn)
 
-	ctxDest, _, _, err := readAndValidate(filesIn[0], config, time.Now())
+	ctxDest, err := readMergeInput(filesIn[0], false, config)
 	if err != nil {
 		return
 	}
@@ -541,12 +861,23 @@ func Merge(filesIn []string, fileOut string, config *types.Configuration) (err e
 		logStatsAPI.Println("Ensure V1.5 for writing object & xref streams")
 	}
 
-	// Repeatedly merge files into fileDest's xref table.
-	for f := range stringSet(filesIn[1:]) {
+	pageCounts := []int{ctxDest.PageCount}
+
+	// Repeatedly merge files into fileDest's xref table in the given order.
+	for _, f := range filesIn[1:] {
+		pageCount := ctxDest.PageCount
 		err = appendTo(f, ctxDest)
 		if err != nil {
 			return
 		}
+		pageCounts = append(pageCounts, ctxDest.PageCount-pageCount)
+	}
+
+	if config.MergeMode != types.MergeAppend {
+		err = merge.InterleavePages(ctxDest, pageCounts)
+		if err != nil {
+			return
+		}
 	}
 
 	err = optimize.XRefTable(ctxDest)
@@ -732,6 +1063,46 @@ func ExtractContent(fileIn, dirOut string, pageSelection []string, config *types
 	return
 }
 
+// ExtractText writes the text of selected pages of fileIn into dirOut, one file per page.
+// jsonOutput selects JSON output including the position, font and font size of each text run.
+func ExtractText(fileIn, dirOut string, pageSelection []string, jsonOutput bool, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	fmt.Printf("extracting text from %s into %s ...\n", fileIn, dirOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	ctx.Write.DirName = dirOut
+	err = extract.Text(ctx, pages, jsonOutput)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("write text           : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
 // Trim generates a trimmed version of fileIn containing all pages selected.
 func Trim(fileIn, fileOut string, pageSelection []string, config *types.Configuration) (err error) {
 
@@ -785,28 +1156,32 @@ func Trim(fileIn, fileOut string, pageSelection []string, config *types.Configur
 func Encrypt(fileIn, fileOut string, config *types.Configuration) (err error) {
 	d := false
 	config.Decrypt = &d
-	return Optimize(fileIn, fileOut, config)
+	_, err = Optimize(fileIn, fileOut, config)
+	return
 }
 
 // Decrypt fileIn and write result to fileOut.
 func Decrypt(fileIn, fileOut string, config *types.Configuration) (err error) {
 	d := true
 	config.Decrypt = &d
-	return Optimize(fileIn, fileOut, config)
+	_, err = Optimize(fileIn, fileOut, config)
+	return
 }
 
 // ChangeUserPassword of fileIn and write result to fileOut.
 func ChangeUserPassword(fileIn, fileOut string, config *types.Configuration, pwOld, pwNew *string) (err error) {
 	config.UserPW = *pwOld
 	config.UserPWNew = pwNew
-	return Optimize(fileIn, fileOut, config)
+	_, err = Optimize(fileIn, fileOut, config)
+	return
 }
 
 // ChangeOwnerPassword of fileIn and write result to fileOut.
 func ChangeOwnerPassword(fileIn, fileOut string, config *types.Configuration, pwOld, pwNew *string) (err error) {
 	config.OwnerPW = *pwOld
 	config.OwnerPWNew = pwNew
-	return Optimize(fileIn, fileOut, config)
+	_, err = Optimize(fileIn, fileOut, config)
+	return
 }
 
 // ListAttachments returns a list of embedded file attachments.
@@ -989,3 +1364,945 @@ func ExtractAttachments(fileIn, dirOut string, files []string, config *types.Con
 
 	return
 }
+
+// ListAnnotations returns a list of annotations for selected pages filtered by subtype.
+func ListAnnotations(fileIn string, pageSelection, subtypes []string, config *types.Configuration) (list []string, err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	list, err = annot.List(ctx, pages, stringSet(subtypes))
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("list annotations     : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// ExportAnnotations writes the annotations of selected pages filtered by subtype as JSON to fileOut.
+func ExportAnnotations(fileIn, fileOut string, pageSelection, subtypes []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	fmt.Printf("exporting annotations from %s into %s ...\n", fileIn, fileOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	err = annot.Export(ctx, fileOut, pages, stringSet(subtypes))
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("export annotations   : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// RemoveAnnotations deletes the annotations of selected pages filtered by subtype and writes the result to fileOut.
+func RemoveAnnotations(fileIn, fileOut string, pageSelection, subtypes []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("removing annotations from %s ...\n", fileIn)
+
+	from := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	var ok bool
+	ok, err = annot.Remove(ctx, pages, stringSet(subtypes))
+	if err != nil {
+		return
+	}
+	if !ok {
+		fmt.Println("no annotation removed.")
+		return
+	}
+
+	durRemove := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("remove annotations   : %6.3fs  %4.1f%%\n", durRemove, durRemove/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// AddAnnotations creates the annotations described in jsonFile and writes the result to fileOut.
+func AddAnnotations(fileIn, jsonFile, fileOut string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	annots, err := annot.ReadFile(jsonFile)
+	if err != nil {
+		return
+	}
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("adding %d annotations to %s ...\n", len(annots), fileIn)
+
+	from := time.Now()
+
+	if ctx.XRefTable.Version() < types.V15 {
+		v, _ := types.Version("1.5")
+		ctx.XRefTable.RootVersion = &v
+		logStatsAPI.Println("Ensure V1.5 for markup annotation entries")
+	}
+
+	err = annot.Add(ctx, annots)
+	if err != nil {
+		return
+	}
+
+	durAdd := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("add annotations      : %6.3fs  %4.1f%%\n", durAdd, durAdd/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// Flatten draws the annotations and form fields of selected pages into the page content and writes the result to fileOut.
+func Flatten(fileIn, fileOut string, pageSelection []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("flattening %s ...\n", fileIn)
+
+	from := time.Now()
+
+	pages, err := pagesForPageSelection(ctx.PageCount, pageSelection)
+	if err != nil {
+		return
+	}
+
+	var ok bool
+	ok, err = annot.Flatten(ctx, pages)
+	if err != nil {
+		return
+	}
+	if !ok {
+		fmt.Println("no annotation flattened.")
+		return
+	}
+
+	durFlatten := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("flatten              : %6.3fs  %4.1f%%\n", durFlatten, durFlatten/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// ListFormFields returns a list of all form fields of fileIn.
+// If jsonFile is not empty the form fields are also written as JSON to jsonFile.
+func ListFormFields(fileIn, jsonFile string, config *types.Configuration) (list []string, err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	list, err = form.List(ctx, jsonFile)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("list form fields     : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// FillForm sets the form field values found in jsonFile and writes the result to fileOut.
+func FillForm(fileIn, jsonFile, fileOut string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	fields, err := form.ReadFile(jsonFile)
+	if err != nil {
+		return
+	}
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("filling %d form fields of %s ...\n", len(fields), fileIn)
+
+	from := time.Now()
+
+	err = form.Fill(ctx, fields)
+	if err != nil {
+		return
+	}
+
+	durFill := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("fill form            : %6.3fs  %4.1f%%\n", durFill, durFill/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// ResetForm restores the default values of selected form fields and writes the result to fileOut.
+// No field names select all fields.
+func ResetForm(fileIn, fileOut string, fieldNames []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("resetting form fields of %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = form.Reset(ctx, fieldNames)
+	if err != nil {
+		return
+	}
+
+	durReset := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("reset form           : %6.3fs  %4.1f%%\n", durReset, durReset/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// LockForm makes selected form fields read only and writes the result to fileOut.
+// No field names select all fields.
+func LockForm(fileIn, fileOut string, fieldNames []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("locking form fields of %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = form.Lock(ctx, fieldNames)
+	if err != nil {
+		return
+	}
+
+	durLock := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("lock form            : %6.3fs  %4.1f%%\n", durLock, durLock/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// RemoveFormFields deletes selected form fields including their widgets and writes the result to fileOut.
+// No field names select all fields.
+func RemoveFormFields(fileIn, fileOut string, fieldNames []string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("removing form fields from %s ...\n", fileIn)
+
+	from := time.Now()
+
+	err = form.Remove(ctx, fieldNames)
+	if err != nil {
+		return
+	}
+
+	durRemove := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("remove form fields   : %6.3fs  %4.1f%%\n", durRemove, durRemove/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// ExportXFA writes the XFA packets of fileIn as XML files into dirOut.
+func ExportXFA(fileIn, dirOut string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	fmt.Printf("exporting XFA from %s into %s ...\n", fileIn, dirOut)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	fileName := strings.TrimSuffix(filepath.Base(fileIn), ".pdf")
+
+	var ok bool
+	ok, err = form.ExportXFA(ctx, dirOut, fileName)
+	if err != nil {
+		return
+	}
+	if !ok {
+		fmt.Println("no XFA form found.")
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("export XFA           : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// StripXFA removes the XFA form of fileIn and writes the result to fileOut.
+func StripXFA(fileIn, fileOut string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("stripping XFA from %s ...\n", fileIn)
+
+	from := time.Now()
+
+	var ok bool
+	ok, err = form.StripXFA(ctx)
+	if err != nil {
+		return
+	}
+	if !ok {
+		fmt.Println("no XFA form found.")
+		return
+	}
+
+	durStrip := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("strip XFA            : %6.3fs  %4.1f%%\n", durStrip, durStrip/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// ExportFormData writes the form field values and markup annotations of fileIn to fdfFile.
+// The format (FDF or XFDF) is chosen by the extension of fdfFile.
+func ExportFormData(fileIn, fdfFile string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	fmt.Printf("exporting form data from %s into %s ...\n", fileIn, fdfFile)
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromWrite := time.Now()
+
+	err = fdf.Export(ctx, fdfFile, fileIn)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("export form data     : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// ImportFormData applies the form field values and markup annotations of fdfFile to fileIn and writes the result to fileOut.
+// The format (FDF or XFDF) is chosen by the extension of fdfFile.
+func ImportFormData(fileIn, fdfFile, fileOut string, config *types.Configuration) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("importing form data from %s into %s ...\n", fdfFile, fileIn)
+
+	from := time.Now()
+
+	if ctx.XRefTable.Version() < types.V15 {
+		v, _ := types.Version("1.5")
+		ctx.XRefTable.RootVersion = &v
+		logStatsAPI.Println("Ensure V1.5 for markup annotation entries")
+	}
+
+	err = fdf.Import(ctx, fdfFile)
+	if err != nil {
+		return
+	}
+
+	durImport := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("import form data     : %6.3fs  %4.1f%%\n", durImport, durImport/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// Info returns the properties of fileIn like page boxes, fonts, images, attachments, form usage and permissions as text or JSON.
+// Pages, fonts, images and attachments are listed in detail only.
+func Info(fileIn string, detail, jsonOutput bool, config *types.Configuration) (list []string, err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, err := readAndValidate(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	from := time.Now()
+
+	err = optimize.FontsAndImages(ctx)
+	if err != nil {
+		return
+	}
+
+	pdfInfo, err := info.PDFInfo(ctx, detail)
+	if err != nil {
+		return
+	}
+
+	if jsonOutput {
+		var bb []byte
+		bb, err = info.JSON(pdfInfo)
+		if err != nil {
+			return
+		}
+		list = []string{string(bb)}
+	} else {
+		list = info.List(pdfInfo)
+	}
+
+	durInfo := time.Since(from).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("info                 : %6.3fs  %4.1f%%\n", durInfo, durInfo/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// Object returns a printable representation of the object identified by path, eg. "5" or "Root/Pages/Kids/0/Resources".
+// If decode is true the decoded content of a stream object is included.
+// The file is read but not validated so misbehaving files may be inspected.
+func Object(fileIn, path string, decode bool, config *types.Configuration) (list []string, err error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return
+	}
+
+	return object.Dump(ctx, path, decode)
+}
+
+// XRef returns a listing of the cross reference table of a PDF file.
+// The file is read but not validated so misbehaving files may be inspected.
+func XRef(fileIn string, config *types.Configuration) (list []string, err error) {
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return
+	}
+
+	return object.XRef(ctx), nil
+}
+
+// editObject reads fileIn, applies a low level edit and writes the result to fileOut.
+// The file is not validated so broken files may be fixed.
+func editObject(fileIn, fileOut string, config *types.Configuration, edit func(ctx *types.PDFContext) error) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		return
+	}
+
+	durRead := time.Since(fromStart).Seconds()
+
+	from := time.Now()
+
+	err = edit(ctx)
+	if err != nil {
+		return
+	}
+
+	durEdit := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("edit                 : %6.3fs  %4.1f%%\n", durEdit, durEdit/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// SetObject sets the object at path to value given in PDF syntax and writes the result to fileOut.
+// eg. path "Root/Pages/Kids/0/MediaBox" and value "[0 0 595 842]"
+func SetObject(fileIn, fileOut, path, value string, config *types.Configuration) (err error) {
+
+	fmt.Printf("setting %s of %s ...\n", path, fileIn)
+
+	return editObject(fileIn, fileOut, config, func(ctx *types.PDFContext) error {
+		return object.Set(ctx, path, value)
+	})
+}
+
+// DeleteObject removes the dict entry or array element at path and writes the result to fileOut.
+// eg. path "Root/OpenAction"
+func DeleteObject(fileIn, fileOut, path string, config *types.Configuration) (err error) {
+
+	fmt.Printf("deleting %s of %s ...\n", path, fileIn)
+
+	return editObject(fileIn, fileOut, config, func(ctx *types.PDFContext) error {
+		return object.Delete(ctx, path)
+	})
+}
+
+// ReplaceStream replaces the content of the stream object at path with the content of contentFile and writes the result to fileOut.
+func ReplaceStream(fileIn, fileOut, path, contentFile string, config *types.Configuration) (err error) {
+
+	content, err := ioutil.ReadFile(contentFile)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("replacing stream %s of %s ...\n", path, fileIn)
+
+	return editObject(fileIn, fileOut, config, func(ctx *types.PDFContext) error {
+		return object.ReplaceStream(ctx, path, content)
+	})
+}
+
+// ListLayers returns a list of the optional content groups of fileIn along with their default state.
+func ListLayers(fileIn string, config *types.Configuration) (list []string, err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	fromList := time.Now()
+
+	list, err = layer.List(ctx)
+	if err != nil {
+		return
+	}
+
+	durList := time.Since(fromList).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("list layers          : %6.3fs  %4.1f%%\n", durList, durList/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+
+	return
+}
+
+// modifyLayers applies f to the layers named by layerNames and writes the result to fileOut.
+func modifyLayers(fileIn, fileOut string, layerNames []string, config *types.Configuration, f func(ctx *types.PDFContext, names []string) error) (err error) {
+
+	fromStart := time.Now()
+
+	ctx, durRead, durVal, durOpt, err := readValidateAndOptimize(fileIn, config, fromStart)
+	if err != nil {
+		return
+	}
+
+	from := time.Now()
+
+	err = f(ctx, layerNames)
+	if err != nil {
+		return
+	}
+
+	durModify := time.Since(from).Seconds()
+
+	fromWrite := time.Now()
+
+	dirName, fileName := filepath.Split(fileOut)
+	ctx.Write.DirName = dirName
+	ctx.Write.FileName = fileName
+
+	err = Write(ctx)
+	if err != nil {
+		return
+	}
+
+	durWrite := time.Since(fromWrite).Seconds()
+	durTotal := time.Since(fromStart).Seconds()
+
+	logStatsAPI.Printf("XRefTable:\n%s\n", ctx)
+	logStatsAPI.Println("Timing:")
+	logStatsAPI.Printf("read                 : %6.3fs  %4.1f%%\n", durRead, durRead/durTotal*100)
+	logStatsAPI.Printf("validate             : %6.3fs  %4.1f%%\n", durVal, durVal/durTotal*100)
+	logStatsAPI.Printf("optimize             : %6.3fs  %4.1f%%\n", durOpt, durOpt/durTotal*100)
+	logStatsAPI.Printf("modify layers        : %6.3fs  %4.1f%%\n", durModify, durModify/durTotal*100)
+	logStatsAPI.Printf("write                : %6.3fs  %4.1f%%\n", durWrite, durWrite/durTotal*100)
+	logStatsAPI.Printf("total processing time: %6.3fs\n\n", durTotal)
+	ctx.Read.LogStats(logStatsAPI, ctx.Optimized)
+	ctx.Write.LogStats(logStatsAPI)
+
+	return
+}
+
+// ShowLayers turns selected layers on in the default configuration and writes the result to fileOut.
+// No layer names select all layers.
+func ShowLayers(fileIn, fileOut string, layerNames []string, config *types.Configuration) (err error) {
+
+	fmt.Printf("showing layers of %s ...\n", fileIn)
+
+	return modifyLayers(fileIn, fileOut, layerNames, config, layer.Show)
+}
+
+// HideLayers turns selected layers off in the default configuration and writes the result to fileOut.
+// No layer names select all layers.
+func HideLayers(fileIn, fileOut string, layerNames []string, config *types.Configuration) (err error) {
+
+	fmt.Printf("hiding layers of %s ...\n", fileIn)
+
+	return modifyLayers(fileIn, fileOut, layerNames, config, layer.Hide)
+}
+
+// LockLayers locks the state of selected layers in the default configuration and writes the result to fileOut.
+// No layer names select all layers.
+func LockLayers(fileIn, fileOut string, layerNames []string, config *types.Configuration) (err error) {
+
+	fmt.Printf("locking layers of %s ...\n", fileIn)
+
+	return modifyLayers(fileIn, fileOut, layerNames, config, layer.Lock)
+}
+
+// RemoveLayers removes selected layers along with their marked page content and writes the result to fileOut.
+// No layer names select all layers.
+func RemoveLayers(fileIn, fileOut string, layerNames []string, config *types.Configuration) (err error) {
+
+	fmt.Printf("removing layers of %s ...\n", fileIn)
+
+	return modifyLayers(fileIn, fileOut, layerNames, config, layer.Remove)
+}
@@ -5,15 +5,25 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/hhrutter/pdfcpu"
+	"github.com/hhrutter/pdfcpu/annot"
 	"github.com/hhrutter/pdfcpu/attach"
+	"github.com/hhrutter/pdfcpu/content"
 	"github.com/hhrutter/pdfcpu/crypto"
 	"github.com/hhrutter/pdfcpu/extract"
+	"github.com/hhrutter/pdfcpu/fdf"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/form"
+	"github.com/hhrutter/pdfcpu/info"
+	"github.com/hhrutter/pdfcpu/layer"
 	"github.com/hhrutter/pdfcpu/merge"
+	"github.com/hhrutter/pdfcpu/object"
 	"github.com/hhrutter/pdfcpu/optimize"
 	"github.com/hhrutter/pdfcpu/read"
+	"github.com/hhrutter/pdfcpu/split"
 	"github.com/hhrutter/pdfcpu/types"
 	"github.com/hhrutter/pdfcpu/validate"
 	"github.com/hhrutter/pdfcpu/write"
@@ -21,9 +31,15 @@ import (
 
 var (
 	fileStats, mode, pageSelection string
+	fileReport                     string
+	subtypes, fieldNames           string
+	layerNames                     string
 	in, out                        string
 	upw, opw                       string
-	verbose                        bool
+	verbose, jsonOutput            bool
+	subset, prune, recompress      bool
+	linearize, detail, stream      bool
+	dpi, quality                   int
 	logInfo                        *log.Logger
 
 	needStackTrace = true
@@ -33,13 +49,33 @@ func init() {
 
 	flag.StringVar(&fileStats, "stats", "", "optimize: a csv file for stats appending")
 	flag.StringVar(&fileStats, "s", "", "optimize: a csv file for stats appending")
+	flag.StringVar(&fileReport, "report", "", "optimize: a json file for the optimize report")
 
-	flag.StringVar(&mode, "mode", "", "validate: strict|relaxed; extract: image|font|content|page")
-	flag.StringVar(&mode, "m", "", "validate: strict|relaxed; extract: image|font|content|page")
+	flag.IntVar(&dpi, "dpi", 0, "optimize: downsample images exceeding this resolution")
+	flag.IntVar(&quality, "quality", 0, "optimize: JPEG quality of downsampled images (1-100)")
+	flag.BoolVar(&subset, "subset", false, "optimize: reduce embedded fonts to the glyphs used")
+	flag.BoolVar(&prune, "prune", false, "optimize: remove unused resources and objects")
+	flag.BoolVar(&recompress, "recompress", false, "optimize: recompress streams and minify content streams")
+	flag.BoolVar(&linearize, "linearize", false, "optimize: write a linearized file (Fast Web View)")
+
+	flag.StringVar(&mode, "mode", "", "validate: strict|relaxed; split: span|page|bookmark|size; merge: append|interleave|zip; extract: image|font|content|text|page")
+	flag.StringVar(&mode, "m", "", "validate: strict|relaxed; split: span|page|bookmark|size; merge: append|interleave|zip; extract: image|font|content|text|page")
 
 	flag.StringVar(&pageSelection, "pages", "", "a comma separated list of pages or page ranges, see pdfcpu help split/extract")
 	flag.StringVar(&pageSelection, "p", "", "a comma separated list of pages or page ranges, see pdfcpu help split/extract")
 
+	flag.StringVar(&subtypes, "subtype", "", "annotations: a comma separated list of annotation subtypes")
+
+	flag.StringVar(&fieldNames, "fields", "", "form: a comma separated list of fully qualified field names")
+
+	flag.StringVar(&layerNames, "layers", "", "layers: a comma separated list of layer names")
+
+	flag.BoolVar(&jsonOutput, "json", false, "extract text: write JSON including text positions, fonts and font sizes; info: print JSON")
+
+	flag.BoolVar(&detail, "detail", false, "info: list pages, fonts, images and attachments")
+
+	flag.BoolVar(&stream, "stream", false, "obj: print the decoded stream content")
+
 	flag.BoolVar(&verbose, "verbose", false, "")
 	flag.BoolVar(&verbose, "v", false, "")
 
@@ -84,7 +120,7 @@ func helpString(topic string) string {
 		return fmt.Sprintf("%s\n\n%s\n", usageSplit, usageLongSplit)
 
 	case "merge":
-		return fmt.Sprintf("%s\n\n%s\n", usageMerge, usageLongMerge)
+		return fmt.Sprintf("%s\n\n%s\n\n%s\n", usageMerge, usageLongMerge, usagePageSelection)
 
 	case "extract":
 		return fmt.Sprintf("%s\n\n%s\n\n%s\n", usageExtract, usageLongExtract, usagePageSelection)
@@ -95,6 +131,27 @@ func helpString(topic string) string {
 	case "attach":
 		return fmt.Sprintf("%s\n\n%s\n", usageAttach, usageLongAttach)
 
+	case "annotations":
+		return fmt.Sprintf("%s\n\n%s\n\n%s\n", usageAnnotations, usageLongAnnotations, usagePageSelection)
+
+	case "flatten":
+		return fmt.Sprintf("%s\n\n%s\n\n%s\n", usageFlatten, usageLongFlatten, usagePageSelection)
+
+	case "form":
+		return fmt.Sprintf("%s\n\n%s\n", usageForm, usageLongForm)
+
+	case "layers":
+		return fmt.Sprintf("%s\n\n%s\n", usageLayers, usageLongLayers)
+
+	case "info", "inspect":
+		return fmt.Sprintf("%s\n\n%s\n", usageInfo, usageLongInfo)
+
+	case "obj":
+		return fmt.Sprintf("%s\n\n%s\n", usageObj, usageLongObj)
+
+	case "xref":
+		return fmt.Sprintf("%s\n\n%s\n", usageXRef, usageLongXRef)
+
 	case "encrypt":
 		return fmt.Sprintf("%s\n\n%s\n", usageEncrypt, usageLongEncrypt)
 
@@ -145,6 +202,15 @@ func setupLogging(verbose bool) {
 	extract.Verbose(verbose)
 	merge.Verbose(verbose)
 	attach.Verbose(verbose)
+	annot.Verbose(verbose)
+	content.Verbose(verbose)
+	font.Verbose(verbose)
+	form.Verbose(verbose)
+	info.Verbose(verbose)
+	layer.Verbose(verbose)
+	object.Verbose(verbose)
+	split.Verbose(verbose)
+	fdf.Verbose(verbose)
 	pdfcpu.Verbose(verbose)
 
 	needStackTrace = verbose
@@ -161,15 +227,35 @@ func parseFlagsAndGetCommand() (command string) {
 	command = os.Args[1]
 
 	i := 2
-	// The attach command uses a subcommand and is therefore a special case => start flag processing after 3rd argument.
-	if command == "attach" {
+	// The attach, annotations, form and layers commands use a subcommand and are therefore a special case => start flag processing after 3rd argument.
+	subCmdUsage := map[string]string{
+		"attach":      usageAttach,
+		"annotations": usageAnnotations,
+		"form":        usageForm,
+		"layers":      usageLayers,
+	}
+	if u, ok := subCmdUsage[command]; ok {
 		if len(os.Args) == 2 {
-			fmt.Fprintln(os.Stderr, usageAttach)
+			fmt.Fprintln(os.Stderr, u)
 			os.Exit(1)
 		}
 		i = 3
 	}
 
+	// form xfa uses yet another level of subcommands.
+	if command == "form" && os.Args[2] == "xfa" {
+		if len(os.Args) == 3 {
+			fmt.Fprintln(os.Stderr, usageFormXFA)
+			os.Exit(1)
+		}
+		i = 4
+	}
+
+	// obj uses optional subcommands for editing.
+	if command == "obj" && len(os.Args) > 2 && objEditCmds[os.Args[2]] {
+		i = 3
+	}
+
 	// Parse commandline flags.
 	flag.CommandLine.Parse(os.Args[i:])
 
@@ -203,7 +289,8 @@ func prepareValidateCommand(config *types.Configuration) pdfcpu.Command {
 
 func prepareOptimizeCommand(config *types.Configuration) pdfcpu.Command {
 
-	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" ||
+		dpi < 0 || quality < 0 || quality > 100 || (quality > 0 && dpi == 0) {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageOptimize)
 		os.Exit(1)
 	}
@@ -217,17 +304,53 @@ func prepareOptimizeCommand(config *types.Configuration) pdfcpu.Command {
 		ensurePdfExtension(filenameOut)
 	}
 
+	config.ImageDPI = dpi
+	config.ImageQuality = quality
+	config.SubsetFonts = subset
+	config.Prune = prune
+	config.Recompress = recompress
+	config.Linearize = linearize
+
 	config.StatsFileName = fileStats
 	if len(fileStats) > 0 {
 		fmt.Printf("stats will be appended to %s\n", fileStats)
 	}
 
+	config.ReportFileName = fileReport
+
 	return pdfcpu.OptimizeCommand(filenameIn, filenameOut, config)
 }
 
+// parseByteSize parses a file size like 500000, 500K, 2M or 1.5MB.
+func parseByteSize(s string) (int64, error) {
+
+	s = strings.TrimSuffix(strings.ToUpper(s), "B")
+
+	unit := types.ByteSize(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = types.KB
+	case strings.HasSuffix(s, "M"):
+		unit = types.MB
+	case strings.HasSuffix(s, "G"):
+		unit = types.GB
+	}
+
+	if unit > 1 {
+		s = s[:len(s)-1]
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return int64(f * float64(unit)), nil
+}
+
 func prepareSplitCommand(config *types.Configuration) pdfcpu.Command {
 
-	if len(flag.Args()) != 2 || pageSelection != "" {
+	if len(flag.Args()) < 2 || pageSelection != "" {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageSplit)
 		os.Exit(1)
 	}
@@ -237,7 +360,57 @@ func prepareSplitCommand(config *types.Configuration) pdfcpu.Command {
 
 	dirnameOut := flag.Arg(1)
 
-	return pdfcpu.SplitCommand(filenameIn, dirnameOut, config)
+	args := flag.Args()[2:]
+
+	// Parse the optional int args of span, page and bookmark mode.
+	var ints []int
+	for _, arg := range args {
+		i, err := strconv.Atoi(arg)
+		if err != nil || i < 1 {
+			ints = nil
+			break
+		}
+		ints = append(ints, i)
+	}
+
+	switch mode {
+
+	case "":
+		if len(args) == 0 {
+			return pdfcpu.SplitCommand(filenameIn, dirnameOut, config)
+		}
+
+	case "span", "s":
+		if len(args) == 1 && len(ints) == 1 {
+			return pdfcpu.SplitSpanCommand(filenameIn, dirnameOut, ints[0], config)
+		}
+
+	case "page", "p":
+		if len(args) > 0 && len(ints) == len(args) {
+			return pdfcpu.SplitAtPagesCommand(filenameIn, dirnameOut, ints, config)
+		}
+
+	case "bookmark", "b":
+		if len(args) == 0 {
+			return pdfcpu.SplitByBookmarksCommand(filenameIn, dirnameOut, 1, config)
+		}
+		if len(args) == 1 && len(ints) == 1 {
+			return pdfcpu.SplitByBookmarksCommand(filenameIn, dirnameOut, ints[0], config)
+		}
+
+	case "size":
+		if len(args) == 1 {
+			maxSize, err := parseByteSize(args[0])
+			if err == nil {
+				return pdfcpu.SplitBySizeCommand(filenameIn, dirnameOut, maxSize, config)
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s\n\n", usageSplit)
+	os.Exit(1)
+
+	return pdfcpu.Command{}
 }
 
 func prepareMergeCommand(config *types.Configuration) pdfcpu.Command {
@@ -247,6 +420,18 @@ func prepareMergeCommand(config *types.Configuration) pdfcpu.Command {
 		os.Exit(1)
 	}
 
+	switch mode {
+	case "", "append", "a":
+		config.MergeMode = types.MergeAppend
+	case "interleave", "i":
+		config.MergeMode = types.MergeInterleave
+	case "zip", "z":
+		config.MergeMode = types.MergeZip
+	default:
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageMerge)
+		os.Exit(1)
+	}
+
 	var filenameOut string
 	filenamesIn := []string{}
 	for i, arg := range flag.Args() {
@@ -255,7 +440,12 @@ func prepareMergeCommand(config *types.Configuration) pdfcpu.Command {
 			ensurePdfExtension(filenameOut)
 			continue
 		}
-		ensurePdfExtension(arg)
+		// An inFile may be followed by a page selection, eg. in.pdf:1-3,5
+		filename := arg
+		if i := strings.LastIndex(arg, ".pdf:"); i > 0 {
+			filename = arg[:i+4]
+		}
+		ensurePdfExtension(filename)
 		filenamesIn = append(filenamesIn, arg)
 	}
 
@@ -265,8 +455,8 @@ func prepareMergeCommand(config *types.Configuration) pdfcpu.Command {
 func prepareExtractCommand(config *types.Configuration) pdfcpu.Command {
 
 	if len(flag.Args()) != 2 || mode == "" ||
-		(mode != "image" && mode != "font" && mode != "page" && mode != "content") &&
-			(mode != "i" && mode != "p" && mode != "c") {
+		(mode != "image" && mode != "font" && mode != "page" && mode != "content" && mode != "text") &&
+			(mode != "i" && mode != "p" && mode != "c" && mode != "t") {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageExtract)
 		os.Exit(1)
 	}
@@ -297,6 +487,9 @@ func prepareExtractCommand(config *types.Configuration) pdfcpu.Command {
 
 	case "content", "c":
 		cmd = pdfcpu.ExtractContentCommand(filenameIn, dirnameOut, pages, config)
+
+	case "text", "t":
+		cmd = pdfcpu.ExtractTextCommand(filenameIn, dirnameOut, pages, jsonOutput, config)
 	}
 
 	return cmd
@@ -444,6 +637,494 @@ func prepareAttachmentCommand(config *types.Configuration) pdfcpu.Command {
 	return cmd
 }
 
+func parseSubtypes() []string {
+
+	if subtypes == "" {
+		return nil
+	}
+
+	return strings.Split(subtypes, ",")
+}
+
+func prepareListAnnotationsCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageAnnotationsList)
+		os.Exit(1)
+	}
+
+	pages, err := pdfcpu.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("annotations: problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.ListAnnotationsCommand(filenameIn, pages, parseSubtypes(), config)
+}
+
+func prepareExportAnnotationsCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageAnnotationsExport)
+		os.Exit(1)
+	}
+
+	pages, err := pdfcpu.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("annotations: problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := flag.Arg(1)
+
+	return pdfcpu.ExportAnnotationsCommand(filenameIn, filenameOut, pages, parseSubtypes(), config)
+}
+
+func prepareRemoveAnnotationsCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageAnnotationsRemove)
+		os.Exit(1)
+	}
+
+	pages, err := pdfcpu.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("annotations: problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.RemoveAnnotationsCommand(filenameIn, filenameOut, pages, parseSubtypes(), config)
+}
+
+func prepareAddAnnotationsCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageAnnotationsAdd)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameJSON := flag.Arg(1)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.AddAnnotationsCommand(filenameIn, filenameJSON, filenameOut, config)
+}
+
+func prepareAnnotationsCommand(config *types.Configuration) pdfcpu.Command {
+
+	var cmd pdfcpu.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListAnnotationsCommand(config)
+
+	case "export":
+		cmd = prepareExportAnnotationsCommand(config)
+
+	case "remove":
+		cmd = prepareRemoveAnnotationsCommand(config)
+
+	case "add":
+		cmd = prepareAddAnnotationsCommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageAnnotations)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareFlattenCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageFlatten)
+		os.Exit(1)
+	}
+
+	pages, err := pdfcpu.ParsePageSelection(pageSelection)
+	if err != nil {
+		log.Fatalf("flatten: problem with flag pageSelection: %v", err)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.FlattenCommand(filenameIn, filenameOut, pages, config)
+}
+
+func prepareListFormFieldsCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := ""
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+	}
+
+	return pdfcpu.ListFormFieldsCommand(filenameIn, filenameOut, config)
+}
+
+func prepareFillFormCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormFill)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameJSON := flag.Arg(1)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.FillFormCommand(filenameIn, filenameJSON, filenameOut, config)
+}
+
+func parseFieldNames() []string {
+
+	if fieldNames == "" {
+		return nil
+	}
+
+	return strings.Split(fieldNames, ",")
+}
+
+func prepareModifyFormCommand(config *types.Configuration, usage string, newCmd func(string, string, []string, *types.Configuration) pdfcpu.Command) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usage)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return newCmd(filenameIn, filenameOut, parseFieldNames(), config)
+}
+
+func prepareExportFormDataCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormExport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.ExportFormDataCommand(filenameIn, flag.Arg(1), config)
+}
+
+func prepareImportFormDataCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormImport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameFDF := flag.Arg(1)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3 {
+		filenameOut = flag.Arg(2)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.ImportFormDataCommand(filenameIn, filenameFDF, filenameOut, config)
+}
+
+func prepareExportXFACommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormXFAExport)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.ExportXFACommand(filenameIn, flag.Arg(1), config)
+}
+
+func prepareStripXFACommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageFormXFAStrip)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return pdfcpu.StripXFACommand(filenameIn, filenameOut, config)
+}
+
+func prepareXFACommand(config *types.Configuration) pdfcpu.Command {
+
+	var cmd pdfcpu.Command
+
+	switch os.Args[3] {
+
+	case "export":
+		cmd = prepareExportXFACommand(config)
+
+	case "strip":
+		cmd = prepareStripXFACommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageFormXFA)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareFormCommand(config *types.Configuration) pdfcpu.Command {
+
+	var cmd pdfcpu.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListFormFieldsCommand(config)
+
+	case "fill":
+		cmd = prepareFillFormCommand(config)
+
+	case "reset":
+		cmd = prepareModifyFormCommand(config, usageFormReset, pdfcpu.ResetFormCommand)
+
+	case "lock":
+		cmd = prepareModifyFormCommand(config, usageFormLock, pdfcpu.LockFormCommand)
+
+	case "remove":
+		cmd = prepareModifyFormCommand(config, usageFormRemove, pdfcpu.RemoveFormFieldsCommand)
+
+	case "export":
+		cmd = prepareExportFormDataCommand(config)
+
+	case "import":
+		cmd = prepareImportFormDataCommand(config)
+
+	case "xfa":
+		cmd = prepareXFACommand(config)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageForm)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareListLayersCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usageLayersList)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.ListLayersCommand(filenameIn, config)
+}
+
+func parseLayerNames() []string {
+
+	if layerNames == "" {
+		return nil
+	}
+
+	return strings.Split(layerNames, ",")
+}
+
+func prepareModifyLayersCommand(config *types.Configuration, usage string, newCmd func(string, string, []string, *types.Configuration) pdfcpu.Command) pdfcpu.Command {
+
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usage)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 2 {
+		filenameOut = flag.Arg(1)
+		ensurePdfExtension(filenameOut)
+	}
+
+	return newCmd(filenameIn, filenameOut, parseLayerNames(), config)
+}
+
+func prepareLayersCommand(config *types.Configuration) pdfcpu.Command {
+
+	var cmd pdfcpu.Command
+
+	subCmd := os.Args[2]
+
+	switch subCmd {
+
+	case "list":
+		cmd = prepareListLayersCommand(config)
+
+	case "show":
+		cmd = prepareModifyLayersCommand(config, usageLayersShow, pdfcpu.ShowLayersCommand)
+
+	case "hide":
+		cmd = prepareModifyLayersCommand(config, usageLayersHide, pdfcpu.HideLayersCommand)
+
+	case "lock":
+		cmd = prepareModifyLayersCommand(config, usageLayersLock, pdfcpu.LockLayersCommand)
+
+	case "remove":
+		cmd = prepareModifyLayersCommand(config, usageLayersRemove, pdfcpu.RemoveLayersCommand)
+
+	default:
+		fmt.Fprintln(os.Stderr, usageLayers)
+		os.Exit(1)
+	}
+
+	return cmd
+}
+
+func prepareInfoCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageInfo)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.InfoCommand(filenameIn, detail, jsonOutput, config)
+}
+
+var objEditCmds = map[string]bool{"set": true, "delete": true, "replace-stream": true}
+
+func prepareObjCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(os.Args) > 2 && objEditCmds[os.Args[2]] {
+		return prepareObjEditCommand(config, os.Args[2])
+	}
+
+	if len(flag.Args()) != 2 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageObj)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.ObjectCommand(filenameIn, flag.Arg(1), stream, config)
+}
+
+func prepareObjEditCommand(config *types.Configuration, subCmd string) pdfcpu.Command {
+
+	// Required args following inFile and path.
+	args := map[string]int{"set": 1, "delete": 0, "replace-stream": 1}[subCmd]
+	usage := map[string]string{"set": usageObjSet, "delete": usageObjDelete, "replace-stream": usageObjReplaceStream}[subCmd]
+
+	if len(flag.Args()) < 2+args || len(flag.Args()) > 3+args {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n", usage)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	path := flag.Arg(1)
+
+	filenameOut := filenameIn
+	if len(flag.Args()) == 3+args {
+		filenameOut = flag.Arg(2 + args)
+		ensurePdfExtension(filenameOut)
+	}
+
+	switch subCmd {
+
+	case "set":
+		return pdfcpu.SetObjectCommand(filenameIn, path, flag.Arg(2), filenameOut, config)
+
+	case "delete":
+		return pdfcpu.DeleteObjectCommand(filenameIn, path, filenameOut, config)
+	}
+
+	return pdfcpu.ReplaceStreamCommand(filenameIn, path, flag.Arg(2), filenameOut, config)
+}
+
+func prepareXRefCommand(config *types.Configuration) pdfcpu.Command {
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageXRef)
+		os.Exit(1)
+	}
+
+	filenameIn := flag.Arg(0)
+	ensurePdfExtension(filenameIn)
+
+	return pdfcpu.XRefCommand(filenameIn, config)
+}
+
 func prepareDecryptCommand(config *types.Configuration) pdfcpu.Command {
 
 	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || pageSelection != "" {
@@ -600,6 +1281,27 @@ func main() {
 	case "attach":
 		cmd = prepareAttachmentCommand(config)
 
+	case "annotations":
+		cmd = prepareAnnotationsCommand(config)
+
+	case "flatten":
+		cmd = prepareFlattenCommand(config)
+
+	case "form":
+		cmd = prepareFormCommand(config)
+
+	case "layers":
+		cmd = prepareLayersCommand(config)
+
+	case "info", "inspect":
+		cmd = prepareInfoCommand(config)
+
+	case "obj":
+		cmd = prepareObjCommand(config)
+
+	case "xref":
+		cmd = prepareXRefCommand(config)
+
 	case "decrypt", "d", "dec":
 		cmd = prepareDecryptCommand(config)
 
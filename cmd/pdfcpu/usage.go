@@ -10,12 +10,19 @@ Usage:
 The commands are:
 	
 	validate	validate PDF against PDF 32000-1:2008 (PDF 1.7)
-	optimize	optimize PDF by getting rid of redundant page resources
+	optimize	optimize PDF by getting rid of redundant page resources, downsample images
 	split		split multi-page PDF into several single-page PDFs
 	merge		concatenate 2 or more PDFs
-    extract		extract images, fonts, content or pages
+    extract		extract images, fonts, content, text or pages
 	trim		create trimmed version
 	attach		list, add, remove, extract embedded file attachments
+	annotations	list, export, remove, add page annotations
+	flatten		flatten annotations and form fields into page content
+	form		list, fill, reset, lock, remove form fields, FDF/XFDF, XFA
+	layers		list, show, hide, lock, remove optional content groups
+	info		print page boxes, fonts, images, attachments, form usage, tagging, encryption
+	obj		print, set, delete objects by number or path, replace stream content
+	xref		list the cross reference table
 	encrypt		set password protection		
 	decrypt		remove password protection
 	changeupw	change user password
@@ -40,38 +47,76 @@ The validation modes are:
  strict ... (default) validates against PDF 32000-1:2008 (PDF 1.7)
 relaxed ... like strict but doesn't complain about common seen spec violations.`
 
-	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-upw userpw] [-opw ownerpw] inFile [outFile]"
-	usageLongOptimize = `Optimize reads inFile, removes redundant page resources like embedded fonts and images and writes the result to outFile.
-
-verbose ... extensive log output
-  stats ... appends a stats line to a csv file with information about the usage of root and page entries.
-            useful for batch optimization and debugging PDFs.
-    upw ... user password
-    opw ... owner password
- inFile ... input pdf file
-outFile ... output pdf file (default: inFile-new.pdf)`
-
-	usageSplit     = "usage: pdfcpu split [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir"
+	usageOptimize     = "usage: pdfcpu optimize [-verbose] [-stats csvFile] [-report jsonFile] [-dpi n [-quality q]] [-subset] [-prune] [-recompress] [-linearize] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongOptimize = `Optimize reads inFile, removes redundant page resources like embedded fonts and images,
+merges different subsets of the same font and writes the result to outFile.
+
+   verbose ... extensive log output
+     stats ... appends a stats line to a csv file with information about the usage of root and page entries.
+               useful for batch optimization and debugging PDFs.
+    report ... writes a JSON report with source and destination sizes of images, fonts and other content,
+               duplicates removed and object/xref stream usage.
+       dpi ... downsample images exceeding this resolution at their placement on the page.
+   quality ... recompress downsampled images as JPEG of this quality (1-100).
+               default: JPEG images keep being JPEG, all other images are compressed using Flate.
+    subset ... reduce embedded TrueType and CFF fonts to the glyphs used.
+     prune ... remove resources not used by any content stream and unreachable objects, renumber objects.
+recompress ... recompress streams at best Flate compression and minify content streams.
+ linearize ... write a linearized file for incremental loading over the web (Fast Web View).
+       upw ... user password
+       opw ... owner password
+    inFile ... input pdf file
+   outFile ... output pdf file (default: inFile-new.pdf)`
+
+	usageSplit     = "usage: pdfcpu split [-verbose] [-mode span|page|bookmark|size] [-upw userpw] [-opw ownerpw] inFile outDir [span|pageNr...|level|maxSize]"
 	usageLongSplit = `Split generates a set of single page PDFs for the input file in outDir.
+Using a mode inFile is split into files of consecutive pages, each keeping only the resources used by its pages.
 
 verbose ... extensive log output
+   mode ... span: split into files of span pages each
+            page: start a new file at each pageNr
+            bookmark: start a new file at each bookmark up to outline level (default 1), files are named after the bookmark title
+            size: split into files not exceeding maxSize, eg. 500K or 2M, larger single pages get a file of their own
     upw ... user password
     opw ... owner password
  inFile ... input pdf file
- outDir ... output directory`
+ outDir ... output directory
 
-	usageMerge     = "usage: pdfcpu merge [-verbose] outFile inFile..."
-	usageLongMerge = `Merge concatenates a sequence of PDFs/inFiles to outFile.
+Examples: pdfcpu split in.pdf outDir
+          pdfcpu split -mode span in.pdf outDir 2
+          pdfcpu split -mode page in.pdf outDir 5 12
+          pdfcpu split -mode bookmark in.pdf outDir 2
+          pdfcpu split -mode size in.pdf outDir 1M`
+
+	usageMerge     = "usage: pdfcpu merge [-verbose] [-mode append|interleave|zip] outFile inFile[:[r]pageSelection]..."
+	usageLongMerge = `Merge concatenates a sequence of PDFs/inFiles to outFile in the given order.
+Each inFile may be followed by a page selection restricting the pages taken from it, eg. in.pdf:1-3,5
+Prefixing the page selection with r reverses the order of the selected pages, eg. in.pdf:r or in.pdf:r1-3
+Form fields and named destinations of pages not selected are dropped.
+Form fields, named destinations, name trees (eg. attachments, JavaScript), page labels and layers are merged,
+colliding field and destination names get renamed, eg. name => name_2.
+Outlines, the open action and the structure tree are dropped.
 
 verbose ... extensive log output
+   mode ... append (default): append the pages of each inFile
+            interleave: take one page of each inFile at a time
+            zip: interleave taking the pages of all inFiles but the first in reverse order
 outFile	... output pdf file
-inFiles ... a list of at least 2 pdf files subject to concatenation.`
+inFiles ... a list of at least 2 pdf files subject to concatenation, each optionally followed by :pageSelection
+
+Interleaving drops page labels.
+Merge the odd and the (reversed) even pages of a duplex scan with: pdfcpu merge -mode zip out.pdf odd.pdf even.pdf
 
-	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|page [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
-	usageLongExtract = `Extract exports inFile's images, fonts, content or pages into outDir.
+Examples: pdfcpu merge out.pdf a.pdf b.pdf c.pdf
+          pdfcpu merge out.pdf a.pdf:1-3 b.pdf:5,7- c.pdf
+          pdfcpu merge -mode interleave out.pdf front.pdf back.pdf:r`
+
+	usageExtract     = "usage: pdfcpu extract [-verbose] -mode image|font|content|text|page [-json] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile outDir"
+	usageLongExtract = `Extract exports inFile's images, fonts, content, text or pages into outDir.
 
 verbose ... extensive log output
    mode ... extraction mode
+   json ... write text as JSON including positions, fonts and font sizes
   pages ... page selection
     upw ... user password
     opw ... owner password
@@ -83,6 +128,7 @@ verbose ... extensive log output
   image ... extract images (supported PDF filters: DCTDecode, JPXDecode)
    font ... extract font files (supported font types: TrueType)
 content ... extract raw page content
+   text ... extract page text
    page ... extract single page PDFs`
 
 	usageTrim     = "usage: pdfcpu trim [-verbose] -pages pageSelection [-upw userpw] [-opw ownerpw] inFile outFile"
@@ -128,6 +174,185 @@ verbose ... extensive log output
  inFile ... input pdf file
  outDir ... output directory`
 
+	usageAnnotationsList   = "pdfcpu annotations list [-verbose] [-pages pageSelection] [-subtype subtypes] [-upw userpw] [-opw ownerpw] inFile"
+	usageAnnotationsExport = "pdfcpu annotations export [-verbose] [-pages pageSelection] [-subtype subtypes] [-upw userpw] [-opw ownerpw] inFile jsonFile"
+	usageAnnotationsRemove = "pdfcpu annotations remove [-verbose] [-pages pageSelection] [-subtype subtypes] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageAnnotationsAdd    = "pdfcpu annotations add [-verbose] [-upw userpw] [-opw ownerpw] inFile jsonFile [outFile]"
+
+	usageAnnotations = "usage: " + usageAnnotationsList + "\n\t" + usageAnnotationsExport + "\n\t" + usageAnnotationsRemove + "\n\t" + usageAnnotationsAdd
+
+	usageLongAnnotations = `Annotations manages page annotations.
+
+ verbose ... extensive log output
+   pages ... page selection
+ subtype ... a comma separated list of annotation subtypes eg. Link,Highlight,Text
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+jsonFile ... output json file (export), input json file (add)
+ outFile ... output pdf file (default: inFile)
+
+Remove also deletes popups belonging to removed annotations
+and cleans up references (IRT) to removed annotations.
+
+Add creates annotations from a json file using the layout written by export.
+Supported subtypes: Text, Link, Highlight, Underline, StrikeOut, Square, Circle, FreeText, FileAttachment.
+Appearance streams are generated for all added annotations.
+
+{
+	"annotations": [
+		{"page": 1, "subtype": "Highlight", "quadPoints": [72, 720, 300, 720, 72, 706, 300, 706]},
+		{"page": 1, "subtype": "Link", "rect": [72, 600, 200, 620], "uri": "https://pdfcpu.io"},
+		{"page": 2, "subtype": "FreeText", "rect": [72, 500, 300, 560], "contents": "Hello", "fontSize": 10}
+	]
+}`
+
+	usageFlatten     = "usage: pdfcpu flatten [-verbose] [-pages pageSelection] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLongFlatten = `Flatten draws the normal appearance of annotations and form fields into the page content.
+Flattened annotations, their popups and form fields are removed.
+Link annotations are kept.
+
+verbose ... extensive log output
+  pages ... page selection
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+outFile ... output pdf file (default: inFile)`
+
+	usageFormList      = "pdfcpu form list [-verbose] [-upw userpw] [-opw ownerpw] inFile [jsonFile]"
+	usageFormFill      = "pdfcpu form fill [-verbose] [-upw userpw] [-opw ownerpw] inFile jsonFile [outFile]"
+	usageFormReset     = "pdfcpu form reset [-verbose] [-fields fieldNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageFormLock      = "pdfcpu form lock [-verbose] [-fields fieldNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageFormRemove    = "pdfcpu form remove [-verbose] [-fields fieldNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageFormExport    = "pdfcpu form export [-verbose] [-upw userpw] [-opw ownerpw] inFile fdfFile"
+	usageFormImport    = "pdfcpu form import [-verbose] [-upw userpw] [-opw ownerpw] inFile fdfFile [outFile]"
+	usageFormXFAExport = "pdfcpu form xfa export [-verbose] [-upw userpw] [-opw ownerpw] inFile outDir"
+	usageFormXFAStrip  = "pdfcpu form xfa strip [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+
+	usageForm    = "usage: " + usageFormList + "\n\t" + usageFormFill + "\n\t" + usageFormReset + "\n\t" + usageFormLock + "\n\t" + usageFormRemove + "\n\t" + usageFormExport + "\n\t" + usageFormImport + "\n\t" + usageFormXFAExport + "\n\t" + usageFormXFAStrip
+	usageFormXFA = "usage: " + usageFormXFAExport + "\n\t" + usageFormXFAStrip
+
+	usageLongForm = `Form manages interactive form fields (AcroForm).
+
+ verbose ... extensive log output
+     upw ... user password
+     opw ... owner password
+  inFile ... input pdf file
+  fields ... a comma separated list of fully qualified field names (default: all fields)
+jsonFile ... output json file (list), input json file (fill)
+ fdfFile ... FDF (.fdf) or XFDF (.xfdf) file
+  outDir ... output directory (xfa export)
+ outFile ... output pdf file (default: inFile)
+
+List prints all fields by their fully qualified name along with type, value and options.
+The optional json file may be edited and used as input for fill:
+
+{
+	"fields": [
+		{"name": "person.name", "value": "John Doe"},
+		{"name": "agree", "value": "Yes"},
+		{"name": "colors", "values": ["r", "b"]}
+	]
+}
+
+Fill sets field values and checkbox/radio button states.
+Appearances of text fields and combo boxes are regenerated using the field's
+default appearance (font, size, color) and the form's default resources.
+A font size of 0 means auto size. Alignment, multiline and comb fields are supported.
+Viewers are asked to regenerate appearances of list boxes.
+
+Reset restores the default values of fields.
+Lock makes fields read only.
+Remove deletes fields along with their widget annotations.
+The name of a non terminal field selects all fields below it, eg. -fields address
+
+Export writes field values and markup annotations as FDF or XFDF depending on the extension of fdfFile.
+Import applies field values and markup annotations of an FDF or XFDF file.
+Fields are matched by their fully qualified name, unknown fields are skipped.
+
+Xfa export writes the packets of an XFA form (eg. template, datasets, config) as XML files.
+Xfa strip removes the XFA form so viewers use the AcroForm fields instead.`
+
+	usageLayersList   = "pdfcpu layers list [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageLayersShow   = "pdfcpu layers show [-verbose] [-layers layerNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLayersHide   = "pdfcpu layers hide [-verbose] [-layers layerNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLayersLock   = "pdfcpu layers lock [-verbose] [-layers layerNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+	usageLayersRemove = "pdfcpu layers remove [-verbose] [-layers layerNames] [-upw userpw] [-opw ownerpw] inFile [outFile]"
+
+	usageLayers = "usage: " + usageLayersList + "\n\t" + usageLayersShow + "\n\t" + usageLayersHide + "\n\t" + usageLayersLock + "\n\t" + usageLayersRemove
+
+	usageLongLayers = `Layers manages optional content groups (OCGs).
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+ layers ... a comma separated list of layer names (default: all layers)
+outFile ... output pdf file (default: inFile)
+
+List prints all layers by object number and name along with their state in the default configuration.
+Show turns layers on and hide turns them off in the default configuration.
+Lock prevents viewers from changing the state of layers.
+Remove deletes layers along with their marked content, XObjects and annotations from all pages.`
+
+	usageInfo     = "usage: pdfcpu info [-verbose] [-detail] [-json] [-upw userpw] [-opw ownerpw] inFile"
+	usageLongInfo = `Info prints the properties of inFile: version, page count and size, form usage (AcroForm, XFA),
+tagging, number of fonts, images and attachments, encryption and permissions.
+
+verbose ... extensive log output
+ detail ... also list the boxes of all pages, fonts (type, encoding, embedded, subset),
+            images (size, color space, bits per component, filter) and attachments.
+   json ... print JSON instead of text
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file
+
+"inspect" may be used as an alias for info.`
+
+	usageObjPrint         = "pdfcpu obj [-verbose] [-stream] [-upw userpw] [-opw ownerpw] inFile objNr|path"
+	usageObjSet           = "pdfcpu obj set [-verbose] [-upw userpw] [-opw ownerpw] inFile path value [outFile]"
+	usageObjDelete        = "pdfcpu obj delete [-verbose] [-upw userpw] [-opw ownerpw] inFile path [outFile]"
+	usageObjReplaceStream = "pdfcpu obj replace-stream [-verbose] [-upw userpw] [-opw ownerpw] inFile path contentFile [outFile]"
+
+	usageObj = "usage: " + usageObjPrint + "\n\t" + usageObjSet + "\n\t" + usageObjDelete + "\n\t" + usageObjReplaceStream
+
+	usageLongObj = `Obj prints or edits single objects of inFile for debugging purposes and one-off fixes.
+The file is not validated so misbehaving files may be inspected and repaired.
+
+    verbose ... extensive log output
+     stream ... also print the decoded content of a stream object
+        upw ... user password
+        opw ... owner password
+     inFile ... input pdf file
+      objNr ... object number
+       path ... objNr or Root, Info, Encrypt followed by dict keys and array indices separated by /
+      value ... a PDF object in PDF syntax
+contentFile ... file holding the new decoded stream content
+    outFile ... output pdf file (default: inFile)
+
+Set stores value as dict entry or array element at path. A single objNr replaces the whole object.
+Delete removes the dict entry or array element at path.
+Objects no longer referenced are dropped when writing.
+Replace-stream replaces the content of the stream object at path, the new content is Flate encoded.
+
+Examples: pdfcpu obj test.pdf 5
+          pdfcpu obj test.pdf Root/Pages/Kids/0/Resources
+          pdfcpu obj -stream test.pdf Root/Pages/Kids/0/Contents
+          pdfcpu obj set test.pdf Root/Pages/Kids/0/MediaBox "[0 0 595 842]"
+          pdfcpu obj delete test.pdf Root/OpenAction
+          pdfcpu obj replace-stream test.pdf Root/Pages/Kids/0/Contents content.txt out.pdf`
+
+	usageXRef     = "usage: pdfcpu xref [-verbose] [-upw userpw] [-opw ownerpw] inFile"
+	usageLongXRef = `Xref lists the cross reference table of inFile for debugging purposes.
+Each entry shows the entry type (f = free, n = in use, c = compressed),
+the offset or the next free object, the generation, the object stream and index of compressed objects
+and the object type.
+
+verbose ... extensive log output
+    upw ... user password
+    opw ... owner password
+ inFile ... input pdf file`
+
 	usageEncrypt     = "usage: pdfcpu encrypt [-verbose] [-upw userpw] [-opw ownerpw] inFile [outFile]"
 	usageLongEncrypt = `Encrypt sets a password protection based on user and owner password.
 
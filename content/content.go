@@ -0,0 +1,364 @@
+// Package content provides tokenizing, parsing and serializing of content streams.
+//
+// A content stream is a sequence of operations, each of them an operator preceded by its operands,
+// see 7.8.2 Content Streams.
+package content
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/read"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugContent, logInfoContent, logErrorContent *log.Logger
+
+func init() {
+	logDebugContent = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfoContent = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logErrorContent = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugContent = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugContent = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Operation is an operator along with its operands.
+//
+// Operands are PDF objects like types.PDFInteger, types.PDFFloat, types.PDFName,
+// types.PDFStringLiteral, types.PDFHexLiteral, types.PDFArray, types.PDFDict, types.PDFBoolean or nil.
+//
+// An inline image is represented by a single BI operation
+// with the image dict as sole operand and the image data in ImageData.
+type Operation struct {
+	Operator  string
+	Operands  []interface{}
+	ImageData []byte
+}
+
+var (
+	errCorruptContent = errors.New("content: corrupt content stream")
+	errMissingEI      = errors.New("content: inline image missing EI")
+)
+
+func whitespace(c byte) bool {
+	return c == 0x00 || c == 0x09 || c == 0x0A || c == 0x0C || c == 0x0D || c == 0x20
+}
+
+func delimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// Scanner reads operations from a content stream.
+type Scanner struct {
+	s       string
+	dropped bool // true if trailing operands have been dropped
+}
+
+// NewScanner returns a new Scanner for a decoded content stream.
+func NewScanner(buf []byte) *Scanner {
+	return &Scanner{s: string(buf)}
+}
+
+// skipWhitespace skips whitespace and comments.
+func (sc *Scanner) skipWhitespace() {
+
+	for len(sc.s) > 0 {
+
+		c := sc.s[0]
+
+		if c == '%' {
+			i := strings.IndexAny(sc.s, "\x0A\x0D")
+			if i < 0 {
+				sc.s = ""
+				return
+			}
+			sc.s = sc.s[i:]
+			continue
+		}
+
+		if !whitespace(c) {
+			return
+		}
+
+		sc.s = sc.s[1:]
+	}
+}
+
+// keyword returns the next sequence of regular characters.
+func (sc *Scanner) keyword() string {
+
+	i := 0
+	for i < len(sc.s) && !whitespace(sc.s[i]) && !delimiter(sc.s[i]) {
+		i++
+	}
+
+	k := sc.s[:i]
+	sc.s = sc.s[i:]
+
+	return k
+}
+
+func operandStart(c byte) bool {
+	return strings.IndexByte("[/(<+-.0123456789", c) >= 0
+}
+
+// hexLiteral parses a hex literal allowing for embedded whitespace.
+func (sc *Scanner) hexLiteral() (interface{}, error) {
+
+	i := strings.IndexByte(sc.s, '>')
+	if i < 0 {
+		return nil, errors.Wrap(errCorruptContent, "unterminated hex literal")
+	}
+
+	var b strings.Builder
+
+	for _, c := range []byte(sc.s[1:i]) {
+		switch {
+		case whitespace(c):
+		case strings.IndexByte("0123456789abcdefABCDEF", c) >= 0:
+			b.WriteByte(c)
+		default:
+			return nil, errors.Wrapf(errCorruptContent, "corrupt hex literal: %s", sc.s[:i+1])
+		}
+	}
+
+	// A missing final digit is assumed to be 0.
+	if b.Len()%2 == 1 {
+		b.WriteByte('0')
+	}
+
+	sc.s = sc.s[i+1:]
+
+	return types.PDFHexLiteral(b.String()), nil
+}
+
+// array parses an array of operands.
+func (sc *Scanner) array() (interface{}, error) {
+
+	a := types.PDFArray{}
+
+	sc.s = sc.s[1:]
+
+	for {
+
+		sc.skipWhitespace()
+
+		if len(sc.s) == 0 {
+			return nil, errors.Wrap(errCorruptContent, "unterminated array")
+		}
+
+		if sc.s[0] == ']' {
+			sc.s = sc.s[1:]
+			return a, nil
+		}
+
+		if !operandStart(sc.s[0]) {
+			switch k := sc.keyword(); k {
+			case "true", "false":
+				a = append(a, types.PDFBoolean(k == "true"))
+			case "null":
+				a = append(a, nil)
+			default:
+				return nil, errors.Wrapf(errCorruptContent, "unexpected %q in array", k)
+			}
+			continue
+		}
+
+		o, err := sc.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, o)
+	}
+}
+
+// operand returns the next operand.
+// Arrays and hex literals are parsed here as content streams tend to contain hex literals spanning multiple lines.
+func (sc *Scanner) operand() (interface{}, error) {
+
+	if sc.s[0] == '[' {
+		return sc.array()
+	}
+
+	if sc.s[0] == '<' && !strings.HasPrefix(sc.s, "<<") {
+		return sc.hexLiteral()
+	}
+
+	o, err := read.ParseNextObject(&sc.s)
+	if err != nil {
+		return nil, errors.Wrap(err, errCorruptContent.Error())
+	}
+
+	return o, nil
+}
+
+// inlineImage parses the image dict and data of an inline image following BI.
+// See 8.9.7 Inline Images.
+func (sc *Scanner) inlineImage() (*Operation, error) {
+
+	d := types.NewPDFDict()
+
+	for {
+
+		sc.skipWhitespace()
+
+		if len(sc.s) == 0 {
+			return nil, errMissingEI
+		}
+
+		if sc.s[0] != '/' {
+			if sc.keyword() != "ID" {
+				return nil, errCorruptContent
+			}
+			break
+		}
+
+		k, err := sc.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		sc.skipWhitespace()
+
+		v, err := sc.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		d.Insert(string(k.(types.PDFName)), v)
+	}
+
+	// A single whitespace character follows ID.
+	if len(sc.s) > 0 {
+		sc.s = sc.s[1:]
+	}
+
+	// The image data is terminated by whitespace, EI and whitespace or end of stream.
+	for i := 0; i+2 <= len(sc.s); i++ {
+
+		if sc.s[i] != 'E' || sc.s[i+1] != 'I' || i == 0 || !whitespace(sc.s[i-1]) {
+			continue
+		}
+
+		if i+2 < len(sc.s) && !whitespace(sc.s[i+2]) && !delimiter(sc.s[i+2]) {
+			continue
+		}
+
+		op := &Operation{
+			Operator:  "BI",
+			Operands:  []interface{}{d},
+			ImageData: []byte(sc.s[:i-1]),
+		}
+
+		sc.s = sc.s[i+2:]
+
+		return op, nil
+	}
+
+	return nil, errMissingEI
+}
+
+// Next returns the next operation or io.EOF at the end of the content stream.
+// Operands not followed by an operator are dropped.
+func (sc *Scanner) Next() (*Operation, error) {
+
+	var operands []interface{}
+
+	for {
+
+		sc.skipWhitespace()
+
+		if len(sc.s) == 0 {
+			if len(operands) > 0 {
+				logDebugContent.Printf("Next: dropping %d trailing operands\n", len(operands))
+				sc.dropped = true
+			}
+			return nil, io.EOF
+		}
+
+		c := sc.s[0]
+
+		if operandStart(c) {
+			o, err := sc.operand()
+			if err != nil {
+				return nil, err
+			}
+			operands = append(operands, o)
+			continue
+		}
+
+		if delimiter(c) {
+			return nil, errors.Wrapf(errCorruptContent, "unexpected %q", c)
+		}
+
+		k := sc.keyword()
+
+		switch k {
+
+		case "true":
+			operands = append(operands, types.PDFBoolean(true))
+			continue
+
+		case "false":
+			operands = append(operands, types.PDFBoolean(false))
+			continue
+
+		case "null":
+			operands = append(operands, nil)
+			continue
+
+		case "BI":
+			return sc.inlineImage()
+		}
+
+		return &Operation{Operator: k, Operands: operands}, nil
+	}
+}
+
+// StringBytes returns the bytes of a string operand.
+func StringBytes(o interface{}) ([]byte, bool) {
+
+	switch o := o.(type) {
+
+	case types.PDFStringLiteral:
+		b, err := types.Unescape(o.Value())
+		return b, err == nil
+
+	case types.PDFHexLiteral:
+		b, err := o.Bytes()
+		return b, err == nil
+	}
+
+	return nil, false
+}
+
+// Parse returns all operations of a decoded content stream.
+func Parse(buf []byte) (ops []Operation, err error) {
+
+	sc := NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, *op)
+	}
+}
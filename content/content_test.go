@@ -0,0 +1,143 @@
+package content
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+const testContent = `q 1 0 0 1 72 720 cm % move to top left
+0 1 0 RG /GS1 gs
+BT /F1 12 Tf 14.5 TL (Hello \(World\)) Tj T* [(A) -250 <42
+43>] TJ ET
+/OC <</MCID 3>> BDC EMC
+BI /W 2 /H 1 /CS /G /BPC 8 ID ` + "\x00\xFF" + `
+EI
+Q`
+
+func TestParse(t *testing.T) {
+
+	ops, err := Parse([]byte(testContent))
+	if err != nil {
+		t.Fatalf("Parse: %v\n", err)
+	}
+
+	var operators []string
+	for _, op := range ops {
+		operators = append(operators, op.Operator)
+	}
+
+	want := []string{"q", "cm", "RG", "gs", "BT", "Tf", "TL", "Tj", "T*", "TJ", "ET", "BDC", "EMC", "BI", "Q"}
+	if !reflect.DeepEqual(operators, want) {
+		t.Fatalf("Parse: got %v, want %v\n", operators, want)
+	}
+
+	// 0 1 RG must not be taken for an indirect reference.
+	if rg := ops[2].Operands; len(rg) != 3 || rg[2] != types.PDFInteger(0) {
+		t.Fatalf("Parse: corrupt RG operands: %v\n", rg)
+	}
+
+	if s := ops[7].Operands[0]; s != types.PDFStringLiteral(`Hello \(World\)`) {
+		t.Fatalf("Parse: corrupt Tj operand: %v\n", s)
+	}
+
+	tj, ok := ops[9].Operands[0].(types.PDFArray)
+	if !ok || len(tj) != 3 || tj[2] != types.PDFHexLiteral("4243") {
+		t.Fatalf("Parse: corrupt TJ operand: %v\n", ops[9].Operands)
+	}
+
+	bi := ops[13]
+	if d, ok := bi.Operands[0].(types.PDFDict); !ok || d.IntEntry("W") == nil || *d.IntEntry("W") != 2 {
+		t.Fatalf("Parse: corrupt inline image dict: %v\n", bi.Operands)
+	}
+
+	if string(bi.ImageData) != "\x00\xFF" {
+		t.Fatalf("Parse: corrupt inline image data: %v\n", bi.ImageData)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	ops, err := Parse([]byte(testContent))
+	if err != nil {
+		t.Fatalf("Parse: %v\n", err)
+	}
+
+	ops2, err := Parse(Bytes(ops))
+	if err != nil {
+		t.Fatalf("Parse serialized content: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(ops, ops2) {
+		t.Fatalf("round trip mismatch:\n%s\n", Bytes(ops2))
+	}
+}
+
+func TestParseFail(t *testing.T) {
+
+	for _, s := range []string{
+		"(unbalanced Tj",
+		"BI /W 1 /H 1 ID abc",
+		"1 0 0 1 0 0 cm ] Q",
+	} {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Errorf("Parse should have failed for: %s\n", s)
+		}
+	}
+}
+
+func TestMinify(t *testing.T) {
+
+	buf, err := Minify([]byte(testContent))
+	if err != nil {
+		t.Fatalf("Minify: %v\n", err)
+	}
+
+	if len(buf) >= len(testContent) {
+		t.Fatalf("Minify: expected savings, got %d -> %d bytes\n", len(testContent), len(buf))
+	}
+
+	ops, err := Parse([]byte(testContent))
+	if err != nil {
+		t.Fatalf("Parse: %v\n", err)
+	}
+
+	ops2, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("Parse minified content: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(ops, ops2) {
+		t.Fatalf("Minify: content differs:\n%s\n", buf)
+	}
+
+	// Content continued by another stream must be left alone.
+	if _, err := Minify([]byte("q 1 0 0 1 72 720")); err == nil {
+		t.Fatalf("Minify should have failed for dangling operands\n")
+	}
+}
+
+func TestMinifiedNumber(t *testing.T) {
+
+	for _, tc := range []struct {
+		f    float64
+		want string
+	}{
+		{0, "0"},
+		{-0.0000001, "-.0000001"},
+		{0.5, ".5"},
+		{-0.25, "-.25"},
+		{612, "612"},
+		{14.50, "14.5"},
+		{0.123456789, ".123457"},
+		{12345.678, "12345.7"},
+		{1234567.8, "1234568"},
+		{0.000123456789, ".000123457"},
+		{1.0000001, "1"},
+	} {
+		if s := minifiedNumber(tc.f); s != tc.want {
+			t.Errorf("minifiedNumber(%v): got %s, want %s\n", tc.f, s, tc.want)
+		}
+	}
+}
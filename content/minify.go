@@ -0,0 +1,172 @@
+package content
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// significantDigits is the precision real numbers are rounded to by Minify.
+const significantDigits = 6
+
+var errDanglingOperands = errors.New("content: operands not followed by an operator")
+
+// minifier writes tokens separated by whitespace only where needed.
+type minifier struct {
+	b    bytes.Buffer
+	last byte
+}
+
+// token appends a token using sep as separator if required.
+func (m *minifier) token(s string, sep byte) {
+
+	if m.b.Len() > 0 && !whitespace(m.last) && !delimiter(m.last) && !delimiter(s[0]) {
+		m.b.WriteByte(sep)
+	}
+
+	m.b.WriteString(s)
+	m.last = s[len(s)-1]
+}
+
+// minifiedNumber returns the shortest representation of a real number rounded to significantDigits.
+func minifiedNumber(f float64) string {
+
+	if f == 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "0"
+	}
+
+	// Integer digits are never rounded.
+	d := significantDigits - 1 - int(math.Floor(math.Log10(math.Abs(f))))
+	if d < 0 {
+		d = 0
+	}
+
+	s := strconv.FormatFloat(f, 'f', d, 64)
+
+	if strings.IndexByte(s, '.') >= 0 {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+
+	switch {
+	case s == "-0" || s == "":
+		s = "0"
+	case strings.HasPrefix(s, "0."):
+		s = s[1:]
+	case strings.HasPrefix(s, "-0."):
+		s = "-" + s[2:]
+	}
+
+	return s
+}
+
+func (m *minifier) operand(o interface{}, sep byte) {
+
+	switch o := o.(type) {
+
+	case nil:
+		m.token("null", sep)
+
+	case types.PDFFloat:
+		m.token(minifiedNumber(o.Value()), sep)
+
+	case types.PDFArray:
+		m.token("[", sep)
+		for _, e := range o {
+			m.operand(e, ' ')
+		}
+		m.token("]", sep)
+
+	case types.PDFDict:
+		m.token("<<", sep)
+		m.dictEntries(o)
+		m.token(">>", sep)
+
+	case interface {
+		PDFString() string
+	}:
+		m.token(o.PDFString(), sep)
+	}
+}
+
+func (m *minifier) dictEntries(d types.PDFDict) {
+
+	var keys []string
+	for k := range d.Dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m.token(types.PDFName(k).PDFString(), ' ')
+		m.operand(d.Dict[k], ' ')
+	}
+}
+
+// operation appends an operation starting on a new line if a separator is required.
+func (m *minifier) operation(op *Operation) {
+
+	if op.Operator == "BI" {
+		m.token("BI", '\n')
+		if len(op.Operands) == 1 {
+			if d, ok := op.Operands[0].(types.PDFDict); ok {
+				m.dictEntries(d)
+			}
+		}
+		// ID is followed by a single whitespace character, EI is surrounded by whitespace.
+		m.token("ID", ' ')
+		m.b.WriteByte(' ')
+		m.b.Write(op.ImageData)
+		m.b.WriteString("\nEI\n")
+		m.last = '\n'
+		return
+	}
+
+	sep := byte('\n')
+
+	for _, o := range op.Operands {
+		m.operand(o, sep)
+		sep = ' '
+	}
+
+	m.token(op.Operator, sep)
+}
+
+// Minify returns a compact version of a decoded content stream.
+// Comments and redundant whitespace are removed and real numbers are rounded to 6 significant digits.
+// Content ending with operands is rejected since it may be continued by the next content stream of a page.
+func Minify(buf []byte) ([]byte, error) {
+
+	var m minifier
+
+	sc := NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.operation(op)
+	}
+
+	if sc.dropped {
+		return nil, errDanglingOperands
+	}
+
+	if m.b.Len() > 0 && m.last != '\n' {
+		m.b.WriteByte('\n')
+	}
+
+	return m.b.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+package content
+
+import (
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// PageContent returns the decoded content of a page.
+// Multiple content streams are concatenated since they may be split at any token boundary.
+// Streams using unsupported filters are skipped.
+func PageContent(ctx *types.PDFContext, pageDict *types.PDFDict) (buf []byte, err error) {
+
+	obj, err := ctx.Dereference(pageDict.Dict["Contents"])
+	if err != nil || obj == nil {
+		return
+	}
+
+	var streams []*types.PDFStreamDict
+
+	switch obj := obj.(type) {
+
+	case types.PDFStreamDict:
+		streams = append(streams, &obj)
+
+	case types.PDFArray:
+		for _, o := range obj {
+			sd, err := ctx.DereferenceStreamDict(o)
+			if err != nil {
+				return nil, err
+			}
+			if sd != nil {
+				streams = append(streams, sd)
+			}
+		}
+
+	default:
+		return nil, errors.New("PageContent: page content must be stream dict or array")
+	}
+
+	for _, sd := range streams {
+
+		err = filter.DecodeStream(sd)
+		if err == filter.ErrUnsupportedFilter {
+			logInfoContent.Println("PageContent: skipping stream using unsupported filter")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(buf, sd.Content...)
+		buf = append(buf, '\n')
+	}
+
+	return buf, nil
+}
+
+// PageOperations returns the operations of a page.
+func PageOperations(ctx *types.PDFContext, pageDict *types.PDFDict) ([]Operation, error) {
+
+	buf, err := PageContent(ctx, pageDict)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(buf)
+}
@@ -0,0 +1,107 @@
+package content
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// writeOperand writes the content stream representation of an operand.
+// Floats are written with full precision as they are subject to further transformations.
+func writeOperand(b *bytes.Buffer, o interface{}) {
+
+	switch o := o.(type) {
+
+	case nil:
+		b.WriteString("null")
+
+	case types.PDFFloat:
+		b.WriteString(strconv.FormatFloat(o.Value(), 'f', -1, 64))
+
+	case types.PDFArray:
+		b.WriteByte('[')
+		for i, e := range o {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeOperand(b, e)
+		}
+		b.WriteByte(']')
+
+	case types.PDFDict:
+		b.WriteString("<<")
+		writeDictEntries(b, o)
+		b.WriteString(">>")
+
+	case interface {
+		PDFString() string
+	}:
+		b.WriteString(o.PDFString())
+	}
+}
+
+func writeDictEntries(b *bytes.Buffer, d types.PDFDict) {
+
+	var keys []string
+	for k := range d.Dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(types.PDFName(k).PDFString())
+		b.WriteByte(' ')
+		writeOperand(b, d.Dict[k])
+	}
+}
+
+// write appends the content stream representation of op to b.
+func (op Operation) write(b *bytes.Buffer) {
+
+	if op.Operator == "BI" {
+		b.WriteString("BI ")
+		if len(op.Operands) == 1 {
+			if d, ok := op.Operands[0].(types.PDFDict); ok {
+				writeDictEntries(b, d)
+			}
+		}
+		b.WriteString(" ID ")
+		b.Write(op.ImageData)
+		b.WriteString("\nEI\n")
+		return
+	}
+
+	for _, o := range op.Operands {
+		writeOperand(b, o)
+		b.WriteByte(' ')
+	}
+
+	b.WriteString(op.Operator)
+	b.WriteByte('\n')
+}
+
+// String returns the content stream representation of op.
+func (op Operation) String() string {
+
+	var b bytes.Buffer
+	op.write(&b)
+
+	return string(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+}
+
+// Bytes returns the content stream for a sequence of operations.
+func Bytes(ops []Operation) []byte {
+
+	var b bytes.Buffer
+
+	for _, op := range ops {
+		op.write(&b)
+	}
+
+	return b.Bytes()
+}
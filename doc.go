@@ -7,12 +7,19 @@ Supported are all versions up to PDF 1.7 (ISO-32000).
 The available commands are:
 
 	validate	validate PDF against PDF 32000-1:2008 (PDF 1.7)
-	optimize	optimize PDF by getting rid of redundant page resources
-	split		split multi-page PDF into several single-page PDFs
+	optimize	optimize PDF by getting rid of redundant page resources, downsample images, subset fonts, prune unused resources, recompress streams, linearize
+	split		split multi-page PDF into single-page PDFs or by page count, page numbers, bookmarks or size
 	merge		concatenate 2 or more PDFs
-	extract		extract images, fonts, content or pages
+	extract		extract images, fonts, content, text or pages
 	trim		create trimmed version
 	attachments	list, add, remove, extract embedded file attachments
+	annotations	list, export, remove, add page annotations
+	flatten		flatten annotations and form fields into page content
+	form		list, fill, reset, lock, remove form fields, FDF/XFDF, XFA
+	layers		list, show, hide, lock, remove optional content groups
+	info		print page boxes, fonts, images, attachments, form usage, tagging, encryption
+	obj		print, set, delete objects by number or path, replace stream content
+	xref		list the cross reference table
 	encrypt		set password
 	decrypt		remove password
 	changeupw	change user password
@@ -0,0 +1,544 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// TextRun is a piece of text shown by a single text showing operator.
+// X and Y are the user space coordinates of its starting point.
+type TextRun struct {
+	Text     string  `json:"text"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Font     string  `json:"font"`
+	FontSize float64 `json:"fontSize"`
+}
+
+// PageText is the text of a page.
+type PageText struct {
+	Page int       `json:"page"`
+	Runs []TextRun `json:"runs"`
+}
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translation(tx, ty float64) matrix {
+	return matrix{1, 0, 0, 1, tx, ty}
+}
+
+// textState holds the text related parameters of the graphics state, see 9.3 Text State Parameters and Operators.
+type textState struct {
+	ctm       matrix
+	font      *fontInfo
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	scale     float64
+	leading   float64
+	rise      float64
+	resources *types.PDFDict
+}
+
+// textExtractor interprets content streams and collects text runs.
+type textExtractor struct {
+	ctx   *types.PDFContext
+	fonts map[*types.PDFDict]*fontInfo
+	gs    textState
+	stack []textState
+	tm    matrix // text matrix
+	tlm   matrix // text line matrix
+	runs  []TextRun
+	depth int
+}
+
+func (te *textExtractor) setFont(name string) {
+
+	te.gs.font = nil
+
+	if te.gs.resources == nil {
+		return
+	}
+
+	fonts, err := te.ctx.DereferenceDict(te.gs.resources.Dict["Font"])
+	if err != nil || fonts == nil {
+		return
+	}
+
+	fontDict, err := te.ctx.DereferenceDict(fonts.Dict[name])
+	if err != nil || fontDict == nil {
+		logDebugExtract.Printf("setFont: unknown font %s\n", name)
+		return
+	}
+
+	f, found := te.fonts[fontDict]
+	if !found {
+		f = newFontInfo(te.ctx, fontDict)
+		te.fonts[fontDict] = f
+	}
+
+	te.gs.font = f
+}
+
+// showText adds a text run for a string and advances the text matrix.
+// Adjustments are the TJ array elements (strings and numbers) to be processed.
+func (te *textExtractor) showText(adjustments []interface{}) {
+
+	f := te.gs.font
+	if f == nil {
+		return
+	}
+
+	fs, th := te.gs.fontSize, te.gs.scale
+
+	// The run starts at the text space origin.
+	trm := matrix{fs * th, 0, 0, fs, 0, te.gs.rise}.multiply(te.tm).multiply(te.gs.ctm)
+	x, y := trm[4], trm[5]
+
+	var sb strings.Builder
+
+	for _, a := range adjustments {
+
+		if a, ok := number(a); ok {
+			tx := -a / 1000 * fs * th
+			// A large negative adjustment usually separates words.
+			if a < -200 && sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") {
+				sb.WriteByte(' ')
+			}
+			te.tm = translation(tx, 0).multiply(te.tm)
+			continue
+		}
+
+		bb, ok := content.StringBytes(a)
+		if !ok {
+			continue
+		}
+
+		for _, c := range f.codes(bb) {
+			sb.WriteString(f.text(c))
+			w := f.width(c) / 1000
+			tx := w*fs + te.gs.charSpace
+			if len(c) == 1 && c[0] == ' ' {
+				tx += te.gs.wordSpace
+			}
+			te.tm = translation(tx*th, 0).multiply(te.tm)
+		}
+	}
+
+	trm = matrix{fs * th, 0, 0, fs, 0, te.gs.rise}.multiply(te.tm).multiply(te.gs.ctm)
+
+	if sb.Len() == 0 {
+		return
+	}
+
+	// The font size in user space is the length of the transformed vertical unit vector.
+	size := math.Hypot(trm[2], trm[3])
+
+	te.runs = append(te.runs, TextRun{
+		Text:     sb.String(),
+		X:        round(x),
+		Y:        round(y),
+		Width:    round(math.Hypot(trm[4]-x, trm[5]-y)),
+		Font:     f.name,
+		FontSize: round(size),
+	})
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func (te *textExtractor) nextLine(tx, ty float64) {
+	te.tlm = translation(tx, ty).multiply(te.tlm)
+	te.tm = te.tlm
+}
+
+func numbers(operands []interface{}, n int) ([]float64, bool) {
+
+	if len(operands) < n {
+		return nil, false
+	}
+
+	ff := make([]float64, n)
+
+	for i, o := range operands[len(operands)-n:] {
+		f, ok := number(o)
+		if !ok {
+			return nil, false
+		}
+		ff[i] = f
+	}
+
+	return ff, true
+}
+
+func (te *textExtractor) formXObject(name string) (err error) {
+
+	if te.gs.resources == nil || te.depth > 8 {
+		return
+	}
+
+	xObjs, err := te.ctx.DereferenceDict(te.gs.resources.Dict["XObject"])
+	if err != nil || xObjs == nil {
+		return
+	}
+
+	sd, err := te.ctx.DereferenceStreamDict(xObjs.Dict[name])
+	if err != nil || sd == nil {
+		return
+	}
+
+	if s := sd.Subtype(); s == nil || *s != "Form" {
+		return
+	}
+
+	saved := te.gs
+	te.stack = append(te.stack, saved)
+
+	if a, err := te.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && a != nil && len(*a) == 6 {
+		var m matrix
+		for i, o := range *a {
+			m[i], _ = number(o)
+		}
+		te.gs.ctm = m.multiply(te.gs.ctm)
+	}
+
+	if res, err := te.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && res != nil {
+		te.gs.resources = res
+	}
+
+	te.depth++
+	err = te.processStream(sd)
+	te.depth--
+
+	te.gs = te.stack[len(te.stack)-1]
+	te.stack = te.stack[:len(te.stack)-1]
+
+	return err
+}
+
+func (te *textExtractor) processOperator(op string, operands []interface{}) (err error) {
+
+	switch op {
+
+	case "q":
+		te.stack = append(te.stack, te.gs)
+
+	case "Q":
+		if len(te.stack) > 0 {
+			te.gs = te.stack[len(te.stack)-1]
+			te.stack = te.stack[:len(te.stack)-1]
+		}
+
+	case "cm":
+		if ff, ok := numbers(operands, 6); ok {
+			var m matrix
+			copy(m[:], ff)
+			te.gs.ctm = m.multiply(te.gs.ctm)
+		}
+
+	case "BT":
+		te.tm, te.tlm = identity, identity
+
+	case "Tf":
+		if len(operands) == 2 {
+			if n, ok := operands[0].(types.PDFName); ok {
+				te.setFont(n.Value())
+			}
+			te.gs.fontSize, _ = number(operands[1])
+		}
+
+	case "Tc":
+		if ff, ok := numbers(operands, 1); ok {
+			te.gs.charSpace = ff[0]
+		}
+
+	case "Tw":
+		if ff, ok := numbers(operands, 1); ok {
+			te.gs.wordSpace = ff[0]
+		}
+
+	case "Tz":
+		if ff, ok := numbers(operands, 1); ok {
+			te.gs.scale = ff[0] / 100
+		}
+
+	case "TL":
+		if ff, ok := numbers(operands, 1); ok {
+			te.gs.leading = ff[0]
+		}
+
+	case "Ts":
+		if ff, ok := numbers(operands, 1); ok {
+			te.gs.rise = ff[0]
+		}
+
+	case "Td":
+		if ff, ok := numbers(operands, 2); ok {
+			te.nextLine(ff[0], ff[1])
+		}
+
+	case "TD":
+		if ff, ok := numbers(operands, 2); ok {
+			te.gs.leading = -ff[1]
+			te.nextLine(ff[0], ff[1])
+		}
+
+	case "Tm":
+		if ff, ok := numbers(operands, 6); ok {
+			copy(te.tlm[:], ff)
+			te.tm = te.tlm
+		}
+
+	case "T*":
+		te.nextLine(0, -te.gs.leading)
+
+	case "Tj":
+		if len(operands) == 1 {
+			te.showText(operands)
+		}
+
+	case "TJ":
+		if len(operands) == 1 {
+			if a, ok := operands[0].(types.PDFArray); ok {
+				te.showText(a)
+			}
+		}
+
+	case "'":
+		te.nextLine(0, -te.gs.leading)
+		if len(operands) == 1 {
+			te.showText(operands)
+		}
+
+	case "\"":
+		if len(operands) == 3 {
+			te.gs.wordSpace, _ = number(operands[0])
+			te.gs.charSpace, _ = number(operands[1])
+			te.nextLine(0, -te.gs.leading)
+			te.showText(operands[2:])
+		}
+
+	case "Do":
+		if len(operands) == 1 {
+			if n, ok := operands[0].(types.PDFName); ok {
+				err = te.formXObject(n.Value())
+			}
+		}
+	}
+
+	return
+}
+
+func (te *textExtractor) process(buf []byte) (err error) {
+
+	sc := content.NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = te.processOperator(op.Operator, op.Operands)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (te *textExtractor) processStream(sd *types.PDFStreamDict) (err error) {
+
+	err = filter.DecodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	return te.process(sd.Content)
+}
+
+// PageTextRuns returns the text runs of a page in content stream order.
+func PageTextRuns(ctx *types.PDFContext, pageNr int) (runs []TextRun, err error) {
+
+	pageDict, _, err := ctx.PageDict(pageNr)
+	if err != nil {
+		return
+	}
+
+	if pageDict == nil {
+		return nil, errors.Errorf("PageTextRuns: page %d not found", pageNr)
+	}
+
+	return textRuns(ctx, pageDict)
+}
+
+// textRuns returns the text runs of pageDict in content stream order.
+func textRuns(ctx *types.PDFContext, pageDict *types.PDFDict) (runs []TextRun, err error) {
+
+	obj, err := ctx.InheritedPageAttr(pageDict, "Resources")
+	if err != nil {
+		return
+	}
+
+	resources, err := ctx.DereferenceDict(obj)
+	if err != nil {
+		return
+	}
+
+	buf, err := content.PageContent(ctx, pageDict)
+	if err != nil {
+		return
+	}
+
+	te := &textExtractor{
+		ctx:   ctx,
+		fonts: map[*types.PDFDict]*fontInfo{},
+		gs:    textState{ctm: identity, scale: 1, resources: resources},
+		tm:    identity,
+		tlm:   identity,
+	}
+
+	err = te.process(buf)
+	if err != nil {
+		return
+	}
+
+	return te.runs, nil
+}
+
+// PlainText arranges text runs into lines.
+// Runs on the same baseline are joined, separated by a space if there is a gap between them.
+func PlainText(runs []TextRun) string {
+
+	var sb strings.Builder
+
+	for i, r := range runs {
+
+		if i > 0 {
+
+			prev := runs[i-1]
+
+			tolerance := math.Max(prev.FontSize, r.FontSize) / 2
+			if tolerance == 0 {
+				tolerance = 1
+			}
+
+			gap := r.X - (prev.X + prev.Width)
+
+			switch {
+
+			case math.Abs(r.Y-prev.Y) > tolerance || gap < -tolerance*4:
+				sb.WriteByte('\n')
+
+			case gap > r.FontSize*0.15 &&
+				!strings.HasSuffix(prev.Text, " ") && !strings.HasPrefix(r.Text, " "):
+				sb.WriteByte(' ')
+			}
+		}
+
+		sb.WriteString(r.Text)
+	}
+
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+func writeText(ctx *types.PDFContext, pageNr int, pageDict *types.PDFDict, jsonOutput bool) (err error) {
+
+	runs, err := textRuns(ctx, pageDict)
+	if err != nil {
+		return
+	}
+
+	var (
+		fileName string
+		bb       []byte
+	)
+
+	if jsonOutput {
+		fileName = fmt.Sprintf("%s/text_p%d.json", ctx.Write.DirName, pageNr)
+		bb, err = json.MarshalIndent(PageText{Page: pageNr, Runs: runs}, "", "\t")
+		if err != nil {
+			return
+		}
+	} else {
+		fileName = fmt.Sprintf("%s/text_p%d.txt", ctx.Write.DirName, pageNr)
+		bb = []byte(PlainText(runs))
+	}
+
+	logInfoExtract.Printf("writing to %s\n", fileName)
+
+	return ioutil.WriteFile(fileName, bb, os.ModePerm)
+}
+
+// Text writes the text of selected pages to dirOut, one file per page.
+// The text is either written as plain text or as JSON including position, font and font size of each text run.
+func Text(ctx *types.PDFContext, selectedPages types.IntSet, jsonOutput bool) (err error) {
+
+	logDebugExtract.Printf("Text begin: dirOut=%s\n", ctx.Write.DirName)
+
+	// Resolve the page tree once instead of for every page.
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	for i, indRef := range pages {
+
+		if !needsPage(selectedPages, i+1) {
+			continue
+		}
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		if pageDict == nil {
+			return errors.Errorf("Text: page %d not found", i+1)
+		}
+
+		err = writeText(ctx, i+1, pageDict, jsonOutput)
+		if err != nil {
+			return err
+		}
+	}
+
+	logDebugExtract.Println("Text end")
+
+	return
+}
@@ -0,0 +1,290 @@
+package extract
+
+import (
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+func code(b []byte) (c int) {
+	for _, v := range b {
+		c = c<<8 | int(v)
+	}
+	return
+}
+
+// fontInfo holds everything needed to decode and measure strings shown with a font.
+type fontInfo struct {
+	name      string
+	composite bool
+	toUnicode *font.CMap
+	encoding  font.Encoding
+	widths    map[int]float64 // glyph widths in 1/1000 text space units
+	dw        float64         // default width
+}
+
+// codes splits a string into character codes.
+func (f *fontInfo) codes(s []byte) (codes [][]byte) {
+
+	for len(s) > 0 {
+
+		n := 1
+		if f.composite {
+			n = 2
+		}
+
+		if f.toUnicode != nil && len(f.toUnicode.Codespace) > 0 {
+			for i := 1; i <= 4 && i <= len(s); i++ {
+				found := false
+				for _, r := range f.toUnicode.Codespace {
+					if r.Contains(s[:i]) {
+						found = true
+						break
+					}
+				}
+				if found {
+					n = i
+					break
+				}
+			}
+		}
+
+		if n > len(s) {
+			n = len(s)
+		}
+
+		codes = append(codes, s[:n])
+		s = s[n:]
+	}
+
+	return
+}
+
+// text returns the Unicode text for a character code.
+func (f *fontInfo) text(c []byte) string {
+
+	if f.toUnicode != nil {
+		if s, found := f.toUnicode.Chars[string(c)]; found {
+			return s
+		}
+	}
+
+	if f.composite {
+		// Without ToUnicode CIDs carry no Unicode information.
+		return "�"
+	}
+
+	if r := f.encoding[c[0]]; r != 0 {
+		return string(r)
+	}
+
+	if c[0] < 0x20 {
+		return ""
+	}
+
+	return string(rune(c[0]))
+}
+
+// width returns the width of a character code in 1/1000 text space units.
+func (f *fontInfo) width(c []byte) float64 {
+
+	if w, found := f.widths[code(c)]; found {
+		return w
+	}
+
+	if !f.composite && font.IsStandardFont(f.name) {
+		return float64(font.CharWidth(f.name, c[0]))
+	}
+
+	return f.dw
+}
+
+func (f *fontInfo) loadToUnicode(ctx *types.PDFContext, fontDict *types.PDFDict) {
+
+	sd, err := ctx.DereferenceStreamDict(fontDict.Dict["ToUnicode"])
+	if err != nil || sd == nil {
+		return
+	}
+
+	err = filter.DecodeStream(sd)
+	if err != nil {
+		logDebugExtract.Printf("loadToUnicode: %v\n", err)
+		return
+	}
+
+	f.toUnicode = font.ParseCMap(sd.Content)
+}
+
+func (f *fontInfo) loadEncoding(ctx *types.PDFContext, fontDict *types.PDFDict) {
+
+	// Symbolic fonts without an encoding use their built-in encoding which is approximated by StandardEncoding.
+	f.encoding = font.StandardEncoding
+	if f.name == "Symbol" || f.name == "ZapfDingbats" {
+		f.encoding = font.Encoding{}
+	}
+
+	obj, err := ctx.Dereference(fontDict.Dict["Encoding"])
+	if err != nil || obj == nil {
+		return
+	}
+
+	var differences types.PDFArray
+
+	switch o := obj.(type) {
+
+	case types.PDFName:
+		if e, ok := font.PredefinedEncoding(o.Value()); ok {
+			f.encoding = *e
+		}
+
+	case types.PDFDict:
+		if n := o.NameEntry("BaseEncoding"); n != nil {
+			if e, ok := font.PredefinedEncoding(*n); ok {
+				f.encoding = *e
+			}
+		}
+		if a, err := ctx.DereferenceArray(o.Dict["Differences"]); err == nil && a != nil {
+			differences = *a
+		}
+	}
+
+	// See 9.6.6.2 Character Encodings: [code name1 name2 ... code name1 ...]
+	c := 0
+	for _, o := range differences {
+		switch o := o.(type) {
+		case types.PDFInteger:
+			c = o.Value()
+		case types.PDFName:
+			if c >= 0 && c < 256 {
+				if r, ok := font.GlyphRune(o.Value()); ok {
+					f.encoding[c] = r
+				}
+			}
+			c++
+		}
+	}
+}
+
+func number(obj interface{}) (float64, bool) {
+
+	switch n := obj.(type) {
+	case types.PDFInteger:
+		return float64(n.Value()), true
+	case types.PDFFloat:
+		return n.Value(), true
+	}
+
+	return 0, false
+}
+
+func (f *fontInfo) loadSimpleWidths(ctx *types.PDFContext, fontDict *types.PDFDict) {
+
+	first := fontDict.IntEntry("FirstChar")
+
+	a, err := ctx.DereferenceArray(fontDict.Dict["Widths"])
+	if err != nil || a == nil || first == nil {
+		return
+	}
+
+	for i, o := range *a {
+		o, _ = ctx.Dereference(o)
+		if w, ok := number(o); ok {
+			f.widths[*first+i] = w
+		}
+	}
+}
+
+// loadCIDWidths reads DW and W of the descendant font, see 9.7.4.3 Glyph Metrics in CIDFonts.
+// Identity encoding of CIDs is assumed.
+func (f *fontInfo) loadCIDWidths(ctx *types.PDFContext, fontDict *types.PDFDict) {
+
+	a, err := ctx.DereferenceArray(fontDict.Dict["DescendantFonts"])
+	if err != nil || a == nil || len(*a) == 0 {
+		return
+	}
+
+	d, err := ctx.DereferenceDict((*a)[0])
+	if err != nil || d == nil {
+		return
+	}
+
+	if o, err := ctx.Dereference(d.Dict["DW"]); err == nil {
+		if w, ok := number(o); ok {
+			f.dw = w
+		}
+	}
+
+	w, err := ctx.DereferenceArray(d.Dict["W"])
+	if err != nil || w == nil {
+		return
+	}
+
+	// c [w1 w2 ...] or cFirst cLast w
+	for i := 0; i < len(*w); {
+
+		c, ok := number((*w)[i])
+		if !ok || i+1 >= len(*w) {
+			return
+		}
+
+		o, _ := ctx.Dereference((*w)[i+1])
+
+		if ws, ok := o.(types.PDFArray); ok {
+			for j, o := range ws {
+				if v, ok := number(o); ok {
+					f.widths[int(c)+j] = v
+				}
+			}
+			i += 2
+			continue
+		}
+
+		last, ok := number(o)
+		if !ok || i+2 >= len(*w) {
+			return
+		}
+		v, _ := number((*w)[i+2])
+		for cid := int(c); cid <= int(last) && cid-int(c) < 0x10000; cid++ {
+			f.widths[cid] = v
+		}
+		i += 3
+	}
+}
+
+// newFontInfo analyzes a font dict.
+func newFontInfo(ctx *types.PDFContext, fontDict *types.PDFDict) *fontInfo {
+
+	f := &fontInfo{widths: map[int]float64{}}
+
+	if n := fontDict.NameEntry("BaseFont"); n != nil {
+		f.name = *n
+		// Drop any subset prefix like in ABCDEF+Helvetica.
+		if len(f.name) > 7 && f.name[6] == '+' {
+			f.name = f.name[7:]
+		}
+	}
+
+	f.loadToUnicode(ctx, fontDict)
+
+	if s := fontDict.Subtype(); s != nil && *s == "Type0" {
+		f.composite = true
+		f.dw = 1000
+		f.loadCIDWidths(ctx, fontDict)
+		return f
+	}
+
+	f.dw = 500
+	f.loadEncoding(ctx, fontDict)
+	f.loadSimpleWidths(ctx, fontDict)
+
+	// Type3 glyph widths are given in glyph space.
+	if a, err := ctx.DereferenceArray(fontDict.Dict["FontMatrix"]); err == nil && a != nil && len(*a) == 6 {
+		if sx, ok := number((*a)[0]); ok {
+			for c, w := range f.widths {
+				f.widths[c] = w * sx * 1000
+			}
+		}
+	}
+
+	return f
+}
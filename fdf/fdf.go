@@ -0,0 +1,276 @@
+// Package fdf provides import and export of form data and annotations
+// using the Forms Data Format (FDF) and its XML counterpart XFDF.
+package fdf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/annot"
+	"github.com/hhrutter/pdfcpu/form"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugFDF, logInfoFDF, logErrorFDF *log.Logger
+
+func init() {
+	logDebugFDF = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfoFDF = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logErrorFDF = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugFDF = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugFDF = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// markupSubtypes are the annotation subtypes taking part in the exchange.
+var markupSubtypes = types.StringSet{
+	"Text":      true,
+	"Highlight": true,
+	"Underline": true,
+	"StrikeOut": true,
+	"Square":    true,
+	"Circle":    true,
+	"FreeText":  true,
+}
+
+// data is the format independent content of an FDF or XFDF file.
+type data struct {
+	fileName string // The PDF file the data belongs to.
+	fields   []form.Field
+	annots   []annot.Annotation
+}
+
+// collect gathers the field values and markup annotations of a document.
+func collect(ctx *types.PDFContext) (d data, err error) {
+
+	fields, err := form.Fields(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, f := range fields {
+		if f.Type == "pushbutton" || f.Type == "signature" {
+			continue
+		}
+		d.fields = append(d.fields, f)
+	}
+
+	d.annots, err = annot.Annotations(ctx, nil, markupSubtypes)
+
+	return
+}
+
+// annotationKey identifies annotations without a name.
+func annotationKey(a annot.Annotation) string {
+	return fmt.Sprintf("%d %s %.2f %q", a.Page, a.Subtype, a.Rect, a.Contents)
+}
+
+// apply sets field values and adds annotations to a document.
+// Unknown fields and annotations already present are skipped.
+func apply(ctx *types.PDFContext, d data) (err error) {
+
+	existing, err := form.Fields(ctx)
+	if err != nil {
+		return
+	}
+
+	known := types.StringSet{}
+	for _, f := range existing {
+		known[f.Name] = true
+	}
+
+	var fields []form.Field
+
+	for _, f := range d.fields {
+		if !known[f.Name] {
+			logInfoFDF.Printf("skipping unknown field: %s\n", f.Name)
+			continue
+		}
+		fields = append(fields, f)
+	}
+
+	if len(fields) > 0 {
+		err = form.Fill(ctx, fields)
+		if err != nil {
+			return
+		}
+	}
+
+	present, err := annot.Annotations(ctx, nil, nil)
+	if err != nil {
+		return
+	}
+
+	names, keys := types.StringSet{}, types.StringSet{}
+	for _, a := range present {
+		if a.Name != "" {
+			names[a.Name] = true
+		}
+		keys[annotationKey(a)] = true
+	}
+
+	var annots []annot.Annotation
+
+	for _, a := range d.annots {
+		if !markupSubtypes[a.Subtype] {
+			logInfoFDF.Printf("skipping unsupported annotation: %s\n", a.Subtype)
+			continue
+		}
+		if a.Name != "" && names[a.Name] || keys[annotationKey(a)] {
+			logDebugFDF.Printf("skipping existing annotation: %s\n", a.Name)
+			continue
+		}
+		annots = append(annots, a)
+	}
+
+	if len(annots) > 0 {
+		err = annot.Add(ctx, annots)
+	}
+
+	return
+}
+
+func isXFDF(fileName string) (bool, error) {
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+
+	case ".fdf":
+		return false, nil
+
+	case ".xfdf":
+		return true, nil
+	}
+
+	return false, errors.Errorf("%s: extension must be .fdf or .xfdf", fileName)
+}
+
+// Export writes the field values and markup annotations of a document to fileName.
+// The format (FDF or XFDF) is chosen by the file extension.
+// pdfFileName is recorded as the document the data belongs to.
+func Export(ctx *types.PDFContext, fileName, pdfFileName string) (err error) {
+
+	logDebugFDF.Println("Export begin")
+
+	useXFDF, err := isXFDF(fileName)
+	if err != nil {
+		return
+	}
+
+	d, err := collect(ctx)
+	if err != nil {
+		return
+	}
+
+	d.fileName = filepath.Base(pdfFileName)
+
+	var bb []byte
+	if useXFDF {
+		bb, err = marshalXFDF(d)
+	} else {
+		bb, err = marshalFDF(d)
+	}
+	if err != nil {
+		return
+	}
+
+	err = ioutil.WriteFile(fileName, bb, os.ModePerm)
+	if err != nil {
+		return errors.Wrapf(err, "Export: can't write %s", fileName)
+	}
+
+	logDebugFDF.Println("Export end")
+
+	return
+}
+
+// Import applies the field values and markup annotations found in fileName to a document.
+// Fields are matched by fully qualified name.
+// The format (FDF or XFDF) is chosen by the file extension.
+func Import(ctx *types.PDFContext, fileName string) (err error) {
+
+	logDebugFDF.Println("Import begin")
+
+	useXFDF, err := isXFDF(fileName)
+	if err != nil {
+		return
+	}
+
+	bb, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return errors.Wrapf(err, "Import: can't read %s", fileName)
+	}
+
+	var d data
+	if useXFDF {
+		d, err = unmarshalXFDF(bb)
+	} else {
+		d, err = unmarshalFDF(bb)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Import: %s", fileName)
+	}
+
+	err = apply(ctx, d)
+	if err != nil {
+		return
+	}
+
+	logDebugFDF.Println("Import end")
+
+	return
+}
+
+// node is a node of the field tree rebuilt from fully qualified field names.
+type node struct {
+	name  string
+	field *form.Field
+	kids  []*node
+}
+
+func (n *node) kid(name string) *node {
+
+	for _, k := range n.kids {
+		if k.name == name {
+			return k
+		}
+	}
+
+	k := &node{name: name}
+	n.kids = append(n.kids, k)
+
+	return k
+}
+
+// fieldTree arranges fields in a tree using their partial names.
+func fieldTree(fields []form.Field) []*node {
+
+	root := &node{}
+
+	for i := range fields {
+		n := root
+		for _, name := range strings.Split(fields[i].Name, ".") {
+			n = n.kid(name)
+		}
+		n.field = &fields[i]
+	}
+
+	return root.kids
+}
+
+func qualifiedName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
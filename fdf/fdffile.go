@@ -0,0 +1,455 @@
+package fdf
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/annot"
+	"github.com/hhrutter/pdfcpu/form"
+	"github.com/hhrutter/pdfcpu/read"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// See 12.7.7 Forms Data Format.
+
+func floatArray(ff []float64) types.PDFArray {
+	a := types.PDFArray{}
+	for _, f := range ff {
+		a = append(a, types.PDFFloat(f))
+	}
+	return a
+}
+
+// fdfValue returns the V entry for a field.
+func fdfValue(f form.Field) interface{} {
+
+	switch f.Type {
+
+	case "checkbox", "radio":
+		if f.Value == "" {
+			return types.PDFName("Off")
+		}
+		return types.PDFName(f.Value)
+
+	case "choice":
+		if len(f.Values) > 0 {
+			a := types.PDFArray{}
+			for _, v := range f.Values {
+				a = append(a, types.TextStringLiteral(v))
+			}
+			return a
+		}
+		if f.Value == "" {
+			return nil
+		}
+	}
+
+	return types.TextStringLiteral(f.Value)
+}
+
+func fdfField(n *node) types.PDFDict {
+
+	d := types.NewPDFDict()
+	d.Insert("T", types.TextStringLiteral(n.name))
+
+	if n.field != nil {
+		if v := fdfValue(*n.field); v != nil {
+			d.Insert("V", v)
+		}
+	}
+
+	if len(n.kids) > 0 {
+		kids := types.PDFArray{}
+		for _, k := range n.kids {
+			kids = append(kids, fdfField(k))
+		}
+		d.Insert("Kids", kids)
+	}
+
+	return d
+}
+
+func fdfAnnotation(a annot.Annotation) types.PDFDict {
+
+	d := types.NewPDFDict()
+	d.Insert("Type", types.PDFName("Annot"))
+	d.Insert("Subtype", types.PDFName(a.Subtype))
+	d.Insert("Page", types.PDFInteger(a.Page-1))
+	d.Insert("Rect", floatArray(a.Rect))
+
+	for k, v := range map[string]string{"Contents": a.Contents, "T": a.Author, "Subj": a.Subject, "NM": a.Name} {
+		if v != "" {
+			d.Insert(k, types.TextStringLiteral(v))
+		}
+	}
+
+	for k, v := range map[string]string{"M": a.ModDate, "CreationDate": a.CreationDate, "DA": a.DA} {
+		if v != "" {
+			d.Insert(k, types.TextStringLiteral(v))
+		}
+	}
+
+	for k, v := range map[string][]float64{"C": a.Color, "IC": a.InteriorCol, "QuadPoints": a.QuadPoints} {
+		if len(v) > 0 {
+			d.Insert(k, floatArray(v))
+		}
+	}
+
+	if a.Flags > 0 {
+		d.Insert("F", types.PDFInteger(a.Flags))
+	}
+
+	if a.Icon != "" {
+		d.Insert("Name", types.PDFName(a.Icon))
+	}
+
+	if a.Open {
+		d.Insert("Open", types.PDFBoolean(true))
+	}
+
+	if a.Quadding > 0 {
+		d.Insert("Q", types.PDFInteger(a.Quadding))
+	}
+
+	if a.BorderWidth > 0 {
+		bs := types.NewPDFDict()
+		bs.Insert("W", types.PDFFloat(a.BorderWidth))
+		d.Insert("BS", bs)
+	}
+
+	return d
+}
+
+func marshalFDF(d data) ([]byte, error) {
+
+	fields := types.PDFArray{}
+	for _, n := range fieldTree(d.fields) {
+		fields = append(fields, fdfField(n))
+	}
+
+	annots := types.PDFArray{}
+	for _, a := range d.annots {
+		annots = append(annots, fdfAnnotation(a))
+	}
+
+	fdf := types.NewPDFDict()
+	fdf.Insert("F", types.TextStringLiteral(d.fileName))
+	if len(fields) > 0 {
+		fdf.Insert("Fields", fields)
+	}
+	if len(annots) > 0 {
+		fdf.Insert("Annots", annots)
+	}
+
+	root := types.NewPDFDict()
+	root.Insert("FDF", fdf)
+
+	var b bytes.Buffer
+	b.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n")
+	b.WriteString("1 0 obj\n" + root.PDFString() + "\nendobj\n")
+	b.WriteString("trailer\n<</Root 1 0 R>>\n%%EOF\n")
+
+	return b.Bytes(), nil
+}
+
+var objHeader = regexp.MustCompile(`^\s*(\d+)\s+\d+\s+obj\b`)
+
+// streamLength returns the Length of a stream dict or -1 if it is not known yet.
+func streamLength(d types.PDFDict, objs map[int]interface{}) int {
+
+	o := d.Dict["Length"]
+	if ir, ok := o.(types.PDFIndirectRef); ok {
+		o = objs[ir.ObjectNumber.Value()]
+	}
+
+	if i, ok := o.(types.PDFInteger); ok {
+		return i.Value()
+	}
+
+	return -1
+}
+
+// skipObjectEnd positions s behind the end of the object just parsed including any stream data.
+func skipObjectEnd(s string, o interface{}, objs map[int]interface{}) (string, error) {
+
+	t := strings.TrimLeft(s, " \t\r\n\f\x00")
+
+	if d, ok := o.(types.PDFDict); ok && strings.HasPrefix(t, "stream") {
+
+		t = strings.TrimPrefix(t[len("stream"):], "\r")
+		t = strings.TrimPrefix(t, "\n")
+
+		if l := streamLength(d, objs); l >= 0 && l <= len(t) {
+			t = t[l:]
+		}
+
+		i := strings.Index(t, "endstream")
+		if i < 0 {
+			return s, errors.New("missing endstream")
+		}
+		t = t[i+len("endstream"):]
+	}
+
+	i := strings.Index(t, "endobj")
+	if i < 0 {
+		return s, errors.New("missing endobj")
+	}
+
+	return t[i+len("endobj"):], nil
+}
+
+// nextObjHeader returns s positioned on the next line starting with an object header.
+func nextObjHeader(s string) (string, []int) {
+
+	for t := s; ; {
+		if m := objHeader.FindStringSubmatchIndex(t); m != nil {
+			return t, m
+		}
+		i := strings.IndexAny(t, "\r\n")
+		if i < 0 {
+			return s, nil
+		}
+		t = t[i+1:]
+	}
+}
+
+// parseObjects returns all indirect objects of an FDF file and the trailer dict.
+// Objects are parsed in order skipping stream data.
+func parseObjects(s string) (objs map[int]interface{}, trailer types.PDFDict, err error) {
+
+	objs = map[int]interface{}{}
+
+	for {
+
+		var m []int
+		if s, m = nextObjHeader(s); m == nil {
+			break
+		}
+
+		objNr, _ := strconv.Atoi(s[m[2]:m[3]])
+		s = s[m[1]:]
+
+		o, err := read.ParseNextObject(&s)
+		if err != nil {
+			return nil, trailer, errors.Wrapf(err, "corrupt object %d", objNr)
+		}
+
+		objs[objNr] = o
+
+		if s, err = skipObjectEnd(s, o, objs); err != nil {
+			return nil, trailer, errors.Wrapf(err, "corrupt object %d", objNr)
+		}
+	}
+
+	i := strings.LastIndex(s, "trailer")
+	if i < 0 {
+		return nil, trailer, errors.New("missing trailer")
+	}
+
+	o, err := read.ParseObject(s[i+len("trailer"):])
+	if err != nil {
+		return nil, trailer, errors.Wrap(err, "corrupt trailer")
+	}
+
+	d, ok := o.(types.PDFDict)
+	if !ok {
+		return nil, trailer, errors.New("corrupt trailer")
+	}
+
+	return objs, d, nil
+}
+
+// resolve replaces all indirect references within obj by the objects they refer to.
+func resolve(obj interface{}, objs map[int]interface{}, depth int) interface{} {
+
+	if depth > 32 {
+		return nil
+	}
+
+	switch o := obj.(type) {
+
+	case types.PDFIndirectRef:
+		return resolve(objs[o.ObjectNumber.Value()], objs, depth+1)
+
+	case types.PDFDict:
+		d := types.NewPDFDict()
+		for k, v := range o.Dict {
+			d.Insert(k, resolve(v, objs, depth+1))
+		}
+		return d
+
+	case types.PDFArray:
+		a := types.PDFArray{}
+		for _, v := range o {
+			a = append(a, resolve(v, objs, depth+1))
+		}
+		return a
+	}
+
+	return obj
+}
+
+func text(obj interface{}) (s string) {
+
+	switch o := obj.(type) {
+
+	case types.PDFStringLiteral:
+		s, _ = types.StringLiteralToString(o.Value())
+
+	case types.PDFHexLiteral:
+		s, _ = types.HexLiteralToString(o.Value())
+
+	case types.PDFName:
+		s = o.Value()
+	}
+
+	return
+}
+
+func number(obj interface{}) (f float64, ok bool) {
+
+	switch n := obj.(type) {
+	case types.PDFInteger:
+		return float64(n.Value()), true
+	case types.PDFFloat:
+		return n.Value(), true
+	}
+
+	return
+}
+
+func numbers(obj interface{}) (ff []float64) {
+
+	a, ok := obj.(types.PDFArray)
+	if !ok {
+		return
+	}
+
+	for _, o := range a {
+		if f, ok := number(o); ok {
+			ff = append(ff, f)
+		}
+	}
+
+	return
+}
+
+// fdfFields collects the values of the fields of an FDF field tree.
+func fdfFields(arr types.PDFArray, parent string, fields *[]form.Field) {
+
+	for _, o := range arr {
+
+		d, ok := o.(types.PDFDict)
+		if !ok {
+			continue
+		}
+
+		name := qualifiedName(parent, text(d.Dict["T"]))
+
+		if v, found := d.Find("V"); found {
+			f := form.Field{Name: name}
+			if a, ok := v.(types.PDFArray); ok {
+				for _, o := range a {
+					f.Values = append(f.Values, text(o))
+				}
+			} else {
+				f.Value = text(v)
+			}
+			*fields = append(*fields, f)
+		}
+
+		if kids, ok := d.Dict["Kids"].(types.PDFArray); ok {
+			fdfFields(kids, name, fields)
+		}
+	}
+}
+
+func annotationFromFDF(d types.PDFDict) annot.Annotation {
+
+	a := annot.Annotation{
+		Rect:         numbers(d.Dict["Rect"]),
+		Contents:     text(d.Dict["Contents"]),
+		Author:       text(d.Dict["T"]),
+		Subject:      text(d.Dict["Subj"]),
+		Name:         text(d.Dict["NM"]),
+		ModDate:      text(d.Dict["M"]),
+		CreationDate: text(d.Dict["CreationDate"]),
+		Color:        numbers(d.Dict["C"]),
+		InteriorCol:  numbers(d.Dict["IC"]),
+		QuadPoints:   numbers(d.Dict["QuadPoints"]),
+		DA:           text(d.Dict["DA"]),
+		Icon:         text(d.Dict["Name"]),
+	}
+
+	if s := d.Subtype(); s != nil {
+		a.Subtype = *s
+	}
+
+	// FDF page numbers are zero based.
+	if i := d.IntEntry("Page"); i != nil {
+		a.Page = *i + 1
+	}
+
+	if i := d.IntEntry("F"); i != nil {
+		a.Flags = *i
+	}
+
+	if i := d.IntEntry("Q"); i != nil {
+		a.Quadding = *i
+	}
+
+	if b := d.BooleanEntry("Open"); b != nil {
+		a.Open = *b
+	}
+
+	if bs, ok := d.Dict["BS"].(types.PDFDict); ok {
+		if w, ok := number(bs.Dict["W"]); ok {
+			a.BorderWidth = w
+		}
+	}
+
+	return a
+}
+
+func unmarshalFDF(bb []byte) (d data, err error) {
+
+	s := string(bb)
+
+	if !strings.HasPrefix(s, "%FDF-") {
+		return d, errors.New("missing FDF header")
+	}
+
+	objs, trailer, err := parseObjects(s)
+	if err != nil {
+		return
+	}
+
+	root, ok := resolve(trailer.Dict["Root"], objs, 0).(types.PDFDict)
+	if !ok {
+		return d, errors.New("missing catalog")
+	}
+
+	fdf, ok := root.Dict["FDF"].(types.PDFDict)
+	if !ok {
+		return d, errors.New("missing FDF dict")
+	}
+
+	d.fileName = text(fdf.Dict["F"])
+
+	if fields, ok := fdf.Dict["Fields"].(types.PDFArray); ok {
+		fdfFields(fields, "", &d.fields)
+	}
+
+	if annots, ok := fdf.Dict["Annots"].(types.PDFArray); ok {
+		for _, o := range annots {
+			if ad, ok := o.(types.PDFDict); ok {
+				d.annots = append(d.annots, annotationFromFDF(ad))
+			}
+		}
+	}
+
+	return
+}
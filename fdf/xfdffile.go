@@ -0,0 +1,363 @@
+package fdf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/annot"
+	"github.com/hhrutter/pdfcpu/form"
+	"github.com/pkg/errors"
+)
+
+// See ISO 19444-1 XML Forms Data Format (XFDF).
+
+type xfdfFile struct {
+	XMLName xml.Name    `xml:"http://ns.adobe.com/xfdf/ xfdf"`
+	F       *xfdfHref   `xml:"f"`
+	Fields  []xfdfField `xml:"fields>field"`
+	Annots  *xfdfAnnots `xml:"annots"`
+}
+
+type xfdfHref struct {
+	Href string `xml:"href,attr"`
+}
+
+type xfdfField struct {
+	Name   string      `xml:"name,attr"`
+	Values []string    `xml:"value"`
+	Fields []xfdfField `xml:"field"`
+}
+
+type xfdfAnnots struct {
+	Annots []xfdfAnnot `xml:",any"`
+}
+
+// xfdfAnnot represents an annotation element, the element name is the lower case subtype.
+type xfdfAnnot struct {
+	XMLName           xml.Name
+	Page              int    `xml:"page,attr"`
+	Rect              string `xml:"rect,attr"`
+	Name              string `xml:"name,attr,omitempty"`
+	Title             string `xml:"title,attr,omitempty"`
+	Subject           string `xml:"subject,attr,omitempty"`
+	Date              string `xml:"date,attr,omitempty"`
+	CreationDate      string `xml:"creationdate,attr,omitempty"`
+	Flags             string `xml:"flags,attr,omitempty"`
+	Color             string `xml:"color,attr,omitempty"`
+	InteriorColor     string `xml:"interior-color,attr,omitempty"`
+	Width             string `xml:"width,attr,omitempty"`
+	Icon              string `xml:"icon,attr,omitempty"`
+	Open              string `xml:"open,attr,omitempty"`
+	Coords            string `xml:"coords,attr,omitempty"`
+	Justification     string `xml:"justification,attr,omitempty"`
+	Contents          string `xml:"contents,omitempty"`
+	DefaultAppearance string `xml:"defaultappearance,omitempty"`
+}
+
+// The names of annotation flags in bit order, see 12.5.3 Annotation Flags.
+var xfdfFlags = []string{"invisible", "hidden", "print", "nozoom", "norotate", "noview", "readonly", "locked", "togglenoview", "lockedcontents"}
+
+var xfdfJustification = []string{"left", "centered", "right"}
+
+func xfdfFlagsString(flags int) string {
+
+	var ss []string
+	for i, s := range xfdfFlags {
+		if flags&(1<<uint(i)) > 0 {
+			ss = append(ss, s)
+		}
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func xfdfFlagsValue(s string) (flags int) {
+
+	for _, w := range strings.Split(s, ",") {
+		for i, f := range xfdfFlags {
+			if strings.TrimSpace(w) == f {
+				flags |= 1 << uint(i)
+			}
+		}
+	}
+
+	return
+}
+
+func numbersString(ff []float64) string {
+
+	ss := make([]string, len(ff))
+	for i, f := range ff {
+		ss[i] = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func parseNumbers(s string) (ff []float64, err error) {
+
+	if s == "" {
+		return
+	}
+
+	for _, v := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number list: %s", s)
+		}
+		ff = append(ff, f)
+	}
+
+	return
+}
+
+// colorString returns a color in #RRGGBB notation.
+func colorString(c []float64) string {
+
+	var r, g, b float64
+
+	switch len(c) {
+
+	case 1:
+		r, g, b = c[0], c[0], c[0]
+
+	case 3:
+		r, g, b = c[0], c[1], c[2]
+
+	case 4:
+		// CMYK
+		r, g, b = (1-c[0])*(1-c[3]), (1-c[1])*(1-c[3]), (1-c[2])*(1-c[3])
+
+	default:
+		return ""
+	}
+
+	return fmt.Sprintf("#%02X%02X%02X", int(r*255+0.5), int(g*255+0.5), int(b*255+0.5))
+}
+
+func parseColor(s string) ([]float64, error) {
+
+	if s == "" {
+		return nil, nil
+	}
+
+	if len(s) != 7 || s[0] != '#' {
+		return nil, errors.Errorf("invalid color: %s", s)
+	}
+
+	var c []float64
+
+	for i := 1; i < 7; i += 2 {
+		v, err := strconv.ParseUint(s[i:i+2], 16, 8)
+		if err != nil {
+			return nil, errors.Errorf("invalid color: %s", s)
+		}
+		c = append(c, float64(v)/255)
+	}
+
+	return c, nil
+}
+
+func xfdfFieldFor(n *node) xfdfField {
+
+	f := xfdfField{Name: n.name}
+
+	if n.field != nil {
+		switch {
+		case len(n.field.Values) > 0:
+			f.Values = n.field.Values
+		case n.field.Type == "checkbox" || n.field.Type == "radio":
+			v := n.field.Value
+			if v == "" {
+				v = "Off"
+			}
+			f.Values = []string{v}
+		default:
+			f.Values = []string{n.field.Value}
+		}
+	}
+
+	for _, k := range n.kids {
+		f.Fields = append(f.Fields, xfdfFieldFor(k))
+	}
+
+	return f
+}
+
+func xfdfAnnotFor(a annot.Annotation) xfdfAnnot {
+
+	x := xfdfAnnot{
+		XMLName:       xml.Name{Local: strings.ToLower(a.Subtype)},
+		Page:          a.Page - 1,
+		Rect:          numbersString(a.Rect),
+		Name:          a.Name,
+		Title:         a.Author,
+		Subject:       a.Subject,
+		Date:          a.ModDate,
+		CreationDate:  a.CreationDate,
+		Flags:         xfdfFlagsString(a.Flags),
+		Color:         colorString(a.Color),
+		InteriorColor: colorString(a.InteriorCol),
+		Icon:          a.Icon,
+		Coords:        numbersString(a.QuadPoints),
+		Contents:      a.Contents,
+	}
+
+	if a.BorderWidth > 0 {
+		x.Width = strconv.FormatFloat(a.BorderWidth, 'f', -1, 64)
+	}
+
+	if a.Open {
+		x.Open = "yes"
+	}
+
+	if a.Subtype == "FreeText" {
+		x.DefaultAppearance = a.DA
+		if a.Quadding > 0 && a.Quadding < len(xfdfJustification) {
+			x.Justification = xfdfJustification[a.Quadding]
+		}
+	}
+
+	return x
+}
+
+func marshalXFDF(d data) ([]byte, error) {
+
+	x := xfdfFile{F: &xfdfHref{Href: d.fileName}}
+
+	for _, n := range fieldTree(d.fields) {
+		x.Fields = append(x.Fields, xfdfFieldFor(n))
+	}
+
+	if len(d.annots) > 0 {
+		x.Annots = &xfdfAnnots{}
+		for _, a := range d.annots {
+			x.Annots.Annots = append(x.Annots.Annots, xfdfAnnotFor(a))
+		}
+	}
+
+	bb, err := xml.MarshalIndent(x, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(bb, '\n')...), nil
+}
+
+// xfdfFieldValues collects the values of the fields of an XFDF field tree.
+func xfdfFieldValues(xx []xfdfField, parent string, fields *[]form.Field) {
+
+	for _, x := range xx {
+
+		name := qualifiedName(parent, x.Name)
+
+		switch len(x.Values) {
+
+		case 0:
+
+		case 1:
+			*fields = append(*fields, form.Field{Name: name, Value: x.Values[0]})
+
+		default:
+			*fields = append(*fields, form.Field{Name: name, Values: x.Values})
+		}
+
+		xfdfFieldValues(x.Fields, name, fields)
+	}
+}
+
+// subtypes maps XFDF element names to annotation subtypes.
+var subtypes = map[string]string{
+	"text":      "Text",
+	"highlight": "Highlight",
+	"underline": "Underline",
+	"strikeout": "StrikeOut",
+	"square":    "Square",
+	"circle":    "Circle",
+	"freetext":  "FreeText",
+}
+
+func annotationFromXFDF(x xfdfAnnot) (a annot.Annotation, err error) {
+
+	a = annot.Annotation{
+		Subtype:      subtypes[x.XMLName.Local],
+		Page:         x.Page + 1,
+		Name:         x.Name,
+		Author:       x.Title,
+		Subject:      x.Subject,
+		ModDate:      x.Date,
+		CreationDate: x.CreationDate,
+		Flags:        xfdfFlagsValue(x.Flags),
+		Icon:         x.Icon,
+		Open:         x.Open == "yes",
+		Contents:     x.Contents,
+		DA:           x.DefaultAppearance,
+	}
+
+	if a.Subtype == "" {
+		// Leave it to apply to skip unsupported annotations.
+		a.Subtype = x.XMLName.Local
+	}
+
+	if a.Rect, err = parseNumbers(x.Rect); err != nil {
+		return
+	}
+
+	if a.QuadPoints, err = parseNumbers(x.Coords); err != nil {
+		return
+	}
+
+	if a.Color, err = parseColor(x.Color); err != nil {
+		return
+	}
+
+	if a.InteriorCol, err = parseColor(x.InteriorColor); err != nil {
+		return
+	}
+
+	if x.Width != "" {
+		if a.BorderWidth, err = strconv.ParseFloat(x.Width, 64); err != nil {
+			return a, errors.Errorf("invalid width: %s", x.Width)
+		}
+	}
+
+	for i, j := range xfdfJustification {
+		if x.Justification == j {
+			a.Quadding = i
+		}
+	}
+
+	return
+}
+
+func unmarshalXFDF(bb []byte) (d data, err error) {
+
+	var x xfdfFile
+
+	err = xml.Unmarshal(bb, &x)
+	if err != nil {
+		return
+	}
+
+	if x.F != nil {
+		d.fileName = x.F.Href
+	}
+
+	xfdfFieldValues(x.Fields, "", &d.fields)
+
+	if x.Annots == nil {
+		return
+	}
+
+	for _, xa := range x.Annots.Annots {
+		a, err := annotationFromXFDF(xa)
+		if err != nil {
+			return d, errors.Wrapf(err, "annotation %s on page %d", xa.XMLName.Local, xa.Page+1)
+		}
+		d.annots = append(d.annots, a)
+	}
+
+	return
+}
@@ -5,6 +5,7 @@ package filter
 
 import (
 	"bytes"
+	"compress/zlib"
 	"io"
 	"io/ioutil"
 	"log"
@@ -43,7 +44,7 @@ func NewFilter(filterName string, decodeParms, encodeParms *types.PDFDict) (filt
 	switch filterName {
 
 	case "FlateDecode":
-		filter = flate{baseFilter{decodeParms, encodeParms}}
+		filter = flate{baseFilter{decodeParms, encodeParms}, zlib.DefaultCompression}
 
 	case "ASCII85Decode":
 		filter = ascii85Decode{baseFilter{decodeParms, encodeParms}}
@@ -72,7 +73,13 @@ type baseFilter struct {
 }
 
 // EncodeStream encodes stream dict data by applying its filter pipeline.
-func EncodeStream(streamDict *types.PDFStreamDict) (err error) {
+func EncodeStream(streamDict *types.PDFStreamDict) error {
+	return EncodeStreamLevel(streamDict, zlib.DefaultCompression)
+}
+
+// EncodeStreamLevel encodes stream dict data by applying its filter pipeline using a Flate compression level
+// like zlib.BestCompression.
+func EncodeStreamLevel(streamDict *types.PDFStreamDict, level int) (err error) {
 
 	logDebugFilter.Printf("encodeStream begin")
 
@@ -82,7 +89,7 @@ func EncodeStream(streamDict *types.PDFStreamDict) (err error) {
 		streamDict.Raw = streamDict.Content
 		streamLength := int64(len(streamDict.Raw))
 		streamDict.StreamLength = &streamLength
-		streamDict.Insert("Length", types.PDFInteger(streamLength))
+		streamDict.Update("Length", types.PDFInteger(streamLength))
 		return
 	}
 
@@ -105,6 +112,11 @@ func EncodeStream(streamDict *types.PDFStreamDict) (err error) {
 			return err
 		}
 
+		if fl, ok := fi.(flate); ok {
+			fl.level = level
+			fi = fl
+		}
+
 		c, err = fi.Encode(b)
 		if err != nil {
 			return err
@@ -119,13 +131,26 @@ func EncodeStream(streamDict *types.PDFStreamDict) (err error) {
 
 	streamLength := int64(len(streamDict.Raw))
 	streamDict.StreamLength = &streamLength
-	streamDict.Insert("Length", types.PDFInteger(streamLength))
+	streamDict.Update("Length", types.PDFInteger(streamLength))
 
 	logDebugFilter.Printf("encodeStream end")
 
 	return
 }
 
+// ReplaceContent replaces the content of streamDict and encodes it using Flate instead of any previous filters.
+func ReplaceContent(streamDict *types.PDFStreamDict, content []byte) error {
+
+	streamDict.Content = content
+	streamDict.FilterPipeline = []types.PDFFilter{{Name: "FlateDecode", DecodeParms: nil}}
+	streamDict.StreamLengthObjNr = nil
+	streamDict.Update("Filter", types.PDFName("FlateDecode"))
+	streamDict.Delete("DecodeParms")
+	streamDict.Delete("DL")
+
+	return EncodeStream(streamDict)
+}
+
 // DecodeStream decodes streamDict data by applying its filter pipeline.
 func DecodeStream(streamDict *types.PDFStreamDict) (err error) {
 
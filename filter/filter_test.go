@@ -3,6 +3,8 @@ package filter
 import (
 	"bytes"
 	"testing"
+
+	"github.com/hhrutter/pdfcpu/types"
 )
 
 // Encode a test string twice with same filter
@@ -56,3 +58,40 @@ func TestEncodeDecode(t *testing.T) {
 	}
 
 }
+
+// Encode image like data using a PNG or TIFF predictor and decode it again.
+func TestFlatePredictors(t *testing.T) {
+
+	// 3 rows of 5 RGB samples.
+	var input []byte
+	for i := 0; i < 45; i++ {
+		input = append(input, byte(i*i+7))
+	}
+
+	for _, p := range []int{1, 2, 10, 11, 12, 13, 14, 15} {
+
+		d := types.NewPDFDict()
+		d.Insert("Predictor", types.PDFInteger(p))
+		d.Insert("Colors", types.PDFInteger(3))
+		d.Insert("Columns", types.PDFInteger(5))
+
+		filter, err := NewFilter("FlateDecode", &d, nil)
+		if err != nil {
+			t.Fatalf("Problem: %v\n", err)
+		}
+
+		b, err := filter.Encode(bytes.NewReader(input))
+		if err != nil {
+			t.Fatalf("Problem encoding with predictor %d: %v\n", p, err)
+		}
+
+		c, err := filter.Decode(b)
+		if err != nil {
+			t.Fatalf("Problem decoding with predictor %d: %v\n", p, err)
+		}
+
+		if !bytes.Equal(input, c.Bytes()) {
+			t.Fatalf("original content != decoded content for predictor %d\n", p)
+		}
+	}
+}
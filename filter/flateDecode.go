@@ -8,14 +8,32 @@ import (
 	"github.com/pkg/errors"
 )
 
-var (
-	errFlateMissingDecodeParmColumn    = errors.New("filter FlateDecode: missing decode parm: Columns")
-	errFlateMissingDecodeParmPredictor = errors.New("filter FlateDecode: missing decode parm: Predictor")
-	errFlatePostProcessing             = errors.New("filter FlateDecode: postprocessing failed")
+var errFlatePostProcessing = errors.New("filter FlateDecode: postprocessing failed")
+
+// Predictor values, see 7.4.4.4 LZW and Flate Predictor Functions.
+const (
+	predictorNo      = 1
+	predictorTIFF    = 2
+	predictorNone    = 10
+	predictorSub     = 11
+	predictorUp      = 12
+	predictorAverage = 13
+	predictorPaeth   = 14
+	predictorOptimum = 15
+)
+
+// PNG filter types prefixing each row of PNG predicted data.
+const (
+	pngNone    = 0x00
+	pngSub     = 0x01
+	pngUp      = 0x02
+	pngAverage = 0x03
+	pngPaeth   = 0x04
 )
 
 type flate struct {
 	baseFilter
+	level int // the zlib compression level used for encoding
 }
 
 // Encode implements encoding for a Flate filter.
@@ -23,12 +41,20 @@ func (f flate) Encode(r io.Reader) (*bytes.Buffer, error) {
 
 	logDebugFilter.Println("EncodeFlate begin")
 
-	// Optional decode parameters need preprocessing
-	// but this filter implementation is used for object streams
-	// and xref streams only and does not use decode parameters.
+	// Optional decode parameters need preprocessing.
+	if f.decodeParms != nil {
+		var err error
+		r, err = f.encodePreProcess(r)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var b bytes.Buffer
-	w := zlib.NewWriter(&b)
+	w, err := zlib.NewWriterLevel(&b, f.level)
+	if err != nil {
+		return nil, err
+	}
 
 	written, err := io.Copy(w, r)
 	if err != nil {
@@ -67,104 +93,290 @@ func (f flate) Decode(r io.Reader) (*bytes.Buffer, error) {
 		return &b, nil
 	}
 
-	logDebugFilter.Println("DecodeFlate end w/o decodeParms")
+	logDebugFilter.Println("DecodeFlate end w/ decodeParms")
 
 	// Optional decode parameters need postprocessing.
 	return f.decodePostProcess(&b)
 }
 
-// decodePostProcess
-func (f flate) decodePostProcess(rin io.Reader) (a *bytes.Buffer, err error) {
-
-	// The only postprocessing needed (for decoding object streams) is: PredictorUp with PngUp.
-
-	const PredictorNo = 1
-	const PredictorTIFF = 2
-	const PredictorNone = 10
-	const PredictorSub = 11
-	const PredictorUp = 12 // implemented
-	const PredictorAverage = 13
-	const PredictorPaeth = 14
-	const PredictorOptimum = 15
-
-	const PngNone = 0x00
-	const PngSub = 0x01
-	const PngUp = 0x02 // implemented
-	const PngAverage = 0x03
-	const PngPaeth = 0x04
-
-	c := f.decodeParms.IntEntry("Columns")
-	if c == nil {
-		err = errFlateMissingDecodeParmColumn
-		return
+// predictorParms returns the predictor, the number of bytes per row and the number of bytes per pixel.
+func (f flate) predictorParms() (predictor, rowSize, bpp int, err error) {
+
+	intEntry := func(key string, def int) int {
+		if i := f.decodeParms.IntEntry(key); i != nil {
+			return *i
+		}
+		return def
 	}
 
-	columns := *c
+	// Predictor optional, integer (Default:1)
+	predictor = intEntry("Predictor", predictorNo)
 
-	p := f.decodeParms.IntEntry("Predictor")
-	if p == nil {
-		err = errFlateMissingDecodeParmPredictor
-		return
-	}
+	// Colors, optional, integer: 1,2,3,4 (Default:1)
+	// The number of interleaved colour components per sample.
+	colors := intEntry("Colors", 1)
+
+	// BitsPerComponent optional, integer: 1,2,4,8,16 (Default:8)
+	// The number of bits used to represent each colour component in a sample.
+	bpc := intEntry("BitsPerComponent", 8)
 
-	predictor := *p
+	// Columns, optional, integer (Default:1)
+	// The number of samples in each row.
+	columns := intEntry("Columns", 1)
 
-	// PredictorUp is a popular predictor used for flate encoded stream dicts.
-	if predictor != PredictorUp {
-		err = errors.Errorf("Filter FlateDecode: Predictor %d unsupported", predictor)
+	if colors < 1 || columns < 1 {
+		err = errors.Errorf("filter FlateDecode: invalid decode parms Colors:%d Columns:%d", colors, columns)
 		return
 	}
 
-	// BitsPerComponent optional, integer: 1,2,4,8,16 (Default:8)
-	// The number of bits used to represent each colour component in a sample.
-	bpc := f.decodeParms.IntEntry("BitsPerComponents")
-	if bpc != nil {
-		err = errors.Errorf("Filter FlateDecode: Unexpected \"BitsPerComponent\": %d", *bpc)
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		err = errors.Errorf("filter FlateDecode: invalid decode parm BitsPerComponent:%d", bpc)
 		return
 	}
 
-	// Colors, optional, integer: 1,2,3,4 (Default:1)
-	// The number of interleaved colour components per sample.
-	colors := f.decodeParms.IntEntry("Colors")
-	if colors != nil {
-		err = errors.Errorf("Filter FlateDecode: Unexpected \"Colors\": %d", *colors)
+	switch predictor {
+
+	case predictorNo:
+
+	case predictorTIFF:
+		if bpc != 8 {
+			err = errors.Errorf("filter FlateDecode: TIFF predictor unsupported for BitsPerComponent:%d", bpc)
+			return
+		}
+
+	case predictorNone, predictorSub, predictorUp, predictorAverage, predictorPaeth, predictorOptimum:
+
+	default:
+		err = errors.Errorf("filter FlateDecode: Predictor %d unsupported", predictor)
 		return
 	}
 
+	rowSize = (colors*bpc*columns + 7) / 8
+
+	bpp = (colors*bpc + 7) / 8
+
+	return
+}
+
+// decodePostProcess reverses the prediction applied to decoded data.
+func (f flate) decodePostProcess(r io.Reader) (*bytes.Buffer, error) {
+
+	predictor, rowSize, bpp, err := f.predictorParms()
+	if err != nil {
+		return nil, err
+	}
+
 	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(rin)
+	_, err = buf.ReadFrom(r)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	if predictor == predictorNo {
+		return buf, nil
 	}
 
 	b := buf.Bytes()
 
-	if len(b)%(columns+1) > 0 {
+	if predictor == predictorTIFF {
+		if len(b)%rowSize > 0 {
+			return nil, errFlatePostProcessing
+		}
+		for i := 0; i < len(b); i += rowSize {
+			row := b[i : i+rowSize]
+			for j := bpp; j < rowSize; j++ {
+				row[j] += row[j-bpp]
+			}
+		}
+		return bytes.NewBuffer(b), nil
+	}
+
+	// PNG prediction: each row is prefixed by its filter type.
+	if len(b)%(rowSize+1) > 0 {
 		return nil, errFlatePostProcessing
 	}
 
-	var fbuf []byte
-	j := 0
-	for i := 0; i < len(b); i += columns + 1 {
-		if b[i] != PngUp {
-			err = errFlatePostProcessing
-			return
+	bufOut := make([]byte, 0, len(b)/(rowSize+1)*rowSize)
+	prev := make([]byte, rowSize)
+
+	for i := 0; i < len(b); i += rowSize + 1 {
+
+		row := b[i+1 : i+rowSize+1]
+
+		err = pngUnfilterRow(b[i], row, prev, bpp)
+		if err != nil {
+			return nil, err
+		}
+
+		bufOut = append(bufOut, row...)
+		prev = row
+	}
+
+	return bytes.NewBuffer(bufOut), nil
+}
+
+// encodePreProcess applies the prediction defined by the decode parms to data about to be encoded.
+func (f flate) encodePreProcess(r io.Reader) (io.Reader, error) {
+
+	predictor, rowSize, bpp, err := f.predictorParms()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if predictor == predictorNo {
+		return buf, nil
+	}
+
+	b := buf.Bytes()
+
+	if len(b)%rowSize > 0 {
+		return nil, errors.New("filter FlateDecode: preprocessing failed")
+	}
+
+	if predictor == predictorTIFF {
+		out := make([]byte, len(b))
+		for i := 0; i < len(b); i += rowSize {
+			for j := 0; j < rowSize; j++ {
+				out[i+j] = b[i+j]
+				if j >= bpp {
+					out[i+j] -= b[i+j-bpp]
+				}
+			}
 		}
-		fbuf = append(fbuf, b[i+1:i+columns+1]...)
-		j++
-	}
-
-	bufOut := make([]byte, len(fbuf))
-	for i := 0; i < len(fbuf); i += columns {
-		for j := 0; j < columns; j++ {
-			from := i - columns + j
-			if from >= 0 {
-				bufOut[i+j] = fbuf[i+j] + bufOut[i-columns+j]
-			} else {
-				bufOut[j] = fbuf[j]
+		return bytes.NewReader(out), nil
+	}
+
+	out := make([]byte, 0, len(b)/rowSize*(rowSize+1))
+	prev := make([]byte, rowSize)
+	filtered := make([]byte, rowSize)
+
+	for i := 0; i < len(b); i += rowSize {
+
+		row := b[i : i+rowSize]
+
+		// Optimum chooses the filter type yielding the smallest sum of absolute differences for each row.
+		ft := byte(predictor - predictorNone)
+		if predictor == predictorOptimum {
+			best := -1
+			for t := byte(pngNone); t <= pngPaeth; t++ {
+				pngFilterRow(t, filtered, row, prev, bpp)
+				if sum := absSum(filtered); best < 0 || sum < best {
+					best, ft = sum, t
+				}
 			}
 		}
+
+		pngFilterRow(ft, filtered, row, prev, bpp)
+
+		out = append(out, ft)
+		out = append(out, filtered...)
+		prev = row
 	}
 
-	return bytes.NewBuffer(bufOut), nil
+	return bytes.NewReader(out), nil
+}
+
+func absSum(b []byte) (sum int) {
+	for _, c := range b {
+		sum += abs(int(int8(c)))
+	}
+	return
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func paeth(a, b, c byte) byte {
+
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+
+	if pa <= pb && pa <= pc {
+		return a
+	}
+
+	if pb <= pc {
+		return b
+	}
+
+	return c
+}
+
+// pngUnfilterRow reverses a PNG filter in place, see http://www.w3.org/TR/PNG-Filters.html
+func pngUnfilterRow(filterType byte, row, prev []byte, bpp int) error {
+
+	switch filterType {
+
+	case pngNone:
+
+	case pngSub:
+		for j := bpp; j < len(row); j++ {
+			row[j] += row[j-bpp]
+		}
+
+	case pngUp:
+		for j := range row {
+			row[j] += prev[j]
+		}
+
+	case pngAverage:
+		for j := range row {
+			var left byte
+			if j >= bpp {
+				left = row[j-bpp]
+			}
+			row[j] += byte((int(left) + int(prev[j])) / 2)
+		}
+
+	case pngPaeth:
+		for j := range row {
+			var left, upLeft byte
+			if j >= bpp {
+				left, upLeft = row[j-bpp], prev[j-bpp]
+			}
+			row[j] += paeth(left, prev[j], upLeft)
+		}
+
+	default:
+		return errFlatePostProcessing
+	}
+
+	return nil
+}
+
+// pngFilterRow applies a PNG filter to row and writes the result to out.
+func pngFilterRow(filterType byte, out, row, prev []byte, bpp int) {
+
+	for j := range row {
+
+		var left, upLeft byte
+		if j >= bpp {
+			left, upLeft = row[j-bpp], prev[j-bpp]
+		}
+
+		switch filterType {
+		case pngNone:
+			out[j] = row[j]
+		case pngSub:
+			out[j] = row[j] - left
+		case pngUp:
+			out[j] = row[j] - prev[j]
+		case pngAverage:
+			out[j] = row[j] - byte((int(left)+int(prev[j]))/2)
+		case pngPaeth:
+			out[j] = row[j] - paeth(left, prev[j], upLeft)
+		}
+	}
 }
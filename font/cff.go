@@ -0,0 +1,1361 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var errCorruptCFF = errors.New("font: corrupt CFF font program")
+
+// emptyCharString is a glyph without outline consisting of endchar only.
+var emptyCharString = []byte{14}
+
+// The standard strings of CFF, see Adobe Technical Note #5176, Appendix A.
+var cffStandardStrings = strings.Fields(`.notdef space exclam quotedbl numbersign dollar percent ampersand quoteright
+	parenleft parenright asterisk plus comma hyphen period slash zero one two three four five six seven eight nine
+	colon semicolon less equal greater question at A B C D E F G H I J K L M N O P Q R S T U V W X Y Z
+	bracketleft backslash bracketright asciicircum underscore quoteleft a b c d e f g h i j k l m n o p q r s t u v w x y z
+	braceleft bar braceright asciitilde exclamdown cent sterling fraction yen florin section currency quotesingle
+	quotedblleft guillemotleft guilsinglleft guilsinglright fi fl endash dagger daggerdbl periodcentered paragraph
+	bullet quotesinglbase quotedblbase quotedblright guillemotright ellipsis perthousand questiondown grave acute
+	circumflex tilde macron breve dotaccent dieresis ring cedilla hungarumlaut ogonek caron emdash AE ordfeminine
+	Lslash Oslash OE ordmasculine ae dotlessi lslash oslash oe germandbls onesuperior logicalnot mu trademark Eth
+	onehalf plusminus Thorn onequarter divide brokenbar degree thorn threequarters twosuperior registered minus eth
+	multiply threesuperior copyright Aacute Acircumflex Adieresis Agrave Aring Atilde Ccedilla Eacute Ecircumflex
+	Edieresis Egrave Iacute Icircumflex Idieresis Igrave Ntilde Oacute Ocircumflex Odieresis Ograve Otilde Scaron
+	Uacute Ucircumflex Udieresis Ugrave Yacute Ydieresis Zcaron aacute acircumflex adieresis agrave aring atilde
+	ccedilla eacute ecircumflex edieresis egrave iacute icircumflex idieresis igrave ntilde oacute ocircumflex
+	odieresis ograve otilde scaron uacute ucircumflex udieresis ugrave yacute ydieresis zcaron exclamsmall
+	Hungarumlautsmall dollaroldstyle dollarsuperior ampersandsmall Acutesmall parenleftsuperior parenrightsuperior
+	twodotenleader onedotenleader zerooldstyle oneoldstyle twooldstyle threeoldstyle fouroldstyle fiveoldstyle
+	sixoldstyle sevenoldstyle eightoldstyle nineoldstyle commasuperior threequartersemdash periodsuperior
+	questionsmall asuperior bsuperior centsuperior dsuperior esuperior isuperior lsuperior msuperior nsuperior
+	osuperior rsuperior ssuperior tsuperior ff ffi ffl parenleftinferior parenrightinferior Circumflexsmall
+	hyphensuperior Gravesmall Asmall Bsmall Csmall Dsmall Esmall Fsmall Gsmall Hsmall Ismall Jsmall Ksmall Lsmall
+	Msmall Nsmall Osmall Psmall Qsmall Rsmall Ssmall Tsmall Usmall Vsmall Wsmall Xsmall Ysmall Zsmall
+	colonmonetary onefitted rupiah Tildesmall exclamdownsmall centoldstyle Lslashsmall Scaronsmall Zcaronsmall
+	Dieresissmall Brevesmall Caronsmall Dotaccentsmall Macronsmall figuredash hypheninferior Ogoneksmall Ringsmall
+	Cedillasmall questiondownsmall oneeighth threeeighths fiveeighths seveneighths onethird twothirds zerosuperior
+	foursuperior fivesuperior sixsuperior sevensuperior eightsuperior ninesuperior zeroinferior oneinferior
+	twoinferior threeinferior fourinferior fiveinferior sixinferior seveninferior eightinferior nineinferior
+	centinferior dollarinferior periodinferior commainferior Agravesmall Aacutesmall Acircumflexsmall Atildesmall
+	Adieresissmall Aringsmall AEsmall Ccedillasmall Egravesmall Eacutesmall Ecircumflexsmall Edieresissmall
+	Igravesmall Iacutesmall Icircumflexsmall Idieresissmall Ethsmall Ntildesmall Ogravesmall Oacutesmall
+	Ocircumflexsmall Otildesmall Odieresissmall OEsmall Oslashsmall Ugravesmall Uacutesmall Ucircumflexsmall
+	Udieresissmall Yacutesmall Thornsmall Ydieresissmall 001.000 001.001 001.002 001.003 Black Bold Book Light
+	Medium Regular Roman Semibold`)
+
+// cffStandardSIDs maps the standard strings to their SIDs.
+var cffStandardSIDs = map[string]int{}
+
+func init() {
+	for sid, s := range cffStandardStrings {
+		cffStandardSIDs[s] = sid
+	}
+}
+
+// DICT operators, two byte operators are represented as 1200 + second byte.
+const (
+	opVersion       = 0
+	opNotice        = 1
+	opFullName      = 2
+	opFamilyName    = 3
+	opWeight        = 4
+	opUniqueID      = 13
+	opXUID          = 14
+	opCharset       = 15
+	opEncoding      = 16
+	opCharStrings   = 17
+	opPrivate       = 18
+	opSubrs         = 19
+	opDefaultWidthX = 20
+	opNominalWidthX = 21
+	opCopyright     = 1200
+	opCharstrType   = 1206
+	opPostScript    = 1221
+	opBaseFont      = 1222
+	opROS           = 1230
+	opFDArray       = 1236
+	opFDSelect      = 1237
+	opFontName      = 1238
+)
+
+// dictEntry is an operator along with its encoded operands.
+type dictEntry struct {
+	op       int
+	operands []byte
+}
+
+// cffPrivate is a Private DICT along with its local subroutines.
+type cffPrivate struct {
+	dict  []dictEntry
+	subrs []byte // encoded INDEX
+}
+
+// CFF is a parsed Compact Font Format font program, see Adobe Technical Note #5176.
+type CFF struct {
+	header      []byte
+	names       []byte // encoded Name INDEX
+	top         []dictEntry
+	strings     [][]byte
+	stringIndex []byte // encoded String INDEX
+	gsubrs      []byte // encoded Global Subr INDEX
+	charStrings [][]byte
+	charset     []int  // SID or CID by glyph id
+	charsetRaw  []byte // nil for predefined charsets
+	encodingRaw []byte // nil for predefined encodings
+	encoding    map[int]int
+	fdSelect    []byte
+	fdArray     [][]dictEntry
+	private     *cffPrivate
+	fdPrivates  []*cffPrivate
+	byRune      map[rune]int // glyph ids by Unicode, see standardGID
+}
+
+// cffIndex returns the items of the INDEX at off and the offset following it.
+func cffIndex(b []byte, off int) (items [][]byte, end int, err error) {
+
+	if off+2 > len(b) {
+		return nil, 0, errors.Wrap(errCorruptCFF, "INDEX")
+	}
+
+	count := u16(b, off)
+	if count == 0 {
+		return nil, off + 2, nil
+	}
+
+	if off+3 > len(b) {
+		return nil, 0, errors.Wrap(errCorruptCFF, "INDEX")
+	}
+
+	offSize := int(b[off+2])
+	offsets := off + 3
+	data := offsets + (count+1)*offSize - 1
+
+	if offSize < 1 || offSize > 4 || offsets+(count+1)*offSize > len(b) {
+		return nil, 0, errors.Wrap(errCorruptCFF, "INDEX")
+	}
+
+	offset := func(i int) int {
+		o := 0
+		for _, c := range b[offsets+i*offSize : offsets+(i+1)*offSize] {
+			o = o<<8 | int(c)
+		}
+		return data + o
+	}
+
+	for i := 0; i < count; i++ {
+		from, to := offset(i), offset(i+1)
+		if from > to || to > len(b) {
+			return nil, 0, errors.Wrap(errCorruptCFF, "INDEX")
+		}
+		items = append(items, b[from:to])
+	}
+
+	return items, offset(count), nil
+}
+
+func writeIndex(items [][]byte) []byte {
+
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+
+	offSize := 1
+	for size >= 1<<uint(8*offSize) {
+		offSize++
+	}
+
+	var b bytes.Buffer
+
+	binary.Write(&b, binary.BigEndian, uint16(len(items)))
+	b.WriteByte(byte(offSize))
+
+	o := 1
+	for i := 0; i <= len(items); i++ {
+		for j := offSize - 1; j >= 0; j-- {
+			b.WriteByte(byte(o >> uint(8*j)))
+		}
+		if i < len(items) {
+			o += len(items[i])
+		}
+	}
+
+	for _, item := range items {
+		b.Write(item)
+	}
+
+	return b.Bytes()
+}
+
+// parseDict splits a DICT into operators and their operands.
+func parseDict(b []byte) (entries []dictEntry, err error) {
+
+	start := 0
+
+	for i := 0; i < len(b); {
+
+		c := b[i]
+
+		switch {
+
+		case c <= 21:
+			op := int(c)
+			n := 1
+			if c == 12 {
+				if i+1 >= len(b) {
+					return nil, errors.Wrap(errCorruptCFF, "DICT")
+				}
+				op, n = 1200+int(b[i+1]), 2
+			}
+			entries = append(entries, dictEntry{op, b[start:i]})
+			i += n
+			start = i
+
+		case c == 28:
+			i += 3
+
+		case c == 29:
+			i += 5
+
+		case c == 30:
+			// A real number ends with a nibble 0xf.
+			for i++; i < len(b) && b[i]&0x0F != 0x0F && b[i]&0xF0 != 0xF0; i++ {
+			}
+			i++
+
+		case c >= 32 && c <= 246:
+			i++
+
+		case c >= 247 && c <= 254:
+			i += 2
+
+		default:
+			return nil, errors.Wrapf(errCorruptCFF, "DICT: reserved byte %d", c)
+		}
+	}
+
+	return entries, nil
+}
+
+// ints decodes integer operands.
+func ints(b []byte) (ii []int, err error) {
+
+	for i := 0; i < len(b); {
+
+		c := int(b[i])
+
+		switch {
+
+		case c >= 32 && c <= 246:
+			ii = append(ii, c-139)
+			i++
+
+		case c >= 247 && c <= 250 && i+1 < len(b):
+			ii = append(ii, (c-247)*256+int(b[i+1])+108)
+			i += 2
+
+		case c >= 251 && c <= 254 && i+1 < len(b):
+			ii = append(ii, -(c-251)*256-int(b[i+1])-108)
+			i += 2
+
+		case c == 28 && i+2 < len(b):
+			ii = append(ii, int(int16(u16(b, i+1))))
+			i += 3
+
+		case c == 29 && i+4 < len(b):
+			ii = append(ii, int(int32(u32(b, i+1))))
+			i += 5
+
+		default:
+			return nil, errors.Wrap(errCorruptCFF, "DICT: integer operand expected")
+		}
+	}
+
+	return ii, nil
+}
+
+// int5 encodes an integer operand using 5 bytes.
+func int5(ii ...int) []byte {
+
+	var b []byte
+	for _, i := range ii {
+		b = append(b, 29, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	}
+
+	return b
+}
+
+func writeDict(entries []dictEntry) []byte {
+
+	var b []byte
+
+	for _, e := range entries {
+		b = append(b, e.operands...)
+		if e.op >= 1200 {
+			b = append(b, 12, byte(e.op-1200))
+		} else {
+			b = append(b, byte(e.op))
+		}
+	}
+
+	return b
+}
+
+func lookup(entries []dictEntry, op int) ([]int, bool) {
+
+	for _, e := range entries {
+		if e.op == op {
+			ii, err := ints(e.operands)
+			return ii, err == nil
+		}
+	}
+
+	return nil, false
+}
+
+func parsePrivate(b []byte, entries []dictEntry) (*cffPrivate, error) {
+
+	ii, found := lookup(entries, opPrivate)
+	if !found {
+		return nil, nil
+	}
+
+	if len(ii) != 2 || ii[0] < 0 || ii[1] < 0 || ii[0]+ii[1] > len(b) {
+		return nil, errors.Wrap(errCorruptCFF, "Private")
+	}
+
+	size, off := ii[0], ii[1]
+
+	dict, err := parseDict(b[off : off+size])
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cffPrivate{dict: dict}
+
+	if ii, found := lookup(dict, opSubrs); found && len(ii) == 1 {
+		_, end, err := cffIndex(b, off+ii[0])
+		if err != nil {
+			return nil, err
+		}
+		p.subrs = b[off+ii[0] : end]
+	}
+
+	return p, nil
+}
+
+func (cff *CFF) parseCharset(b []byte, off, n int) (err error) {
+
+	cff.charset = make([]int, n)
+
+	// Predefined charsets: ISOAdobe, Expert, ExpertSubset.
+	if off <= 2 {
+		if off == 0 {
+			for gid := range cff.charset {
+				cff.charset[gid] = gid
+			}
+		}
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Wrap(errCorruptCFF, "charset")
+		}
+	}()
+
+	format := b[off]
+	i := off + 1
+
+	for gid := 1; gid < n; {
+		switch format {
+		case 0:
+			cff.charset[gid] = u16(b, i)
+			gid++
+			i += 2
+		case 1, 2:
+			first, nLeft := u16(b, i), int(b[i+2])
+			i += 3
+			if format == 2 {
+				nLeft = u16(b, i-1)
+				i++
+			}
+			for j := 0; j <= nLeft && gid < n; j++ {
+				cff.charset[gid] = first + j
+				gid++
+			}
+		default:
+			return errors.Wrapf(errCorruptCFF, "charset format %d", format)
+		}
+	}
+
+	cff.charsetRaw = b[off:i]
+
+	return nil
+}
+
+func (cff *CFF) parseEncoding(b []byte, off int) (err error) {
+
+	cff.encoding = map[int]int{}
+
+	// Predefined encodings: Standard, Expert.
+	if off <= 1 {
+		if off == 0 {
+			for c := range StandardEncoding {
+				if gid, found := cff.standardGID(c); found {
+					cff.encoding[c] = gid
+				}
+			}
+		}
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Wrap(errCorruptCFF, "encoding")
+		}
+	}()
+
+	format := b[off]
+	i := off + 1
+
+	switch format & 0x7F {
+
+	case 0:
+		n := int(b[i])
+		for j := 0; j < n; j++ {
+			cff.encoding[int(b[i+1+j])] = j + 1
+		}
+		i += 1 + n
+
+	case 1:
+		n := int(b[i])
+		gid := 1
+		for j := 0; j < n; j++ {
+			first, nLeft := int(b[i+1+2*j]), int(b[i+2+2*j])
+			for k := 0; k <= nLeft; k++ {
+				cff.encoding[first+k] = gid
+				gid++
+			}
+		}
+		i += 1 + 2*n
+
+	default:
+		return errors.Wrapf(errCorruptCFF, "encoding format %d", format)
+	}
+
+	// Supplements map additional codes to glyphs by SID.
+	if format&0x80 > 0 {
+		n := int(b[i])
+		for j := 0; j < n; j++ {
+			c, sid := int(b[i+1+3*j]), u16(b, i+2+3*j)
+			for gid, s := range cff.charset {
+				if s == sid {
+					cff.encoding[c] = gid
+					break
+				}
+			}
+		}
+		i += 1 + 3*n
+	}
+
+	cff.encodingRaw = b[off:i]
+
+	return nil
+}
+
+func (cff *CFF) parseFDSelect(b []byte, off int) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Wrap(errCorruptCFF, "FDSelect")
+		}
+	}()
+
+	switch b[off] {
+
+	case 0:
+		cff.fdSelect = b[off : off+1+len(cff.charStrings)]
+
+	case 3:
+		n := u16(b, off+1)
+		cff.fdSelect = b[off : off+5+3*n]
+
+	default:
+		return errors.Wrapf(errCorruptCFF, "FDSelect format %d", b[off])
+	}
+
+	return nil
+}
+
+// ParseCFF parses a CFF font program containing a single font.
+func ParseCFF(b []byte) (cff *CFF, err error) {
+
+	if len(b) < 4 || int(b[2]) > len(b) {
+		return nil, errCorruptCFF
+	}
+
+	cff = &CFF{header: b[:b[2]]}
+
+	off := int(b[2])
+
+	names, end, err := cffIndex(b, off)
+	if err != nil {
+		return
+	}
+	if len(names) != 1 {
+		return nil, errors.New("font: CFF font sets are not supported")
+	}
+	cff.names = b[off:end]
+
+	topDicts, end, err := cffIndex(b, end)
+	if err != nil {
+		return
+	}
+	if len(topDicts) != 1 {
+		return nil, errors.Wrap(errCorruptCFF, "Top DICT")
+	}
+
+	cff.top, err = parseDict(topDicts[0])
+	if err != nil {
+		return
+	}
+
+	if ii, found := lookup(cff.top, opCharstrType); found && (len(ii) != 1 || ii[0] != 2) {
+		return nil, errors.New("font: unsupported CFF charstring type")
+	}
+
+	off = end
+	cff.strings, end, err = cffIndex(b, off)
+	if err != nil {
+		return
+	}
+	cff.stringIndex = b[off:end]
+
+	off = end
+	_, end, err = cffIndex(b, off)
+	if err != nil {
+		return
+	}
+	cff.gsubrs = b[off:end]
+
+	ii, found := lookup(cff.top, opCharStrings)
+	if !found || len(ii) != 1 {
+		return nil, errors.Wrap(errCorruptCFF, "CharStrings")
+	}
+
+	cff.charStrings, _, err = cffIndex(b, ii[0])
+	if err != nil {
+		return
+	}
+
+	charsetOff := 0
+	if ii, found := lookup(cff.top, opCharset); found && len(ii) == 1 {
+		charsetOff = ii[0]
+	}
+
+	err = cff.parseCharset(b, charsetOff, len(cff.charStrings))
+	if err != nil {
+		return
+	}
+
+	if cff.IsCIDFont() {
+
+		ii, found := lookup(cff.top, opFDSelect)
+		if !found || len(ii) != 1 {
+			return nil, errors.Wrap(errCorruptCFF, "FDSelect")
+		}
+
+		err = cff.parseFDSelect(b, ii[0])
+		if err != nil {
+			return
+		}
+
+		ii, found = lookup(cff.top, opFDArray)
+		if !found || len(ii) != 1 {
+			return nil, errors.Wrap(errCorruptCFF, "FDArray")
+		}
+
+		fds, _, err := cffIndex(b, ii[0])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fd := range fds {
+
+			dict, err := parseDict(fd)
+			if err != nil {
+				return nil, err
+			}
+
+			p, err := parsePrivate(b, dict)
+			if err != nil {
+				return nil, err
+			}
+
+			cff.fdArray = append(cff.fdArray, dict)
+			cff.fdPrivates = append(cff.fdPrivates, p)
+		}
+
+	} else {
+
+		encodingOff := 0
+		if ii, found := lookup(cff.top, opEncoding); found && len(ii) == 1 {
+			encodingOff = ii[0]
+		}
+
+		err = cff.parseEncoding(b, encodingOff)
+		if err != nil {
+			return
+		}
+	}
+
+	cff.private, err = parsePrivate(b, cff.top)
+
+	return
+}
+
+// NumGlyphs returns the number of glyphs.
+func (cff *CFF) NumGlyphs() int {
+	return len(cff.charStrings)
+}
+
+// IsCIDFont returns true for CID-keyed fonts.
+func (cff *CFF) IsCIDFont() bool {
+	_, found := lookup(cff.top, opROS)
+	return found
+}
+
+// GlyphName returns the name of a glyph of a font that is not CID-keyed.
+func (cff *CFF) GlyphName(gid int) string {
+
+	if gid < 0 || gid >= len(cff.charset) {
+		return ""
+	}
+
+	sid := cff.charset[gid]
+
+	if sid < len(cffStandardStrings) {
+		return cffStandardStrings[sid]
+	}
+
+	if sid -= len(cffStandardStrings); sid < len(cff.strings) {
+		return string(cff.strings[sid])
+	}
+
+	return ""
+}
+
+// GID returns the glyph id for a CID of a CID-keyed font.
+func (cff *CFF) GID(cid int) (int, bool) {
+
+	for gid, c := range cff.charset {
+		if c == cid {
+			return gid, true
+		}
+	}
+
+	return 0, false
+}
+
+// standardGID returns the glyph id for a code of StandardEncoding.
+func (cff *CFF) standardGID(c int) (int, bool) {
+
+	if cff.byRune == nil {
+		cff.byRune = map[rune]int{}
+		for gid := len(cff.charset) - 1; gid > 0; gid-- {
+			name := cff.GlyphName(gid)
+			if strings.Contains(name, ".") {
+				continue
+			}
+			if r, ok := GlyphRune(name); ok {
+				cff.byRune[r] = gid
+			}
+		}
+	}
+
+	if c < 0 || c >= len(StandardEncoding) || StandardEncoding[c] == 0 {
+		return 0, false
+	}
+
+	gid, found := cff.byRune[StandardEncoding[c]]
+
+	return gid, found
+}
+
+func subrBias(n int) int {
+
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+
+	return 32768
+}
+
+// accentComponents returns the base and accent glyph of a glyph composed
+// by an endchar operator taking the arguments of the Type 1 seac operator.
+// This is done by interpreting the charstring as far as needed for its operands, hints and subroutine calls.
+func (cff *CFF) accentComponents(gid int) (components []int) {
+
+	if cff.IsCIDFont() {
+		return nil
+	}
+
+	gsubrs, _, _ := cffIndex(cff.gsubrs, 0)
+
+	var lsubrs [][]byte
+	if cff.private != nil && cff.private.subrs != nil {
+		lsubrs, _, _ = cffIndex(cff.private.subrs, 0)
+	}
+
+	var (
+		stack  []int
+		nStems int
+	)
+
+	var run func(cs []byte, depth int) (end bool)
+
+	run = func(cs []byte, depth int) bool {
+
+		if depth > 10 {
+			return true
+		}
+
+		for i := 0; i < len(cs); {
+
+			c := int(cs[i])
+
+			switch {
+
+			case c >= 32 && c <= 246:
+				stack = append(stack, c-139)
+				i++
+
+			case c >= 247 && c <= 250 && i+1 < len(cs):
+				stack = append(stack, (c-247)*256+int(cs[i+1])+108)
+				i += 2
+
+			case c >= 251 && c <= 254 && i+1 < len(cs):
+				stack = append(stack, -(c-251)*256-int(cs[i+1])-108)
+				i += 2
+
+			case c == 28 && i+2 < len(cs):
+				stack = append(stack, int(int16(u16(cs, i+1))))
+				i += 3
+
+			case c == 255 && i+4 < len(cs):
+				// 16.16 fixed
+				stack = append(stack, int(int32(u32(cs, i+1)))>>16)
+				i += 5
+
+			// hstem, vstem, hstemhm, vstemhm
+			case c == 1 || c == 3 || c == 18 || c == 23:
+				nStems += len(stack) / 2
+				stack = nil
+				i++
+
+			// hintmask, cntrmask with optional implicit vstem
+			case c == 19 || c == 20:
+				nStems += len(stack) / 2
+				stack = nil
+				i += 1 + (nStems+7)/8
+
+			// callsubr, callgsubr
+			case c == 10 || c == 29:
+				if len(stack) == 0 {
+					return true
+				}
+				subrs := lsubrs
+				if c == 29 {
+					subrs = gsubrs
+				}
+				j := stack[len(stack)-1] + subrBias(len(subrs))
+				stack = stack[:len(stack)-1]
+				if j < 0 || j >= len(subrs) || run(subrs[j], depth+1) {
+					return true
+				}
+				i++
+
+			// return
+			case c == 11:
+				return false
+
+			// endchar
+			case c == 14:
+				if n := len(stack); n >= 4 {
+					for _, code := range stack[n-2:] {
+						if gid, found := cff.standardGID(code); found {
+							components = append(components, gid)
+						}
+					}
+				}
+				return true
+
+			case c == 12:
+				stack = nil
+				i += 2
+
+			default:
+				stack = nil
+				i++
+			}
+		}
+
+		return false
+	}
+
+	if gid >= 0 && gid < len(cff.charStrings) {
+		run(cff.charStrings[gid], 0)
+	}
+
+	return components
+}
+
+// Encoding returns the built-in encoding of a font that is not CID-keyed as mapping from codes to glyph ids.
+func (cff *CFF) Encoding() map[int]int {
+	return cff.encoding
+}
+
+// bytes returns the encoded Private DICT followed by its local subroutines.
+func (p *cffPrivate) bytes() []byte {
+
+	var dict []dictEntry
+	for _, e := range p.dict {
+		if e.op != opSubrs {
+			dict = append(dict, e)
+		}
+	}
+
+	if p.subrs == nil {
+		return writeDict(dict)
+	}
+
+	// Local subroutines follow the Private DICT.
+	size := len(writeDict(dict)) + len(int5(0)) + 1
+	dict = append(dict, dictEntry{opSubrs, int5(size)})
+
+	return append(writeDict(dict), p.subrs...)
+}
+
+// widths returns defaultWidthX and nominalWidthX of a Private DICT.
+func (p *cffPrivate) widths() (defaultWidth, nominalWidth int, err error) {
+
+	if p == nil {
+		return 0, 0, nil
+	}
+
+	for _, e := range p.dict {
+		if e.op != opDefaultWidthX && e.op != opNominalWidthX {
+			continue
+		}
+		ii, err := ints(e.operands)
+		if err != nil || len(ii) != 1 {
+			return 0, 0, errors.Wrap(errCorruptCFF, "Private DICT: width")
+		}
+		if e.op == opDefaultWidthX {
+			defaultWidth = ii[0]
+		} else {
+			nominalWidth = ii[0]
+		}
+	}
+
+	return
+}
+
+// withoutWidths returns the encoded Private DICT and local subroutines except for defaultWidthX and nominalWidthX.
+func (p *cffPrivate) withoutWidths() []byte {
+
+	if p == nil {
+		return nil
+	}
+
+	q := cffPrivate{subrs: p.subrs}
+	for _, e := range p.dict {
+		if e.op != opDefaultWidthX && e.op != opNominalWidthX {
+			q.dict = append(q.dict, e)
+		}
+	}
+
+	return q.bytes()
+}
+
+// csNumber returns the value and the length of the number at the beginning of a charstring or 0 if there is none.
+func csNumber(cs []byte) (v, n int) {
+
+	if len(cs) == 0 {
+		return 0, 0
+	}
+
+	c := int(cs[0])
+
+	switch {
+
+	case c >= 32 && c <= 246:
+		return c - 139, 1
+
+	case c >= 247 && c <= 250 && len(cs) > 1:
+		return (c-247)*256 + int(cs[1]) + 108, 2
+
+	case c >= 251 && c <= 254 && len(cs) > 1:
+		return -(c-251)*256 - int(cs[1]) - 108, 2
+
+	case c == 28 && len(cs) > 2:
+		return int(int16(u16(cs, 1))), 3
+
+	case c == 255 && len(cs) > 4:
+		// 16.16 fixed
+		return int(int32(u32(cs, 1))) >> 16, 5
+	}
+
+	return 0, 0
+}
+
+// csEncode returns the charstring encoding of an integer.
+func csEncode(v int) []byte {
+
+	switch {
+
+	case v >= -107 && v <= 107:
+		return []byte{byte(v + 139)}
+
+	case v >= 108 && v <= 1131:
+		v -= 108
+		return []byte{byte(v>>8 + 247), byte(v)}
+
+	case v >= -1131 && v <= -108:
+		v = -v - 108
+		return []byte{byte(v>>8 + 251), byte(v)}
+	}
+
+	return []byte{28, byte(v >> 8), byte(v)}
+}
+
+// rebaseWidth returns a charstring with its advance width encoded for other values of defaultWidthX and nominalWidthX.
+// The width is an optional first operand of the first stack clearing operator, see Adobe Technical Note #5177, 3.1.
+func rebaseWidth(cs []byte, fromDefault, fromNominal, toDefault, toNominal int) ([]byte, error) {
+
+	var (
+		first, firstLen int
+		args            int
+		i               int
+	)
+
+	for i < len(cs) {
+		v, n := csNumber(cs[i:])
+		if n == 0 {
+			break
+		}
+		if args == 0 {
+			first, firstLen = v, n
+		}
+		args++
+		i += n
+	}
+
+	if i >= len(cs) {
+		return nil, errors.Wrap(errCorruptCFF, "charstring")
+	}
+
+	var hasWidth bool
+
+	switch cs[i] {
+
+	// hstem, vstem, hintmask, cntrmask, hstemhm, vstemhm
+	case 1, 3, 18, 19, 20, 23:
+		hasWidth = args%2 == 1
+
+	// rmoveto
+	case 21:
+		hasWidth = args == 3
+
+	// hmoveto, vmoveto
+	case 4, 22:
+		hasWidth = args == 2
+
+	// endchar
+	case 14:
+		hasWidth = args == 1 || args == 5
+
+	default:
+		return nil, errors.New("font: charstring starting with subroutine call")
+	}
+
+	width, rest := fromDefault, cs
+	if hasWidth {
+		if cs[0] == 255 && u16(cs, 3) != 0 {
+			return nil, errors.New("font: fractional charstring width")
+		}
+		width, rest = fromNominal+first, cs[firstLen:]
+	}
+
+	if width == toDefault {
+		return append([]byte{}, rest...), nil
+	}
+
+	return append(csEncode(width-toNominal), rest...), nil
+}
+
+func (p *cffPrivate) dictSize() int {
+	return len(p.bytes()) - len(p.subrs)
+}
+
+// withOffsets returns a copy of a DICT with the operands of given operators replaced.
+func withOffsets(entries []dictEntry, offsets map[int][]byte) []dictEntry {
+
+	var dict []dictEntry
+
+	for _, e := range entries {
+		if e.op == opUniqueID || e.op == opXUID {
+			// A subset must not claim the identity of the original font.
+			continue
+		}
+		if o, found := offsets[e.op]; found {
+			e.operands = o
+		}
+		dict = append(dict, e)
+	}
+
+	return dict
+}
+
+// Subset returns a font program containing only the glyphs in gids, .notdef and any accent components.
+// Glyph ids are retained with unused glyphs left empty.
+func (cff *CFF) Subset(gids map[int]bool) []byte {
+
+	used := map[int]bool{0: true}
+	for gid := range gids {
+		used[gid] = true
+		for _, c := range cff.accentComponents(gid) {
+			used[c] = true
+		}
+	}
+
+	charStrings := make([][]byte, len(cff.charStrings))
+	for gid, cs := range cff.charStrings {
+		charStrings[gid] = cs
+		if !used[gid] {
+			charStrings[gid] = emptyCharString
+		}
+	}
+
+	return cff.write(charStrings)
+}
+
+// write returns the font program using given charstrings.
+func (cff *CFF) write(charStrings [][]byte) []byte {
+
+	charStringIndex := writeIndex(charStrings)
+
+	var privates [][]byte
+	if cff.private != nil {
+		privates = append(privates, cff.private.bytes())
+	}
+	for _, p := range cff.fdPrivates {
+		if p != nil {
+			privates = append(privates, p.bytes())
+		}
+	}
+
+	// Offset operands are written using 5 bytes each so the DICT sizes do not depend on the final layout.
+	layout := func(offsets map[int][]byte, fdOffsets []map[int][]byte) (top, fdArray []byte) {
+		top = writeIndex([][]byte{writeDict(withOffsets(cff.top, offsets))})
+		var fds [][]byte
+		for i, fd := range cff.fdArray {
+			fds = append(fds, writeDict(withOffsets(fd, fdOffsets[i])))
+		}
+		return top, writeIndex(fds)
+	}
+
+	zero := map[int][]byte{opCharset: int5(0), opEncoding: int5(0), opCharStrings: int5(0), opPrivate: int5(0, 0), opFDArray: int5(0), opFDSelect: int5(0)}
+	fdZero := make([]map[int][]byte, len(cff.fdArray))
+	for i := range fdZero {
+		fdZero[i] = map[int][]byte{opPrivate: int5(0, 0)}
+	}
+
+	top, fdArray := layout(zero, fdZero)
+
+	offsets := map[int][]byte{}
+	off := len(cff.header) + len(cff.names) + len(top) + len(cff.stringIndex) + len(cff.gsubrs)
+
+	if cff.charsetRaw != nil {
+		offsets[opCharset] = int5(off)
+		off += len(cff.charsetRaw)
+	}
+
+	if cff.encodingRaw != nil {
+		offsets[opEncoding] = int5(off)
+		off += len(cff.encodingRaw)
+	}
+
+	if cff.fdSelect != nil {
+		offsets[opFDSelect] = int5(off)
+		off += len(cff.fdSelect)
+	}
+
+	offsets[opCharStrings] = int5(off)
+	off += len(charStringIndex)
+
+	if cff.fdArray != nil {
+		offsets[opFDArray] = int5(off)
+		off += len(fdArray)
+	}
+
+	fdOffsets := make([]map[int][]byte, len(cff.fdArray))
+
+	if cff.private != nil {
+		offsets[opPrivate] = int5(cff.private.dictSize(), off)
+		off += len(cff.private.bytes())
+	}
+
+	for i, p := range cff.fdPrivates {
+		fdOffsets[i] = map[int][]byte{}
+		if p != nil {
+			fdOffsets[i][opPrivate] = int5(p.dictSize(), off)
+			off += len(p.bytes())
+		}
+	}
+
+	top, fdArray = layout(offsets, fdOffsets)
+
+	var b bytes.Buffer
+
+	for _, s := range [][]byte{cff.header, cff.names, top, cff.stringIndex, cff.gsubrs, cff.charsetRaw, cff.encodingRaw, cff.fdSelect, charStringIndex} {
+		b.Write(s)
+	}
+
+	if cff.fdArray != nil {
+		b.Write(fdArray)
+	}
+
+	for _, p := range privates {
+		b.Write(p)
+	}
+
+	return b.Bytes()
+}
+
+// sid returns the SID for a string, appending it to the strings of cff if needed.
+func (cff *CFF) sid(s string) int {
+
+	if sid, found := cffStandardSIDs[s]; found {
+		return sid
+	}
+
+	for i, t := range cff.strings {
+		if string(t) == s {
+			return len(cffStandardStrings) + i
+		}
+	}
+
+	cff.strings = append(cff.strings, []byte(s))
+
+	return len(cffStandardStrings) + len(cff.strings) - 1
+}
+
+// glyphDict returns the Top DICT without entries referring to strings or other structures,
+// which is what subsets of the same font have in common.
+func (cff *CFF) glyphDict() []byte {
+
+	var dict []dictEntry
+
+	for _, e := range cff.top {
+		switch e.op {
+		case opVersion, opNotice, opFullName, opFamilyName, opWeight, opCopyright, opPostScript, opBaseFont, opFontName,
+			opUniqueID, opXUID, opCharset, opEncoding, opCharStrings, opPrivate:
+			continue
+		}
+		dict = append(dict, e)
+	}
+
+	return writeDict(dict)
+}
+
+// rebasedCharStrings returns the charstrings of all fonts with widths encoded according to the Private DICT of the first font.
+// Charstrings remain untouched if all fonts agree on defaultWidthX and nominalWidthX.
+func rebasedCharStrings(fonts []*CFF) ([][][]byte, error) {
+
+	dw, nw, err := fonts[0].private.widths()
+	if err != nil {
+		return nil, err
+	}
+
+	same := true
+	for _, f := range fonts[1:] {
+		d, n, err := f.private.widths()
+		if err != nil {
+			return nil, err
+		}
+		if d != dw || n != nw {
+			same = false
+		}
+	}
+
+	charStrings := make([][][]byte, len(fonts))
+
+	for i, f := range fonts {
+
+		if same {
+			charStrings[i] = f.charStrings
+			continue
+		}
+
+		d, n, _ := f.private.widths()
+
+		charStrings[i] = make([][]byte, len(f.charStrings))
+		for gid, cs := range f.charStrings {
+			if bytes.Equal(cs, emptyCharString) {
+				charStrings[i][gid] = cs
+				continue
+			}
+			if charStrings[i][gid], err = rebaseWidth(cs, d, n, dw, nw); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return charStrings, nil
+}
+
+// MergeCFF returns a font program holding the glyphs of all given subsets of a font that is not CID-keyed.
+// Glyphs are identified by name and have to be identical if present with an outline in more than one subset.
+// The remaining structures are taken from the first subset.
+func MergeCFF(fonts []*CFF) (buf []byte, err error) {
+
+	if len(fonts) == 0 {
+		return nil, errors.New("font: no fonts to merge")
+	}
+
+	base := fonts[0]
+
+	for _, f := range fonts {
+
+		if f.IsCIDFont() {
+			return nil, errors.New("font: merging CID-keyed CFF fonts is not supported")
+		}
+
+		if ii, found := lookup(f.top, opCharset); f.charsetRaw == nil && found && len(ii) == 1 && ii[0] != 0 {
+			return nil, errors.New("font: merging CFF fonts using expert charsets is not supported")
+		}
+
+		if ii, found := lookup(f.top, opEncoding); f.encodingRaw == nil && found && len(ii) == 1 && ii[0] != 0 {
+			return nil, errors.New("font: merging CFF fonts using expert encoding is not supported")
+		}
+
+		if !bytes.Equal(f.gsubrs, base.gsubrs) || !bytes.Equal(f.glyphDict(), base.glyphDict()) ||
+			(f.private == nil) != (base.private == nil) || !bytes.Equal(f.private.withoutWidths(), base.private.withoutWidths()) {
+			return nil, errors.New("font: subsets differ in global data")
+		}
+	}
+
+	// Subsets may have been optimized for different nominal widths.
+	fontCharStrings, err := rebasedCharStrings(fonts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := *base
+	m.strings = append([][]byte{}, base.strings...)
+
+	var (
+		names       []string
+		charStrings [][]byte
+	)
+
+	gids := map[string]int{}
+	encoding := map[int]string{}
+	custom := false
+
+	for i, f := range fonts {
+
+		if f.encodingRaw != nil {
+			custom = true
+		}
+
+		for gid, cs := range fontCharStrings[i] {
+
+			name := f.GlyphName(gid)
+			if gid == 0 {
+				name = ".notdef"
+			}
+
+			g, found := gids[name]
+			if !found {
+				gids[name] = len(names)
+				names = append(names, name)
+				charStrings = append(charStrings, cs)
+				continue
+			}
+
+			if bytes.Equal(cs, emptyCharString) {
+				continue
+			}
+
+			if !bytes.Equal(charStrings[g], emptyCharString) && !bytes.Equal(charStrings[g], cs) {
+				return nil, errors.Errorf("font: subsets differ in glyph %s", name)
+			}
+
+			charStrings[g] = cs
+		}
+
+		for c, gid := range f.encoding {
+			name := f.GlyphName(gid)
+			if n, found := encoding[c]; found && n != name {
+				return nil, errors.Errorf("font: subsets differ in encoding of code %d", c)
+			}
+			encoding[c] = name
+		}
+	}
+
+	// Charset format 0
+	m.charset = []int{0}
+	charset := []byte{0}
+	for _, name := range names[1:] {
+		sid := m.sid(name)
+		m.charset = append(m.charset, sid)
+		charset = append(charset, byte(sid>>8), byte(sid))
+	}
+	m.charsetRaw = charset
+	m.stringIndex = writeIndex(m.strings)
+
+	m.top = append([]dictEntry{}, base.top...)
+	if _, found := lookup(m.top, opCharset); !found {
+		m.top = append(m.top, dictEntry{opCharset, int5(0)})
+	}
+
+	if custom {
+		// Encoding format 0 without codes followed by supplements mapping codes to glyph names.
+		var codes []int
+		for c := range encoding {
+			codes = append(codes, c)
+		}
+		sort.Ints(codes)
+		if len(codes) > 255 {
+			codes = codes[:255]
+		}
+		enc := []byte{0x80, 0, byte(len(codes))}
+		for _, c := range codes {
+			sid := m.sid(encoding[c])
+			enc = append(enc, byte(c), byte(sid>>8), byte(sid))
+		}
+		m.encodingRaw = enc
+		m.stringIndex = writeIndex(m.strings)
+		if _, found := lookup(m.top, opEncoding); !found {
+			m.top = append(m.top, dictEntry{opEncoding, int5(0)})
+		}
+	}
+
+	return m.write(charStrings), nil
+}
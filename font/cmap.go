@@ -0,0 +1,206 @@
+package font
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// CodespaceRange is a range of character codes of a given length.
+type CodespaceRange struct {
+	Low, High []byte
+}
+
+// Contains returns true if b is a character code within r.
+func (r CodespaceRange) Contains(b []byte) bool {
+
+	if len(b) != len(r.Low) {
+		return false
+	}
+
+	for i := range b {
+		if b[i] < r.Low[i] || b[i] > r.High[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CMap is a ToUnicode CMap mapping character codes to text, see 9.10.3 ToUnicode CMaps.
+type CMap struct {
+	Codespace []CodespaceRange
+	Chars     map[string]string // by character code bytes
+}
+
+func code(b []byte) (c int) {
+	for _, v := range b {
+		c = c<<8 | int(v)
+	}
+	return
+}
+
+// codeBytes returns the n byte representation of a character code.
+func codeBytes(c, n int) []byte {
+
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(c)
+		c >>= 8
+	}
+
+	return b
+}
+
+func utf16String(b []byte) string {
+
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+
+	return string(utf16.Decode(u))
+}
+
+// addRange maps the codes from low to high to consecutive destinations starting at dst.
+func (m *CMap) addRange(low, high, dst []byte) {
+
+	if len(low) != len(high) || len(dst) == 0 {
+		return
+	}
+
+	lo, hi := code(low), code(high)
+
+	d := make([]byte, len(dst))
+	copy(d, dst)
+
+	for c := lo; c <= hi && c-lo < 0x10000; c++ {
+		m.Chars[string(codeBytes(c, len(low)))] = utf16String(d)
+		// Increment the last byte of the destination.
+		d[len(d)-1]++
+	}
+}
+
+// ParseCMap parses a ToUnicode CMap.
+func ParseCMap(buf []byte) *CMap {
+
+	m := &CMap{Chars: map[string]string{}}
+
+	sc := content.NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err != nil {
+			if err != io.EOF {
+				logDebugFont.Printf("ParseCMap: %v\n", err)
+			}
+			break
+		}
+
+		operands := op.Operands
+
+		switch op.Operator {
+
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := content.StringBytes(operands[i])
+				hi, ok2 := content.StringBytes(operands[i+1])
+				if ok1 && ok2 && len(lo) == len(hi) {
+					m.Codespace = append(m.Codespace, CodespaceRange{lo, hi})
+				}
+			}
+
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := content.StringBytes(operands[i])
+				dst, ok2 := content.StringBytes(operands[i+1])
+				if ok1 && ok2 {
+					m.Chars[string(src)] = utf16String(dst)
+				}
+			}
+
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := content.StringBytes(operands[i])
+				hi, ok2 := content.StringBytes(operands[i+1])
+				if !ok1 || !ok2 {
+					continue
+				}
+				if dst, ok := operands[i+2].(types.PDFArray); ok {
+					for j, c := 0, code(lo); j < len(dst) && c <= code(hi); j, c = j+1, c+1 {
+						if d, ok := content.StringBytes(dst[j]); ok {
+							m.Chars[string(codeBytes(c, len(lo)))] = utf16String(d)
+						}
+					}
+					continue
+				}
+				if dst, ok := content.StringBytes(operands[i+2]); ok {
+					m.addRange(lo, hi, dst)
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+func hexString(b []byte) string {
+	return fmt.Sprintf("<%X>", b)
+}
+
+// Bytes returns the serialized ToUnicode CMap.
+func (m *CMap) Bytes() []byte {
+
+	var codes []string
+	for c := range m.Chars {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+
+	codespace := m.Codespace
+	if len(codespace) == 0 && len(codes) > 0 {
+		n := len(codes[0])
+		codespace = []CodespaceRange{{bytes.Repeat([]byte{0x00}, n), bytes.Repeat([]byte{0xFF}, n)}}
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+
+	fmt.Fprintf(&b, "%d begincodespacerange\n", len(codespace))
+	for _, r := range codespace {
+		fmt.Fprintf(&b, "%s %s\n", hexString(r.Low), hexString(r.High))
+	}
+	b.WriteString("endcodespacerange\n")
+
+	// At most 100 mappings are allowed per block.
+	for i := 0; i < len(codes); i += 100 {
+
+		j := i + 100
+		if j > len(codes) {
+			j = len(codes)
+		}
+
+		fmt.Fprintf(&b, "%d beginbfchar\n", j-i)
+		for _, c := range codes[i:j] {
+			var dst []byte
+			for _, u := range utf16.Encode([]rune(m.Chars[c])) {
+				dst = append(dst, byte(u>>8), byte(u))
+			}
+			fmt.Fprintf(&b, "%s %s\n", hexString([]byte(c)), hexString(dst))
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+
+	return b.Bytes()
+}
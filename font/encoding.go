@@ -0,0 +1,156 @@
+package font
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Encoding maps single byte character codes to Unicode.
+type Encoding [256]rune
+
+// macRomanHigh holds the MacRomanEncoding code points 0x80 - 0xFF.
+const macRomanHigh = "" +
+	"ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü" +
+	"†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+	"¿¡¬√ƒ≈∆«»…\u00a0ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄¤‹›ﬁﬂ" +
+	"‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ\uf8ffÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ"
+
+// The StandardEncoding code points 0xA1 - 0xFB, see Annex D.
+var standardHigh = map[byte]rune{
+	0xA1: '¡', 0xA2: '¢', 0xA3: '£', 0xA4: '⁄', 0xA5: '¥', 0xA6: 'ƒ', 0xA7: '§', 0xA8: '¤',
+	0xA9: '\'', 0xAA: '“', 0xAB: '«', 0xAC: '‹', 0xAD: '›', 0xAE: 'ﬁ', 0xAF: 'ﬂ', 0xB1: '–',
+	0xB2: '†', 0xB3: '‡', 0xB4: '·', 0xB6: '¶', 0xB7: '•', 0xB8: '‚', 0xB9: '„', 0xBA: '”',
+	0xBB: '»', 0xBC: '…', 0xBD: '‰', 0xBF: '¿', 0xC1: '`', 0xC2: '´', 0xC3: 'ˆ', 0xC4: '˜',
+	0xC5: '¯', 0xC6: '˘', 0xC7: '˙', 0xC8: '¨', 0xCA: '˚', 0xCB: '¸', 0xCD: '˝', 0xCE: '˛',
+	0xCF: 'ˇ', 0xD0: '—', 0xE1: 'Æ', 0xE3: 'ª', 0xE8: 'Ł', 0xE9: 'Ø', 0xEA: 'Œ', 0xEB: 'º',
+	0xF1: 'æ', 0xF5: 'ı', 0xF8: 'ł', 0xF9: 'ø', 0xFA: 'œ', 0xFB: 'ß',
+}
+
+// The predefined simple font encodings.
+var (
+	WinAnsiEncoding, MacRomanEncoding, StandardEncoding Encoding
+)
+
+// Glyph names of the ASCII code points 0x20 - 0x7E.
+var asciiGlyphNames = strings.Fields(`space exclam quotedbl numbersign dollar percent ampersand quotesingle
+	parenleft parenright asterisk plus comma hyphen period slash
+	zero one two three four five six seven eight nine colon semicolon less equal greater question
+	at A B C D E F G H I J K L M N O P Q R S T U V W X Y Z bracketleft backslash bracketright asciicircum underscore
+	grave a b c d e f g h i j k l m n o p q r s t u v w x y z braceleft bar braceright asciitilde`)
+
+// Glyph names of the Latin-1 code points 0xA1 - 0xFF.
+var latin1GlyphNames = strings.Fields(`exclamdown cent sterling currency yen brokenbar section dieresis
+	copyright ordfeminine guillemotleft logicalnot hyphen registered macron
+	degree plusminus twosuperior threesuperior acute mu paragraph periodcentered
+	cedilla onesuperior ordmasculine guillemotright onequarter onehalf threequarters questiondown
+	Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla
+	Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis
+	Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply
+	Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls
+	agrave aacute acircumflex atilde adieresis aring ae ccedilla
+	egrave eacute ecircumflex edieresis igrave iacute icircumflex idieresis
+	eth ntilde ograve oacute ocircumflex otilde odieresis divide
+	oslash ugrave uacute ucircumflex udieresis yacute thorn ydieresis`)
+
+// glyphRunes maps the glyph names used by the predefined encodings to Unicode.
+var glyphRunes = map[string]rune{
+	"Euro": '€', "quotesinglbase": '‚', "florin": 'ƒ', "quotedblbase": '„', "ellipsis": '…',
+	"dagger": '†', "daggerdbl": '‡', "circumflex": 'ˆ', "perthousand": '‰', "Scaron": 'Š',
+	"guilsinglleft": '‹', "OE": 'Œ', "Zcaron": 'Ž', "quoteleft": '‘', "quoteright": '’',
+	"quotedblleft": '“', "quotedblright": '”', "bullet": '•', "endash": '–', "emdash": '—',
+	"tilde": '˜', "trademark": '™', "scaron": 'š', "guilsinglright": '›', "oe": 'œ',
+	"zcaron": 'ž', "Ydieresis": 'Ÿ', "fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ',
+	"dotlessi": 'ı', "Lslash": 'Ł', "lslash": 'ł', "fraction": '⁄', "minus": '−',
+	"breve": '˘', "dotaccent": '˙', "ring": '˚', "ogonek": '˛', "caron": 'ˇ', "hungarumlaut": '˝',
+	"nbspace": '\u00a0', "sfthyphen": '\u00ad',
+}
+
+func init() {
+
+	for i, n := range asciiGlyphNames {
+		glyphRunes[n] = rune(0x20 + i)
+	}
+
+	for i, n := range latin1GlyphNames {
+		if _, found := glyphRunes[n]; !found {
+			glyphRunes[n] = rune(0xA1 + i)
+		}
+	}
+
+	for c := 0x20; c < 0x7F; c++ {
+		WinAnsiEncoding[c] = rune(c)
+		MacRomanEncoding[c] = rune(c)
+		StandardEncoding[c] = rune(c)
+	}
+
+	for c := 0xA0; c <= 0xFF; c++ {
+		WinAnsiEncoding[c] = rune(c)
+	}
+
+	for r, c := range winAnsiSpecials {
+		WinAnsiEncoding[c] = r
+	}
+
+	for i, r := range []rune(macRomanHigh) {
+		MacRomanEncoding[0x80+i] = r
+	}
+
+	StandardEncoding['\''] = '’'
+	StandardEncoding['`'] = '‘'
+	for c, r := range standardHigh {
+		StandardEncoding[c] = r
+	}
+}
+
+// PredefinedEncoding returns the encoding for the name of a predefined simple font encoding.
+func PredefinedEncoding(name string) (*Encoding, bool) {
+
+	switch name {
+
+	case "WinAnsiEncoding":
+		return &WinAnsiEncoding, true
+
+	case "MacRomanEncoding":
+		return &MacRomanEncoding, true
+
+	case "StandardEncoding":
+		return &StandardEncoding, true
+	}
+
+	return nil, false
+}
+
+// GlyphRune returns the Unicode code point for a glyph name
+// following the conventions of the Adobe Glyph List.
+func GlyphRune(name string) (rune, bool) {
+
+	// Drop any suffix like in "a.sc" or "f_i.liga".
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+
+	if r, found := glyphRunes[name]; found {
+		return r, true
+	}
+
+	var hex string
+
+	switch {
+
+	case strings.HasPrefix(name, "uni") && len(name) == 7:
+		hex = name[3:]
+
+	case strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7:
+		hex = name[1:]
+
+	default:
+		return 0, false
+	}
+
+	i, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return rune(i), true
+}
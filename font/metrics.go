@@ -0,0 +1,170 @@
+// Package font provides metrics and encoding support for the standard 14 fonts
+// as needed for generating appearance streams and for decoding text.
+package font
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+var logDebugFont, logInfoFont, logErrorFont *log.Logger
+
+func init() {
+	logDebugFont = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfoFont = log.New(ioutil.Discard, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logErrorFont = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugFont = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugFont = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Glyph widths of Helvetica for the WinAnsi codes 0x20 - 0x7E in 1/1000 text space units.
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // 0x20
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0x30
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // 0x40
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // 0x50
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // 0x60
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // 0x70
+}
+
+// Glyph widths of Helvetica-Bold for the WinAnsi codes 0x20 - 0x7E in 1/1000 text space units.
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // 0x20
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611, // 0x30
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778, // 0x40
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556, // 0x50
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611, // 0x60
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584, // 0x70
+}
+
+// Glyph widths of Times-Roman for the WinAnsi codes 0x20 - 0x7E in 1/1000 text space units.
+var timesRomanWidths = []int{
+	250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278, // 0x20
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444, // 0x30
+	921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722, // 0x40
+	556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500, // 0x50
+	333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500, // 0x60
+	500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541, // 0x70
+}
+
+// The WinAnsi code points 0x80 - 0x9F deviating from ISO-8859-1.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// IsStandardFont returns true for the base font names of the standard 14 fonts.
+func IsStandardFont(fontName string) bool {
+
+	for _, s := range []string{
+		"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic",
+		"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique",
+		"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique",
+		"Symbol", "ZapfDingbats"} {
+		if s == fontName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func widths(fontName string) []int {
+
+	switch {
+
+	case strings.HasPrefix(fontName, "Helvetica-Bold"), strings.HasPrefix(fontName, "Arial-Bold"):
+		return helveticaBoldWidths
+
+	case strings.HasPrefix(fontName, "Times"):
+		return timesRomanWidths
+	}
+
+	return helveticaWidths
+}
+
+// CharWidth returns the width of a WinAnsi encoded character in 1/1000 text space units.
+// Fonts other than the standard 14 fonts are approximated by Helvetica.
+func CharWidth(fontName string, c byte) int {
+
+	if strings.HasPrefix(fontName, "Courier") {
+		return 600
+	}
+
+	if c < 0x20 {
+		return 0
+	}
+
+	w := widths(fontName)
+	if int(c)-0x20 < len(w) {
+		return w[c-0x20]
+	}
+
+	// Use the width of a digit for anything beyond ASCII.
+	return w['0'-0x20]
+}
+
+// TextWidth returns the width of a WinAnsi encoded string for a font and font size in user space units.
+func TextWidth(s string, fontName string, fontSize float64) float64 {
+
+	w := 0
+	for i := 0; i < len(s); i++ {
+		w += CharWidth(fontName, s[i])
+	}
+
+	return float64(w) * fontSize / 1000
+}
+
+// EncodeWinAnsi converts s into a WinAnsi encoded string.
+// Characters not covered by WinAnsiEncoding are replaced by '?'.
+func EncodeWinAnsi(s string) string {
+
+	b := make([]byte, 0, len(s))
+
+	for _, r := range s {
+
+		switch {
+
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			b = append(b, byte(r))
+
+		default:
+			c, ok := winAnsiSpecials[r]
+			if !ok {
+				logDebugFont.Printf("EncodeWinAnsi: unsupported rune %U\n", r)
+				c = '?'
+			}
+			b = append(b, c)
+		}
+	}
+
+	return string(b)
+}
+
+// NewStandardFontDict returns a simple font dict for one of the standard 14 fonts using WinAnsiEncoding.
+func NewStandardFontDict(baseFont string) types.PDFDict {
+
+	d := types.NewPDFDict()
+	d.Insert("Type", types.PDFName("Font"))
+	d.Insert("Subtype", types.PDFName("Type1"))
+	d.Insert("BaseFont", types.PDFName(baseFont))
+
+	if baseFont != "Symbol" && baseFont != "ZapfDingbats" {
+		d.Insert("Encoding", types.PDFName("WinAnsiEncoding"))
+	}
+
+	return d
+}
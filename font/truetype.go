@@ -0,0 +1,701 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var errCorruptTrueType = errors.New("font: corrupt TrueType font program")
+
+// TrueType is a parsed TrueType font program, see the OpenType specification.
+type TrueType struct {
+	tables    map[string][]byte
+	numGlyphs int
+	longLoca  bool
+	loca      []int
+}
+
+// Tables needed for rendering a TrueType font embedded in a PDF file, see 9.9 Embedded Font Programs.
+var trueTypeTables = []string{"OS/2", "cmap", "cvt ", "fpgm", "gasp", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "name", "post", "prep"}
+
+func u16(b []byte, off int) int {
+	return int(binary.BigEndian.Uint16(b[off:]))
+}
+
+func u32(b []byte, off int) int {
+	return int(binary.BigEndian.Uint32(b[off:]))
+}
+
+// ParseTrueType parses a TrueType font program.
+func ParseTrueType(buf []byte) (tt *TrueType, err error) {
+
+	if len(buf) < 12 {
+		return nil, errCorruptTrueType
+	}
+
+	numTables := u16(buf, 4)
+	if len(buf) < 12+numTables*16 {
+		return nil, errCorruptTrueType
+	}
+
+	tt = &TrueType{tables: map[string][]byte{}}
+
+	for i := 0; i < numTables; i++ {
+		rec := buf[12+i*16:]
+		tag := string(rec[:4])
+		off, l := u32(rec, 8), u32(rec, 12)
+		if off < 0 || l < 0 || off+l > len(buf) {
+			return nil, errors.Wrapf(errCorruptTrueType, "table %q out of bounds", tag)
+		}
+		tt.tables[tag] = buf[off : off+l]
+	}
+
+	head, maxp, loca := tt.tables["head"], tt.tables["maxp"], tt.tables["loca"]
+	if len(head) < 54 || len(maxp) < 6 || loca == nil || tt.tables["glyf"] == nil {
+		return nil, errors.Wrap(errCorruptTrueType, "missing required table")
+	}
+
+	tt.numGlyphs = u16(maxp, 4)
+	tt.longLoca = u16(head, 50) == 1
+
+	tt.loca = make([]int, tt.numGlyphs+1)
+
+	for i := range tt.loca {
+		if tt.longLoca {
+			if len(loca) < 4*(i+1) {
+				return nil, errors.Wrap(errCorruptTrueType, "loca")
+			}
+			tt.loca[i] = u32(loca, 4*i)
+		} else {
+			if len(loca) < 2*(i+1) {
+				return nil, errors.Wrap(errCorruptTrueType, "loca")
+			}
+			tt.loca[i] = 2 * u16(loca, 2*i)
+		}
+	}
+
+	return tt, nil
+}
+
+// NumGlyphs returns the number of glyphs.
+func (tt *TrueType) NumGlyphs() int {
+	return tt.numGlyphs
+}
+
+func (tt *TrueType) glyph(gid int) []byte {
+
+	if gid < 0 || gid >= tt.numGlyphs {
+		return nil
+	}
+
+	glyf := tt.tables["glyf"]
+
+	from, to := tt.loca[gid], tt.loca[gid+1]
+	if from >= to || to > len(glyf) {
+		return nil
+	}
+
+	return glyf[from:to]
+}
+
+// Flags of composite glyph components.
+const (
+	argsAreWords   = 0x0001
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+)
+
+// components returns the offsets of the glyph indices of the components of a composite glyph.
+func components(g []byte) (offsets []int) {
+
+	if len(g) < 10 || int16(u16(g, 0)) >= 0 {
+		return nil
+	}
+
+	for i := 10; i+4 <= len(g); {
+
+		flags := u16(g, i)
+		offsets = append(offsets, i+2)
+
+		i += 4
+		if flags&argsAreWords > 0 {
+			i += 4
+		} else {
+			i += 2
+		}
+
+		switch {
+		case flags&haveScale > 0:
+			i += 2
+		case flags&haveXYScale > 0:
+			i += 4
+		case flags&haveTwoByTwo > 0:
+			i += 8
+		}
+
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+
+	return offsets
+}
+
+// closure adds the components of composite glyphs and .notdef to a glyph set.
+func (tt *TrueType) closure(gids map[int]bool) map[int]bool {
+
+	all := map[int]bool{}
+
+	var add func(gid int)
+	add = func(gid int) {
+		if gid < 0 || gid >= tt.numGlyphs || all[gid] {
+			return
+		}
+		all[gid] = true
+		g := tt.glyph(gid)
+		for _, off := range components(g) {
+			add(u16(g, off))
+		}
+	}
+
+	add(0)
+	for gid := range gids {
+		add(gid)
+	}
+
+	return all
+}
+
+// CMap returns the mapping from character codes to glyph ids of the cmap subtable for given platform and encoding.
+// Supported are subtable formats 0, 4, 6 and 12.
+func (tt *TrueType) CMap(platformID, encodingID int) map[int]int {
+
+	t := tt.tables["cmap"]
+	if len(t) < 4 {
+		return nil
+	}
+
+	for i, n := 0, u16(t, 2); i < n && 4+i*8+8 <= len(t); i++ {
+
+		rec := t[4+i*8:]
+		if u16(rec, 0) != platformID || u16(rec, 2) != encodingID {
+			continue
+		}
+
+		off := u32(rec, 4)
+		if off+4 > len(t) {
+			return nil
+		}
+
+		m, err := cmapSubtable(t[off:])
+		if err != nil {
+			logDebugFont.Printf("CMap(%d,%d): %v\n", platformID, encodingID, err)
+			return nil
+		}
+
+		return m
+	}
+
+	return nil
+}
+
+func cmapSubtable(t []byte) (m map[int]int, err error) {
+
+	defer func() {
+		// Guard against corrupt subtables.
+		if r := recover(); r != nil {
+			m, err = nil, errors.Wrap(errCorruptTrueType, "cmap")
+		}
+	}()
+
+	m = map[int]int{}
+
+	switch u16(t, 0) {
+
+	case 0:
+		for c := 0; c < 256; c++ {
+			m[c] = int(t[6+c])
+		}
+
+	case 4:
+		segCount := u16(t, 6) / 2
+		ends, starts, deltas, rangeOffs := 14, 16+2*segCount, 16+4*segCount, 16+6*segCount
+		for i := 0; i < segCount; i++ {
+			end, start := u16(t, ends+2*i), u16(t, starts+2*i)
+			delta, rangeOff := u16(t, deltas+2*i), u16(t, rangeOffs+2*i)
+			for c := start; c <= end && c < 0xFFFF; c++ {
+				if rangeOff == 0 {
+					m[c] = (c + delta) & 0xFFFF
+					continue
+				}
+				if g := u16(t, rangeOffs+2*i+rangeOff+2*(c-start)); g != 0 {
+					m[c] = (g + delta) & 0xFFFF
+				}
+			}
+		}
+
+	case 6:
+		first, count := u16(t, 6), u16(t, 8)
+		for i := 0; i < count; i++ {
+			m[first+i] = u16(t, 10+2*i)
+		}
+
+	case 12:
+		for i, n := 0, u32(t, 12); i < n; i++ {
+			g := t[16+12*i:]
+			start, end, gid := u32(g, 0), u32(g, 4), u32(g, 8)
+			for c := start; c <= end && c-start < 0x10000; c++ {
+				m[c] = gid + c - start
+			}
+		}
+
+	default:
+		return nil, errors.Errorf("font: unsupported cmap format %d", u16(t, 0))
+	}
+
+	return m, nil
+}
+
+func checksum(b []byte) (sum uint32) {
+
+	for i := 0; i < len(b); i += 4 {
+		var w [4]byte
+		copy(w[:], b[i:])
+		sum += binary.BigEndian.Uint32(w[:])
+	}
+
+	return
+}
+
+// pad4 returns a copy of b padded to a multiple of 4 bytes.
+func pad4(b []byte) []byte {
+	p := make([]byte, (len(b)+3)/4*4)
+	copy(p, b)
+	return p
+}
+
+// writeTrueType assembles a font program from tables.
+func writeTrueType(tables map[string][]byte) []byte {
+
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<uint(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << uint(entrySelector)
+
+	var b bytes.Buffer
+
+	binary.Write(&b, binary.BigEndian, []uint16{0x0001, 0x0000, uint16(n), uint16(searchRange), uint16(entrySelector), uint16(n*16 - searchRange)})
+
+	off := 12 + 16*n
+
+	var data []byte
+	headOff := 0
+
+	for _, tag := range tags {
+
+		t := tables[tag]
+		if tag == "head" {
+			headOff = off
+			// Zero checkSumAdjustment for checksum calculation.
+			t = append([]byte{}, t...)
+			binary.BigEndian.PutUint32(t[8:], 0)
+		}
+
+		b.WriteString(tag)
+		binary.Write(&b, binary.BigEndian, []uint32{checksum(t), uint32(off), uint32(len(t))})
+
+		t = pad4(t)
+		data = append(data, t...)
+		off += len(t)
+	}
+
+	buf := append(b.Bytes(), data...)
+
+	binary.BigEndian.PutUint32(buf[headOff+8:], 0xB1B0AFBA-checksum(buf))
+
+	return buf
+}
+
+// metrics returns the advance width and left side bearing of a glyph.
+func (tt *TrueType) metrics(gid int) (advance, lsb int, err error) {
+
+	hhea, hmtx := tt.tables["hhea"], tt.tables["hmtx"]
+	if len(hhea) < 36 {
+		return 0, 0, errors.Wrap(errCorruptTrueType, "hhea")
+	}
+
+	numberOfHMetrics := u16(hhea, 34)
+	if numberOfHMetrics == 0 || len(hmtx) < 4*numberOfHMetrics+2*(tt.numGlyphs-numberOfHMetrics) {
+		return 0, 0, errors.Wrap(errCorruptTrueType, "hmtx")
+	}
+
+	if gid < 0 || gid >= tt.numGlyphs {
+		return 0, 0, errors.Wrapf(errCorruptTrueType, "glyph %d out of range", gid)
+	}
+
+	if gid < numberOfHMetrics {
+		return u16(hmtx, 4*gid), u16(hmtx, 4*gid+2), nil
+	}
+
+	return u16(hmtx, 4*(numberOfHMetrics-1)), u16(hmtx, 4*numberOfHMetrics+2*(gid-numberOfHMetrics)), nil
+}
+
+// withGlyphs returns the tables of tt with the glyph data replaced by glyphs indexed by glyph id.
+func (tt *TrueType) withGlyphs(glyphs [][]byte) map[string][]byte {
+
+	tables := map[string][]byte{}
+	for _, tag := range trueTypeTables {
+		if t, found := tt.tables[tag]; found {
+			tables[tag] = t
+		}
+	}
+
+	var glyf []byte
+	loca := make([]int, 0, len(glyphs)+1)
+
+	for _, g := range glyphs {
+		loca = append(loca, len(glyf))
+		glyf = append(glyf, g...)
+		if len(glyf)%2 > 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+
+	loca = append(loca, len(glyf))
+
+	// Short offsets are limited to 0x1FFFE bytes of glyph data, see head.indexToLocFormat.
+	longLoca := tt.longLoca || len(glyf) > 0x1FFFE
+	if longLoca && !tt.longLoca {
+		head := append([]byte{}, tables["head"]...)
+		binary.BigEndian.PutUint16(head[50:], 1)
+		tables["head"] = head
+	}
+
+	var l bytes.Buffer
+	for _, off := range loca {
+		if longLoca {
+			binary.Write(&l, binary.BigEndian, uint32(off))
+		} else {
+			binary.Write(&l, binary.BigEndian, uint16(off/2))
+		}
+	}
+
+	tables["glyf"] = glyf
+	tables["loca"] = l.Bytes()
+
+	return tables
+}
+
+// maxProfile returns the maxp table of tt holding the maximum values of all fonts given.
+func (tt *TrueType) maxProfile(fonts []*TrueType, numGlyphs int) []byte {
+
+	maxp := append([]byte{}, tt.tables["maxp"]...)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(numGlyphs))
+
+	for _, f := range fonts {
+		m := f.tables["maxp"]
+		for i := 6; i+2 <= len(maxp) && i+2 <= len(m); i += 2 {
+			if u16(m, i) > u16(maxp, i) {
+				copy(maxp[i:], m[i:i+2])
+			}
+		}
+	}
+
+	return maxp
+}
+
+// sameTables returns true if all fonts share the content of the given tables.
+func sameTables(fonts []*TrueType, tags ...string) bool {
+
+	for _, f := range fonts[1:] {
+		for _, tag := range tags {
+			if !bytes.Equal(f.tables[tag], fonts[0].tables[tag]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Glyph refers to a glyph of a font program.
+type Glyph struct {
+	Font *TrueType
+	GID  int
+}
+
+// maxComponentDepth limits the nesting of composite glyphs followed.
+const maxComponentDepth = 16
+
+func sameGlyph(a, b Glyph, depth int) bool {
+
+	ga, gb := a.Font.glyph(a.GID), b.Font.glyph(b.GID)
+	if len(ga) != len(gb) || depth > maxComponentDepth {
+		return false
+	}
+
+	aa, al, err := a.Font.metrics(a.GID)
+	if err != nil {
+		return false
+	}
+
+	ba, bl, err := b.Font.metrics(b.GID)
+	if err != nil || aa != ba || al != bl {
+		return false
+	}
+
+	// Component glyph ids are compared by the glyphs they refer to.
+	offsets := components(ga)
+
+	ga, gb = append([]byte{}, ga...), append([]byte{}, gb...)
+	ids := make([][2]int, len(offsets))
+	for i, off := range offsets {
+		ids[i] = [2]int{u16(ga, off), u16(gb, off)}
+		ga[off], ga[off+1], gb[off], gb[off+1] = 0, 0, 0, 0
+	}
+
+	if !bytes.Equal(ga, gb) {
+		return false
+	}
+
+	for _, id := range ids {
+		if !sameGlyph(Glyph{a.Font, id[0]}, Glyph{b.Font, id[1]}, depth+1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SameGlyph returns true if two glyphs, possibly of different font programs, share outline and horizontal metrics.
+func SameGlyph(a, b Glyph) bool {
+	return sameGlyph(a, b, 0)
+}
+
+// symbolicCMap returns a cmap table mapping single byte codes to glyph ids as expected for symbolic fonts,
+// using subtables (1,0) and (3,0) with codes in the range 0xF000 to 0xF0FF, see 9.6.6.4 Encodings for TrueType Fonts.
+func symbolicCMap(codes map[int]int) []byte {
+
+	var cc []int
+	for c := range codes {
+		cc = append(cc, c)
+	}
+	sort.Ints(cc)
+
+	// Format 6: trimmed table mapping
+	var f6 bytes.Buffer
+	first, count := cc[0], cc[len(cc)-1]-cc[0]+1
+	binary.Write(&f6, binary.BigEndian, []uint16{6, uint16(10 + 2*count), 0, uint16(first), uint16(count)})
+	for c := first; c < first+count; c++ {
+		binary.Write(&f6, binary.BigEndian, uint16(codes[c]))
+	}
+
+	// Format 4: one segment per code followed by the final segment.
+	segCount := len(cc) + 1
+	entrySelector := 0
+	for 1<<uint(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 << uint(entrySelector)
+
+	ends, starts, deltas := make([]uint16, segCount), make([]uint16, segCount), make([]uint16, segCount)
+	for i, c := range cc {
+		ends[i], starts[i] = uint16(0xF000+c), uint16(0xF000+c)
+		deltas[i] = uint16(codes[c] - (0xF000 + c))
+	}
+	ends[len(cc)], starts[len(cc)], deltas[len(cc)] = 0xFFFF, 0xFFFF, 1
+
+	var f4 bytes.Buffer
+	binary.Write(&f4, binary.BigEndian, []uint16{4, uint16(16 + 8*segCount), 0, uint16(2 * segCount), uint16(searchRange), uint16(entrySelector), uint16(2*segCount - searchRange)})
+	binary.Write(&f4, binary.BigEndian, ends)
+	binary.Write(&f4, binary.BigEndian, uint16(0))
+	binary.Write(&f4, binary.BigEndian, starts)
+	binary.Write(&f4, binary.BigEndian, deltas)
+	binary.Write(&f4, binary.BigEndian, make([]uint16, segCount))
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, []uint16{0, 2})
+	binary.Write(&b, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&b, binary.BigEndian, uint32(20))
+	binary.Write(&b, binary.BigEndian, []uint16{3, 0})
+	binary.Write(&b, binary.BigEndian, uint32(20+f6.Len()))
+	b.Write(f6.Bytes())
+	b.Write(f4.Bytes())
+
+	return b.Bytes()
+}
+
+// AssembleTrueType returns a font program made of given glyphs numbered in order followed by any components missing.
+// The glyphs may stem from several font programs sharing the same hinting programs, like subsets of the same font.
+// The remaining tables are taken from the font of the first glyph, which is expected to be .notdef.
+//
+// If codes mapping single byte codes to glyph ids is given, the cmap table is replaced by one for a symbolic font.
+// Otherwise the cmap table no longer applying is dropped, which is fine for CIDFonts.
+func AssembleTrueType(glyphs []Glyph, codes map[int]int) (buf []byte, err error) {
+
+	if len(glyphs) == 0 {
+		return nil, errors.New("font: no glyphs to assemble")
+	}
+
+	var fonts []*TrueType
+	seen := map[*TrueType]bool{}
+
+	index := map[Glyph]int{}
+	for i, g := range glyphs {
+		if _, found := index[g]; !found {
+			index[g] = i
+		}
+		if !seen[g.Font] {
+			seen[g.Font] = true
+			fonts = append(fonts, g.Font)
+		}
+	}
+
+	if !sameTables(fonts, "cvt ", "fpgm", "prep") {
+		return nil, errors.New("font: font programs using different hinting programs")
+	}
+
+	var (
+		data [][]byte
+		hmtx bytes.Buffer
+	)
+
+	// Components not given are appended while iterating.
+	for i := 0; i < len(glyphs); i++ {
+
+		g := append([]byte{}, glyphs[i].Font.glyph(glyphs[i].GID)...)
+
+		for _, off := range components(g) {
+			c := Glyph{glyphs[i].Font, u16(g, off)}
+			j, found := index[c]
+			if !found {
+				j = len(glyphs)
+				glyphs = append(glyphs, c)
+				index[c] = j
+			}
+			binary.BigEndian.PutUint16(g[off:], uint16(j))
+		}
+
+		data = append(data, g)
+
+		advance, lsb, err := glyphs[i].Font.metrics(glyphs[i].GID)
+		if err != nil {
+			return nil, err
+		}
+		binary.Write(&hmtx, binary.BigEndian, []uint16{uint16(advance), uint16(lsb)})
+	}
+
+	base := glyphs[0].Font
+
+	tables := base.withGlyphs(data)
+
+	delete(tables, "cmap")
+	if len(codes) > 0 {
+		tables["cmap"] = symbolicCMap(codes)
+	}
+
+	// Glyph names are indexed by glyph id, see post table format 3.0.
+	if post := tables["post"]; len(post) >= 32 {
+		post = append([]byte{}, post[:32]...)
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		tables["post"] = post
+	}
+
+	tables["maxp"] = base.maxProfile(fonts, len(glyphs))
+	tables["hmtx"] = hmtx.Bytes()
+
+	hhea := append([]byte{}, tables["hhea"]...)
+	binary.BigEndian.PutUint16(hhea[34:], uint16(len(glyphs)))
+	tables["hhea"] = hhea
+
+	return writeTrueType(tables), nil
+}
+
+// MergeTrueType returns a font program holding the glyphs of all given subsets of a font retaining its glyph ids.
+// Glyphs present in more than one subset have to be identical.
+func MergeTrueType(fonts []*TrueType) (buf []byte, err error) {
+
+	if len(fonts) == 0 {
+		return nil, errors.New("font: no fonts to merge")
+	}
+
+	for _, f := range fonts {
+		if f.numGlyphs != fonts[0].numGlyphs {
+			return nil, errors.New("font: subsets differ in number of glyphs")
+		}
+	}
+
+	if !sameTables(fonts, "cmap", "cvt ", "fpgm", "prep", "hhea", "hmtx") {
+		return nil, errors.New("font: subsets differ in glyph mapping, metrics or hinting programs")
+	}
+
+	glyphs := make([][]byte, fonts[0].numGlyphs)
+
+	for gid := range glyphs {
+		for _, f := range fonts {
+			g := f.glyph(gid)
+			if len(g) == 0 {
+				continue
+			}
+			if glyphs[gid] != nil && !bytes.Equal(glyphs[gid], g) {
+				return nil, errors.Errorf("font: subsets differ in glyph %d", gid)
+			}
+			glyphs[gid] = g
+		}
+	}
+
+	tables := fonts[0].withGlyphs(glyphs)
+	tables["maxp"] = fonts[0].maxProfile(fonts, fonts[0].numGlyphs)
+
+	return writeTrueType(tables), nil
+}
+
+// Subset returns a font program containing only the glyphs in gids, their components and .notdef.
+//
+// Glyph ids are retained with unused glyphs left empty unless compact is set.
+// Compact subsets get their glyphs renumbered in ascending order and the mapping of old to new glyph ids is returned.
+// As the cmap table no longer applies it is dropped, which is fine for CIDFonts.
+func (tt *TrueType) Subset(gids map[int]bool, compact bool) (buf []byte, newGID map[int]int, err error) {
+
+	all := tt.closure(gids)
+
+	newGID = map[int]int{}
+
+	if !compact {
+		glyphs := make([][]byte, tt.numGlyphs)
+		for gid := range all {
+			glyphs[gid] = tt.glyph(gid)
+			newGID[gid] = gid
+		}
+		return writeTrueType(tt.withGlyphs(glyphs)), newGID, nil
+	}
+
+	var order []int
+	for gid := range all {
+		order = append(order, gid)
+	}
+	sort.Ints(order)
+
+	glyphs := make([]Glyph, len(order))
+	for i, gid := range order {
+		glyphs[i] = Glyph{tt, gid}
+		newGID[gid] = i
+	}
+
+	buf, err = AssembleTrueType(glyphs, nil)
+
+	return buf, newGID, err
+}
@@ -0,0 +1,457 @@
+package form
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/annot"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+const (
+	padding     = 2
+	leading     = 1.15
+	minFontSize = 4
+)
+
+func fmtNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// fontInfo describes the font used for rendering variable text.
+type fontInfo struct {
+	name      string // The resource name used in DA.
+	baseFont  string
+	indRef    *types.PDFIndirectRef
+	firstChar int
+	widths    []float64
+}
+
+// textWidth returns the width of a WinAnsi encoded string in user space units.
+func (fi fontInfo) textWidth(s string, fontSize float64) float64 {
+
+	if fi.widths == nil {
+		return font.TextWidth(s, fi.baseFont, fontSize)
+	}
+
+	w := 0.
+	for i := 0; i < len(s); i++ {
+		j := int(s[i]) - fi.firstChar
+		if j >= 0 && j < len(fi.widths) {
+			w += fi.widths[j]
+			continue
+		}
+		w += float64(font.CharWidth(fi.baseFont, s[i]))
+	}
+
+	return w * fontSize / 1000
+}
+
+// fontForDA looks up the font named in a default appearance string in the AcroForm default resources.
+func fontForDA(ctx *types.PDFContext, acroForm *types.PDFDict, name string) (fi fontInfo, err error) {
+
+	fi = fontInfo{name: name, baseFont: annot.BaseFont(name)}
+
+	dr, err := ctx.DereferenceDict(acroForm.Dict["DR"])
+	if err != nil || dr == nil {
+		return
+	}
+
+	fonts, err := ctx.DereferenceDict(dr.Dict["Font"])
+	if err != nil || fonts == nil {
+		return
+	}
+
+	indRef := fonts.IndirectRefEntry(name)
+	if indRef == nil {
+		return
+	}
+
+	d, err := ctx.DereferenceDict(*indRef)
+	if err != nil || d == nil {
+		return
+	}
+
+	fi.indRef = indRef
+
+	if bf := d.NameEntry("BaseFont"); bf != nil {
+		fi.baseFont = *bf
+	}
+
+	if fc := d.IntEntry("FirstChar"); fc != nil {
+		fi.firstChar = *fc
+		if arr, err := ctx.DereferenceArray(d.Dict["Widths"]); err == nil && arr != nil {
+			for _, obj := range *arr {
+				f, _ := number(ctx, obj)
+				fi.widths = append(fi.widths, f)
+			}
+		}
+	}
+
+	return fi, nil
+}
+
+// resources returns the resource dict for an appearance stream using fi.
+func (fi fontInfo) resources(ctx *types.PDFContext) (*types.PDFDict, error) {
+
+	if fi.indRef == nil {
+		return annot.FontResources(ctx, fi.name)
+	}
+
+	fonts := types.NewPDFDict()
+	fonts.Insert(fi.name, *fi.indRef)
+
+	d := types.NewPDFDict()
+	d.Insert("Font", fonts)
+
+	return &d, nil
+}
+
+// defaultAppearance returns the DA of a widget taking into account inheritance and the AcroForm default.
+func (f *field) defaultAppearance(ctx *types.PDFContext, w *types.PDFDict, acroForm *types.PDFDict) (string, error) {
+
+	obj, found := w.Find("DA")
+	if !found {
+		var err error
+		obj, err = inheritedEntry(ctx, f.dict, "DA")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if obj == nil {
+		obj = acroForm.Dict["DA"]
+	}
+
+	return textString(ctx, obj), nil
+}
+
+// quadding returns the text alignment of a field: 0=left, 1=centered, 2=right.
+func (f *field) quadding(ctx *types.PDFContext, acroForm *types.PDFDict) int {
+
+	obj, err := inheritedEntry(ctx, f.dict, "Q")
+	if err != nil {
+		return 0
+	}
+
+	if obj == nil {
+		obj = acroForm.Dict["Q"]
+	}
+
+	if i, err := ctx.DereferenceInteger(obj); err == nil && i != nil {
+		return i.Value()
+	}
+
+	return 0
+}
+
+func (f *field) maxLen(ctx *types.PDFContext) int {
+
+	if obj, err := inheritedEntry(ctx, f.dict, "MaxLen"); err == nil && obj != nil {
+		if i, err := ctx.DereferenceInteger(obj); err == nil && i != nil {
+			return i.Value()
+		}
+	}
+
+	return 0
+}
+
+func number(ctx *types.PDFContext, obj interface{}) (f float64, ok bool) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch n := obj.(type) {
+	case types.PDFInteger:
+		return float64(n.Value()), true
+	case types.PDFFloat:
+		return n.Value(), true
+	}
+
+	return
+}
+
+// numberArrayEntry returns a number array found in d for key.
+func numberArrayEntry(ctx *types.PDFContext, d *types.PDFDict, key string) (a []float64) {
+
+	if d == nil {
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(d.Dict[key])
+	if err != nil || arr == nil {
+		return
+	}
+
+	for _, obj := range *arr {
+		if f, ok := number(ctx, obj); ok {
+			a = append(a, f)
+		}
+	}
+
+	return
+}
+
+// textBox describes the layout of variable text within a widget.
+type textBox struct {
+	w, h      float64
+	fi        fontInfo
+	fontSize  float64
+	color     []float64
+	q         int
+	multiline bool
+	comb      int // The number of comb cells.
+}
+
+// autoFontSize computes the font size for auto sized fields (font size 0 in DA).
+func (tb *textBox) autoFontSize(s string) float64 {
+
+	h := tb.h - 2*padding
+	w := tb.w - 2*padding
+
+	if tb.multiline {
+		for fs := 12.; fs > minFontSize; fs -= 0.5 {
+			lines := annot.WrapText(s, w, func(s string) float64 { return tb.fi.textWidth(font.EncodeWinAnsi(s), fs) })
+			if float64(len(lines))*fs*leading <= h {
+				return fs
+			}
+		}
+		return minFontSize
+	}
+
+	fs := h / leading
+
+	if tb.comb > 0 {
+		// Glyphs must fit into their cells.
+		if cw := w / float64(tb.comb); fs > cw {
+			fs = cw
+		}
+		return fs
+	}
+
+	if tw := tb.fi.textWidth(font.EncodeWinAnsi(s), fs); tw > w {
+		fs *= w / tw
+	}
+
+	if fs < minFontSize {
+		fs = minFontSize
+	}
+
+	return fs
+}
+
+func writeText(b *bytes.Buffer, s string, x, y float64) {
+	esc, _ := types.Escape(s)
+	fmt.Fprintf(b, "1 0 0 1 %s %s Tm (%s) Tj\n", fmtNum(x), fmtNum(y), *esc)
+}
+
+// content renders s into the appearance stream of a text box.
+func (tb *textBox) content(b *bytes.Buffer, s string) {
+
+	fs := tb.fontSize
+	if fs == 0 {
+		fs = tb.autoFontSize(s)
+	}
+
+	fmt.Fprintf(b, "/Tx BMC\nq\n%s %s %s %s re W n\nBT\n", fmtNum(1), fmtNum(1), fmtNum(tb.w-2), fmtNum(tb.h-2))
+	fmt.Fprintf(b, "/%s %s Tf\n", tb.fi.name, fmtNum(fs))
+	b.WriteString(annot.ColorOp(tb.color, false))
+
+	x := func(line string) float64 {
+		switch tb.q {
+		case 1:
+			return (tb.w - tb.fi.textWidth(line, fs)) / 2
+		case 2:
+			return tb.w - padding - tb.fi.textWidth(line, fs)
+		}
+		return padding
+	}
+
+	switch {
+
+	case tb.comb > 0:
+		// Each character is centered in its own cell.
+		cw := tb.w / float64(tb.comb)
+		y := (tb.h-fs)/2 + fs*0.22
+		for i, r := range []rune(s) {
+			if i == tb.comb {
+				break
+			}
+			c := font.EncodeWinAnsi(string(r))
+			writeText(b, c, float64(i)*cw+(cw-tb.fi.textWidth(c, fs))/2, y)
+		}
+
+	case tb.multiline:
+		lines := annot.WrapText(s, tb.w-2*padding, func(s string) float64 { return tb.fi.textWidth(font.EncodeWinAnsi(s), fs) })
+		y := tb.h - padding - fs
+		for _, line := range lines {
+			l := font.EncodeWinAnsi(line)
+			writeText(b, l, x(l), y)
+			y -= fs * leading
+		}
+
+	default:
+		l := font.EncodeWinAnsi(strings.Replace(s, "\n", " ", -1))
+		writeText(b, l, x(l), (tb.h-fs)/2+fs*0.22)
+	}
+
+	b.WriteString("ET\nQ\nEMC\n")
+}
+
+// border renders the background and border defined by the appearance characteristics dict of a widget.
+func border(ctx *types.PDFContext, b *bytes.Buffer, w *types.PDFDict, width, height float64) {
+
+	mk, err := ctx.DereferenceDict(w.Dict["MK"])
+	if err != nil || mk == nil {
+		return
+	}
+
+	if bg := numberArrayEntry(ctx, mk, "BG"); len(bg) > 0 {
+		b.WriteString(annot.ColorOp(bg, false))
+		fmt.Fprintf(b, "0 0 %s %s re f\n", fmtNum(width), fmtNum(height))
+	}
+
+	bc := numberArrayEntry(ctx, mk, "BC")
+	if len(bc) == 0 {
+		return
+	}
+
+	bw := 1.
+	if bs, err := ctx.DereferenceDict(w.Dict["BS"]); err == nil && bs != nil {
+		if f, ok := number(ctx, bs.Dict["W"]); ok {
+			bw = f
+		}
+	}
+
+	if bw == 0 {
+		return
+	}
+
+	b.WriteString(annot.ColorOp(bc, true))
+	fmt.Fprintf(b, "%s w %s %s %s %s re S\n", fmtNum(bw), fmtNum(bw/2), fmtNum(bw/2), fmtNum(width-bw), fmtNum(height-bw))
+}
+
+// displayValue returns the text shown for the value of a text or combo box field.
+func (f *field) displayValue(ctx *types.PDFContext) (string, error) {
+
+	v, vv, err := f.value(ctx, "V")
+	if err != nil {
+		return "", err
+	}
+
+	if v == "" && len(vv) > 0 {
+		v = vv[0]
+	}
+
+	if f.ft == "Tx" && f.ff&ffPassword > 0 {
+		v = strings.Repeat("*", len([]rune(v)))
+	}
+
+	return v, nil
+}
+
+// generateAppearances regenerates the normal appearance of all widgets of a text or combo box field.
+// ok returns false for fields whose appearance is left to the viewer.
+func (f *field) generateAppearances(ctx *types.PDFContext, acroForm *types.PDFDict) (ok bool, err error) {
+
+	switch {
+	case f.ft == "Tx":
+	case f.ft == "Ch" && f.ff&ffCombo > 0:
+	default:
+		return false, nil
+	}
+
+	s, err := f.displayValue(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, w := range f.widgets {
+
+		rect := numberArrayEntry(ctx, w.dict, "Rect")
+		if len(rect) != 4 {
+			continue
+		}
+
+		da, err := f.defaultAppearance(ctx, w.dict, acroForm)
+		if err != nil {
+			return false, err
+		}
+
+		pda, err := annot.ParseDA(da)
+		if err != nil {
+			return false, err
+		}
+
+		if pda.FontName == "" {
+			pda.FontName = "Helv"
+		}
+
+		fi, err := fontForDA(ctx, acroForm, pda.FontName)
+		if err != nil {
+			return false, err
+		}
+
+		tb := textBox{
+			w:         rect[2] - rect[0],
+			h:         rect[3] - rect[1],
+			fi:        fi,
+			fontSize:  pda.FontSize,
+			color:     pda.Color,
+			q:         f.quadding(ctx, acroForm),
+			multiline: f.ft == "Tx" && f.ff&ffMultiline > 0,
+		}
+
+		if tb.w < 0 {
+			tb.w = -tb.w
+		}
+
+		if tb.h < 0 {
+			tb.h = -tb.h
+		}
+
+		if f.ft == "Tx" && f.ff&ffComb > 0 {
+			tb.comb = f.maxLen(ctx)
+		}
+
+		var b bytes.Buffer
+		border(ctx, &b, w.dict, tb.w, tb.h)
+		tb.content(&b, s)
+
+		res, err := fi.resources(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		indRef, err := annot.FormXObject(ctx, []float64{0, 0, tb.w, tb.h}, b.Bytes(), res)
+		if err != nil {
+			return false, err
+		}
+
+		ap := types.NewPDFDict()
+		ap.Insert("N", *indRef)
+		w.dict.Update("AP", ap)
+
+		logDebugForm.Printf("generateAppearances: %s obj#%d\n", f.name, w.objNr)
+	}
+
+	return true, nil
+}
+
+// updateAppearances regenerates the appearances of f after a value change.
+// ok returns false if the viewer needs to take care of this.
+func (f *field) updateAppearances(ctx *types.PDFContext, acroForm *types.PDFDict) (ok bool, err error) {
+
+	ok, err = f.generateAppearances(ctx, acroForm)
+	if err != nil {
+		return
+	}
+
+	// Leave list boxes to the viewer.
+	return ok || f.ft != "Ch", nil
+}
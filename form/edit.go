@@ -0,0 +1,248 @@
+package form
+
+import (
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/annot"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// selectFields returns the terminal fields identified by names.
+// A name selects a terminal field or all terminal fields below a non terminal field.
+// No names select all fields.
+func selectFields(ctx *types.PDFContext, names []string) (fields []*field, err error) {
+
+	ff, err := terminalFields(ctx)
+	if err != nil {
+		return
+	}
+
+	if len(ff) == 0 {
+		return nil, errors.New("no form fields available")
+	}
+
+	if len(names) == 0 {
+		return ff, nil
+	}
+
+	selected := map[*field]bool{}
+
+	for _, name := range names {
+
+		found := false
+
+		for _, f := range ff {
+			if f.name == name || strings.HasPrefix(f.name, name+".") {
+				selected[f] = true
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, errors.Errorf("unknown field: %s", name)
+		}
+	}
+
+	// Keep field tree order.
+	for _, f := range ff {
+		if selected[f] {
+			fields = append(fields, f)
+		}
+	}
+
+	return
+}
+
+// reset sets the value of f to its default value.
+func (f *field) reset(ctx *types.PDFContext) error {
+
+	switch f.typ() {
+
+	case "checkbox", "radio":
+		dv, _, err := f.value(ctx, "DV")
+		if err != nil {
+			return err
+		}
+		return f.fillButton(ctx, dv)
+
+	case "text", "choice":
+		obj, err := inheritedEntry(ctx, f.dict, "DV")
+		if err != nil {
+			return err
+		}
+		obj, err = ctx.Dereference(obj)
+		if err != nil {
+			return err
+		}
+		if obj == nil {
+			f.dict.Delete("V")
+		} else {
+			f.dict.Update("V", obj)
+		}
+		f.dict.Delete("I")
+	}
+
+	return nil
+}
+
+// Reset restores the default values of selected form fields.
+// No names select all fields. Read only fields are left untouched.
+func Reset(ctx *types.PDFContext, names []string) (err error) {
+
+	logDebugForm.Println("Reset begin")
+
+	fields, err := selectFields(ctx, names)
+	if err != nil {
+		return errors.Wrap(err, "Reset")
+	}
+
+	acroForm, err := acroFormDict(ctx)
+	if err != nil {
+		return
+	}
+
+	needAppearances := false
+
+	for _, f := range fields {
+
+		if f.ff&ffReadOnly > 0 {
+			logInfoForm.Printf("Reset: skipping read only field: %s\n", f.name)
+			continue
+		}
+
+		err = f.reset(ctx)
+		if err != nil {
+			return err
+		}
+
+		ok, err := f.updateAppearances(ctx, acroForm)
+		if err != nil {
+			return err
+		}
+
+		needAppearances = needAppearances || !ok
+
+		logDebugForm.Printf("Reset: %s\n", f.name)
+	}
+
+	if needAppearances {
+		acroForm.Update("NeedAppearances", types.PDFBoolean(true))
+	}
+
+	logDebugForm.Println("Reset end")
+
+	return
+}
+
+// Lock sets the ReadOnly flag of selected form fields.
+// No names select all fields.
+func Lock(ctx *types.PDFContext, names []string) (err error) {
+
+	logDebugForm.Println("Lock begin")
+
+	fields, err := selectFields(ctx, names)
+	if err != nil {
+		return errors.Wrap(err, "Lock")
+	}
+
+	for _, f := range fields {
+
+		// Ff is inheritable, so the effective flags go into the terminal field.
+		f.ff |= ffReadOnly
+		f.dict.Update("Ff", types.PDFInteger(f.ff))
+
+		logDebugForm.Printf("Lock: %s\n", f.name)
+	}
+
+	logDebugForm.Println("Lock end")
+
+	return
+}
+
+// removeFromCalculationOrder drops removed fields from the AcroForm calculation order array.
+func removeFromCalculationOrder(ctx *types.PDFContext, removed types.IntSet) (err error) {
+
+	acroForm, err := acroFormDict(ctx)
+	if err != nil || acroForm == nil {
+		return
+	}
+
+	obj, found := acroForm.Find("CO")
+	if !found {
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return
+	}
+
+	var a types.PDFArray
+	for _, o := range *arr {
+		if !removed[objNr(o)] {
+			a = append(a, o)
+		}
+	}
+
+	if len(a) == 0 {
+		acroForm.Delete("CO")
+		return
+	}
+
+	if indRef, ok := obj.(types.PDFIndirectRef); ok {
+		entry, found := ctx.FindTableEntryForIndRef(&indRef)
+		if !found {
+			return errors.Errorf("removeFromCalculationOrder: missing CO obj#%d", indRef.ObjectNumber)
+		}
+		entry.Object = a
+		return
+	}
+
+	acroForm.Update("CO", a)
+
+	return
+}
+
+// Remove deletes selected form fields along with their widget annotations.
+// No names select all fields.
+func Remove(ctx *types.PDFContext, names []string) (err error) {
+
+	logDebugForm.Println("Remove begin")
+
+	fields, err := selectFields(ctx, names)
+	if err != nil {
+		return errors.Wrap(err, "Remove")
+	}
+
+	removed := types.IntSet{}
+
+	for _, f := range fields {
+
+		if f.objNr > 0 {
+			removed[f.objNr] = true
+		}
+
+		for _, w := range f.widgets {
+			if w.objNr > 0 {
+				removed[w.objNr] = true
+			}
+		}
+
+		logDebugForm.Printf("Remove: %s\n", f.name)
+	}
+
+	err = removeFromCalculationOrder(ctx, removed)
+	if err != nil {
+		return
+	}
+
+	err = annot.RemoveWidgets(ctx, removed)
+	if err != nil {
+		return
+	}
+
+	logDebugForm.Println("Remove end")
+
+	return
+}
@@ -0,0 +1,221 @@
+package form
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// ReadFile reads field values from a JSON file using the layout written by List.
+func ReadFile(fileName string) (fields []Field, err error) {
+
+	bb, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ReadFile: can't read %s", fileName)
+	}
+
+	var ff formFile
+
+	err = json.Unmarshal(bb, &ff)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ReadFile: invalid JSON in %s", fileName)
+	}
+
+	return ff.Fields, nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// buttonState returns the appearance state for a checkbox or radio button value.
+func (f *field) buttonState(ctx *types.PDFContext, value string) (string, error) {
+
+	states := f.onStates(ctx)
+
+	if contains(states, value) || value == "Off" {
+		return value, nil
+	}
+
+	if f.typ() == "checkbox" && len(states) > 0 {
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1":
+			return states[0], nil
+		case "false", "no", "off", "0", "":
+			return "Off", nil
+		}
+	}
+
+	if value == "" {
+		return "Off", nil
+	}
+
+	return "", errors.Errorf("field %s: invalid value %q, must be one of: Off %s", f.name, value, strings.Join(states, " "))
+}
+
+func (f *field) fillButton(ctx *types.PDFContext, value string) error {
+
+	state, err := f.buttonState(ctx, value)
+	if err != nil {
+		return err
+	}
+
+	f.dict.Update("V", types.PDFName(state))
+
+	for _, w := range f.widgets {
+		as := "Off"
+		if contains(appearanceStates(ctx, w.dict), state) {
+			as = state
+		}
+		w.dict.Update("AS", types.PDFName(as))
+	}
+
+	return nil
+}
+
+func (f *field) fillText(ctx *types.PDFContext, value string) error {
+
+	if max := f.maxLen(ctx); max > 0 && len([]rune(value)) > max {
+		return errors.Errorf("field %s: value exceeds maximum length of %d", f.name, max)
+	}
+
+	f.dict.Update("V", types.TextStringLiteral(value))
+
+	return nil
+}
+
+func (f *field) fillChoice(ctx *types.PDFContext, fd Field) error {
+
+	var opts []string
+	if obj, err := inheritedEntry(ctx, f.dict, "Opt"); err == nil {
+		opts = choiceOptions(ctx, obj)
+	}
+
+	values := fd.Values
+	if len(values) == 0 && fd.Value != "" {
+		values = []string{fd.Value}
+	}
+
+	if len(values) > 1 && f.ff&ffMultiSelect == 0 {
+		return errors.Errorf("field %s: multiple values for single selection", f.name)
+	}
+
+	for _, v := range values {
+		if !contains(opts, v) && f.ff&ffEdit == 0 {
+			return errors.Errorf("field %s: invalid value %q, must be one of: %s", f.name, v, strings.Join(opts, ", "))
+		}
+	}
+
+	// The selected indices are optional and would need to be kept in sync.
+	f.dict.Delete("I")
+
+	switch len(values) {
+
+	case 0:
+		f.dict.Delete("V")
+
+	case 1:
+		f.dict.Update("V", types.TextStringLiteral(values[0]))
+
+	default:
+		arr := types.PDFArray{}
+		for _, v := range values {
+			arr = append(arr, types.TextStringLiteral(v))
+		}
+		f.dict.Update("V", arr)
+	}
+
+	return nil
+}
+
+// fill sets the value of f.
+func (f *field) fill(ctx *types.PDFContext, fd Field) error {
+
+	switch f.typ() {
+
+	case "text":
+		return f.fillText(ctx, fd.Value)
+
+	case "checkbox", "radio":
+		return f.fillButton(ctx, fd.Value)
+
+	case "choice":
+		return f.fillChoice(ctx, fd)
+	}
+
+	return errors.Errorf("field %s: can't fill %s fields", f.name, f.typ())
+}
+
+// Fill sets the values of form fields identified by their fully qualified names.
+// Checkbox and radio button appearance states are updated.
+// The appearances of text fields and combo boxes are regenerated using the default appearance string (DA)
+// and the fonts of the AcroForm default resources (DR).
+// For list boxes the form is flagged for appearance generation by the viewer.
+func Fill(ctx *types.PDFContext, fields []Field) (err error) {
+
+	logDebugForm.Println("Fill begin")
+
+	ff, err := terminalFields(ctx)
+	if err != nil {
+		return
+	}
+
+	if len(ff) == 0 {
+		return errors.New("Fill: no form fields available")
+	}
+
+	m := map[string]*field{}
+	for _, f := range ff {
+		m[f.name] = f
+	}
+
+	acroForm, err := acroFormDict(ctx)
+	if err != nil {
+		return
+	}
+
+	needAppearances := false
+
+	for _, fd := range fields {
+
+		f, ok := m[fd.Name]
+		if !ok {
+			return errors.Errorf("Fill: unknown field: %s", fd.Name)
+		}
+
+		if f.ff&ffReadOnly > 0 {
+			logInfoForm.Printf("Fill: skipping read only field: %s\n", f.name)
+			continue
+		}
+
+		err = f.fill(ctx, fd)
+		if err != nil {
+			return err
+		}
+
+		ok, err := f.updateAppearances(ctx, acroForm)
+		if err != nil {
+			return err
+		}
+
+		needAppearances = needAppearances || !ok
+
+		logDebugForm.Printf("Fill: %s\n", f.name)
+	}
+
+	if needAppearances {
+		acroForm.Update("NeedAppearances", types.PDFBoolean(true))
+	}
+
+	logDebugForm.Println("Fill end")
+
+	return
+}
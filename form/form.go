@@ -0,0 +1,566 @@
+// Package form provides management code for interactive forms (AcroForm).
+package form
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugForm, logInfoForm, logErrorForm *log.Logger
+
+func init() {
+	logDebugForm = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logInfoForm = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	logErrorForm = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugForm = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugForm = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Field flags, see 12.7.3.1 and following.
+const (
+	ffReadOnly      = 1
+	ffRequired      = 1 << 1
+	ffMultiline     = 1 << 12
+	ffPassword      = 1 << 13
+	ffNoToggleToOff = 1 << 14
+	ffRadio         = 1 << 15
+	ffPushbutton    = 1 << 16
+	ffCombo         = 1 << 17
+	ffEdit          = 1 << 18
+	ffMultiSelect   = 1 << 21
+	ffComb          = 1 << 24
+)
+
+// Field represents the exportable attributes of a terminal form field.
+type Field struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Value       string   `json:"value,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	ReadOnly    bool     `json:"readOnly,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Multiline   bool     `json:"multiline,omitempty"`
+	Password    bool     `json:"password,omitempty"`
+	Comb        bool     `json:"comb,omitempty"`
+	Combo       bool     `json:"combo,omitempty"`
+	Editable    bool     `json:"editable,omitempty"`
+	MultiSelect bool     `json:"multiSelect,omitempty"`
+	MaxLen      int      `json:"maxLen,omitempty"`
+	Pages       []int    `json:"pages,omitempty"`
+}
+
+// formFile is the JSON layout used for export and filling.
+type formFile struct {
+	Fields []Field `json:"fields"`
+}
+
+// field is a terminal field of the field tree along with its widget annotations.
+type field struct {
+	name    string
+	objNr   int
+	dict    *types.PDFDict
+	ft      string
+	ff      int
+	widgets []widget
+}
+
+type widget struct {
+	objNr int
+	dict  *types.PDFDict
+}
+
+func (f *field) typ() string {
+
+	switch f.ft {
+
+	case "Tx":
+		return "text"
+
+	case "Btn":
+		switch {
+		case f.ff&ffPushbutton > 0:
+			return "pushbutton"
+		case f.ff&ffRadio > 0:
+			return "radio"
+		}
+		return "checkbox"
+
+	case "Ch":
+		return "choice"
+
+	case "Sig":
+		return "signature"
+	}
+
+	return "unknown"
+}
+
+func objNr(obj interface{}) int {
+	if indRef, ok := obj.(types.PDFIndirectRef); ok {
+		return indRef.ObjectNumber.Value()
+	}
+	return 0
+}
+
+func textString(ctx *types.PDFContext, obj interface{}) (s string) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch str := obj.(type) {
+
+	case types.PDFStringLiteral:
+		s, _ = types.StringLiteralToString(str.Value())
+
+	case types.PDFHexLiteral:
+		s, _ = types.HexLiteralToString(str.Value())
+
+	case types.PDFName:
+		s = str.Value()
+	}
+
+	return
+}
+
+// inheritedEntry returns the value of an inheritable field attribute.
+func inheritedEntry(ctx *types.PDFContext, d *types.PDFDict, key string) (interface{}, error) {
+
+	for d != nil {
+
+		if obj, found := d.Find(key); found {
+			return obj, nil
+		}
+
+		parent, found := d.Find("Parent")
+		if !found {
+			break
+		}
+
+		var err error
+		d, err = ctx.DereferenceDict(parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func isField(d *types.PDFDict) bool {
+	_, found := d.Find("T")
+	return found
+}
+
+// collectFields walks the field tree and collects all terminal fields.
+func collectFields(ctx *types.PDFContext, arr types.PDFArray, parentName, ft string, ff int, fields *[]*field) (err error) {
+
+	for _, obj := range arr {
+
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return err
+		}
+
+		if d == nil {
+			continue
+		}
+
+		name := parentName
+		if t := textString(ctx, d.Dict["T"]); t != "" {
+			if name != "" {
+				name += "."
+			}
+			name += t
+		}
+
+		xft, xff := ft, ff
+		if n := d.NameEntry("FT"); n != nil {
+			xft = *n
+		}
+		if i := d.IntEntry("Ff"); i != nil {
+			xff = *i
+		}
+
+		kids, err := ctx.DereferenceArray(d.Dict["Kids"])
+		if err != nil {
+			return err
+		}
+
+		if kids == nil || len(*kids) == 0 {
+			// A terminal field merged with its only widget annotation.
+			*fields = append(*fields, &field{name: name, objNr: objNr(obj), dict: d, ft: xft, ff: xff, widgets: []widget{{objNr(obj), d}}})
+			continue
+		}
+
+		var (
+			widgets   []widget
+			subFields types.PDFArray
+		)
+
+		for _, o := range *kids {
+
+			kid, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+
+			if kid == nil {
+				continue
+			}
+
+			if isField(kid) {
+				subFields = append(subFields, o)
+				continue
+			}
+
+			widgets = append(widgets, widget{objNr(o), kid})
+		}
+
+		if len(widgets) > 0 {
+			*fields = append(*fields, &field{name: name, objNr: objNr(obj), dict: d, ft: xft, ff: xff, widgets: widgets})
+		}
+
+		if len(subFields) > 0 {
+			err = collectFields(ctx, subFields, name, xft, xff, fields)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return
+}
+
+// acroFormDict returns the interactive form dict or nil.
+func acroFormDict(ctx *types.PDFContext) (*types.PDFDict, error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+}
+
+// terminalFields returns all terminal fields of the interactive form.
+func terminalFields(ctx *types.PDFContext) (fields []*field, err error) {
+
+	d, err := acroFormDict(ctx)
+	if err != nil || d == nil {
+		return
+	}
+
+	arr, err := ctx.DereferenceArray(d.Dict["Fields"])
+	if err != nil || arr == nil {
+		return
+	}
+
+	err = collectFields(ctx, *arr, "", "", 0, &fields)
+
+	return
+}
+
+// widgetPages maps the object numbers of annotations to their page numbers.
+func widgetPages(ctx *types.PDFContext) (map[int]int, error) {
+
+	m := map[int]int{}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		arr, err := ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil {
+			return nil, err
+		}
+
+		if arr == nil {
+			continue
+		}
+
+		for _, obj := range *arr {
+			if nr := objNr(obj); nr > 0 {
+				m[nr] = i + 1
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// appearanceStates returns the "on" states of a button widget.
+func appearanceStates(ctx *types.PDFContext, w *types.PDFDict) (states []string) {
+
+	ap, err := ctx.DereferenceDict(w.Dict["AP"])
+	if err != nil || ap == nil {
+		return
+	}
+
+	n, err := ctx.DereferenceDict(ap.Dict["N"])
+	if err != nil || n == nil {
+		return
+	}
+
+	for k := range n.Dict {
+		if k != "Off" {
+			states = append(states, k)
+		}
+	}
+
+	sort.Strings(states)
+
+	return
+}
+
+func (f *field) onStates(ctx *types.PDFContext) (states []string) {
+
+	m := map[string]bool{}
+
+	for _, w := range f.widgets {
+		for _, s := range appearanceStates(ctx, w.dict) {
+			if !m[s] {
+				m[s] = true
+				states = append(states, s)
+			}
+		}
+	}
+
+	return
+}
+
+// choiceOptions returns the export values for the options of a choice field.
+func choiceOptions(ctx *types.PDFContext, opt interface{}) (opts []string) {
+
+	arr, err := ctx.DereferenceArray(opt)
+	if err != nil || arr == nil {
+		return
+	}
+
+	for _, obj := range *arr {
+
+		o, err := ctx.Dereference(obj)
+		if err != nil {
+			continue
+		}
+
+		// An option is either a text string or an array of export value and display text.
+		if a, ok := o.(types.PDFArray); ok && len(a) > 0 {
+			opts = append(opts, textString(ctx, a[0]))
+			continue
+		}
+
+		opts = append(opts, textString(ctx, o))
+	}
+
+	return
+}
+
+// value returns the value of an inheritable value entry like V or DV.
+func (f *field) value(ctx *types.PDFContext, key string) (value string, values []string, err error) {
+
+	obj, err := inheritedEntry(ctx, f.dict, key)
+	if err != nil || obj == nil {
+		return
+	}
+
+	obj, err = ctx.Dereference(obj)
+	if err != nil {
+		return
+	}
+
+	if arr, ok := obj.(types.PDFArray); ok {
+		for _, o := range arr {
+			values = append(values, textString(ctx, o))
+		}
+		return
+	}
+
+	return textString(ctx, obj), nil, nil
+}
+
+func (f *field) export(ctx *types.PDFContext, pageNrs map[int]int) (Field, error) {
+
+	fd := Field{
+		Name:     f.name,
+		Type:     f.typ(),
+		ReadOnly: f.ff&ffReadOnly > 0,
+		Required: f.ff&ffRequired > 0,
+	}
+
+	v, vv, err := f.value(ctx, "V")
+	if err != nil {
+		return fd, err
+	}
+
+	dv, _, err := f.value(ctx, "DV")
+	if err != nil {
+		return fd, err
+	}
+
+	fd.Default = dv
+
+	switch fd.Type {
+
+	case "text":
+		fd.Value = v
+		fd.Multiline = f.ff&ffMultiline > 0
+		fd.Password = f.ff&ffPassword > 0
+		fd.Comb = f.ff&ffComb > 0
+		fd.MaxLen = f.maxLen(ctx)
+
+	case "checkbox", "radio":
+		fd.Value = v
+		if fd.Value == "" {
+			fd.Value = "Off"
+		}
+		fd.Options = f.onStates(ctx)
+
+	case "choice":
+		fd.Combo = f.ff&ffCombo > 0
+		fd.Editable = f.ff&ffEdit > 0
+		fd.MultiSelect = f.ff&ffMultiSelect > 0
+		fd.Value = v
+		if len(vv) > 0 {
+			if fd.MultiSelect {
+				fd.Values = vv
+			} else {
+				fd.Value = vv[0]
+			}
+		}
+		if obj, err := inheritedEntry(ctx, f.dict, "Opt"); err == nil {
+			fd.Options = choiceOptions(ctx, obj)
+		}
+
+	case "signature":
+		if v, found := f.dict.Find("V"); found && v != nil {
+			fd.Value = "signed"
+		}
+	}
+
+	for _, w := range f.widgets {
+		if p, ok := pageNrs[w.objNr]; ok {
+			fd.Pages = appendUnique(fd.Pages, p)
+		}
+	}
+
+	return fd, nil
+}
+
+func appendUnique(ii []int, i int) []int {
+	for _, j := range ii {
+		if i == j {
+			return ii
+		}
+	}
+	return append(ii, i)
+}
+
+// Fields returns all terminal fields of the interactive form in field tree order.
+func Fields(ctx *types.PDFContext) (fields []Field, err error) {
+
+	logDebugForm.Println("Fields begin")
+
+	ff, err := terminalFields(ctx)
+	if err != nil {
+		return
+	}
+
+	pageNrs, err := widgetPages(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, f := range ff {
+		fd, err := f.export(ctx, pageNrs)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fd)
+	}
+
+	logDebugForm.Println("Fields end")
+
+	return
+}
+
+func fieldString(f Field) string {
+
+	s := fmt.Sprintf("%s (%s)", f.Name, f.Type)
+
+	switch {
+	case len(f.Values) > 0:
+		s += " = " + strings.Join(f.Values, ", ")
+	case f.Value != "":
+		s += fmt.Sprintf(" = %q", f.Value)
+	}
+
+	if len(f.Options) > 0 {
+		s += " [" + strings.Join(f.Options, "|") + "]"
+	}
+
+	if f.ReadOnly {
+		s += " read only"
+	}
+
+	return s
+}
+
+// List returns a list of all terminal form fields.
+// If jsonFile is not empty the fields are also written as JSON to jsonFile.
+func List(ctx *types.PDFContext, jsonFile string) (list []string, err error) {
+
+	logDebugForm.Println("List begin")
+
+	fields, err := Fields(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, f := range fields {
+		list = append(list, fieldString(f))
+	}
+
+	if jsonFile != "" {
+
+		bb, err := json.MarshalIndent(formFile{Fields: fields}, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(jsonFile, bb, os.ModePerm)
+		if err != nil {
+			return nil, errors.Wrapf(err, "List: can't write %s", jsonFile)
+		}
+	}
+
+	logDebugForm.Println("List end")
+
+	return
+}
@@ -0,0 +1,168 @@
+package form
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// xfaPacket is a named part of an XML Data Package (XDP).
+type xfaPacket struct {
+	name   string
+	objNr  int
+	stream *types.PDFStreamDict
+}
+
+// xfaPackets returns the packets of the XFA entry of the AcroForm dict.
+// A single stream contains the complete XDP and is returned as packet "xdp".
+// See 12.7.8 XFA Forms.
+func xfaPackets(ctx *types.PDFContext, acroForm *types.PDFDict) (packets []xfaPacket, err error) {
+
+	obj, found := acroForm.Find("XFA")
+	if !found {
+		return
+	}
+
+	o, err := ctx.Dereference(obj)
+	if err != nil || o == nil {
+		return
+	}
+
+	switch o := o.(type) {
+
+	case types.PDFStreamDict:
+		packets = append(packets, xfaPacket{"xdp", objNr(obj), &o})
+
+	case types.PDFArray:
+		for i := 0; i+1 < len(o); i += 2 {
+			sd, err := ctx.DereferenceStreamDict(o[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if sd == nil {
+				continue
+			}
+			packets = append(packets, xfaPacket{textString(ctx, o[i]), objNr(o[i+1]), sd})
+		}
+
+	default:
+		return nil, errors.New("xfaPackets: corrupt XFA entry")
+	}
+
+	return
+}
+
+// ExportXFA writes the XFA packets of the interactive form as XML files into dirOut.
+// The files are named after fileName and the packet eg. in_template.xml.
+// The preamble and postamble packets just open and close the enclosing xdp element and are skipped.
+// ok returns false if there is no XFA form.
+func ExportXFA(ctx *types.PDFContext, dirOut, fileName string) (ok bool, err error) {
+
+	logDebugForm.Println("ExportXFA begin")
+
+	acroForm, err := acroFormDict(ctx)
+	if err != nil || acroForm == nil {
+		return
+	}
+
+	packets, err := xfaPackets(ctx, acroForm)
+	if err != nil || len(packets) == 0 {
+		return
+	}
+
+	for _, p := range packets {
+
+		if p.name == "preamble" || p.name == "postamble" {
+			continue
+		}
+
+		err = filter.DecodeStream(p.stream)
+		if err != nil {
+			return false, err
+		}
+
+		fn := fmt.Sprintf("%s/%s_%s.xml", dirOut, fileName, packetFileName(p.name))
+
+		logInfoForm.Printf("writing %s\n", fn)
+
+		err = ioutil.WriteFile(fn, p.stream.Content, os.ModePerm)
+		if err != nil {
+			return false, errors.Wrapf(err, "ExportXFA: can't write %s", fn)
+		}
+	}
+
+	logDebugForm.Println("ExportXFA end")
+
+	return true, nil
+}
+
+// packetFileName replaces all characters of a packet name not allowed in file names.
+// This keeps packet names like ../x from escaping the output dir.
+func packetFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// StripXFA removes the XFA form so viewers fall back to the AcroForm fields.
+// ok returns false if there is no XFA form.
+func StripXFA(ctx *types.PDFContext) (ok bool, err error) {
+
+	logDebugForm.Println("StripXFA begin")
+
+	acroForm, err := acroFormDict(ctx)
+	if err != nil || acroForm == nil {
+		return
+	}
+
+	obj, found := acroForm.Find("XFA")
+	if !found {
+		return
+	}
+
+	packets, err := xfaPackets(ctx, acroForm)
+	if err != nil {
+		return
+	}
+
+	acroForm.Delete("XFA")
+
+	// NeedsRendering asks viewers to render the XFA form.
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+	rootDict.Delete("NeedsRendering")
+
+	if fields, err := ctx.DereferenceArray(acroForm.Dict["Fields"]); err == nil && (fields == nil || len(*fields) == 0) {
+		logInfoForm.Println("StripXFA: no AcroForm fields left")
+	}
+
+	for _, p := range packets {
+		if p.objNr > 0 {
+			err = ctx.DeleteObject(p.objNr)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if nr := objNr(obj); nr > 0 {
+		err = ctx.DeleteObject(nr)
+		if err != nil {
+			return
+		}
+	}
+
+	logDebugForm.Println("StripXFA end")
+
+	return true, nil
+}
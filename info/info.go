@@ -0,0 +1,679 @@
+// Package info provides a summary of the properties of a PDF file like page boxes, fonts, images and permissions.
+package info
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/attach"
+	"github.com/hhrutter/pdfcpu/optimize"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+var logDebugInfo *log.Logger
+
+func init() {
+	logDebugInfo = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugInfo = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugInfo = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Page represents the boxes of a page.
+// Width and height are the dimensions of the visible region taking rotation into account.
+type Page struct {
+	Number   int       `json:"page"`
+	Width    float64   `json:"width"`
+	Height   float64   `json:"height"`
+	Rotate   int       `json:"rotate,omitempty"`
+	MediaBox []float64 `json:"mediaBox"`
+	CropBox  []float64 `json:"cropBox,omitempty"`
+	BleedBox []float64 `json:"bleedBox,omitempty"`
+	TrimBox  []float64 `json:"trimBox,omitempty"`
+	ArtBox   []float64 `json:"artBox,omitempty"`
+}
+
+// Font represents a font used by some pages.
+type Font struct {
+	ObjNr    int    `json:"objNr"`
+	Name     string `json:"name"`
+	Subtype  string `json:"subtype"`
+	Encoding string `json:"encoding"`
+	Embedded bool   `json:"embedded"`
+	Subset   bool   `json:"subset"`
+	Size     int64  `json:"size,omitempty"`
+	Pages    []int  `json:"pages"`
+}
+
+// Image represents an image XObject used by some pages.
+type Image struct {
+	ObjNr            int    `json:"objNr"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	BitsPerComponent int    `json:"bitsPerComponent,omitempty"`
+	ColorSpace       string `json:"colorSpace,omitempty"`
+	Filter           string `json:"filter,omitempty"`
+	Size             int64  `json:"size"`
+	Pages            []int  `json:"pages"`
+}
+
+// Encryption represents the security handler in use along with the permissions granted.
+type Encryption struct {
+	V           int      `json:"v"`
+	R           int      `json:"r"`
+	KeyLength   int      `json:"keyLength"`
+	Method      string   `json:"method"`
+	Permissions []string `json:"permissions"`
+}
+
+// Info represents the properties of a PDF file.
+// Pages, fonts, images and attachments are available in detail only.
+type Info struct {
+	FileName    string      `json:"fileName"`
+	FileSize    int64       `json:"fileSize"`
+	Version     string      `json:"version"`
+	Author      string      `json:"author,omitempty"`
+	Creator     string      `json:"creator,omitempty"`
+	Producer    string      `json:"producer,omitempty"`
+	PageCount   int         `json:"pageCount"`
+	PageSize    string      `json:"pageSize"`
+	Linearized  bool        `json:"linearized"`
+	Tagged      bool        `json:"tagged"`
+	Form        bool        `json:"form"`
+	XFA         bool        `json:"xfa"`
+	FontCount   int         `json:"fontCount"`
+	ImageCount  int         `json:"imageCount"`
+	Attachments int         `json:"attachmentCount"`
+	Encryption  *Encryption `json:"encryption,omitempty"`
+
+	Pages           []Page   `json:"pages,omitempty"`
+	Fonts           []Font   `json:"fonts,omitempty"`
+	Images          []Image  `json:"images,omitempty"`
+	AttachmentNames []string `json:"attachments,omitempty"`
+}
+
+func number(ctx *types.PDFContext, obj interface{}) (f float64, ok bool) {
+
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+
+	switch n := obj.(type) {
+
+	case types.PDFInteger:
+		return float64(n.Value()), true
+
+	case types.PDFFloat:
+		return n.Value(), true
+	}
+
+	return
+}
+
+func numberArray(ctx *types.PDFContext, obj interface{}) (a []float64) {
+
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil || arr == nil {
+		return
+	}
+
+	for _, o := range *arr {
+		if f, ok := number(ctx, o); ok {
+			a = append(a, f)
+		}
+	}
+
+	return
+}
+
+// box returns a normalized rectangle of a page dict entry, inherited entries are taken into account.
+func box(ctx *types.PDFContext, pageDict *types.PDFDict, key string, inherited bool) ([]float64, error) {
+
+	obj, found := pageDict.Find(key)
+	if !found && inherited {
+		var err error
+		obj, err = ctx.InheritedPageAttr(pageDict, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := numberArray(ctx, obj)
+	if len(r) != 4 {
+		return nil, nil
+	}
+
+	if r[0] > r[2] {
+		r[0], r[2] = r[2], r[0]
+	}
+	if r[1] > r[3] {
+		r[1], r[3] = r[3], r[1]
+	}
+
+	return r, nil
+}
+
+func page(ctx *types.PDFContext, pageNr int, pageDict *types.PDFDict) (p Page, err error) {
+
+	p.Number = pageNr
+
+	p.MediaBox, err = box(ctx, pageDict, "MediaBox", true)
+	if err != nil {
+		return
+	}
+
+	p.CropBox, err = box(ctx, pageDict, "CropBox", true)
+	if err != nil {
+		return
+	}
+
+	for _, b := range []struct {
+		key string
+		r   *[]float64
+	}{
+		{"BleedBox", &p.BleedBox},
+		{"TrimBox", &p.TrimBox},
+		{"ArtBox", &p.ArtBox},
+	} {
+		*b.r, err = box(ctx, pageDict, b.key, false)
+		if err != nil {
+			return
+		}
+	}
+
+	obj, err := ctx.InheritedPageAttr(pageDict, "Rotate")
+	if err != nil {
+		return
+	}
+	if f, ok := number(ctx, obj); ok {
+		p.Rotate = (int(f)%360 + 360) % 360
+	}
+
+	r := p.CropBox
+	if r == nil {
+		r = p.MediaBox
+	}
+	if r != nil {
+		p.Width, p.Height = r[2]-r[0], r[3]-r[1]
+	}
+	if p.Rotate == 90 || p.Rotate == 270 {
+		p.Width, p.Height = p.Height, p.Width
+	}
+
+	return
+}
+
+func pages(ctx *types.PDFContext) ([]Page, error) {
+
+	pageRefs, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	pp := make([]Page, len(pageRefs))
+
+	for i, indRef := range pageRefs {
+
+		d, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		if d == nil {
+			pp[i] = Page{Number: i + 1}
+			continue
+		}
+
+		pp[i], err = page(ctx, i+1, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pp, nil
+}
+
+func sizeString(p Page) string {
+	return fmt.Sprintf("%.2f x %.2f points", p.Width, p.Height)
+}
+
+// pageSize returns the size shared by all pages or "mixed".
+func pageSize(pp []Page) string {
+
+	if len(pp) == 0 {
+		return ""
+	}
+
+	for _, p := range pp[1:] {
+		if sizeString(p) != sizeString(pp[0]) {
+			return "mixed"
+		}
+	}
+
+	return sizeString(pp[0])
+}
+
+// pagesUsing returns the sorted page numbers of pages using an object.
+func pagesUsing(pageObjs []types.IntSet, objNr int) []int {
+
+	pp := []int{}
+
+	for i, s := range pageObjs {
+		if s[objNr] {
+			pp = append(pp, i+1)
+		}
+	}
+
+	return pp
+}
+
+// fontFileSize returns the length of the embedded font program of a font.
+func fontFileSize(ctx *types.PDFContext, fontDict *types.PDFDict, objNr int) (size int64, embedded bool) {
+
+	fd, err := optimize.FontDescriptor(ctx.XRefTable, fontDict, objNr)
+	if err != nil || fd == nil {
+		return 0, false
+	}
+
+	indRef := optimize.FontDescriptorFontFileIndirectObjectRef(fd)
+	if indRef == nil {
+		return 0, false
+	}
+
+	sd, err := ctx.DereferenceStreamDict(*indRef)
+	if err != nil || sd == nil {
+		return 0, true
+	}
+
+	return streamSize(sd), true
+}
+
+func streamSize(sd *types.PDFStreamDict) int64 {
+
+	if sd.StreamLength != nil {
+		return *sd.StreamLength
+	}
+
+	return int64(len(sd.Raw))
+}
+
+func fonts(ctx *types.PDFContext) (ff []Font) {
+
+	oc := ctx.Optimize
+
+	var objNrs []int
+	for objNr := range oc.FontObjects {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+
+		fo := oc.FontObjects[objNr]
+
+		f := Font{
+			ObjNr:    objNr,
+			Name:     fo.FontName,
+			Subtype:  fo.SubType(),
+			Encoding: fo.Encoding(),
+			Subset:   fo.Prefix != "",
+			Pages:    pagesUsing(oc.PageFonts, objNr),
+		}
+
+		if baseFont := fo.FontDict.NameEntry("BaseFont"); baseFont != nil {
+			f.Name = *baseFont
+		}
+
+		f.Size, f.Embedded = fontFileSize(ctx, fo.FontDict, objNr)
+
+		ff = append(ff, f)
+	}
+
+	return
+}
+
+func colorSpace(ctx *types.PDFContext, sd *types.PDFStreamDict) string {
+
+	o, err := ctx.Dereference(sd.Dict["ColorSpace"])
+	if err != nil || o == nil {
+		return ""
+	}
+
+	if a, ok := o.(types.PDFArray); ok && len(a) > 0 {
+		o = a[0]
+	}
+
+	if n, ok := o.(types.PDFName); ok {
+		return n.Value()
+	}
+
+	return ""
+}
+
+func images(ctx *types.PDFContext) (ii []Image) {
+
+	oc := ctx.Optimize
+
+	var objNrs []int
+	for objNr := range oc.ImageObjects {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+
+		sd := oc.ImageObjects[objNr].ImageDict
+
+		img := Image{
+			ObjNr:      objNr,
+			ColorSpace: colorSpace(ctx, sd),
+			Size:       streamSize(sd),
+			Pages:      pagesUsing(oc.PageImages, objNr),
+		}
+
+		for _, e := range []struct {
+			key string
+			i   *int
+		}{
+			{"Width", &img.Width},
+			{"Height", &img.Height},
+			{"BitsPerComponent", &img.BitsPerComponent},
+		} {
+			if i := sd.IntEntry(e.key); i != nil {
+				*e.i = *i
+			}
+		}
+
+		var ss []string
+		for _, f := range sd.FilterPipeline {
+			ss = append(ss, f.Name)
+		}
+		img.Filter = strings.Join(ss, ",")
+
+		ii = append(ii, img)
+	}
+
+	return
+}
+
+// permissions returns the operations granted by the access permissions of an encrypted file.
+// See 7.6.3.2 Table 22.
+func permissions(enc *types.Enc) (pp []string) {
+
+	bits := []struct {
+		bit  uint
+		name string
+		r3   bool // revision 3 or greater only
+	}{
+		{3, "print", false},
+		{4, "modify", false},
+		{5, "copy", false},
+		{6, "annotate", false},
+		{9, "fill forms", true},
+		{10, "extract for accessibility", true},
+		{11, "assemble", true},
+		{12, "print high quality", true},
+	}
+
+	for _, b := range bits {
+		if b.r3 && enc.R < 3 {
+			continue
+		}
+		if enc.P&(1<<(b.bit-1)) > 0 {
+			pp = append(pp, b.name)
+		}
+	}
+
+	return
+}
+
+func encryption(ctx *types.PDFContext) *Encryption {
+
+	if ctx.Encrypt == nil || ctx.E == nil {
+		return nil
+	}
+
+	e := &Encryption{
+		V:           ctx.E.V,
+		R:           ctx.E.R,
+		KeyLength:   ctx.E.L,
+		Method:      "RC4",
+		Permissions: permissions(ctx.E),
+	}
+
+	if ctx.AES4Streams {
+		e.Method = "AES"
+	}
+
+	return e
+}
+
+// form returns whether the catalog holds an interactive form with fields or XFA.
+func form(ctx *types.PDFContext) (acroForm, xfa bool, err error) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	obj, found := rootDict.Find("AcroForm")
+	if !found {
+		return
+	}
+
+	d, err := ctx.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return
+	}
+
+	if a, err := ctx.DereferenceArray(d.Dict["Fields"]); err == nil && a != nil {
+		acroForm = len(*a) > 0
+	}
+
+	_, xfa = d.Find("XFA")
+
+	return
+}
+
+// PDFInfo returns the properties of a PDF file whose fonts and images have been recorded in the optimization context.
+func PDFInfo(ctx *types.PDFContext, detail bool) (info *Info, err error) {
+
+	logDebugInfo.Println("PDFInfo begin")
+
+	info = &Info{
+		FileName:   ctx.Read.FileName,
+		FileSize:   ctx.Read.FileSize,
+		Version:    ctx.VersionString(),
+		Author:     ctx.Author,
+		Creator:    ctx.Creator,
+		Producer:   ctx.Producer,
+		PageCount:  ctx.PageCount,
+		Linearized: ctx.Read.Linearized,
+		Tagged:     ctx.Tagged,
+		FontCount:  len(ctx.Optimize.FontObjects),
+		ImageCount: len(ctx.Optimize.ImageObjects),
+		Encryption: encryption(ctx),
+	}
+
+	pp, err := pages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.PageSize = pageSize(pp)
+
+	info.Form, info.XFA, err = form(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := attach.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.Attachments = len(attachments)
+
+	if detail {
+		info.Pages = pp
+		info.Fonts = fonts(ctx)
+		info.Images = images(ctx)
+		info.AttachmentNames = attachments
+	}
+
+	logDebugInfo.Println("PDFInfo end")
+
+	return
+}
+
+func boxString(name string, r []float64) string {
+
+	ss := make([]string, len(r))
+	for i, f := range r {
+		ss[i] = fmt.Sprintf("%.2f", f)
+	}
+
+	return fmt.Sprintf(" %s [%s]", name, strings.Join(ss, " "))
+}
+
+func pageString(p Page) string {
+
+	s := fmt.Sprintf("page %d: %s", p.Number, sizeString(p))
+
+	if p.Rotate != 0 {
+		s += fmt.Sprintf(" rotated %d", p.Rotate)
+	}
+
+	for _, b := range []struct {
+		name string
+		r    []float64
+	}{
+		{"MediaBox", p.MediaBox},
+		{"CropBox", p.CropBox},
+		{"BleedBox", p.BleedBox},
+		{"TrimBox", p.TrimBox},
+		{"ArtBox", p.ArtBox},
+	} {
+		if b.r != nil {
+			s += boxString(b.name, b.r)
+		}
+	}
+
+	return s
+}
+
+func pagesString(pp []int) string {
+
+	ss := make([]string, len(pp))
+	for i, p := range pp {
+		ss[i] = fmt.Sprintf("%d", p)
+	}
+
+	return strings.Join(ss, ",")
+}
+
+func fontString(f Font) string {
+
+	embedded := "not embedded"
+	if f.Embedded {
+		embedded = fmt.Sprintf("embedded %s", types.ByteSize(f.Size))
+		if f.Subset {
+			embedded += " subset"
+		}
+	}
+
+	return fmt.Sprintf("obj#%d %s %s %s %s pages %s", f.ObjNr, f.Name, f.Subtype, f.Encoding, embedded, pagesString(f.Pages))
+}
+
+func imageString(img Image) string {
+
+	return fmt.Sprintf("obj#%d %dx%d %s bpc %d %s %s pages %s",
+		img.ObjNr, img.Width, img.Height, img.ColorSpace, img.BitsPerComponent, img.Filter, types.ByteSize(img.Size), pagesString(img.Pages))
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// List returns a text representation of the properties of a PDF file.
+func List(info *Info) (list []string) {
+
+	list = append(list,
+		fmt.Sprintf("      File: %s (%s)", info.FileName, types.ByteSize(info.FileSize)),
+		fmt.Sprintf("   Version: %s", info.Version))
+
+	for _, s := range []struct{ key, val string }{
+		{"    Author", info.Author},
+		{"   Creator", info.Creator},
+		{"  Producer", info.Producer},
+	} {
+		if s.val != "" {
+			list = append(list, fmt.Sprintf("%s: %s", s.key, s.val))
+		}
+	}
+
+	list = append(list,
+		fmt.Sprintf("     Pages: %d", info.PageCount),
+		fmt.Sprintf(" Page size: %s", info.PageSize),
+		fmt.Sprintf("Linearized: %s", yesNo(info.Linearized)),
+		fmt.Sprintf("    Tagged: %s", yesNo(info.Tagged)),
+		fmt.Sprintf("      Form: %s", yesNo(info.Form)),
+		fmt.Sprintf("       XFA: %s", yesNo(info.XFA)),
+		fmt.Sprintf("     Fonts: %d", info.FontCount),
+		fmt.Sprintf("    Images: %d", info.ImageCount),
+		fmt.Sprintf("  Attached: %d", info.Attachments))
+
+	if e := info.Encryption; e != nil {
+		list = append(list,
+			fmt.Sprintf(" Encrypted: %s %d bit (V%d R%d)", e.Method, e.KeyLength, e.V, e.R),
+			fmt.Sprintf("Permission: %s", strings.Join(e.Permissions, ", ")))
+	} else {
+		list = append(list, " Encrypted: no")
+	}
+
+	if len(info.Pages) > 0 {
+		list = append(list, "", "Pages:")
+		for _, p := range info.Pages {
+			list = append(list, pageString(p))
+		}
+	}
+
+	if len(info.Fonts) > 0 {
+		list = append(list, "", "Fonts:")
+		for _, f := range info.Fonts {
+			list = append(list, fontString(f))
+		}
+	}
+
+	if len(info.Images) > 0 {
+		list = append(list, "", "Images:")
+		for _, img := range info.Images {
+			list = append(list, imageString(img))
+		}
+	}
+
+	if len(info.AttachmentNames) > 0 {
+		list = append(list, "", "Attachments:")
+		list = append(list, info.AttachmentNames...)
+	}
+
+	return
+}
+
+// JSON returns the indented JSON representation of the properties of a PDF file.
+func JSON(info *Info) ([]byte, error) {
+	return json.MarshalIndent(info, "", "\t")
+}
@@ -0,0 +1,397 @@
+// Package layer provides management code for optional content groups (layers).
+package layer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugLayer *log.Logger
+
+func init() {
+	logDebugLayer = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugLayer = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugLayer = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Layer represents an optional content group along with its state in the default configuration.
+type Layer struct {
+	ObjNr  int
+	Name   string
+	On     bool
+	Locked bool
+}
+
+// ocProperties returns the optional content properties dict of the catalog.
+func ocProperties(ctx *types.PDFContext) (*types.PDFDict, error) {
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := ctx.DereferenceDict(root.Dict["OCProperties"])
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return nil, errors.New("no layers available")
+	}
+
+	return d, nil
+}
+
+// defaultConfig returns the default viewing configuration dict, which gets created if missing.
+func defaultConfig(ctx *types.PDFContext, ocProps *types.PDFDict) (*types.PDFDict, error) {
+
+	d, err := ctx.DereferenceDict(ocProps.Dict["D"])
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		d1 := types.NewPDFDict()
+		ocProps.Insert("D", d1)
+		d = &d1
+	}
+
+	return d, nil
+}
+
+// indRefs returns the indirect references of the array entry key of d.
+func indRefs(ctx *types.PDFContext, d *types.PDFDict, key string) ([]types.PDFIndirectRef, error) {
+
+	arr, err := ctx.DereferenceArray(d.Dict[key])
+	if err != nil || arr == nil {
+		return nil, err
+	}
+
+	var refs []types.PDFIndirectRef
+	for _, o := range *arr {
+		if indRef, ok := o.(types.PDFIndirectRef); ok {
+			refs = append(refs, indRef)
+		}
+	}
+
+	return refs, nil
+}
+
+// contains returns true if refs contains an indirect reference to objNr.
+func contains(refs []types.PDFIndirectRef, objNr int) bool {
+
+	for _, indRef := range refs {
+		if indRef.ObjectNumber.Value() == objNr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// layerName returns the name of an optional content group.
+func layerName(ctx *types.PDFContext, d *types.PDFDict) string {
+
+	o, err := ctx.Dereference(d.Dict["Name"])
+	if err != nil {
+		return ""
+	}
+
+	var s string
+
+	switch str := o.(type) {
+	case types.PDFStringLiteral:
+		s, err = types.StringLiteralToString(str.Value())
+	case types.PDFHexLiteral:
+		s, err = types.HexLiteralToString(str.Value())
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// Layers returns the optional content groups of ctx along with their default state.
+func Layers(ctx *types.PDFContext) (layers []Layer, err error) {
+
+	ocProps, err := ocProperties(ctx)
+	if err != nil {
+		return
+	}
+
+	ocgs, err := indRefs(ctx, ocProps, "OCGs")
+	if err != nil {
+		return
+	}
+
+	d, err := defaultConfig(ctx, ocProps)
+	if err != nil {
+		return
+	}
+
+	on, err := indRefs(ctx, d, "ON")
+	if err != nil {
+		return
+	}
+
+	off, err := indRefs(ctx, d, "OFF")
+	if err != nil {
+		return
+	}
+
+	locked, err := indRefs(ctx, d, "Locked")
+	if err != nil {
+		return
+	}
+
+	baseOn := true
+	if bs := d.NameEntry("BaseState"); bs != nil && *bs == "OFF" {
+		baseOn = false
+	}
+
+	for _, indRef := range ocgs {
+
+		ocg, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+		if ocg == nil {
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+
+		l := Layer{
+			ObjNr:  objNr,
+			Name:   layerName(ctx, ocg),
+			On:     baseOn,
+			Locked: contains(locked, objNr),
+		}
+
+		switch {
+		case contains(on, objNr):
+			l.On = true
+		case contains(off, objNr):
+			l.On = false
+		}
+
+		layers = append(layers, l)
+	}
+
+	return layers, nil
+}
+
+// List returns a list of all layers and their default state.
+func List(ctx *types.PDFContext) (list []string, err error) {
+
+	logDebugLayer.Println("List begin")
+
+	layers, err := Layers(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, l := range layers {
+
+		s := "off"
+		if l.On {
+			s = "on"
+		}
+
+		if l.Locked {
+			s += ", locked"
+		}
+
+		list = append(list, fmt.Sprintf("obj#%d %s (%s)", l.ObjNr, l.Name, s))
+	}
+
+	logDebugLayer.Println("List end")
+
+	return
+}
+
+// selectLayers returns the object numbers of the layers with the given names.
+// If no names are given all layers are selected.
+func selectLayers(ctx *types.PDFContext, names []string) (types.IntSet, error) {
+
+	layers, err := Layers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := types.IntSet{}
+
+	if len(names) == 0 {
+		for _, l := range layers {
+			selected[l.ObjNr] = true
+		}
+		return selected, nil
+	}
+
+	for _, name := range names {
+		found := false
+		for _, l := range layers {
+			if l.Name == name {
+				selected[l.ObjNr] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("layer not found: %s", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// filtered returns the elements of arr not referring to any of objNrs.
+// Nested arrays like in Order or RBGroups get filtered too.
+func filtered(arr types.PDFArray, objNrs types.IntSet) types.PDFArray {
+
+	a := types.PDFArray{}
+
+	for _, o := range arr {
+
+		switch o := o.(type) {
+
+		case types.PDFIndirectRef:
+			if objNrs[o.ObjectNumber.Value()] {
+				continue
+			}
+
+		case types.PDFArray:
+			a = append(a, filtered(o, objNrs))
+			continue
+		}
+
+		a = append(a, o)
+	}
+
+	return a
+}
+
+// update removes the selected layers from the array entry key of d and appends them if add is true.
+func update(ctx *types.PDFContext, d *types.PDFDict, key string, objNrs types.IntSet, add bool) error {
+
+	arr, err := ctx.DereferenceArray(d.Dict[key])
+	if err != nil {
+		return err
+	}
+
+	a := types.PDFArray{}
+	if arr != nil {
+		a = filtered(*arr, objNrs)
+	}
+
+	if add {
+		for _, objNr := range sortedKeys(objNrs) {
+			gen := 0
+			if entry, found := ctx.Find(objNr); found && entry.Generation != nil {
+				gen = *entry.Generation
+			}
+			a = append(a, types.NewPDFIndirectRef(objNr, gen))
+		}
+	}
+
+	if len(a) == 0 {
+		d.Delete(key)
+		return nil
+	}
+
+	d.Update(key, a)
+
+	return nil
+}
+
+// sortedKeys returns the object numbers of s in ascending order.
+func sortedKeys(s types.IntSet) []int {
+
+	var keys []int
+	for k, v := range s {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Ints(keys)
+
+	return keys
+}
+
+// modify applies f to the default configuration for the selected layers.
+func modify(ctx *types.PDFContext, names []string, f func(d *types.PDFDict, objNrs types.IntSet) error) error {
+
+	objNrs, err := selectLayers(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	ocProps, err := ocProperties(ctx)
+	if err != nil {
+		return err
+	}
+
+	d, err := defaultConfig(ctx, ocProps)
+	if err != nil {
+		return err
+	}
+
+	return f(d, objNrs)
+}
+
+// Show turns the selected layers on in the default configuration.
+func Show(ctx *types.PDFContext, names []string) error {
+
+	logDebugLayer.Printf("Show %v\n", names)
+
+	return modify(ctx, names, func(d *types.PDFDict, objNrs types.IntSet) error {
+		if err := update(ctx, d, "OFF", objNrs, false); err != nil {
+			return err
+		}
+		return update(ctx, d, "ON", objNrs, true)
+	})
+}
+
+// Hide turns the selected layers off in the default configuration.
+func Hide(ctx *types.PDFContext, names []string) error {
+
+	logDebugLayer.Printf("Hide %v\n", names)
+
+	return modify(ctx, names, func(d *types.PDFDict, objNrs types.IntSet) error {
+		if err := update(ctx, d, "ON", objNrs, false); err != nil {
+			return err
+		}
+		return update(ctx, d, "OFF", objNrs, true)
+	})
+}
+
+// Lock prevents the user from changing the state of the selected layers in a viewer.
+func Lock(ctx *types.PDFContext, names []string) error {
+
+	logDebugLayer.Printf("Lock %v\n", names)
+
+	// Locked is available since PDF 1.6.
+	if ctx.Version() < types.V16 {
+		v := types.V16
+		ctx.RootVersion = &v
+	}
+
+	return modify(ctx, names, func(d *types.PDFDict, objNrs types.IntSet) error {
+		return update(ctx, d, "Locked", objNrs, true)
+	})
+}
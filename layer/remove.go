@@ -0,0 +1,589 @@
+package layer
+
+import (
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// remover strips the content of removed layers.
+type remover struct {
+	ctx       *types.PDFContext
+	objNrs    types.IntSet     // the removed optional content groups
+	visited   types.IntSet     // the processed form XObjects
+	resources []*types.PDFDict // the resource dicts whose Properties get pruned
+}
+
+// removedOC returns true if o is a removed optional content group
+// or a membership dict whose groups have all been removed.
+func (r *remover) removedOC(o interface{}) bool {
+
+	if indRef, ok := o.(types.PDFIndirectRef); ok && r.objNrs[indRef.ObjectNumber.Value()] {
+		return true
+	}
+
+	d, err := r.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return false
+	}
+
+	if t := d.Type(); t == nil || *t != "OCMD" {
+		return false
+	}
+
+	ocgs, err := r.ctx.Dereference(d.Dict["OCGs"])
+	if err != nil {
+		return false
+	}
+
+	switch ocgs := ocgs.(type) {
+
+	case types.PDFDict:
+		return r.removedOC(d.Dict["OCGs"])
+
+	case types.PDFArray:
+		if len(ocgs) == 0 {
+			return false
+		}
+		for _, o := range ocgs {
+			if !r.removedOC(o) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// resourceEntry returns the value for name in the resource category key of res.
+func (r *remover) resourceEntry(res *types.PDFDict, key string, operands []interface{}) (interface{}, error) {
+
+	if res == nil || len(operands) == 0 {
+		return nil, nil
+	}
+
+	name, ok := operands[len(operands)-1].(types.PDFName)
+	if !ok {
+		return nil, nil
+	}
+
+	d, err := r.ctx.DereferenceDict(res.Dict[key])
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	return d.Dict[name.Value()], nil
+}
+
+// hidden returns true if op starts a marked content section of a removed layer.
+func (r *remover) hidden(op content.Operation, res *types.PDFDict) (bool, error) {
+
+	if len(op.Operands) != 2 {
+		return false, nil
+	}
+
+	if tag, ok := op.Operands[0].(types.PDFName); !ok || tag != "OC" {
+		return false, nil
+	}
+
+	o, err := r.resourceEntry(res, "Properties", op.Operands)
+	if err != nil || o == nil {
+		return false, err
+	}
+
+	return r.removedOC(o), nil
+}
+
+// hiddenXObject returns true if op paints an XObject of a removed layer.
+// Other form XObjects get processed recursively.
+func (r *remover) hiddenXObject(op content.Operation, res *types.PDFDict) (bool, error) {
+
+	o, err := r.resourceEntry(res, "XObject", op.Operands)
+	if err != nil || o == nil {
+		return false, err
+	}
+
+	indRef, ok := o.(types.PDFIndirectRef)
+	if !ok {
+		return false, nil
+	}
+
+	sd, err := r.ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return false, err
+	}
+
+	if oc, found := sd.Find("OC"); found && r.removedOC(oc) {
+		return true, nil
+	}
+
+	if st := sd.Subtype(); st != nil && *st == "Form" {
+		return false, r.form(indRef, res)
+	}
+
+	return false, nil
+}
+
+// hiddenSection collects the operations of a marked content section of a removed layer
+// affecting the content following the section.
+type hiddenSection struct {
+	ops  []content.Operation // graphics state, text state and clipping operations
+	path []content.Operation // the current path
+	clip bool                // true if the current path is used for clipping
+}
+
+// add processes an operation of a hidden section.
+// Painting, text showing and XObjects are dropped, state changes and clipping are kept.
+func (hs *hiddenSection) add(op content.Operation) {
+
+	switch op.Operator {
+
+	case "m", "l", "c", "v", "y", "h", "re":
+		hs.path = append(hs.path, op)
+
+	case "W", "W*":
+		hs.path = append(hs.path, op)
+		hs.clip = true
+
+	case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+		if hs.clip {
+			hs.ops = append(hs.ops, hs.path...)
+			hs.ops = append(hs.ops, content.Operation{Operator: "n"})
+		}
+		hs.path, hs.clip = nil, false
+
+	case "Tj", "TJ", "Do", "sh", "BI", "MP", "DP", "BMC", "BDC", "EMC":
+
+	case "'":
+		hs.ops = append(hs.ops, content.Operation{Operator: "T*"})
+
+	case "\"":
+		// aw ac string " sets the word and character spacing.
+		if len(op.Operands) == 3 {
+			hs.ops = append(hs.ops,
+				content.Operation{Operator: "Tw", Operands: op.Operands[:1]},
+				content.Operation{Operator: "Tc", Operands: op.Operands[1:2]})
+		}
+		hs.ops = append(hs.ops, content.Operation{Operator: "T*"})
+
+	default:
+		hs.ops = append(hs.ops, op)
+	}
+}
+
+// strip returns ops without the marked content and XObjects of removed layers.
+// State changes within removed marked content are kept since they affect the content following.
+func (r *remover) strip(ops []content.Operation, res *types.PDFDict) ([]content.Operation, bool, error) {
+
+	if res != nil {
+		r.resources = append(r.resources, res)
+	}
+
+	var out []content.Operation
+	changed := false
+
+	// depth counts the nesting of marked content within a removed section.
+	depth := 0
+	var hs *hiddenSection
+
+	for _, op := range ops {
+
+		if depth > 0 {
+			switch op.Operator {
+			case "BMC", "BDC":
+				depth++
+			case "EMC":
+				depth--
+			}
+			if depth == 0 {
+				out = append(out, hs.ops...)
+				continue
+			}
+			hs.add(op)
+			continue
+		}
+
+		switch op.Operator {
+
+		case "BDC":
+			hidden, err := r.hidden(op, res)
+			if err != nil {
+				return nil, false, err
+			}
+			if hidden {
+				depth, changed, hs = 1, true, &hiddenSection{}
+				continue
+			}
+
+		case "Do":
+			hidden, err := r.hiddenXObject(op, res)
+			if err != nil {
+				return nil, false, err
+			}
+			if hidden {
+				changed = true
+				continue
+			}
+		}
+
+		out = append(out, op)
+	}
+
+	// An unterminated section extends to the end of the content stream.
+	if depth > 0 {
+		out = append(out, hs.ops...)
+	}
+
+	return out, changed, nil
+}
+
+// decode decodes sd and fails for unsupported filters since the content would get lost on rewrite.
+func decode(sd *types.PDFStreamDict) error {
+
+	err := filter.DecodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		return errors.New("layer: cannot remove content from stream using unsupported filter")
+	}
+
+	return err
+}
+
+// form strips the content of removed layers from a form XObject.
+func (r *remover) form(indRef types.PDFIndirectRef, parentRes *types.PDFDict) error {
+
+	objNr := indRef.ObjectNumber.Value()
+	if r.visited[objNr] {
+		return nil
+	}
+	r.visited[objNr] = true
+
+	entry, found := r.ctx.FindTableEntryForIndRef(&indRef)
+	if !found {
+		return errors.Errorf("object #%d not found", objNr)
+	}
+
+	sd, ok := entry.Object.(types.PDFStreamDict)
+	if !ok {
+		return nil
+	}
+
+	err := decode(&sd)
+	if err != nil {
+		return err
+	}
+
+	res := parentRes
+	d, err := r.ctx.DereferenceDict(sd.Dict["Resources"])
+	if err != nil {
+		return err
+	}
+	if d != nil {
+		res = d
+	}
+
+	ops, err := content.Parse(sd.Content)
+	if err != nil {
+		return err
+	}
+
+	ops, changed, err := r.strip(ops, res)
+	if err != nil || !changed {
+		return err
+	}
+
+	logDebugLayer.Printf("Remove: rewriting form obj#%d\n", objNr)
+
+	err = filter.ReplaceContent(&sd, content.Bytes(ops))
+	if err != nil {
+		return err
+	}
+
+	entry.Object = sd
+
+	return nil
+}
+
+// pageContent returns the decoded content of a page.
+func (r *remover) pageContent(pageDict *types.PDFDict) ([]byte, error) {
+
+	obj, err := r.ctx.Dereference(pageDict.Dict["Contents"])
+	if err != nil || obj == nil {
+		return nil, err
+	}
+
+	var streams []*types.PDFStreamDict
+
+	switch obj := obj.(type) {
+
+	case types.PDFStreamDict:
+		streams = append(streams, &obj)
+
+	case types.PDFArray:
+		for _, o := range obj {
+			sd, err := r.ctx.DereferenceStreamDict(o)
+			if err != nil {
+				return nil, err
+			}
+			if sd != nil {
+				streams = append(streams, sd)
+			}
+		}
+
+	default:
+		return nil, errors.New("layer: page content must be stream dict or array")
+	}
+
+	var buf []byte
+
+	for _, sd := range streams {
+		if err := decode(sd); err != nil {
+			return nil, err
+		}
+		buf = append(buf, sd.Content...)
+		buf = append(buf, '\n')
+	}
+
+	return buf, nil
+}
+
+// page strips the content and annotations of removed layers from a page.
+func (r *remover) page(pageNr int, pageDict *types.PDFDict) error {
+
+	obj, err := r.ctx.InheritedPageAttr(pageDict, "Resources")
+	if err != nil {
+		return err
+	}
+
+	res, err := r.ctx.DereferenceDict(obj)
+	if err != nil {
+		return err
+	}
+
+	buf, err := r.pageContent(pageDict)
+	if err != nil {
+		return err
+	}
+
+	ops, err := content.Parse(buf)
+	if err != nil {
+		return err
+	}
+
+	ops, changed, err := r.strip(ops, res)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+
+		logDebugLayer.Printf("Remove: rewriting content of page %d\n", pageNr)
+
+		sd, err := r.ctx.InsertPDFStreamDict(content.Bytes(ops))
+		if err != nil {
+			return err
+		}
+
+		err = filter.EncodeStream(sd)
+		if err != nil {
+			return err
+		}
+
+		objNr, err := r.ctx.InsertObject(*sd)
+		if err != nil {
+			return err
+		}
+
+		pageDict.Update("Contents", types.NewPDFIndirectRef(objNr, 0))
+	}
+
+	return r.annotations(pageDict)
+}
+
+// annotations removes the annotations of removed layers from a page.
+// Widgets are kept since they belong to form fields.
+func (r *remover) annotations(pageDict *types.PDFDict) error {
+
+	arr, err := r.ctx.DereferenceArray(pageDict.Dict["Annots"])
+	if err != nil || arr == nil {
+		return err
+	}
+
+	a := types.PDFArray{}
+
+	for _, o := range *arr {
+
+		d, err := r.ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		if d != nil {
+			oc, found := d.Find("OC")
+			if st := d.Subtype(); found && (st == nil || *st != "Widget") && r.removedOC(oc) {
+				continue
+			}
+		}
+
+		a = append(a, o)
+	}
+
+	if len(a) == len(*arr) {
+		return nil
+	}
+
+	if len(a) == 0 {
+		pageDict.Delete("Annots")
+		return nil
+	}
+
+	pageDict.Update("Annots", a)
+
+	return nil
+}
+
+// pruneProperties deletes the property lists of removed layers from the processed resource dicts.
+func (r *remover) pruneProperties() error {
+
+	for _, res := range r.resources {
+
+		d, err := r.ctx.DereferenceDict(res.Dict["Properties"])
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+
+		for k, v := range d.Dict {
+			if r.removedOC(v) {
+				d.Delete(k)
+			}
+		}
+
+		if len(d.Dict) == 0 {
+			res.Delete("Properties")
+		}
+	}
+
+	return nil
+}
+
+// pruneConfig removes the selected layers from an optional content configuration dict.
+func pruneConfig(ctx *types.PDFContext, d *types.PDFDict, objNrs types.IntSet) error {
+
+	for _, key := range []string{"ON", "OFF", "Locked", "Order", "RBGroups"} {
+		if err := update(ctx, d, key, objNrs, false); err != nil {
+			return err
+		}
+	}
+
+	as, err := ctx.DereferenceArray(d.Dict["AS"])
+	if err != nil || as == nil {
+		return err
+	}
+
+	for _, o := range *as {
+		usage, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if usage != nil {
+			if err = update(ctx, usage, "OCGs", objNrs, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pruneOCProperties removes the selected layers from the optional content properties.
+func pruneOCProperties(ctx *types.PDFContext, objNrs types.IntSet) error {
+
+	ocProps, err := ocProperties(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = update(ctx, ocProps, "OCGs", objNrs, false)
+	if err != nil {
+		return err
+	}
+
+	if _, found := ocProps.Find("OCGs"); !found {
+		root, err := ctx.Catalog()
+		if err != nil {
+			return err
+		}
+		root.Delete("OCProperties")
+		return nil
+	}
+
+	d, err := defaultConfig(ctx, ocProps)
+	if err != nil {
+		return err
+	}
+
+	err = pruneConfig(ctx, d, objNrs)
+	if err != nil {
+		return err
+	}
+
+	configs, err := ctx.DereferenceArray(ocProps.Dict["Configs"])
+	if err != nil || configs == nil {
+		return err
+	}
+
+	for _, o := range *configs {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			if err = pruneConfig(ctx, d, objNrs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the selected layers along with their marked content from all pages.
+func Remove(ctx *types.PDFContext, names []string) error {
+
+	logDebugLayer.Printf("Remove %v\n", names)
+
+	objNrs, err := selectLayers(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	r := remover{ctx: ctx, objNrs: objNrs, visited: types.IntSet{}}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return err
+	}
+
+	for i, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		if err = r.page(i+1, pageDict); err != nil {
+			return err
+		}
+	}
+
+	err = r.pruneProperties()
+	if err != nil {
+		return err
+	}
+
+	return pruneOCProperties(ctx, objNrs)
+}
@@ -0,0 +1,765 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// keyString returns the string value of a name, string literal or hex literal.
+func keyString(o interface{}) (string, bool) {
+
+	switch obj := o.(type) {
+
+	case types.PDFName:
+		return obj.Value(), true
+
+	case types.PDFStringLiteral:
+		s, err := types.StringLiteralToString(obj.Value())
+		return s, err == nil
+
+	case types.PDFHexLiteral:
+		s, err := types.HexLiteralToString(obj.Value())
+		return s, err == nil
+	}
+
+	return "", false
+}
+
+// renamedKey returns s as an object of the same kind as o.
+func renamedKey(o interface{}, s string) interface{} {
+
+	if _, ok := o.(types.PDFName); ok {
+		return types.PDFName(s)
+	}
+
+	return types.TextStringLiteral(s)
+}
+
+// uniqueName returns the first name of the form name_2, name_3.. not in use and marks it as used.
+func uniqueName(name string, used map[string]bool) string {
+
+	for i := 2; ; i++ {
+		s := fmt.Sprintf("%s_%d", name, i)
+		if !used[s] {
+			used[s] = true
+			return s
+		}
+	}
+}
+
+// renames returns new names for all source names colliding with dest names.
+func renames(srcNames, destNames []string) map[string]string {
+
+	used := map[string]bool{}
+	dest := map[string]bool{}
+
+	for _, s := range destNames {
+		used[s] = true
+		dest[s] = true
+	}
+
+	for _, s := range srcNames {
+		used[s] = true
+	}
+
+	m := map[string]string{}
+
+	for _, s := range srcNames {
+		if _, done := m[s]; dest[s] && !done {
+			m[s] = uniqueName(s, used)
+		}
+	}
+
+	return m
+}
+
+// treeEntries returns the key value pairs of a name tree (key = "Names") or a number tree (key = "Nums").
+func treeEntries(ctx *types.PDFContext, o interface{}, key string) (types.PDFArray, error) {
+
+	d, err := ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	var arr types.PDFArray
+
+	if o, found := d.Find(key); found {
+		a, err := ctx.DereferenceArray(o)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			arr = append(arr, *a...)
+		}
+	}
+
+	if o, found := d.Find("Kids"); found {
+		kids, err := ctx.DereferenceArray(o)
+		if err != nil || kids == nil {
+			return nil, err
+		}
+		for _, kid := range *kids {
+			a, err := treeEntries(ctx, kid, key)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, a...)
+		}
+	}
+
+	return arr, nil
+}
+
+// treeKeys returns the string keys of a name tree array of key value pairs.
+func treeKeys(arr types.PDFArray) []string {
+
+	var ss []string
+
+	for i := 0; i+1 < len(arr); i += 2 {
+		if s, ok := keyString(arr[i]); ok {
+			ss = append(ss, s)
+		}
+	}
+
+	return ss
+}
+
+// insertNameTree creates a flat name tree for pairs sorted by key and returns its indirect reference.
+func insertNameTree(ctx *types.PDFContext, pairs types.PDFArray) (*types.PDFIndirectRef, error) {
+
+	type pair struct {
+		key      string
+		k, value interface{}
+	}
+
+	var pp []pair
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s, _ := keyString(pairs[i])
+		pp = append(pp, pair{s, pairs[i], pairs[i+1]})
+	}
+
+	sort.SliceStable(pp, func(i, j int) bool { return pp[i].key < pp[j].key })
+
+	arr := types.PDFArray{}
+	for _, p := range pp {
+		arr = append(arr, p.k, p.value)
+	}
+
+	d := types.NewPDFDict()
+	d.Insert("Names", arr)
+
+	objNr, err := ctx.InsertObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	indRef := types.NewPDFIndirectRef(objNr, 0)
+
+	return &indRef, nil
+}
+
+// renameDestRefs renames all references to named destinations of an object.
+func renameDestRefs(o interface{}, m map[string]string) {
+
+	switch obj := o.(type) {
+
+	case types.PDFDict:
+		renameDestRefsInDict(obj, m)
+
+	case types.PDFStreamDict:
+		renameDestRefsInDict(obj.PDFDict, m)
+
+	case types.PDFArray:
+		for _, v := range obj {
+			renameDestRefs(v, m)
+		}
+	}
+}
+
+func renameDestRefsInDict(d types.PDFDict, m map[string]string) {
+
+	goTo := d.NameEntry("S") != nil && *d.NameEntry("S") == "GoTo"
+
+	for k, v := range d.Dict {
+
+		if k == "Dest" || (k == "D" && goTo) {
+			if s, ok := keyString(v); ok {
+				if name, found := m[s]; found {
+					d.Dict[k] = renamedKey(v, name)
+				}
+				continue
+			}
+		}
+
+		renameDestRefs(v, m)
+	}
+}
+
+// destNames returns the names of all named destinations of a catalog.
+// Named destinations live in the Dests dict (PDF 1.1) or in the Dests name tree.
+func destNames(ctx *types.PDFContext, root *types.PDFDict) (ss []string, err error) {
+
+	dests, err := ctx.DereferenceDict(root.Dict["Dests"])
+	if err != nil {
+		return nil, err
+	}
+
+	if dests != nil {
+		for k := range dests.Dict {
+			ss = append(ss, k)
+		}
+	}
+
+	names, err := ctx.DereferenceDict(root.Dict["Names"])
+	if err != nil || names == nil {
+		return ss, err
+	}
+
+	arr, err := treeEntries(ctx, names.Dict["Dests"], "Names")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ss, treeKeys(arr)...), nil
+}
+
+// mergeDests merges the source Dests dict into dest and renames colliding named destinations.
+// All references to renamed destinations within the source objects get patched.
+func mergeDests(ctxSource, ctxDest *types.PDFContext, srcRoot, destRoot *types.PDFDict) (m map[string]string, err error) {
+
+	srcNames, err := destNames(ctxDest, srcRoot)
+	if err != nil || len(srcNames) == 0 {
+		return nil, err
+	}
+
+	names, err := destNames(ctxDest, destRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	m = renames(srcNames, names)
+
+	if len(m) > 0 {
+		logInfoMerge.Printf("mergeDests: renaming %d named destinations\n", len(m))
+		for _, entry := range ctxSource.Table {
+			if !entry.Free && entry.Object != nil {
+				renameDestRefs(entry.Object, m)
+			}
+		}
+	}
+
+	srcDests, err := ctxDest.DereferenceDict(srcRoot.Dict["Dests"])
+	if err != nil || srcDests == nil {
+		return m, err
+	}
+
+	dests, err := ctxDest.DereferenceDict(destRoot.Dict["Dests"])
+	if err != nil {
+		return nil, err
+	}
+
+	if dests == nil {
+		d := types.NewPDFDict()
+		dests = &d
+		destRoot.Insert("Dests", d)
+	}
+
+	for k, v := range srcDests.Dict {
+		if name, found := m[k]; found {
+			k = name
+		}
+		dests.Dict[k] = v
+	}
+
+	return m, nil
+}
+
+// mergeNameTrees merges all source name trees (eg. Dests, EmbeddedFiles, JavaScript) into dest.
+// Colliding keys get renamed, for Dests according to destRenames.
+func mergeNameTrees(ctxDest *types.PDFContext, srcRoot, destRoot *types.PDFDict, destRenames map[string]string) error {
+
+	srcNames, err := ctxDest.DereferenceDict(srcRoot.Dict["Names"])
+	if err != nil || srcNames == nil {
+		return err
+	}
+
+	names, err := ctxDest.DereferenceDict(destRoot.Dict["Names"])
+	if err != nil {
+		return err
+	}
+
+	if names == nil {
+		d := types.NewPDFDict()
+		names = &d
+		destRoot.Insert("Names", d)
+	}
+
+	for key, o := range srcNames.Dict {
+
+		srcPairs, err := treeEntries(ctxDest, o, "Names")
+		if err != nil {
+			return err
+		}
+
+		pairs, err := treeEntries(ctxDest, names.Dict[key], "Names")
+		if err != nil {
+			return err
+		}
+
+		m := destRenames
+		if key != "Dests" {
+			m = renames(treeKeys(srcPairs), treeKeys(pairs))
+		}
+
+		for i := 0; i+1 < len(srcPairs); i += 2 {
+			k := srcPairs[i]
+			if s, ok := keyString(k); ok {
+				if name, found := m[s]; found {
+					k = renamedKey(k, name)
+				}
+			}
+			pairs = append(pairs, k, srcPairs[i+1])
+		}
+
+		indRef, err := insertNameTree(ctxDest, pairs)
+		if err != nil {
+			return err
+		}
+
+		logInfoMerge.Printf("mergeNameTrees: %s with %d entries\n", key, len(pairs)/2)
+
+		names.Dict[key] = *indRef
+	}
+
+	return nil
+}
+
+// mergeResources adds all resources of src missing in dest.
+func mergeResources(ctx *types.PDFContext, src, dest *types.PDFDict) error {
+
+	for category, o := range src.Dict {
+
+		srcRes, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+
+		res, err := ctx.DereferenceDict(dest.Dict[category])
+		if err != nil {
+			return err
+		}
+
+		if srcRes == nil || res == nil {
+			dest.Insert(category, o)
+			continue
+		}
+
+		for k, v := range srcRes.Dict {
+			res.Insert(k, v)
+		}
+	}
+
+	return nil
+}
+
+// mergeAcroForms adds the source form fields to the dest form.
+// Colliding top level field names get renamed, an XFA form gets removed since it no longer reflects the fields.
+func mergeAcroForms(ctxDest *types.PDFContext, srcRoot, destRoot *types.PDFDict) error {
+
+	o, found := srcRoot.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	srcForm, err := ctxDest.DereferenceDict(o)
+	if err != nil || srcForm == nil {
+		return err
+	}
+
+	form, err := ctxDest.DereferenceDict(destRoot.Dict["AcroForm"])
+	if err != nil {
+		return err
+	}
+
+	if form == nil {
+		destRoot.Insert("AcroForm", o)
+		return nil
+	}
+
+	srcFields, err := ctxDest.DereferenceArray(srcForm.Dict["Fields"])
+	if err != nil {
+		return err
+	}
+
+	fields, err := ctxDest.DereferenceArray(form.Dict["Fields"])
+	if err != nil {
+		return err
+	}
+
+	if fields == nil {
+		fields = &types.PDFArray{}
+	}
+
+	used := map[string]bool{}
+	for _, f := range *fields {
+		d, err := ctxDest.DereferenceDict(f)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+		if s, ok := keyString(d.Dict["T"]); ok {
+			used[s] = true
+		}
+	}
+
+	arr := append(types.PDFArray{}, *fields...)
+
+	if srcFields != nil {
+		for _, f := range *srcFields {
+			d, err := ctxDest.DereferenceDict(f)
+			if err != nil {
+				return err
+			}
+			if d == nil {
+				continue
+			}
+			if s, ok := keyString(d.Dict["T"]); ok && used[s] {
+				name := uniqueName(s, used)
+				logInfoMerge.Printf("mergeAcroForms: renaming field %s to %s\n", s, name)
+				d.Dict["T"] = types.TextStringLiteral(name)
+			}
+			arr = append(arr, f)
+		}
+	}
+
+	form.Update("Fields", arr)
+
+	if srcForm.BooleanEntry("NeedAppearances") != nil && *srcForm.BooleanEntry("NeedAppearances") {
+		form.Update("NeedAppearances", types.PDFBoolean(true))
+	}
+
+	if i := srcForm.IntEntry("SigFlags"); i != nil {
+		flags := *i
+		if j := form.IntEntry("SigFlags"); j != nil {
+			flags |= *j
+		}
+		form.Update("SigFlags", types.PDFInteger(flags))
+	}
+
+	if srcCO, err := ctxDest.DereferenceArray(srcForm.Dict["CO"]); err == nil && srcCO != nil {
+		co, err := ctxDest.DereferenceArray(form.Dict["CO"])
+		if err != nil {
+			return err
+		}
+		if co == nil {
+			co = &types.PDFArray{}
+		}
+		form.Update("CO", append(append(types.PDFArray{}, *co...), *srcCO...))
+	}
+
+	if da, found := srcForm.Find("DA"); found {
+		form.Insert("DA", da)
+	}
+
+	if q, found := srcForm.Find("Q"); found {
+		form.Insert("Q", q)
+	}
+
+	if dr, found := srcForm.Find("DR"); found {
+		srcDR, err := ctxDest.DereferenceDict(dr)
+		if err != nil {
+			return err
+		}
+		destDR, err := ctxDest.DereferenceDict(form.Dict["DR"])
+		if err != nil {
+			return err
+		}
+		if destDR == nil {
+			form.Insert("DR", dr)
+		} else if srcDR != nil {
+			err = mergeResources(ctxDest, srcDR, destDR)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if form.Delete("XFA") != nil {
+		logInfoMerge.Println("mergeAcroForms: removing XFA form")
+	}
+
+	return nil
+}
+
+// mergePageLabels appends the source page labels to the dest page labels.
+// Missing page labels default to decimal page numbers.
+func mergePageLabels(ctxDest *types.PDFContext, srcRoot, destRoot *types.PDFDict, destPageCount int) error {
+
+	srcPL, srcFound := srcRoot.Find("PageLabels")
+	pl, found := destRoot.Find("PageLabels")
+
+	if !srcFound && !found {
+		return nil
+	}
+
+	decimal := func(pageIndex int) types.PDFDict {
+		d := types.NewPDFDict()
+		d.Insert("S", types.PDFName("D"))
+		if pageIndex > 0 {
+			d.Insert("St", types.PDFInteger(pageIndex+1))
+		}
+		return d
+	}
+
+	nums := types.PDFArray{types.PDFInteger(0), decimal(0)}
+	if found {
+		arr, err := treeEntries(ctxDest, pl, "Nums")
+		if err != nil {
+			return err
+		}
+		nums = arr
+	}
+
+	if !srcFound {
+		nums = append(nums, types.PDFInteger(destPageCount), decimal(destPageCount))
+	} else {
+		arr, err := treeEntries(ctxDest, srcPL, "Nums")
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(arr); i += 2 {
+			pageIndex, ok := arr[i].(types.PDFInteger)
+			if !ok {
+				continue
+			}
+			nums = append(nums, types.PDFInteger(destPageCount+pageIndex.Value()), arr[i+1])
+		}
+	}
+
+	d := types.NewPDFDict()
+	d.Insert("Nums", nums)
+
+	objNr, err := ctxDest.InsertObject(d)
+	if err != nil {
+		return err
+	}
+
+	destRoot.Update("PageLabels", types.NewPDFIndirectRef(objNr, 0))
+
+	return nil
+}
+
+// arrayEntry returns a copy of the array entry key of d.
+func arrayEntry(ctx *types.PDFContext, d *types.PDFDict, key string) (types.PDFArray, error) {
+
+	if d == nil {
+		return nil, nil
+	}
+
+	arr, err := ctx.DereferenceArray(d.Dict[key])
+	if err != nil || arr == nil {
+		return nil, err
+	}
+
+	return append(types.PDFArray{}, *arr...), nil
+}
+
+// ocgStates returns the optional content groups of a default configuration dict turned on and off.
+func ocgStates(ctx *types.PDFContext, ocgs types.PDFArray, d *types.PDFDict) (on, off types.PDFArray, err error) {
+
+	offArr, err := arrayEntry(ctx, d, "OFF")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onArr, err := arrayEntry(ctx, d, "ON")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isOff := map[int]bool{}
+
+	if d != nil {
+		if bs := d.NameEntry("BaseState"); bs != nil && *bs == "OFF" {
+			for _, o := range ocgs {
+				if indRef, ok := o.(types.PDFIndirectRef); ok {
+					isOff[indRef.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+
+	for _, o := range offArr {
+		if indRef, ok := o.(types.PDFIndirectRef); ok {
+			isOff[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	for _, o := range onArr {
+		if indRef, ok := o.(types.PDFIndirectRef); ok {
+			isOff[indRef.ObjectNumber.Value()] = false
+		}
+	}
+
+	for _, o := range ocgs {
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok {
+			continue
+		}
+		if isOff[indRef.ObjectNumber.Value()] {
+			off = append(off, o)
+		} else {
+			on = append(on, o)
+		}
+	}
+
+	return on, off, nil
+}
+
+// mergeOCProperties adds the source optional content groups (layers) to the dest optional content properties.
+// The state of the source groups in the default configuration is kept,
+// alternate configurations and usage application dicts of the source are dropped.
+func mergeOCProperties(ctxDest *types.PDFContext, srcRoot, destRoot *types.PDFDict) error {
+
+	o, found := srcRoot.Find("OCProperties")
+	if !found {
+		return nil
+	}
+
+	srcProps, err := ctxDest.DereferenceDict(o)
+	if err != nil || srcProps == nil {
+		return err
+	}
+
+	props, err := ctxDest.DereferenceDict(destRoot.Dict["OCProperties"])
+	if err != nil {
+		return err
+	}
+
+	if props == nil {
+		destRoot.Insert("OCProperties", o)
+		return nil
+	}
+
+	srcOCGs, err := arrayEntry(ctxDest, srcProps, "OCGs")
+	if err != nil {
+		return err
+	}
+
+	ocgs, err := arrayEntry(ctxDest, props, "OCGs")
+	if err != nil {
+		return err
+	}
+
+	srcD, err := ctxDest.DereferenceDict(srcProps.Dict["D"])
+	if err != nil {
+		return err
+	}
+
+	d, err := ctxDest.DereferenceDict(props.Dict["D"])
+	if err != nil {
+		return err
+	}
+
+	if d == nil {
+		d1 := types.NewPDFDict()
+		props.Insert("D", d1)
+		d = &d1
+	}
+
+	// Dest groups missing in Order would no longer be listed by viewers.
+	if _, found := d.Find("Order"); !found && srcD != nil {
+		if _, found := srcD.Find("Order"); found {
+			d.Insert("Order", append(types.PDFArray{}, ocgs...))
+		}
+	}
+
+	props.Update("OCGs", append(ocgs, srcOCGs...))
+
+	on, off, err := ocgStates(ctxDest, srcOCGs, srcD)
+	if err != nil {
+		return err
+	}
+
+	appendTo := func(key string, arr types.PDFArray) error {
+		if len(arr) == 0 {
+			return nil
+		}
+		a, err := arrayEntry(ctxDest, d, key)
+		if err != nil {
+			return err
+		}
+		d.Update(key, append(a, arr...))
+		return nil
+	}
+
+	// The dest base state may differ from the source base state.
+	for key, arr := range map[string]types.PDFArray{"ON": on, "OFF": off} {
+		if err = appendTo(key, arr); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range []string{"Locked", "Order", "RBGroups"} {
+		arr, err := arrayEntry(ctxDest, srcD, key)
+		if err != nil {
+			return err
+		}
+		if err = appendTo(key, arr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeCatalogs merges the catalog level structures of source into dest:
+// AcroForm fields, named destinations, name trees, page labels and optional content groups.
+// The source objects need to be part of ctxDest already.
+func mergeCatalogs(ctxSource, ctxDest *types.PDFContext, destPageCount int) (err error) {
+
+	logDebugMerge.Println("mergeCatalogs begin")
+
+	srcRoot, err := ctxDest.DereferenceDict(*ctxSource.Root)
+	if err != nil {
+		return
+	}
+
+	destRoot, err := ctxDest.Catalog()
+	if err != nil {
+		return
+	}
+
+	m, err := mergeDests(ctxSource, ctxDest, srcRoot, destRoot)
+	if err != nil {
+		return
+	}
+
+	err = mergeNameTrees(ctxDest, srcRoot, destRoot, m)
+	if err != nil {
+		return
+	}
+
+	err = mergeAcroForms(ctxDest, srcRoot, destRoot)
+	if err != nil {
+		return
+	}
+
+	err = mergePageLabels(ctxDest, srcRoot, destRoot, destPageCount)
+	if err != nil {
+		return
+	}
+
+	err = mergeOCProperties(ctxDest, srcRoot, destRoot)
+	if err != nil {
+		return
+	}
+
+	logDebugMerge.Println("mergeCatalogs end")
+
+	return
+}
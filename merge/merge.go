@@ -321,6 +321,8 @@ func mergeDuplicateObjNumberIntSets(ctxSource, ctxDest *types.PDFContext) (err e
 }
 
 // XRefTables merges PDFContext ctxSource into ctxDest by appending its page tree.
+// The AcroForm fields, named destinations, name trees, page labels and layers of both catalogs get merged,
+// colliding field and destination names of ctxSource are renamed.
 func XRefTables(ctxSource, ctxDest *types.PDFContext) (err error) {
 
 	// Sweep over ctxSource cross ref table and ensure valid object numbers in ctxDest's space.
@@ -329,6 +331,8 @@ func XRefTables(ctxSource, ctxDest *types.PDFContext) (err error) {
 		return
 	}
 
+	destPageCount := ctxDest.PageCount
+
 	// Append ctxSource pageTree to ctxDest pageTree.
 	logInfoMerge.Println("appendSourcePageTreeToDestPageTree")
 	err = appendSourcePageTreeToDestPageTree(ctxSource, ctxDest)
@@ -343,6 +347,13 @@ func XRefTables(ctxSource, ctxDest *types.PDFContext) (err error) {
 		return err
 	}
 
+	// Merge AcroForm fields, named destinations, name trees, page labels and layers.
+	logInfoMerge.Println("mergeCatalogs")
+	err = mergeCatalogs(ctxSource, ctxDest, destPageCount)
+	if err != nil {
+		return err
+	}
+
 	// Mark source's root object as free.
 	err = ctxDest.DeleteObject(int(ctxSource.Root.ObjectNumber))
 	if err != nil {
@@ -0,0 +1,805 @@
+package merge
+
+import (
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// inheritedPageAttrs are the page attributes a page may inherit from its ancestors in the page tree.
+var inheritedPageAttrs = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// pageLabel returns the label dict in effect for the page with index pageIndex
+// along with the start index of its label range and the label number of the page.
+func pageLabel(ctx *types.PDFContext, nums types.PDFArray, pageIndex int) (d *types.PDFDict, start, nr int, err error) {
+
+	for i := len(nums) - 2; i >= 0; i -= 2 {
+
+		st, ok := nums[i].(types.PDFInteger)
+		if !ok || st.Value() > pageIndex {
+			continue
+		}
+
+		d, err = ctx.DereferenceDict(nums[i+1])
+		if err != nil || d == nil {
+			return nil, -1, 0, err
+		}
+
+		nr = 1
+		if i := d.IntEntry("St"); i != nil {
+			nr = *i
+		}
+
+		return d, st.Value(), nr + pageIndex - st.Value(), nil
+	}
+
+	return nil, -1, 0, nil
+}
+
+// selectPageLabels translates the page labels of the document to the selected pages.
+// A new label range starts wherever the selection skips a page or an original range starts.
+func selectPageLabels(ctx *types.PDFContext, root *types.PDFDict, pageIndices []int) error {
+
+	o, found := root.Find("PageLabels")
+	if !found {
+		return nil
+	}
+
+	arr, err := treeEntries(ctx, o, "Nums")
+	if err != nil {
+		return err
+	}
+
+	// Nums need to be sorted by page index.
+	type label struct {
+		start int
+		d     interface{}
+	}
+	var ll []label
+	for i := 0; i+1 < len(arr); i += 2 {
+		if start, ok := arr[i].(types.PDFInteger); ok {
+			ll = append(ll, label{start.Value(), arr[i+1]})
+		}
+	}
+	sort.SliceStable(ll, func(i, j int) bool { return ll[i].start < ll[j].start })
+
+	nums := types.PDFArray{}
+	for _, l := range ll {
+		nums = append(nums, types.PDFInteger(l.start), l.d)
+	}
+
+	newNums := types.PDFArray{}
+	prevStart, prevIndex := -1, -2
+
+	for j, pageIndex := range pageIndices {
+
+		d, start, nr, err := pageLabel(ctx, nums, pageIndex)
+		if err != nil {
+			return err
+		}
+
+		// Continue the current label range.
+		if pageIndex == prevIndex+1 && start == prevStart {
+			prevIndex = pageIndex
+			continue
+		}
+
+		label := types.NewPDFDict()
+		if d != nil {
+			for _, k := range []string{"S", "P"} {
+				if v, found := d.Find(k); found {
+					label.Insert(k, v)
+				}
+			}
+			if nr != 1 {
+				label.Insert("St", types.PDFInteger(nr))
+			}
+		}
+
+		newNums = append(newNums, types.PDFInteger(j), label)
+		prevStart, prevIndex = start, pageIndex
+	}
+
+	d := types.NewPDFDict()
+	d.Insert("Nums", newNums)
+
+	objNr, err := ctx.InsertObject(d)
+	if err != nil {
+		return err
+	}
+
+	root.Update("PageLabels", types.NewPDFIndirectRef(objNr, 0))
+
+	return nil
+}
+
+// orderPages flattens the page tree of ctx to the pages pageNrs in the given order.
+// The remaining pages become kids of the page tree root keeping their inherited attributes.
+func orderPages(ctx *types.PDFContext, pageNrs []int) (err error) {
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	rootIndRef, err := ctx.Pages()
+	if err != nil {
+		return
+	}
+
+	rootDict, err := ctx.DereferenceDict(*rootIndRef)
+	if err != nil {
+		return
+	}
+
+	if len(pageNrs) == 0 {
+		return errors.New("orderPages: no pages selected")
+	}
+
+	kids := types.PDFArray{}
+
+	for _, pageNr := range pageNrs {
+
+		if pageNr < 1 || pageNr > len(pages) {
+			return errors.Errorf("orderPages: invalid page number: %d", pageNr)
+		}
+
+		indRef := pages[pageNr-1]
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range inheritedPageAttrs {
+			if _, found := pageDict.Find(key); found {
+				continue
+			}
+			obj, err := ctx.InheritedPageAttr(pageDict, key)
+			if err != nil {
+				return err
+			}
+			if obj != nil {
+				pageDict.Insert(key, obj)
+			}
+		}
+
+		kids = append(kids, indRef)
+	}
+
+	// Reparent after all inherited attributes have been resolved.
+	for _, o := range kids {
+		pageDict, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		pageDict.Update("Parent", *rootIndRef)
+	}
+
+	// The pages carry their inherited attributes now.
+	for _, key := range inheritedPageAttrs {
+		rootDict.Delete(key)
+	}
+
+	rootDict.Update("Kids", kids)
+	rootDict.Update("Count", types.PDFInteger(len(kids)))
+
+	ctx.PageCount = len(kids)
+
+	return nil
+}
+
+// selection holds what remains of a document reduced to some of its pages.
+type selection struct {
+	ctx     *types.PDFContext
+	pages   types.IntSet    // object numbers of the selected pages
+	dropped types.StringSet // named destinations removed
+}
+
+// keepsDest returns false for destinations targeting a dropped page or a removed named destination.
+// o may be a destination, a destination dict or an action.
+func (sel *selection) keepsDest(o interface{}) bool {
+
+	o, err := sel.ctx.Dereference(o)
+	if err != nil || o == nil {
+		return true
+	}
+
+	if d, ok := o.(types.PDFDict); ok {
+		// Destinations of remote and embedded go-to actions refer to other documents.
+		if s := d.NameEntry("S"); s != nil && (*s == "GoToR" || *s == "GoToE") {
+			return true
+		}
+		if o, err = sel.ctx.Dereference(d.Dict["D"]); err != nil || o == nil {
+			return true
+		}
+	}
+
+	if name, ok := keyString(o); ok {
+		return !sel.dropped[name]
+	}
+
+	arr, ok := o.(types.PDFArray)
+	if !ok || len(arr) == 0 {
+		return true
+	}
+
+	indRef, ok := arr[0].(types.PDFIndirectRef)
+
+	return !ok || sel.pages[indRef.ObjectNumber.Value()]
+}
+
+// keepsTarget returns false for annotations and outline items whose destination or go-to action targets a dropped page.
+func (sel *selection) keepsTarget(d *types.PDFDict) bool {
+	return sel.keepsDest(d.Dict["Dest"]) && sel.keepsDest(d.Dict["A"])
+}
+
+// outlineItems removes the outline items below parent targeting a dropped page
+// and returns the number of visible items left.
+// Items with remaining children lose their destination instead.
+func (sel *selection) outlineItems(parent *types.PDFDict, visited types.IntSet) (visible int, err error) {
+
+	ctx := sel.ctx
+
+	var kids []types.PDFIndirectRef
+
+	for o := parent.Dict["First"]; o != nil; {
+
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok || visited[indRef.ObjectNumber.Value()] {
+			break
+		}
+		visited[indRef.ObjectNumber.Value()] = true
+
+		d, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return 0, err
+		}
+		if d == nil {
+			break
+		}
+
+		o = d.Dict["Next"]
+
+		n, err := sel.outlineItems(d, visited)
+		if err != nil {
+			return 0, err
+		}
+
+		_, hasKids := d.Find("First")
+
+		if !sel.keepsTarget(d) {
+			if !hasKids {
+				continue
+			}
+			d.Delete("Dest")
+			d.Delete("A")
+		}
+
+		// Closed items have a negative count.
+		closed := d.IntEntry("Count") != nil && *d.IntEntry("Count") < 0
+
+		d.Delete("Count")
+		if hasKids {
+			if closed {
+				d.Insert("Count", types.PDFInteger(-n))
+			} else {
+				d.Insert("Count", types.PDFInteger(n))
+				visible += n
+			}
+		}
+
+		kids = append(kids, indRef)
+		visible++
+	}
+
+	parent.Delete("First")
+	parent.Delete("Last")
+
+	for i, indRef := range kids {
+
+		d, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return 0, err
+		}
+
+		d.Delete("Prev")
+		d.Delete("Next")
+
+		if i > 0 {
+			d.Insert("Prev", kids[i-1])
+		}
+		if i < len(kids)-1 {
+			d.Insert("Next", kids[i+1])
+		}
+	}
+
+	if len(kids) > 0 {
+		parent.Insert("First", kids[0])
+		parent.Insert("Last", kids[len(kids)-1])
+	}
+
+	return visible, nil
+}
+
+// outlines removes the outline items targeting a dropped page.
+func (sel *selection) outlines(root *types.PDFDict) error {
+
+	outlines, err := sel.ctx.DereferenceDict(root.Dict["Outlines"])
+	if err != nil || outlines == nil {
+		return err
+	}
+
+	visible, err := sel.outlineItems(outlines, types.IntSet{})
+	if err != nil {
+		return err
+	}
+
+	if _, found := outlines.Find("First"); !found {
+		root.Delete("Outlines")
+		return nil
+	}
+
+	outlines.Update("Count", types.PDFInteger(visible))
+
+	return nil
+}
+
+// threads removes the beads of article threads placed on a dropped page.
+// Threads without beads left get removed.
+func (sel *selection) threads(root *types.PDFDict) error {
+
+	ctx := sel.ctx
+
+	arr, err := ctx.DereferenceArray(root.Dict["Threads"])
+	if err != nil || arr == nil {
+		return err
+	}
+
+	threads := types.PDFArray{}
+
+	for _, o := range *arr {
+
+		thread, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if thread == nil {
+			continue
+		}
+
+		// Beads form a circular list.
+		var beads []types.PDFIndirectRef
+		visited := types.IntSet{}
+
+		for o := thread.Dict["F"]; o != nil; {
+
+			indRef, ok := o.(types.PDFIndirectRef)
+			if !ok || visited[indRef.ObjectNumber.Value()] {
+				break
+			}
+			visited[indRef.ObjectNumber.Value()] = true
+
+			bead, err := ctx.DereferenceDict(indRef)
+			if err != nil {
+				return err
+			}
+			if bead == nil {
+				break
+			}
+
+			o = bead.Dict["N"]
+
+			if p, ok := bead.Dict["P"].(types.PDFIndirectRef); ok && sel.pages[p.ObjectNumber.Value()] {
+				beads = append(beads, indRef)
+			}
+		}
+
+		if len(beads) == 0 {
+			continue
+		}
+
+		for i, indRef := range beads {
+			bead, err := ctx.DereferenceDict(indRef)
+			if err != nil {
+				return err
+			}
+			bead.Update("N", beads[(i+1)%len(beads)])
+			bead.Update("V", beads[(i+len(beads)-1)%len(beads)])
+			bead.Update("T", o)
+		}
+
+		thread.Update("F", beads[0])
+		threads = append(threads, o)
+	}
+
+	if len(threads) == 0 {
+		root.Delete("Threads")
+		return nil
+	}
+
+	root.Update("Threads", threads)
+
+	return nil
+}
+
+// links removes the link annotations of pageList targeting a dropped page.
+func (sel *selection) links(pageList []types.PDFIndirectRef) error {
+
+	ctx := sel.ctx
+
+	for _, indRef := range pageList {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		arr, err := ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil {
+			return err
+		}
+
+		if arr == nil {
+			continue
+		}
+
+		annots := types.PDFArray{}
+
+		for _, o := range *arr {
+
+			d, err := ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+
+			if d != nil {
+				if s := d.Subtype(); s != nil && *s == "Link" && !sel.keepsTarget(d) {
+					continue
+				}
+				// The page an annotation is placed on is optional.
+				if p, ok := d.Dict["P"].(types.PDFIndirectRef); ok && !sel.pages[p.ObjectNumber.Value()] {
+					d.Delete("P")
+				}
+			}
+
+			annots = append(annots, o)
+		}
+
+		if len(annots) < len(*arr) {
+			pageDict.Update("Annots", annots)
+		}
+	}
+
+	return nil
+}
+
+// dests removes the named destinations targeting a dropped page and records their names.
+func (sel *selection) dests(root *types.PDFDict) error {
+
+	ctx := sel.ctx
+
+	dests, err := ctx.DereferenceDict(root.Dict["Dests"])
+	if err != nil {
+		return err
+	}
+
+	if dests != nil {
+		for k, v := range dests.Dict {
+			if !sel.keepsDest(v) {
+				delete(dests.Dict, k)
+				sel.dropped[k] = true
+			}
+		}
+	}
+
+	names, err := ctx.DereferenceDict(root.Dict["Names"])
+	if err != nil || names == nil {
+		return err
+	}
+
+	o, found := names.Find("Dests")
+	if !found {
+		return nil
+	}
+
+	arr, err := treeEntries(ctx, o, "Names")
+	if err != nil {
+		return err
+	}
+
+	pairs := types.PDFArray{}
+	for i := 0; i+1 < len(arr); i += 2 {
+		if sel.keepsDest(arr[i+1]) {
+			pairs = append(pairs, arr[i], arr[i+1])
+			continue
+		}
+		if name, ok := keyString(arr[i]); ok {
+			sel.dropped[name] = true
+		}
+	}
+
+	if len(pairs) == len(arr) {
+		return nil
+	}
+
+	if len(pairs) == 0 {
+		names.Delete("Dests")
+		return nil
+	}
+
+	indRef, err := insertNameTree(ctx, pairs)
+	if err != nil {
+		return err
+	}
+
+	names.Update("Dests", *indRef)
+
+	return nil
+}
+
+// selectFields returns the form fields of arr having widgets in annots.
+// Fields without widgets are kept, fields whose kids all got removed are dropped.
+// The object numbers of the kept fields and widgets are added to kept.
+func selectFields(ctx *types.PDFContext, arr types.PDFArray, annots, kept types.IntSet) (types.PDFArray, error) {
+
+	fields := types.PDFArray{}
+
+	for _, o := range arr {
+
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+
+		indRef, isRef := o.(types.PDFIndirectRef)
+
+		if o, found := d.Find("Kids"); found {
+
+			kids, err := ctx.DereferenceArray(o)
+			if err != nil {
+				return nil, err
+			}
+
+			if kids != nil {
+
+				a, err := selectFields(ctx, *kids, annots, kept)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(a) == 0 {
+					continue
+				}
+
+				d.Update("Kids", a)
+			}
+
+		} else {
+
+			_, hasPage := d.Find("P")
+			isWidget := hasPage || d.Subtype() != nil && *d.Subtype() == "Widget"
+
+			if isWidget && !(isRef && annots[indRef.ObjectNumber.Value()]) {
+				continue
+			}
+		}
+
+		if isRef {
+			kept[indRef.ObjectNumber.Value()] = true
+		}
+
+		fields = append(fields, o)
+	}
+
+	return fields, nil
+}
+
+// selectFormFields removes the form fields and widgets not placed on pages.
+func selectFormFields(ctx *types.PDFContext, root *types.PDFDict, pages []types.PDFIndirectRef) error {
+
+	acroForm, err := ctx.DereferenceDict(root.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		return err
+	}
+
+	annots := types.IntSet{}
+
+	for _, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		arr, err := ctx.DereferenceArray(pageDict.Dict["Annots"])
+		if err != nil {
+			return err
+		}
+
+		if arr == nil {
+			continue
+		}
+
+		for _, o := range *arr {
+			if indRef, ok := o.(types.PDFIndirectRef); ok {
+				annots[indRef.ObjectNumber.Value()] = true
+			}
+		}
+	}
+
+	arr, err := ctx.DereferenceArray(acroForm.Dict["Fields"])
+	if err != nil || arr == nil {
+		return err
+	}
+
+	kept := types.IntSet{}
+
+	fields, err := selectFields(ctx, *arr, annots, kept)
+	if err != nil {
+		return err
+	}
+
+	logDebugMerge.Printf("selectFormFields: %d of %d top level fields kept\n", len(fields), len(*arr))
+
+	if len(fields) == 0 {
+		root.Delete("AcroForm")
+		return nil
+	}
+
+	acroForm.Update("Fields", fields)
+
+	// The calculation order may only refer to kept fields.
+	co, err := ctx.DereferenceArray(acroForm.Dict["CO"])
+	if err != nil || co == nil {
+		return err
+	}
+
+	a := types.PDFArray{}
+	for _, o := range *co {
+		if indRef, ok := o.(types.PDFIndirectRef); ok && kept[indRef.ObjectNumber.Value()] {
+			a = append(a, o)
+		}
+	}
+
+	if len(a) == 0 {
+		acroForm.Delete("CO")
+		return nil
+	}
+
+	acroForm.Update("CO", a)
+
+	return nil
+}
+
+// SelectPages reduces the page tree of ctx to the selected pages, optionally in reverse order.
+// The remaining pages become kids of the page tree root keeping their inherited attributes.
+// Page labels get adjusted accordingly, form fields, named destinations, outline items, links,
+// article beads and the open action of dropped pages get removed.
+func SelectPages(ctx *types.PDFContext, selectedPages types.IntSet, reverse bool) (err error) {
+
+	logDebugMerge.Println("SelectPages begin")
+
+	var pageNrs, pageIndices []int
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		if selectedPages[pageNr] {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+
+	if reverse {
+		for i, j := 0, len(pageNrs)-1; i < j; i, j = i+1, j-1 {
+			pageNrs[i], pageNrs[j] = pageNrs[j], pageNrs[i]
+		}
+	}
+
+	err = orderPages(ctx, pageNrs)
+	if err != nil {
+		return
+	}
+
+	for _, pageNr := range pageNrs {
+		pageIndices = append(pageIndices, pageNr-1)
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	err = selectPageLabels(ctx, root, pageIndices)
+	if err != nil {
+		return
+	}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	err = selectFormFields(ctx, root, pages)
+	if err != nil {
+		return
+	}
+
+	sel := &selection{ctx: ctx, pages: types.IntSet{}, dropped: types.StringSet{}}
+	for _, indRef := range pages {
+		sel.pages[indRef.ObjectNumber.Value()] = true
+	}
+
+	err = sel.dests(root)
+	if err != nil {
+		return
+	}
+
+	if !sel.keepsDest(root.Dict["OpenAction"]) {
+		root.Delete("OpenAction")
+	}
+
+	err = sel.outlines(root)
+	if err != nil {
+		return
+	}
+
+	err = sel.links(pages)
+	if err != nil {
+		return
+	}
+
+	err = sel.threads(root)
+	if err != nil {
+		return
+	}
+
+	logDebugMerge.Printf("SelectPages end: %d pages\n", ctx.PageCount)
+
+	return
+}
+
+// InterleavePages reorders the pages of a merged document page by page.
+// pageCounts holds the page counts of the merged inputs in merge order.
+// The result starts with the first page of every input followed by the second page of every input and so on.
+// Inputs running out of pages are skipped. Page labels get removed.
+func InterleavePages(ctx *types.PDFContext, pageCounts []int) (err error) {
+
+	logDebugMerge.Println("InterleavePages begin")
+
+	var pageNrs []int
+
+	max := 0
+	for _, c := range pageCounts {
+		if c > max {
+			max = c
+		}
+	}
+
+	for i := 0; i < max; i++ {
+		offset := 0
+		for _, c := range pageCounts {
+			if i < c {
+				pageNrs = append(pageNrs, offset+i+1)
+			}
+			offset += c
+		}
+	}
+
+	err = orderPages(ctx, pageNrs)
+	if err != nil {
+		return
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	root.Delete("PageLabels")
+
+	logDebugMerge.Printf("InterleavePages end: %d pages\n", ctx.PageCount)
+
+	return
+}
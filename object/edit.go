@@ -0,0 +1,195 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/read"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// parseValue parses a single object given in PDF syntax, eg. "[0 0 595 842]" or "<</S/JavaScript>>".
+func parseValue(value string) (interface{}, error) {
+
+	s := value
+
+	obj, err := read.ParseNextObject(&s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value: %s", value)
+	}
+
+	if obj == nil || strings.TrimSpace(s) != "" {
+		return nil, errors.Errorf("invalid value: %s", value)
+	}
+
+	return obj, nil
+}
+
+// checkIndRefs makes sure all indirect references of a new value point to existing objects.
+func checkIndRefs(ctx *types.PDFContext, obj interface{}) error {
+
+	switch o := obj.(type) {
+
+	case types.PDFIndirectRef:
+		if _, found := ctx.FindTableEntryForIndRef(&o); !found {
+			return errors.Errorf("invalid value: object #%d not found", o.ObjectNumber)
+		}
+
+	case types.PDFDict:
+		for _, v := range o.Dict {
+			if err := checkIndRefs(ctx, v); err != nil {
+				return err
+			}
+		}
+
+	case types.PDFArray:
+		for _, v := range o {
+			if err := checkIndRefs(ctx, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// set stores obj at the location identified by elems.
+// A single path element identifies a whole object which gets replaced.
+func set(ctx *types.PDFContext, elems []string, obj interface{}) error {
+
+	if len(elems) == 1 {
+		_, indRef, err := resolve(ctx, elems)
+		if err != nil {
+			return err
+		}
+		entry, found := ctx.FindTableEntryForIndRef(indRef)
+		if !found {
+			return errors.Errorf("object #%d not found", indRef.ObjectNumber)
+		}
+		entry.Object = obj
+		return nil
+	}
+
+	parent, _, err := resolve(ctx, elems[:len(elems)-1])
+	if err != nil {
+		return err
+	}
+
+	if sd, ok := streamDict(parent); ok {
+		parent = sd.PDFDict
+	}
+
+	key := elems[len(elems)-1]
+
+	switch p := parent.(type) {
+
+	case types.PDFDict:
+		p.Dict[key] = obj
+		return nil
+
+	case types.PDFArray:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(p) {
+			return errors.Errorf("invalid array index: %s (len=%d)", key, len(p))
+		}
+		p[i] = obj
+		return nil
+	}
+
+	return errors.Errorf("cannot set %s: %T is neither a dict nor an array", key, parent)
+}
+
+// Set parses value and stores it at path.
+// If path is a single object number or trailer entry the whole object gets replaced,
+// otherwise the last path element is the dict key to set or the array index to replace.
+func Set(ctx *types.PDFContext, path, value string) error {
+
+	obj, err := parseValue(value)
+	if err != nil {
+		return err
+	}
+
+	if err = checkIndRefs(ctx, obj); err != nil {
+		return err
+	}
+
+	logDebugObject.Printf("Set %s: %v\n", path, obj)
+
+	return set(ctx, splitPath(path), obj)
+}
+
+// Delete removes the dict entry or the array element identified by path.
+// Whole objects may not be deleted, objects no longer referenced are not written.
+func Delete(ctx *types.PDFContext, path string) error {
+
+	elems := splitPath(path)
+
+	if len(elems) < 2 {
+		return errors.Errorf("cannot delete %s: delete the references to an object instead", path)
+	}
+
+	parent, _, err := resolve(ctx, elems[:len(elems)-1])
+	if err != nil {
+		return err
+	}
+
+	if sd, ok := streamDict(parent); ok {
+		parent = sd.PDFDict
+	}
+
+	key := elems[len(elems)-1]
+
+	logDebugObject.Printf("Delete %s\n", path)
+
+	switch p := parent.(type) {
+
+	case types.PDFDict:
+		if p.Delete(key) == nil {
+			return errors.Errorf("missing dict entry: %s", key)
+		}
+		return nil
+
+	case types.PDFArray:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(p) {
+			return errors.Errorf("invalid array index: %s (len=%d)", key, len(p))
+		}
+		a := append(p[:i:i], p[i+1:]...)
+		return set(ctx, elems[:len(elems)-1], a)
+	}
+
+	return errors.Errorf("cannot delete %s: %T is neither a dict nor an array", key, parent)
+}
+
+// ReplaceStream replaces the content of the stream object identified by path.
+// The new content is stored Flate encoded.
+func ReplaceStream(ctx *types.PDFContext, path string, content []byte) error {
+
+	obj, indRef, err := Resolve(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	sd, ok := obj.(types.PDFStreamDict)
+	if !ok {
+		return errors.Errorf("cannot replace stream of %s: %s is not a stream", path, typeName(obj))
+	}
+
+	entry, found := ctx.FindTableEntryForIndRef(indRef)
+	if !found {
+		return errors.Errorf("object #%d not found", indRef.ObjectNumber)
+	}
+
+	logDebugObject.Printf("ReplaceStream obj#%d: %d bytes\n", indRef.ObjectNumber, len(content))
+
+	err = filter.ReplaceContent(&sd, content)
+	if err != nil {
+		return err
+	}
+
+	entry.Object = sd
+
+	return nil
+}
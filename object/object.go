@@ -0,0 +1,299 @@
+// Package object provides low level access to the objects and the cross reference table of a PDF file.
+package object
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugObject *log.Logger
+
+func init() {
+	logDebugObject = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugObject = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugObject = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// trailerEntry returns the indirect reference for a trailer key.
+func trailerEntry(ctx *types.PDFContext, key string) (*types.PDFIndirectRef, error) {
+
+	var indRef *types.PDFIndirectRef
+
+	switch key {
+	case "Root":
+		indRef = ctx.Root
+	case "Info":
+		indRef = ctx.Info
+	case "Encrypt":
+		indRef = ctx.Encrypt
+	default:
+		return nil, errors.Errorf("invalid path start: %s, expected an object number, Root, Info or Encrypt", key)
+	}
+
+	if indRef == nil {
+		return nil, errors.Errorf("missing trailer entry: %s", key)
+	}
+
+	return indRef, nil
+}
+
+// streamDict returns the stream dict of any kind of stream object.
+func streamDict(obj interface{}) (*types.PDFStreamDict, bool) {
+
+	switch sd := obj.(type) {
+
+	case types.PDFStreamDict:
+		return &sd, true
+
+	case types.PDFObjectStreamDict:
+		return &sd.PDFStreamDict, true
+
+	case types.PDFXRefStreamDict:
+		return &sd.PDFStreamDict, true
+	}
+
+	return nil, false
+}
+
+// child returns the element of a dict or array identified by a path element.
+func child(obj interface{}, elem string) (interface{}, error) {
+
+	if sd, ok := streamDict(obj); ok {
+		obj = sd.PDFDict
+	}
+
+	switch o := obj.(type) {
+
+	case types.PDFDict:
+		v, found := o.Find(elem)
+		if !found {
+			return nil, errors.Errorf("missing dict entry: %s", elem)
+		}
+		return v, nil
+
+	case types.PDFArray:
+		i, err := strconv.Atoi(elem)
+		if err != nil || i < 0 || i >= len(o) {
+			return nil, errors.Errorf("invalid array index: %s (len=%d)", elem, len(o))
+		}
+		return o[i], nil
+	}
+
+	return nil, errors.Errorf("cannot resolve %s: %T is neither a dict nor an array", elem, obj)
+}
+
+// Resolve returns the object for a path like "5" or "Root/Pages/Kids/0/Resources".
+// The first element is an object number or one of the trailer entries Root, Info and Encrypt.
+// Any further element is a dict key or an array index.
+// Indirect references are followed along the way,
+// indRef refers to the last indirect object visited.
+func Resolve(ctx *types.PDFContext, path string) (obj interface{}, indRef *types.PDFIndirectRef, err error) {
+	return resolve(ctx, splitPath(path))
+}
+
+// splitPath returns the non empty elements of path.
+func splitPath(path string) []string {
+
+	var elems []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			elems = append(elems, s)
+		}
+	}
+
+	return elems
+}
+
+func resolve(ctx *types.PDFContext, elems []string) (obj interface{}, indRef *types.PDFIndirectRef, err error) {
+
+	if len(elems) == 0 {
+		return nil, nil, errors.New("missing object number or path")
+	}
+
+	if objNr, err := strconv.Atoi(elems[0]); err == nil {
+		entry, found := ctx.Find(objNr)
+		if !found || entry.Free {
+			return nil, nil, errors.Errorf("object #%d not found", objNr)
+		}
+		ir := types.NewPDFIndirectRef(objNr, *entry.Generation)
+		indRef = &ir
+	} else {
+		indRef, err = trailerEntry(ctx, elems[0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	obj = *indRef
+
+	for _, elem := range elems[1:] {
+
+		logDebugObject.Printf("Resolve: %s\n", elem)
+
+		obj, err = ctx.Dereference(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		obj, err = child(obj, elem)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ir, ok := obj.(types.PDFIndirectRef); ok {
+			indRef = &ir
+		}
+	}
+
+	if ir, ok := obj.(types.PDFIndirectRef); ok {
+		indRef = &ir
+		obj, err = ctx.Dereference(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if obj == nil {
+			return nil, nil, errors.Errorf("object #%d not found", ir.ObjectNumber)
+		}
+	}
+
+	return obj, indRef, nil
+}
+
+// filterNames returns a comma separated list of the filters of a stream.
+func filterNames(sd *types.PDFStreamDict) string {
+
+	var ss []string
+	for _, f := range sd.FilterPipeline {
+		ss = append(ss, f.Name)
+	}
+
+	return strings.Join(ss, ",")
+}
+
+// Dump returns a printable representation of the object identified by path.
+// If decode is true the content of a stream object is decoded and appended.
+func Dump(ctx *types.PDFContext, path string, decode bool) (list []string, err error) {
+
+	obj, indRef, err := Resolve(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	list = append(list, fmt.Sprintf("%s: %s obj#%d gen#%d", path, typeName(obj), indRef.ObjectNumber, indRef.GenerationNumber))
+
+	sd, ok := streamDict(obj)
+	if !ok {
+		list = append(list, fmt.Sprintf("%v", obj))
+		return list, nil
+	}
+
+	list = append(list, sd.PDFDict.String())
+
+	s := fmt.Sprintf("stream: %d bytes", len(sd.Raw))
+	if len(sd.FilterPipeline) > 0 {
+		s += fmt.Sprintf(" filter=%s", filterNames(sd))
+	}
+	list = append(list, s)
+
+	if !decode {
+		return list, nil
+	}
+
+	err = filter.DecodeStream(sd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decode stream of obj#%d", indRef.ObjectNumber)
+	}
+
+	list = append(list, fmt.Sprintf("decoded: %d bytes", len(sd.Content)), string(sd.Content))
+
+	return list, nil
+}
+
+// typeName returns a short type description of an object including its Type and Subtype.
+func typeName(obj interface{}) string {
+
+	s := strings.TrimPrefix(fmt.Sprintf("%T", obj), "types.")
+
+	d, ok := obj.(types.PDFDict)
+	if sd, isStream := streamDict(obj); isStream {
+		d, ok = sd.PDFDict, true
+	}
+
+	if !ok {
+		return s
+	}
+
+	if t := d.Type(); t != nil {
+		s += fmt.Sprintf(" Type=%s", *t)
+	}
+
+	if st := d.Subtype(); st != nil {
+		s += fmt.Sprintf(" Subtype=%s", *st)
+	}
+
+	return s
+}
+
+// XRef returns a listing of the cross reference table.
+// Each line shows the entry type (f = free, n = in use, c = compressed),
+// the offset (or the next free object), the generation, the object stream membership and the object type.
+func XRef(ctx *types.PDFContext) []string {
+
+	var objNrs []int
+	for objNr := range ctx.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	list := []string{fmt.Sprintf("xref: %d entries, size=%d", len(objNrs), *ctx.Size)}
+
+	for _, objNr := range objNrs {
+
+		entry := ctx.Table[objNr]
+
+		gen := 0
+		if entry.Generation != nil {
+			gen = *entry.Generation
+		}
+
+		if entry.Free {
+			var next int64
+			if entry.Offset != nil {
+				next = *entry.Offset
+			}
+			list = append(list, fmt.Sprintf("%5d f next=%-8d gen=%-5d", objNr, next, gen))
+			continue
+		}
+
+		if entry.ObjectStream != nil {
+			list = append(list, fmt.Sprintf("%5d c objstm=%d[%d] gen=%-5d %s",
+				objNr, *entry.ObjectStream, *entry.ObjectStreamInd, gen, typeName(entry.Object)))
+			continue
+		}
+
+		offset := "nil"
+		if entry.Offset != nil {
+			offset = fmt.Sprintf("%d", *entry.Offset)
+		}
+
+		list = append(list, fmt.Sprintf("%5d n offset=%-8s gen=%-5d %s", objNr, offset, gen, typeName(entry.Object)))
+	}
+
+	return list
+}
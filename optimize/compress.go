@@ -0,0 +1,207 @@
+package optimize
+
+import (
+	"compress/zlib"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// flateStream returns a copy of the stream dict sd holding buf encoded using Flate at best compression with optional decode parms.
+func flateStream(sd types.PDFStreamDict, buf []byte, parms *types.PDFDict) (*types.PDFStreamDict, error) {
+
+	sd2 := sd
+	sd2.Content = buf
+	sd2.FilterPipeline = []types.PDFFilter{{Name: "FlateDecode", DecodeParms: parms}}
+	sd2.Dict = map[string]interface{}{}
+	for k, v := range sd.Dict {
+		sd2.Dict[k] = v
+	}
+	sd2.Update("Filter", types.PDFName("FlateDecode"))
+	sd2.Delete("DecodeParms")
+	if parms != nil {
+		sd2.Insert("DecodeParms", *parms)
+	}
+	sd2.StreamLengthObjNr = nil
+
+	if err := filter.EncodeStreamLevel(&sd2, zlib.BestCompression); err != nil {
+		return nil, err
+	}
+
+	return &sd2, nil
+}
+
+// flateParms returns the decode parms of the Flate filter of a pipeline consisting of supported filters only.
+func flateParms(sd *types.PDFStreamDict) (parms *types.PDFDict, ok bool) {
+
+	for _, f := range sd.FilterPipeline {
+		switch f.Name {
+		case "FlateDecode":
+			parms = f.DecodeParms
+		case "ASCII85Decode", "ASCIIHexDecode":
+		default:
+			return nil, false
+		}
+	}
+
+	return parms, true
+}
+
+// contentStreams returns the object numbers of all page content streams, forms, tiling patterns and glyph descriptions.
+func contentStreams(ctx *types.PDFContext) (types.IntSet, error) {
+
+	objNrs := types.IntSet{}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil || pageDict == nil {
+			return nil, err
+		}
+
+		switch o := pageDict.Dict["Contents"].(type) {
+
+		case types.PDFIndirectRef:
+			objNrs[o.ObjectNumber.Value()] = true
+			if a, err := ctx.DereferenceArray(o); err == nil && a != nil {
+				for _, o := range *a {
+					if indRef, ok := o.(types.PDFIndirectRef); ok {
+						objNrs[indRef.ObjectNumber.Value()] = true
+					}
+				}
+			}
+
+		case types.PDFArray:
+			for _, o := range o {
+				if indRef, ok := o.(types.PDFIndirectRef); ok {
+					objNrs[indRef.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+
+	for objNr, entry := range ctx.Table {
+
+		switch o := entry.Object.(type) {
+
+		case types.PDFStreamDict:
+			if s := o.Subtype(); s != nil && *s == "Form" {
+				objNrs[objNr] = true
+			}
+			if pt := o.IntEntry("PatternType"); pt != nil && *pt == 1 {
+				objNrs[objNr] = true
+			}
+
+		case types.PDFDict:
+			if s := o.Subtype(); s == nil || *s != "Type3" {
+				continue
+			}
+			charProcs, err := ctx.DereferenceDict(o.Dict["CharProcs"])
+			if err != nil || charProcs == nil {
+				continue
+			}
+			for _, o := range charProcs.Dict {
+				if indRef, ok := o.(types.PDFIndirectRef); ok {
+					objNrs[indRef.ObjectNumber.Value()] = true
+				}
+			}
+		}
+	}
+
+	return objNrs, nil
+}
+
+// recompressStream encodes a stream using Flate at best compression, content streams are also minified.
+// The stream is only replaced if this saves space.
+func recompressStream(ctx *types.PDFContext, objNr int, isContent bool) (saved int) {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found || entry.Free {
+		return
+	}
+
+	sd, ok := entry.Object.(types.PDFStreamDict)
+	if !ok {
+		return
+	}
+
+	// Leave alone external stream data and metadata meant to be readable by any tool.
+	if _, found := sd.Find("F"); found {
+		return
+	}
+	if t := sd.Type(); t != nil && *t == "Metadata" {
+		return
+	}
+
+	parms, ok := flateParms(&sd)
+	if !ok {
+		return
+	}
+
+	if err := filter.DecodeStream(&sd); err != nil {
+		logInfoOptimize.Printf("recompressStreams: obj#%d: %v\n", objNr, err)
+		return
+	}
+
+	candidates := [][]byte{sd.Content}
+	if isContent {
+		if buf, err := content.Minify(sd.Content); err == nil {
+			candidates = append(candidates, buf)
+		}
+	}
+
+	var best *types.PDFStreamDict
+
+	for _, buf := range candidates {
+
+		sd2, err := flateStream(sd, buf, parms)
+		if err != nil {
+			logInfoOptimize.Printf("recompressStreams: obj#%d: %v\n", objNr, err)
+			return 0
+		}
+
+		if best == nil || len(sd2.Raw) < len(best.Raw) {
+			best = sd2
+		}
+	}
+
+	saved = len(sd.Raw) - len(best.Raw)
+	if saved <= 0 {
+		return 0
+	}
+
+	entry.Object = *best
+
+	return saved
+}
+
+// recompressStreams encodes all streams using Flate at best compression and minifies content streams.
+func recompressStreams(ctx *types.PDFContext) (err error) {
+
+	isContent, err := contentStreams(ctx)
+	if err != nil {
+		return
+	}
+
+	var objNrs []int
+	for objNr := range ctx.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	var total int
+	for _, objNr := range objNrs {
+		total += recompressStream(ctx, objNr, isContent[objNr])
+	}
+
+	logInfoOptimize.Printf("recompressStreams: %d bytes saved\n", total)
+
+	return
+}
@@ -0,0 +1,864 @@
+package optimize
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var errIncompatibleSubsets = errors.New("optimize: incompatible font subsets")
+
+// fontGroup is a set of fonts using different subsets of the same font, like the chapters of a merged book do.
+type fontGroup struct {
+	name  string
+	kind  int
+	fonts []int       // font dict object numbers
+	files map[int]int // font file object numbers by font dict object number
+}
+
+// mergedFont holds everything needed to replace the fonts of a group by a single font.
+type mergedFont struct {
+	program   []byte
+	firstChar int
+	widths    types.PDFArray      // simple fonts
+	encoding  interface{}         // simple fonts, nil if unchanged
+	cidWidths map[int]interface{} // CIDFonts
+	cidToGID  []byte              // CIDFonts
+	toUnicode *font.CMap          // nil if unchanged
+	symbolic  bool                // codes map to glyphs by the cmap of the program
+	codes     map[int]bool        // codes shown using any font of the group
+}
+
+// fontMerger combines the font subsets of a group into a single font.
+type fontMerger struct {
+	ctx       *types.PDFContext
+	gc        *glyphCollector
+	g         *fontGroup
+	fontDicts map[int]*types.PDFDict
+}
+
+// users returns the fonts of the group showing code c.
+func (fm *fontMerger) users(c int) (objNrs []int) {
+	for _, objNr := range fm.g.fonts {
+		if fm.gc.codes[objNr][c] {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	return
+}
+
+func sameNumber(o1, o2 interface{}) bool {
+	f1, ok1 := number(o1)
+	f2, ok2 := number(o2)
+	return ok1 == ok2 && f1 == f2
+}
+
+// mergeWidths returns the widths for the codes shown using the fonts of the group.
+func (fm *fontMerger) mergeWidths() (first int, widths types.PDFArray, err error) {
+
+	ws := map[int]interface{}{}
+
+	for _, objNr := range fm.g.fonts {
+
+		fontDict := fm.fontDicts[objNr]
+
+		fc := fontDict.IntEntry("FirstChar")
+
+		a, err := fm.ctx.DereferenceArray(fontDict.Dict["Widths"])
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if fc == nil || a == nil {
+			return 0, nil, errors.Wrap(errIncompatibleSubsets, "missing widths")
+		}
+
+		for i, o := range *a {
+			c := *fc + i
+			if !fm.gc.codes[objNr][c] {
+				continue
+			}
+			if o, err = fm.ctx.Dereference(o); err != nil {
+				return 0, nil, err
+			}
+			if w, found := ws[c]; found && !sameNumber(w, o) {
+				return 0, nil, errors.Wrapf(errIncompatibleSubsets, "width of code %d", c)
+			}
+			ws[c] = o
+		}
+	}
+
+	if len(ws) == 0 {
+		return 0, nil, errors.Wrap(errIncompatibleSubsets, "missing widths")
+	}
+
+	lo, hi := 255, 0
+	for c := range ws {
+		if c < lo {
+			lo = c
+		}
+		if c > hi {
+			hi = c
+		}
+	}
+
+	widths = make(types.PDFArray, hi-lo+1)
+	for i := range widths {
+		widths[i] = types.PDFInteger(0)
+		if w, found := ws[lo+i]; found {
+			widths[i] = w
+		}
+	}
+
+	return lo, widths, nil
+}
+
+// baseEncoding returns the name of the encoding a simple font encoding is based on.
+func baseEncoding(o interface{}) (string, bool) {
+
+	switch o := o.(type) {
+
+	case nil:
+		return "", true
+
+	case types.PDFName:
+		return o.Value(), true
+
+	case types.PDFDict:
+		if n := o.NameEntry("BaseEncoding"); n != nil {
+			return *n, true
+		}
+		return "", true
+	}
+
+	return "", false
+}
+
+// mergeEncoding returns an encoding for all codes shown using the fonts of the group or nil if all fonts share the same encoding.
+// Encodings have to be based on the same encoding and must not differ for codes shown.
+func (fm *fontMerger) mergeEncoding() (interface{}, error) {
+
+	var (
+		encodings []interface{}
+		same      = true
+	)
+
+	for i, objNr := range fm.g.fonts {
+
+		o, err := fm.ctx.Dereference(fm.fontDicts[objNr].Dict["Encoding"])
+		if err != nil {
+			return nil, err
+		}
+
+		encodings = append(encodings, o)
+
+		if i == 0 || !same {
+			continue
+		}
+
+		if o == nil || encodings[0] == nil {
+			same = o == nil && encodings[0] == nil
+			continue
+		}
+
+		if same, err = equalPDFObjects(encodings[0], o, fm.ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if same {
+		return nil, nil
+	}
+
+	base, ok := baseEncoding(encodings[0])
+	if !ok {
+		return nil, errors.Wrap(errIncompatibleSubsets, "encoding")
+	}
+
+	for _, o := range encodings[1:] {
+		if b, ok := baseEncoding(o); !ok || b != base {
+			return nil, errors.Wrap(errIncompatibleSubsets, "base encoding")
+		}
+	}
+
+	// Glyph names by code, empty if according to the base encoding.
+	names := map[int]string{}
+
+	for _, objNr := range fm.g.fonts {
+		_, differences := simpleEncoding(fm.ctx, fm.fontDicts[objNr])
+		for c := range fm.gc.codes[objNr] {
+			if name, found := names[c]; found && name != differences[c] {
+				return nil, errors.Wrapf(errIncompatibleSubsets, "encoding of code %d", c)
+			}
+			names[c] = differences[c]
+		}
+	}
+
+	var codes []int
+	for c, name := range names {
+		if name != "" {
+			codes = append(codes, c)
+		}
+	}
+	sort.Ints(codes)
+
+	var differences types.PDFArray
+	for i, c := range codes {
+		if i == 0 || c != codes[i-1]+1 {
+			differences = append(differences, types.PDFInteger(c))
+		}
+		differences = append(differences, types.PDFName(names[c]))
+	}
+
+	d := types.NewPDFDict()
+	d.Insert("Type", types.PDFName("Encoding"))
+	if base != "" {
+		d.Insert("BaseEncoding", types.PDFName(base))
+	}
+	if len(differences) > 0 {
+		d.Insert("Differences", differences)
+	}
+
+	return d, nil
+}
+
+// mergeToUnicode returns a ToUnicode CMap for all codes of n bytes shown using the fonts of the group
+// or nil if all fonts share the same ToUnicode CMap.
+// If fromEncoding is set, codes not covered are mapped according to the encoding of simple fonts.
+func (fm *fontMerger) mergeToUnicode(n int, fromEncoding bool) (*font.CMap, error) {
+
+	var (
+		m     = &font.CMap{Chars: map[string]string{}}
+		first *types.PDFIndirectRef
+		same  = true
+	)
+
+	for i, objNr := range fm.g.fonts {
+
+		fontDict := fm.fontDicts[objNr]
+
+		indRef := fontDict.IndirectRefEntry("ToUnicode")
+		if i == 0 {
+			first = indRef
+		}
+		if (indRef == nil) != (first == nil) || indRef != nil && *indRef != *first {
+			same = false
+		}
+
+		chars := map[string]string{}
+
+		if o := fontDict.Dict["ToUnicode"]; o != nil {
+
+			sd, err := fm.ctx.DereferenceStreamDict(o)
+			if err != nil || sd == nil {
+				return nil, err
+			}
+
+			if err = filter.DecodeStream(sd); err != nil {
+				return nil, err
+			}
+
+			cm := font.ParseCMap(sd.Content)
+			if m.Codespace == nil {
+				m.Codespace = cm.Codespace
+			}
+			chars = cm.Chars
+		}
+
+		if fromEncoding {
+			runes, _ := simpleEncoding(fm.ctx, fontDict)
+			for c := range fm.gc.codes[objNr] {
+				if s := string([]byte{byte(c)}); chars[s] == "" && runes[c] != 0 {
+					chars[s] = string(runes[c])
+				}
+			}
+		}
+
+		for s, u := range chars {
+			c := 0
+			for _, b := range []byte(s) {
+				c = c<<8 | int(b)
+			}
+			if len(s) != n || !fm.gc.codes[objNr][c] {
+				continue
+			}
+			if v, found := m.Chars[s]; found && v != u {
+				return nil, errors.Wrapf(errIncompatibleSubsets, "text of code %d", c)
+			}
+			m.Chars[s] = u
+		}
+	}
+
+	if same && !fromEncoding || len(m.Chars) == 0 {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+// mergeCIDFonts returns a font program holding the glyphs of all CIDs shown using the CIDFontType2 fonts of the group
+// along with the CIDToGIDMap and widths of the CIDs.
+func (fm *fontMerger) mergeCIDFonts(programs map[int]*font.TrueType, mf *mergedFont) (err error) {
+
+	cidFonts := map[int]*types.PDFDict{}
+	cidToGIDs := map[int][]byte{}
+
+	for _, objNr := range fm.g.fonts {
+		cidFont := descendantFont(fm.ctx, fm.fontDicts[objNr])
+		if cidFont == nil {
+			return errors.Wrap(errIncompatibleSubsets, "missing CIDFont")
+		}
+		cidFonts[objNr] = cidFont
+		cidToGIDs[objNr] = cidToGID(fm.ctx, cidFont)
+	}
+
+	first := cidFonts[fm.g.fonts[0]]
+	dw := first.IntEntry("DW")
+
+	for _, objNr := range fm.g.fonts[1:] {
+
+		cidFont := cidFonts[objNr]
+
+		if w := cidFont.IntEntry("DW"); (w == nil) != (dw == nil) || w != nil && *w != *dw {
+			return errors.Wrap(errIncompatibleSubsets, "default width")
+		}
+
+		ok, err := equalPDFObjects(first.Dict["CIDSystemInfo"], cidFont.Dict["CIDSystemInfo"], fm.ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.Wrap(errIncompatibleSubsets, "CIDSystemInfo")
+		}
+	}
+
+	// Widths
+	mf.cidWidths = map[int]interface{}{}
+	seen := map[int]bool{}
+
+	for _, objNr := range fm.g.fonts {
+
+		widths, _ := cidWidths(fm.ctx, cidFonts[objNr], fm.gc.codes[objNr])
+
+		for cid := range fm.gc.codes[objNr] {
+			w, found := widths[cid]
+			if w, err = fm.ctx.Dereference(w); err != nil {
+				return err
+			}
+			if seen[cid] && !sameNumber(w, mf.cidWidths[cid]) {
+				return errors.Wrapf(errIncompatibleSubsets, "width of CID %d", cid)
+			}
+			seen[cid] = true
+			if found {
+				mf.cidWidths[cid] = w
+			}
+		}
+	}
+
+	// Glyphs
+	gid := func(objNr, cid int) int {
+		m := cidToGIDs[objNr]
+		if m == nil {
+			return cid
+		}
+		if 2*cid+1 >= len(m) {
+			return 0
+		}
+		return int(m[2*cid])<<8 | int(m[2*cid+1])
+	}
+
+	var cids []int
+	for cid := range mf.codes {
+		cids = append(cids, cid)
+	}
+	sort.Ints(cids)
+
+	glyphs := []font.Glyph{{Font: programs[fm.g.files[fm.g.fonts[0]]], GID: 0}}
+	mf.cidToGID = make([]byte, 2*(cids[len(cids)-1]+1))
+
+	for _, cid := range cids {
+
+		var g *font.Glyph
+
+		for _, objNr := range fm.users(cid) {
+			h := font.Glyph{Font: programs[fm.g.files[objNr]], GID: gid(objNr, cid)}
+			if g == nil {
+				g = &h
+				continue
+			}
+			if !font.SameGlyph(*g, h) {
+				return errors.Wrapf(errIncompatibleSubsets, "glyph of CID %d", cid)
+			}
+		}
+
+		if g.GID == 0 {
+			continue
+		}
+
+		i := len(glyphs)
+		glyphs = append(glyphs, *g)
+		mf.cidToGID[2*cid], mf.cidToGID[2*cid+1] = byte(i>>8), byte(i)
+	}
+
+	mf.program, err = font.AssembleTrueType(glyphs, nil)
+
+	return
+}
+
+// mergeTrueTypesByCode returns a font program holding the glyphs of all codes shown using the simple TrueType fonts of the group
+// mapped by a symbolic cmap. This applies to subsets having their glyphs renumbered.
+func (fm *fontMerger) mergeTrueTypesByCode(programs map[int]*font.TrueType, mf *mergedFont) (err error) {
+
+	gids := map[int]map[int]map[int]bool{}
+	for _, objNr := range fm.g.fonts {
+		tt := programs[fm.g.files[objNr]]
+		gids[objNr] = trueTypeGlyphs(fm.ctx, tt, fm.fontDicts[objNr], fm.gc.codes[objNr])
+	}
+
+	var codes []int
+	for c := range mf.codes {
+		codes = append(codes, c)
+	}
+	sort.Ints(codes)
+
+	glyphs := []font.Glyph{{Font: programs[fm.g.files[fm.g.fonts[0]]], GID: 0}}
+	cmap := map[int]int{}
+
+	for _, c := range codes {
+
+		var g *font.Glyph
+
+		for _, objNr := range fm.users(c) {
+			for gid := range gids[objNr][c] {
+				h := font.Glyph{Font: programs[fm.g.files[objNr]], GID: gid}
+				if g == nil {
+					g = &h
+					continue
+				}
+				if !font.SameGlyph(*g, h) {
+					return errors.Wrapf(errIncompatibleSubsets, "glyph of code %d", c)
+				}
+			}
+		}
+
+		// Missing glyphs show as .notdef.
+		if g == nil {
+			continue
+		}
+
+		cmap[c] = len(glyphs)
+		glyphs = append(glyphs, *g)
+	}
+
+	if len(cmap) == 0 {
+		return errors.Wrap(errIncompatibleSubsets, "no glyphs")
+	}
+
+	mf.program, err = font.AssembleTrueType(glyphs, cmap)
+	mf.symbolic = true
+
+	return
+}
+
+// prepare checks the fonts of the group for compatibility and returns the merged font.
+func (fm *fontMerger) prepare() (mf *mergedFont, err error) {
+
+	mf = &mergedFont{codes: map[int]bool{}}
+
+	fm.fontDicts = map[int]*types.PDFDict{}
+	for _, objNr := range fm.g.fonts {
+		entry, _ := fm.ctx.FindTableEntryLight(objNr)
+		fontDict := entry.Object.(types.PDFDict)
+		fm.fontDicts[objNr] = &fontDict
+		for c := range fm.gc.codes[objNr] {
+			mf.codes[c] = true
+		}
+	}
+
+	if len(mf.codes) == 0 {
+		return nil, errors.Wrap(errIncompatibleSubsets, "no text shown")
+	}
+
+	var objNrs []int
+	programs := map[int][]byte{}
+
+	for _, objNr := range fm.g.files {
+
+		if _, found := programs[objNr]; found {
+			continue
+		}
+
+		entry, _ := fm.ctx.FindTableEntryLight(objNr)
+		sd, ok := entry.Object.(types.PDFStreamDict)
+		if !ok {
+			return nil, errors.Errorf("obj#%d: missing font file", objNr)
+		}
+
+		if err = filter.DecodeStream(&sd); err != nil {
+			return nil, err
+		}
+
+		programs[objNr] = sd.Content
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	switch fm.g.kind {
+
+	case simpleTrueType, cidTrueType:
+
+		tts := map[int]*font.TrueType{}
+		var fonts []*font.TrueType
+
+		for _, objNr := range objNrs {
+			tt, err := font.ParseTrueType(programs[objNr])
+			if err != nil {
+				return nil, err
+			}
+			tts[objNr] = tt
+			fonts = append(fonts, tt)
+		}
+
+		if fm.g.kind == cidTrueType {
+			err = fm.mergeCIDFonts(tts, mf)
+		} else if mf.program, err = font.MergeTrueType(fonts); err != nil {
+			logInfoOptimize.Printf("prepare: %s: %v, merging by code\n", fm.g.name, err)
+			err = fm.mergeTrueTypesByCode(tts, mf)
+		}
+
+	case simpleCFF:
+
+		var fonts []*font.CFF
+
+		for _, objNr := range objNrs {
+			cff, err := font.ParseCFF(programs[objNr])
+			if err != nil {
+				return nil, err
+			}
+			fonts = append(fonts, cff)
+		}
+
+		mf.program, err = font.MergeCFF(fonts)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	n := 2
+
+	if fm.g.kind != cidTrueType {
+		n = 1
+		if mf.firstChar, mf.widths, err = fm.mergeWidths(); err != nil {
+			return nil, err
+		}
+		if !mf.symbolic {
+			if mf.encoding, err = fm.mergeEncoding(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	mf.toUnicode, err = fm.mergeToUnicode(n, mf.symbolic)
+
+	return mf, err
+}
+
+// insertStream adds a Flate encoded stream with content buf and returns a reference to it.
+func insertStream(ctx *types.PDFContext, buf []byte) (*types.PDFIndirectRef, error) {
+
+	sd, err := ctx.InsertPDFStreamDict(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = filter.EncodeStream(sd); err != nil {
+		return nil, err
+	}
+
+	objNr, err := ctx.InsertObject(*sd)
+	if err != nil {
+		return nil, err
+	}
+
+	indRef := types.NewPDFIndirectRef(objNr, 0)
+
+	return &indRef, nil
+}
+
+// apply turns the first font of the group into the merged font.
+func (fm *fontMerger) apply(mf *mergedFont) (err error) {
+
+	objNr := fm.g.fonts[0]
+	fontDict := fm.fontDicts[objNr]
+
+	fd, key, fileObjNr, _ := fontProgram(fm.ctx, fontDict)
+
+	entry, _ := fm.ctx.FindTableEntryLight(fileObjNr)
+
+	sd, err := fontFileStream(entry.Object.(types.PDFStreamDict), key, mf.program)
+	if err != nil {
+		return
+	}
+
+	entry.Object = *sd
+
+	tag := subsetTag(objNr, mf.codes)
+
+	rename(fontDict, "BaseFont", tag)
+
+	rename(fd, "FontName", tag)
+
+	// The glyph sets of the merged font are no longer valid.
+	fd.Delete("CharSet")
+	fd.Delete("CIDSet")
+
+	if fm.g.kind == cidTrueType {
+
+		cidFont := descendantFont(fm.ctx, fontDict)
+		rename(cidFont, "BaseFont", tag)
+
+		cidFont.Delete("W")
+		if len(mf.cidWidths) > 0 {
+			cidFont.Insert("W", cidWidthArray(mf.cidWidths))
+		}
+
+		indRef, err := insertStream(fm.ctx, mf.cidToGID)
+		if err != nil {
+			return err
+		}
+		cidFont.Update("CIDToGIDMap", *indRef)
+
+	} else {
+
+		fontDict.Update("FirstChar", types.PDFInteger(mf.firstChar))
+		fontDict.Update("LastChar", types.PDFInteger(mf.firstChar+len(mf.widths)-1))
+		fontDict.Update("Widths", mf.widths)
+
+		if mf.encoding != nil {
+			fontDict.Update("Encoding", mf.encoding)
+		}
+
+		if mf.symbolic {
+			fontDict.Delete("Encoding")
+			flags := 0
+			if f := fd.IntEntry("Flags"); f != nil {
+				flags = *f
+			}
+			// Nonsymbolic off, symbolic on, see 9.8.2 Font Descriptor Flags.
+			fd.Update("Flags", types.PDFInteger(flags&^32|4))
+		}
+	}
+
+	if mf.toUnicode != nil {
+		indRef, err := insertStream(fm.ctx, mf.toUnicode.Bytes())
+		if err != nil {
+			return err
+		}
+		fontDict.Update("ToUnicode", *indRef)
+	}
+
+	return
+}
+
+// repointFonts replaces all references to font dicts merged into another font by references to the merged font.
+func repointFonts(ctx *types.PDFContext, merged map[int]types.PDFIndirectRef) {
+
+	var repoint func(o interface{}) interface{}
+
+	repoint = func(o interface{}) interface{} {
+
+		switch o := o.(type) {
+
+		case types.PDFIndirectRef:
+			if indRef, found := merged[o.ObjectNumber.Value()]; found {
+				return indRef
+			}
+
+		case types.PDFDict:
+			for k, v := range o.Dict {
+				o.Dict[k] = repoint(v)
+			}
+
+		case types.PDFStreamDict:
+			for k, v := range o.Dict {
+				o.Dict[k] = repoint(v)
+			}
+
+		case types.PDFArray:
+			for i, v := range o {
+				o[i] = repoint(v)
+			}
+		}
+
+		return o
+	}
+
+	for _, entry := range ctx.Table {
+		if entry != nil && !entry.Free && entry.Object != nil {
+			entry.Object = repoint(entry.Object)
+		}
+	}
+}
+
+// fontGroups returns the groups of fonts using subsets of the same font.
+// Only font programs exclusively used by fonts shown in processed content are considered.
+func fontGroups(ctx *types.PDFContext, gc *glyphCollector) []*fontGroup {
+
+	groups := map[string]*fontGroup{}
+	users := map[int]int{} // number of fonts by font file object number
+
+	for objNr, entry := range ctx.Table {
+
+		if entry == nil || entry.Free {
+			continue
+		}
+
+		fontDict, ok := entry.Object.(types.PDFDict)
+		if !ok || fontDict.Type() == nil || *fontDict.Type() != "Font" {
+			continue
+		}
+
+		// CIDFonts are processed along with their Type0 font.
+		if s := fontDict.Subtype(); s == nil || *s == "CIDFontType0" || *s == "CIDFontType2" {
+			continue
+		}
+
+		_, _, fileObjNr, ok := fontProgram(ctx, &fontDict)
+		if !ok {
+			continue
+		}
+
+		users[fileObjNr]++
+
+		kind := subsetKind(ctx, &fontDict)
+		if _, found := gc.codes[objNr]; !found || kind == 0 || kind == cidCFF {
+			gc.unsafe[fileObjNr] = true
+			continue
+		}
+
+		n := fontDict.NameEntry("BaseFont")
+		if n == nil {
+			continue
+		}
+
+		name, isSubset := baseFontName(*n)
+		if !isSubset {
+			continue
+		}
+
+		k := fmt.Sprintf("%s %d", name, kind)
+		if e := fontDict.NameEntry("Encoding"); e != nil && kind == cidTrueType {
+			k += " " + *e
+		}
+
+		g := groups[k]
+		if g == nil {
+			g = &fontGroup{name: name, kind: kind, files: map[int]int{}}
+			groups[k] = g
+		}
+
+		g.fonts = append(g.fonts, objNr)
+		g.files[objNr] = fileObjNr
+	}
+
+	var keys []string
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var gg []*fontGroup
+
+	for _, k := range keys {
+
+		g := groups[k]
+		sort.Ints(g.fonts)
+
+		fonts := map[int]int{} // number of fonts of g by font file object number
+		for _, fileObjNr := range g.files {
+			fonts[fileObjNr]++
+		}
+
+		// Nothing to merge.
+		if len(fonts) < 2 {
+			continue
+		}
+
+		ok := true
+		for fileObjNr, n := range fonts {
+			if gc.unsafe[fileObjNr] || users[fileObjNr] != n {
+				ok = false
+			}
+		}
+
+		if !ok {
+			logInfoOptimize.Printf("fontGroups: %s: skipping font subsets\n", g.name)
+			continue
+		}
+
+		gg = append(gg, g)
+	}
+
+	return gg
+}
+
+// mergeFontSubsets replaces different subsets of the same font by a single font holding the union of their glyphs,
+// which is common for files resulting from merging documents.
+func mergeFontSubsets(ctx *types.PDFContext) (err error) {
+
+	logInfoOptimize.Println("mergeFontSubsets begin")
+
+	gc, err := collectGlyphs(ctx)
+	if err != nil {
+		return
+	}
+
+	// Glyphs of content not processed are unknown.
+	if gc.failed {
+		logInfoOptimize.Println("mergeFontSubsets end: skipped")
+		return nil
+	}
+
+	excludeFormFonts(ctx, gc)
+
+	merged := map[int]types.PDFIndirectRef{}
+
+	for _, g := range fontGroups(ctx, gc) {
+
+		fm := &fontMerger{ctx: ctx, gc: gc, g: g}
+
+		mf, err := fm.prepare()
+		if err != nil {
+			logInfoOptimize.Printf("mergeFontSubsets: %s: %v\n", g.name, err)
+			continue
+		}
+
+		if err = fm.apply(mf); err != nil {
+			return err
+		}
+
+		indRef := types.NewPDFIndirectRef(g.fonts[0], 0)
+		if entry, found := ctx.FindTableEntryLight(g.fonts[0]); found && entry.Generation != nil {
+			indRef = types.NewPDFIndirectRef(g.fonts[0], *entry.Generation)
+		}
+
+		for _, objNr := range g.fonts[1:] {
+			merged[objNr] = indRef
+		}
+
+		logInfoOptimize.Printf("mergeFontSubsets: %s: merged %d fonts\n", g.name, len(g.fonts))
+	}
+
+	if len(merged) > 0 {
+		repointFonts(ctx, merged)
+	}
+
+	logInfoOptimize.Println("mergeFontSubsets end")
+
+	return
+}
@@ -0,0 +1,855 @@
+package optimize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// defaultJPEGQuality is used for recompressing JPEG images if no quality has been configured.
+const defaultJPEGQuality = 75
+
+var errUnsupportedImage = errors.New("optimize: unsupported image")
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func number(o interface{}) (float64, bool) {
+
+	switch o := o.(type) {
+	case types.PDFInteger:
+		return float64(o.Value()), true
+	case types.PDFFloat:
+		return o.Value(), true
+	}
+
+	return 0, false
+}
+
+func matrixFromArray(a types.PDFArray) (m matrix, ok bool) {
+
+	if len(a) != 6 {
+		return m, false
+	}
+
+	for i, o := range a {
+		if m[i], ok = number(o); !ok {
+			return m, false
+		}
+	}
+
+	return m, true
+}
+
+// placementWalker interprets content streams and records the lowest effective resolution of each image placed.
+type placementWalker struct {
+	ctx      *types.PDFContext
+	dpi      map[int]float64 // by image object number
+	depth    int
+	unseen   types.IntSet // images reachable from content that could not be walked
+	excluded types.IntSet // forms whose resources have been excluded
+}
+
+// exclude marks all images reachable from resources as unseen.
+// Some of their placements are unknown so their effective resolution is too.
+func (pw *placementWalker) exclude(resources *types.PDFDict) {
+
+	if resources == nil {
+		return
+	}
+
+	xObjs, err := pw.ctx.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xObjs == nil {
+		return
+	}
+
+	for _, o := range xObjs.Dict {
+
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+
+		sd, err := pw.ctx.DereferenceStreamDict(indRef)
+		if err != nil || sd == nil || sd.Subtype() == nil {
+			continue
+		}
+
+		switch *sd.Subtype() {
+
+		case "Image":
+			pw.unseen[objNr] = true
+
+		case "Form":
+			if pw.excluded[objNr] {
+				continue
+			}
+			pw.excluded[objNr] = true
+			if res, err := pw.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil {
+				pw.exclude(res)
+			}
+		}
+	}
+}
+
+// place records the effective resolution of an image painted with given current transformation matrix.
+// An image is mapped onto the unit square, see 8.9.4 Image Coordinate Systems.
+func (pw *placementWalker) place(objNr int, sd *types.PDFStreamDict, ctm matrix) (err error) {
+
+	w, err := pw.ctx.DereferenceInteger(sd.Dict["Width"])
+	if err != nil || w == nil {
+		return
+	}
+
+	h, err := pw.ctx.DereferenceInteger(sd.Dict["Height"])
+	if err != nil || h == nil {
+		return
+	}
+
+	sx, sy := math.Hypot(ctm[0], ctm[1]), math.Hypot(ctm[2], ctm[3])
+	if sx == 0 || sy == 0 {
+		return
+	}
+
+	dpi := math.Min(float64(w.Value())*72/sx, float64(h.Value())*72/sy)
+
+	if d, found := pw.dpi[objNr]; !found || dpi < d {
+		pw.dpi[objNr] = dpi
+	}
+
+	return
+}
+
+func (pw *placementWalker) form(sd *types.PDFStreamDict, resources *types.PDFDict, ctm matrix) (err error) {
+
+	// A form without resources inherits the resources of the page.
+	if res, err := pw.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && res != nil {
+		resources = res
+	}
+
+	if pw.depth > 8 {
+		logInfoOptimize.Println("placementWalker: forms nested too deep")
+		pw.exclude(resources)
+		return
+	}
+
+	if a, err := pw.ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && a != nil {
+		if m, ok := matrixFromArray(*a); ok {
+			ctm = m.multiply(ctm)
+		}
+	}
+
+	err = filter.DecodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		logInfoOptimize.Println("placementWalker: form using unsupported filter")
+		pw.exclude(resources)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	pw.depth++
+	err = pw.walk(sd.Content, resources, ctm)
+	pw.depth--
+
+	return
+}
+
+func (pw *placementWalker) xObject(name string, resources *types.PDFDict, ctm matrix) (err error) {
+
+	if resources == nil {
+		return
+	}
+
+	xObjs, err := pw.ctx.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xObjs == nil {
+		return
+	}
+
+	indRef, ok := xObjs.Dict[name].(types.PDFIndirectRef)
+	if !ok {
+		return
+	}
+
+	sd, err := pw.ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return
+	}
+
+	s := sd.Subtype()
+	if s == nil {
+		return
+	}
+
+	switch *s {
+
+	case "Image":
+		err = pw.place(indRef.ObjectNumber.Value(), sd, ctm)
+
+	case "Form":
+		err = pw.form(sd, resources, ctm)
+	}
+
+	return
+}
+
+// walk processes the graphics state operators and XObjects of a content stream.
+// Inline images are left alone.
+func (pw *placementWalker) walk(buf []byte, resources *types.PDFDict, ctm matrix) (err error) {
+
+	var stack []matrix
+
+	sc := content.NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch op.Operator {
+
+		case "q":
+			stack = append(stack, ctm)
+
+		case "Q":
+			if len(stack) > 0 {
+				ctm = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+
+		case "cm":
+			if m, ok := matrixFromArray(op.Operands); ok {
+				ctm = m.multiply(ctm)
+			}
+
+		case "Do":
+			if len(op.Operands) != 1 {
+				continue
+			}
+			if name, ok := op.Operands[0].(types.PDFName); ok {
+				err = pw.xObject(string(name), resources, ctm)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// imageResolutions returns the lowest effective resolution of all images placed on pages by object number.
+// Images also placed by content that could not be walked are left out.
+func imageResolutions(ctx *types.PDFContext) (map[int]float64, error) {
+
+	pw := &placementWalker{ctx: ctx, dpi: map[int]float64{}, unseen: types.IntSet{}, excluded: types.IntSet{}}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		obj, err := ctx.InheritedPageAttr(pageDict, "Resources")
+		if err != nil {
+			return nil, err
+		}
+
+		resources, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if !decodable(ctx, pageDict) {
+			logInfoOptimize.Printf("imageResolutions: skipping page %d: unsupported filter\n", i+1)
+			pw.exclude(resources)
+			continue
+		}
+
+		buf, err := content.PageContent(ctx, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		err = pw.walk(buf, resources, identity)
+		if err != nil {
+			logInfoOptimize.Printf("imageResolutions: skipping page %d: %v\n", i+1, err)
+			pw.exclude(resources)
+		}
+	}
+
+	for objNr := range pw.unseen {
+		if _, found := pw.dpi[objNr]; found {
+			logInfoOptimize.Printf("imageResolutions: not downsampling obj#%d: unknown placement\n", objNr)
+			delete(pw.dpi, objNr)
+		}
+	}
+
+	return pw.dpi, nil
+}
+
+// imageData holds the unpacked samples of an image, one byte per sample.
+type imageData struct {
+	w, h    int
+	n       int // colour components per pixel
+	bpc     int
+	samples []byte
+}
+
+// colorComponents returns the number of colour components of an image
+// and whether its samples are indices into a colour table.
+func colorComponents(ctx *types.PDFContext, sd *types.PDFStreamDict) (n int, indexed bool, err error) {
+
+	if im := sd.BooleanEntry("ImageMask"); im != nil && *im {
+		return 1, false, nil
+	}
+
+	cs, err := ctx.Dereference(sd.Dict["ColorSpace"])
+	if err != nil {
+		return
+	}
+
+	switch cs := cs.(type) {
+
+	case types.PDFName:
+		switch cs {
+		case "DeviceGray":
+			return 1, false, nil
+		case "DeviceRGB":
+			return 3, false, nil
+		case "DeviceCMYK":
+			return 4, false, nil
+		}
+
+	case types.PDFArray:
+		if len(cs) == 0 {
+			break
+		}
+
+		name, _ := cs[0].(types.PDFName)
+
+		switch name {
+
+		case "CalGray", "Separation":
+			return 1, false, nil
+
+		case "CalRGB", "Lab":
+			return 3, false, nil
+
+		case "Indexed":
+			return 1, true, nil
+
+		case "ICCBased":
+			if len(cs) < 2 {
+				break
+			}
+			iccProfile, err := ctx.DereferenceStreamDict(cs[1])
+			if err != nil || iccProfile == nil {
+				return 0, false, errUnsupportedImage
+			}
+			if n := iccProfile.IntEntry("N"); n != nil {
+				return *n, false, nil
+			}
+
+		case "DeviceN":
+			if len(cs) < 2 {
+				break
+			}
+			names, err := ctx.DereferenceArray(cs[1])
+			if err != nil || names == nil {
+				return 0, false, errUnsupportedImage
+			}
+			return len(*names), false, nil
+		}
+	}
+
+	return 0, false, errUnsupportedImage
+}
+
+func decodeJPEG(sd *types.PDFStreamDict, n int) (*imageData, error) {
+
+	img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+	if err != nil {
+		return nil, errUnsupportedImage
+	}
+
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+
+	var samples []byte
+
+	switch img := img.(type) {
+
+	case *image.Gray:
+		if n != 1 {
+			return nil, errUnsupportedImage
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			samples = append(samples, img.Pix[i:i+w]...)
+		}
+
+	case *image.YCbCr:
+		if n != 3 {
+			return nil, errUnsupportedImage
+		}
+		samples = make([]byte, 0, w*h*3)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := img.YCbCrAt(x, y)
+				rr, g, b := color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+				samples = append(samples, rr, g, b)
+			}
+		}
+
+	default:
+		// CMYK JPEGs are left alone.
+		return nil, errUnsupportedImage
+	}
+
+	return &imageData{w: w, h: h, n: n, bpc: 8, samples: samples}, nil
+}
+
+// unpack expands the packed sample data of an image into one byte per sample.
+func unpack(buf []byte, w, h, n, bpc int) ([]byte, error) {
+
+	rowSize := (w*n*bpc + 7) / 8
+
+	if len(buf) < rowSize*h {
+		return nil, errUnsupportedImage
+	}
+
+	if bpc == 8 {
+		return buf[:rowSize*h], nil
+	}
+
+	samples := make([]byte, 0, w*h*n)
+	mask := byte(1<<uint(bpc) - 1)
+
+	for y := 0; y < h; y++ {
+		row := buf[y*rowSize : (y+1)*rowSize]
+		for i := 0; i < w*n; i++ {
+			bit := i * bpc
+			shift := uint(8 - bpc - bit%8)
+			samples = append(samples, row[bit/8]>>shift&mask)
+		}
+	}
+
+	return samples, nil
+}
+
+// pack reverses unpack.
+func (img *imageData) pack() []byte {
+
+	if img.bpc == 8 {
+		return img.samples
+	}
+
+	rowSize := (img.w*img.n*img.bpc + 7) / 8
+	buf := make([]byte, rowSize*img.h)
+
+	for y := 0; y < img.h; y++ {
+		row := buf[y*rowSize : (y+1)*rowSize]
+		for i, s := range img.samples[y*img.w*img.n : (y+1)*img.w*img.n] {
+			bit := i * img.bpc
+			row[bit/8] |= s << uint(8-img.bpc-bit%8)
+		}
+	}
+
+	return buf
+}
+
+// decodeImage returns the samples of an image and whether it is JPEG encoded.
+func decodeImage(ctx *types.PDFContext, sd *types.PDFStreamDict) (img *imageData, isJPEG bool, err error) {
+
+	n, _, err := colorComponents(ctx, sd)
+	if err != nil {
+		return
+	}
+
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		return nil, false, errUnsupportedImage
+	}
+
+	if sd.HasSoleFilterNamed("DCTDecode") {
+		img, err = decodeJPEG(sd, n)
+		if err != nil {
+			return
+		}
+		if img.w != *w || img.h != *h {
+			return nil, false, errUnsupportedImage
+		}
+		return img, true, nil
+	}
+
+	bpc := 1
+	if i := sd.IntEntry("BitsPerComponent"); i != nil {
+		bpc = *i
+	}
+
+	switch bpc {
+	case 1, 2, 4, 8:
+	default:
+		return nil, false, errUnsupportedImage
+	}
+
+	err = filter.DecodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		return nil, false, errUnsupportedImage
+	}
+	if err != nil {
+		return
+	}
+
+	samples, err := unpack(sd.Content, *w, *h, n, bpc)
+	if err != nil {
+		return
+	}
+
+	return &imageData{w: *w, h: *h, n: n, bpc: bpc, samples: samples}, false, nil
+}
+
+// resample returns a copy of img scaled to w x h.
+// Each sample is the average of the area it covers or, if nearest is set, the sample closest to its center.
+func (img *imageData) resample(w, h int, nearest bool) *imageData {
+
+	n := img.n
+	samples := make([]byte, 0, w*h*n)
+	sums := make([]int, n)
+
+	for y := 0; y < h; y++ {
+
+		y0, y1 := y*img.h/h, (y+1)*img.h/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < w; x++ {
+
+			x0, x1 := x*img.w/w, (x+1)*img.w/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			if nearest {
+				i := (((2*y+1)*img.h/(2*h))*img.w + (2*x+1)*img.w/(2*w)) * n
+				samples = append(samples, img.samples[i:i+n]...)
+				continue
+			}
+
+			for c := range sums {
+				sums[c] = 0
+			}
+
+			for yy := y0; yy < y1; yy++ {
+				i := (yy*img.w + x0) * n
+				for xx := x0; xx < x1; xx++ {
+					for c := 0; c < n; c++ {
+						sums[c] += int(img.samples[i+c])
+					}
+					i += n
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			for _, sum := range sums {
+				samples = append(samples, byte((sum+count/2)/count))
+			}
+		}
+	}
+
+	return &imageData{w: w, h: h, n: n, bpc: img.bpc, samples: samples}
+}
+
+func (img *imageData) encodeJPEG(quality int) ([]byte, error) {
+
+	r := image.Rect(0, 0, img.w, img.h)
+
+	var m image.Image
+
+	switch img.n {
+
+	case 1:
+		m = &image.Gray{Pix: img.samples, Stride: img.w, Rect: r}
+
+	case 3:
+		rgba := image.NewRGBA(r)
+		for i, j := 0, 0; i < len(img.samples); i, j = i+3, j+4 {
+			copy(rgba.Pix[j:j+3], img.samples[i:i+3])
+			rgba.Pix[j+3] = 0xFF
+		}
+		m = rgba
+
+	default:
+		return nil, errUnsupportedImage
+	}
+
+	var b bytes.Buffer
+
+	err := jpeg.Encode(&b, m, &jpeg.Options{Quality: quality})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// downsampler resamples images along with their masks and writes them back to the xRefTable.
+type downsampler struct {
+	ctx     *types.PDFContext
+	quality int
+	done    types.IntSet
+}
+
+// encodeImage returns the encoded image data along with filter and decode parms.
+// A quality > 0 yields a JPEG, otherwise Flate is used along with a PNG predictor.
+func encodeImage(img *imageData, quality int) (raw []byte, f types.PDFFilter, err error) {
+
+	if quality > 0 {
+		raw, err = img.encodeJPEG(quality)
+		return raw, types.PDFFilter{Name: "DCTDecode", DecodeParms: nil}, err
+	}
+
+	decodeParms := types.NewPDFDict()
+	decodeParms.Insert("Predictor", types.PDFInteger(15))
+	decodeParms.Insert("Colors", types.PDFInteger(img.n))
+	decodeParms.Insert("BitsPerComponent", types.PDFInteger(img.bpc))
+	decodeParms.Insert("Columns", types.PDFInteger(img.w))
+
+	f = types.PDFFilter{Name: "FlateDecode", DecodeParms: &decodeParms}
+
+	fi, err := filter.NewFilter(f.Name, f.DecodeParms, nil)
+	if err != nil {
+		return
+	}
+
+	b, err := fi.Encode(bytes.NewReader(img.pack()))
+	if err != nil {
+		return
+	}
+
+	return b.Bytes(), f, nil
+}
+
+// updateImage replaces the image data of an image stream dict.
+func updateImage(sd *types.PDFStreamDict, img *imageData, raw []byte, f types.PDFFilter) {
+
+	sd.Update("Width", types.PDFInteger(img.w))
+	sd.Update("Height", types.PDFInteger(img.h))
+
+	if im := sd.BooleanEntry("ImageMask"); im == nil || !*im {
+		sd.Update("BitsPerComponent", types.PDFInteger(img.bpc))
+	}
+
+	sd.Update("Filter", types.PDFName(f.Name))
+	sd.Delete("DecodeParms")
+	if f.DecodeParms != nil {
+		sd.Insert("DecodeParms", *f.DecodeParms)
+	}
+
+	sd.FilterPipeline = []types.PDFFilter{f}
+	sd.Raw = raw
+	sd.Content = nil
+
+	streamLength := int64(len(raw))
+	sd.StreamLength = &streamLength
+	sd.StreamLengthObjNr = nil
+	sd.Update("Length", types.PDFInteger(streamLength))
+}
+
+// resampleImage resamples the image with given object number to the size returned by scale.
+// It returns the original size of the image and false if the image has been left alone.
+// An image other than a mask is only replaced if this saves space.
+func (ds *downsampler) resampleImage(objNr int, scale func(w, h int) (int, int), mask bool) (w, h int, ok bool, err error) {
+
+	if ds.done[objNr] {
+		return
+	}
+	ds.done[objNr] = true
+
+	entry, found := ds.ctx.FindTableEntryLight(objNr)
+	if !found {
+		return
+	}
+
+	sd, isStreamDict := entry.Object.(types.PDFStreamDict)
+	if !isStreamDict {
+		return
+	}
+
+	img, isJPEG, err := decodeImage(ds.ctx, &sd)
+	if err == errUnsupportedImage {
+		logInfoOptimize.Printf("resampleImage: obj#%d: skipping unsupported image\n", objNr)
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	w2, h2 := scale(img.w, img.h)
+	if w2 >= img.w && h2 >= img.h {
+		return
+	}
+
+	_, indexed, _ := colorComponents(ds.ctx, &sd)
+
+	// Color key masking relies on exact sample values.
+	_, colorKey := sd.Dict["Mask"].(types.PDFArray)
+
+	quality := 0
+	if !mask && !indexed && !colorKey && img.bpc == 8 && (img.n == 1 || img.n == 3) {
+		if ds.quality > 0 {
+			quality = ds.quality
+		} else if isJPEG {
+			quality = defaultJPEGQuality
+		}
+	}
+
+	img2 := img.resample(w2, h2, indexed || colorKey)
+
+	raw, f, err := encodeImage(img2, quality)
+	if err != nil {
+		return
+	}
+
+	// Account for changes in decode parms when comparing sizes.
+	size, sizeOrig := len(raw), len(sd.Raw)
+	if f.DecodeParms != nil {
+		size += len(f.DecodeParms.PDFString())
+	}
+	if d := sd.PDFDictEntry("DecodeParms"); d != nil {
+		sizeOrig += len(d.PDFString())
+	}
+
+	// Masks follow their image in order to stay aligned.
+	if !mask && size >= sizeOrig {
+		logInfoOptimize.Printf("resampleImage: obj#%d: keeping original image\n", objNr)
+		return
+	}
+
+	logInfoOptimize.Printf("resampleImage: obj#%d: %dx%d -> %dx%d\n", objNr, img.w, img.h, w2, h2)
+
+	updateImage(&sd, img2, raw, f)
+
+	entry.Object = sd
+
+	return img.w, img.h, true, nil
+}
+
+func scaled(i int, f float64) int {
+
+	s := int(math.Round(float64(i) * f))
+	if s < 1 {
+		s = 1
+	}
+
+	return s
+}
+
+// downsampleImage resamples an image by factor f along with its soft mask or stencil mask.
+// Masks having the same size as the image keep matching its new size.
+func (ds *downsampler) downsampleImage(objNr int, f float64) (err error) {
+
+	scale := func(w, h int) (int, int) {
+		return scaled(w, f), scaled(h, f)
+	}
+
+	w, h, ok, err := ds.resampleImage(objNr, scale, false)
+	if err != nil || !ok {
+		return
+	}
+
+	entry, _ := ds.ctx.FindTableEntryLight(objNr)
+	sd := entry.Object.(types.PDFStreamDict)
+	w2, h2 := scale(w, h)
+
+	for _, key := range []string{"SMask", "Mask"} {
+
+		indRef, isIndRef := sd.Dict[key].(types.PDFIndirectRef)
+		if !isIndRef {
+			continue
+		}
+
+		_, _, _, err = ds.resampleImage(indRef.ObjectNumber.Value(), func(mw, mh int) (int, int) {
+			if mw == w && mh == h {
+				return w2, h2
+			}
+			return scale(mw, mh)
+		}, true)
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// downsampleImages resamples images whose effective resolution on the page exceeds the configured image resolution.
+func downsampleImages(ctx *types.PDFContext) (err error) {
+
+	logInfoOptimize.Println("downsampleImages begin")
+
+	dpi, err := imageResolutions(ctx)
+	if err != nil {
+		return
+	}
+
+	var objNrs []int
+	for objNr := range dpi {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	ds := &downsampler{ctx: ctx, quality: ctx.ImageQuality, done: types.IntSet{}}
+
+	for _, objNr := range objNrs {
+
+		if dpi[objNr] <= float64(ctx.ImageDPI) {
+			continue
+		}
+
+		err = ds.downsampleImage(objNr, float64(ctx.ImageDPI)/dpi[objNr])
+		if err != nil {
+			return
+		}
+	}
+
+	logInfoOptimize.Println("downsampleImages end")
+
+	return
+}
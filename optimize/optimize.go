@@ -1,6 +1,10 @@
 // Package optimize contains code for optimizing the resources of a PDF file.
 //
 // Subject of optimization are embedded font files and images.
+// Different subsets of the same font, as found in merged documents, are combined into one.
+// Images may also be downsampled and recompressed and fonts reduced to the glyphs used.
+// Pruning removes resources not used by any content stream as well as unreachable objects.
+// Streams may be recompressed at best Flate compression and content streams minified.
 package optimize
 
 import (
@@ -1030,11 +1034,26 @@ func calcBinarySizes(ctx *types.PDFContext) (err error) {
 	return
 }
 
+// FontsAndImages records the fonts and images used by all pages in the optimization context.
+// Unlike XRefTable it leaves fonts and images as they are.
+func FontsAndImages(ctx *types.PDFContext) error {
+	return optimizeFontAndImages(ctx)
+}
+
 // XRefTable optimizes an xRefTable by locating and getting rid of redundant embedded fonts and images.
+// If configured images get downsampled to a target resolution.
 func XRefTable(ctx *types.PDFContext) (err error) {
 
 	logInfoOptimize.Println("XRefTable begin")
 
+	// Combine different subsets of the same font.
+	if ctx.MergeFontSubsets {
+		err = mergeFontSubsets(ctx)
+		if err != nil {
+			return
+		}
+	}
+
 	// Get rid of duplicate embedded fonts and images.
 	err = optimizeFontAndImages(ctx)
 	if err != nil {
@@ -1047,6 +1066,42 @@ func XRefTable(ctx *types.PDFContext) (err error) {
 		return
 	}
 
+	ctx.Optimize.Report = newReport(ctx)
+
+	// Downsample images exceeding the target resolution.
+	if ctx.ImageDPI > 0 {
+		err = downsampleImages(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	// Reduce embedded fonts to the glyphs used.
+	if ctx.SubsetFonts {
+		err = subsetFonts(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	// Remove unused resources and objects.
+	if ctx.Prune {
+		err = prune(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	// Squeeze the remaining streams.
+	if ctx.Recompress {
+		err = recompressStreams(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	updateReport(ctx)
+
 	ctx.Optimized = true
 
 	logInfoOptimize.Println("XRefTable end")
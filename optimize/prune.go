@@ -0,0 +1,713 @@
+package optimize
+
+import (
+	"io"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// resourceTypes lists the entries of a resource dict holding resources referred to by name.
+var resourceTypes = []string{"ColorSpace", "ExtGState", "Font", "Pattern", "Properties", "Shading", "XObject"}
+
+// categoryUsage represents a resource category dict like Font or XObject along with the names used.
+type categoryUsage struct {
+	dict  *types.PDFDict
+	objNr int // 0 for a direct dict
+	names types.StringSet
+	users []*resourceUsage // resource dicts referring to this dict
+}
+
+// resourceUsage represents the resource dict of a page, form, pattern or Type3 font.
+type resourceUsage struct {
+	dict       *types.PDFDict
+	objNr      int          // 0 for a direct dict
+	owners     types.IntSet // objects referring to an indirect resource dict
+	categories map[string]*categoryUsage
+	unsafe     bool // true if some content using this dict could not be processed
+}
+
+// visit identifies content processed using the resources of a parent.
+type visit struct {
+	objNr     int
+	resources *resourceUsage
+}
+
+// resourcePruner interprets content streams and records the resources used by name.
+type resourcePruner struct {
+	ctx        *types.PDFContext
+	refs       map[int]int            // reference counts of reachable objects
+	indirect   map[int]*resourceUsage // indirect resource dicts by object number
+	direct     map[int]*resourceUsage // direct resource dicts by object number of the owner
+	categories map[int]*categoryUsage // indirect category dicts by object number
+	visited    map[visit]bool         // content already processed
+	depth      int
+}
+
+func newResourcePruner(ctx *types.PDFContext, refs map[int]int) *resourcePruner {
+	return &resourcePruner{
+		ctx:        ctx,
+		refs:       refs,
+		indirect:   map[int]*resourceUsage{},
+		direct:     map[int]*resourceUsage{},
+		categories: map[int]*categoryUsage{},
+		visited:    map[visit]bool{},
+	}
+}
+
+// category registers a resource category dict.
+func (rp *resourcePruner) category(o interface{}) *categoryUsage {
+
+	indRef, indirect := o.(types.PDFIndirectRef)
+	if indirect {
+		if cu, found := rp.categories[indRef.ObjectNumber.Value()]; found {
+			return cu
+		}
+	}
+
+	d, err := rp.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil
+	}
+
+	cu := &categoryUsage{dict: d, names: types.StringSet{}}
+
+	if indirect {
+		cu.objNr = indRef.ObjectNumber.Value()
+		rp.categories[cu.objNr] = cu
+	}
+
+	return cu
+}
+
+// resources registers the resource dict o referred to by object owner.
+func (rp *resourcePruner) resources(owner int, o interface{}) *resourceUsage {
+
+	indRef, indirect := o.(types.PDFIndirectRef)
+	if indirect {
+		if ru, found := rp.indirect[indRef.ObjectNumber.Value()]; found {
+			ru.owners[owner] = true
+			return ru
+		}
+	} else if ru, found := rp.direct[owner]; found {
+		return ru
+	}
+
+	d, err := rp.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil
+	}
+
+	ru := &resourceUsage{dict: d, owners: types.IntSet{owner: true}, categories: map[string]*categoryUsage{}}
+
+	if indirect {
+		ru.objNr = indRef.ObjectNumber.Value()
+		rp.indirect[ru.objNr] = ru
+	} else {
+		rp.direct[owner] = ru
+	}
+
+	for _, key := range resourceTypes {
+		o, found := d.Find(key)
+		if !found {
+			continue
+		}
+		if cu := rp.category(o); cu != nil {
+			cu.users = append(cu.users, ru)
+			ru.categories[key] = cu
+		}
+	}
+
+	return ru
+}
+
+// ownResources returns the resources of a dict or the resources of its parent if there are none.
+func (rp *resourcePruner) ownResources(d types.PDFDict, objNr int, parent *resourceUsage) *resourceUsage {
+
+	if o, found := d.Find("Resources"); found {
+		return rp.resources(objNr, o)
+	}
+
+	return parent
+}
+
+func markUnsafe(ru *resourceUsage) {
+	if ru != nil {
+		ru.unsafe = true
+	}
+}
+
+// use records the use of a named resource and returns the resource.
+func (rp *resourcePruner) use(ru *resourceUsage, key, name string) interface{} {
+
+	if ru == nil {
+		return nil
+	}
+
+	cu := ru.categories[key]
+	if cu == nil {
+		return nil
+	}
+
+	cu.names[name] = true
+
+	return cu.dict.Dict[name]
+}
+
+// content processes a form, tiling pattern, glyph description or appearance stream.
+func (rp *resourcePruner) content(sd *types.PDFStreamDict, objNr int, ru *resourceUsage) {
+
+	v := visit{objNr, ru}
+	if rp.visited[v] {
+		return
+	}
+	rp.visited[v] = true
+
+	if rp.depth > 8 {
+		markUnsafe(ru)
+		return
+	}
+
+	err := filter.DecodeStream(sd)
+	if err == nil {
+		rp.depth++
+		err = rp.walk(sd.Content, ru)
+		rp.depth--
+	}
+
+	if err != nil {
+		logInfoOptimize.Printf("pruneResources: obj#%d: %v\n", objNr, err)
+		markUnsafe(ru)
+	}
+}
+
+// stream processes the content stream o using the resources of its own or ru.
+func (rp *resourcePruner) stream(o interface{}, ru *resourceUsage) {
+
+	indRef, ok := o.(types.PDFIndirectRef)
+	if !ok {
+		return
+	}
+
+	sd, err := rp.ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+
+	rp.content(sd, objNr, rp.ownResources(sd.PDFDict, objNr, ru))
+}
+
+// font processes the glyph descriptions of a Type3 font.
+func (rp *resourcePruner) font(name string, ru *resourceUsage) {
+
+	o := rp.use(ru, "Font", name)
+
+	fontDict, err := rp.ctx.DereferenceDict(o)
+	if err != nil || fontDict == nil {
+		return
+	}
+
+	if s := fontDict.Subtype(); s == nil || *s != "Type3" {
+		return
+	}
+
+	if _, found := fontDict.Find("Resources"); found {
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok {
+			// The resources of a direct font dict cannot be told apart.
+			return
+		}
+		ru = rp.resources(indRef.ObjectNumber.Value(), fontDict.Dict["Resources"])
+	}
+
+	charProcs, err := rp.ctx.DereferenceDict(fontDict.Dict["CharProcs"])
+	if err != nil || charProcs == nil {
+		return
+	}
+
+	for _, o := range charProcs.Dict {
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok {
+			continue
+		}
+		if sd, err := rp.ctx.DereferenceStreamDict(indRef); err == nil && sd != nil {
+			rp.content(sd, indRef.ObjectNumber.Value(), ru)
+		}
+	}
+}
+
+// xObject processes a form XObject, images are left alone.
+func (rp *resourcePruner) xObject(name string, ru *resourceUsage) {
+
+	o := rp.use(ru, "XObject", name)
+
+	sd, err := rp.ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return
+	}
+
+	if s := sd.Subtype(); s != nil && *s == "Form" {
+		rp.stream(o, ru)
+	}
+}
+
+// extGState processes the transparency group of a soft mask.
+func (rp *resourcePruner) extGState(name string, ru *resourceUsage) {
+
+	d, err := rp.ctx.DereferenceDict(rp.use(ru, "ExtGState", name))
+	if err != nil || d == nil {
+		return
+	}
+
+	// SMask may also be the name None.
+	sm, err := rp.ctx.DereferenceDict(d.Dict["SMask"])
+	if err != nil || sm == nil {
+		return
+	}
+
+	rp.stream(sm.Dict["G"], ru)
+}
+
+// pattern processes a tiling pattern.
+func (rp *resourcePruner) pattern(name string, ru *resourceUsage) {
+
+	o := rp.use(ru, "Pattern", name)
+
+	sd, err := rp.ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return
+	}
+
+	if pt := sd.IntEntry("PatternType"); pt != nil && *pt == 1 {
+		rp.stream(o, nil)
+	}
+}
+
+// deviceColorSpace returns true for color space names that are not resources.
+func deviceColorSpace(name string) bool {
+
+	switch name {
+	case "DeviceGray", "DeviceRGB", "DeviceCMYK", "Pattern",
+		"G", "RGB", "CMYK", "I", "Indexed":
+		return true
+	}
+
+	return false
+}
+
+// inlineImage records the color space resource of an inline image.
+func (rp *resourcePruner) inlineImage(d types.PDFDict, ru *resourceUsage) {
+
+	o, found := d.Find("CS")
+	if !found {
+		o, found = d.Find("ColorSpace")
+	}
+	if !found {
+		return
+	}
+
+	// An indexed color space may be based on a color space resource.
+	if a, ok := o.(types.PDFArray); ok && len(a) > 1 {
+		o = a[1]
+	}
+
+	if name, ok := o.(types.PDFName); ok && !deviceColorSpace(string(name)) {
+		rp.use(ru, "ColorSpace", string(name))
+	}
+}
+
+// walk records the named resources used by a content stream.
+func (rp *resourcePruner) walk(buf []byte, ru *resourceUsage) (err error) {
+
+	sc := content.NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		operands := op.Operands
+
+		var name string
+		if len(operands) > 0 {
+			if n, ok := operands[len(operands)-1].(types.PDFName); ok {
+				name = string(n)
+			}
+		}
+
+		switch op.Operator {
+
+		case "Tf":
+			if len(operands) == 2 {
+				if n, ok := operands[0].(types.PDFName); ok {
+					rp.font(string(n), ru)
+				}
+			}
+
+		case "Do":
+			if name != "" {
+				rp.xObject(name, ru)
+			}
+
+		case "gs":
+			if name != "" {
+				rp.extGState(name, ru)
+			}
+
+		case "cs", "CS":
+			if name != "" && !deviceColorSpace(name) {
+				rp.use(ru, "ColorSpace", name)
+			}
+
+		case "scn", "SCN":
+			if name != "" {
+				rp.pattern(name, ru)
+			}
+
+		case "sh":
+			if name != "" {
+				rp.use(ru, "Shading", name)
+			}
+
+		case "BDC", "DP":
+			if len(operands) == 2 && name != "" {
+				rp.use(ru, "Properties", name)
+			}
+
+		case "BI":
+			if len(operands) == 1 {
+				if d, ok := operands[0].(types.PDFDict); ok {
+					rp.inlineImage(d, ru)
+				}
+			}
+		}
+	}
+}
+
+// pageResources returns the resources of a page and the object number of the page tree node holding them.
+func pageResources(ctx *types.PDFContext, indRef types.PDFIndirectRef) (o interface{}, objNr int, err error) {
+
+	for {
+
+		objNr = indRef.ObjectNumber.Value()
+
+		d, err := ctx.DereferenceDict(indRef)
+		if err != nil || d == nil {
+			return nil, 0, err
+		}
+
+		if o, found := d.Find("Resources"); found {
+			return o, objNr, nil
+		}
+
+		parent, ok := d.Dict["Parent"].(types.PDFIndirectRef)
+		if !ok {
+			return nil, 0, nil
+		}
+
+		indRef = parent
+	}
+}
+
+// collect processes the content of all pages including the appearance streams of annotations.
+func (rp *resourcePruner) collect() (err error) {
+
+	pages, err := rp.ctx.PageList()
+	if err != nil {
+		return
+	}
+
+	for i, indRef := range pages {
+
+		pageDict, err := rp.ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+
+		o, owner, err := pageResources(rp.ctx, indRef)
+		if err != nil {
+			return err
+		}
+
+		var ru *resourceUsage
+		if o != nil {
+			ru = rp.resources(owner, o)
+		}
+
+		if !decodable(rp.ctx, pageDict) {
+			logInfoOptimize.Printf("pruneResources: page %d: unsupported filter\n", i+1)
+			markUnsafe(ru)
+		} else {
+			buf, err := content.PageContent(rp.ctx, pageDict)
+			if err == nil {
+				err = rp.walk(buf, ru)
+			}
+			if err != nil {
+				logInfoOptimize.Printf("pruneResources: page %d: %v\n", i+1, err)
+				markUnsafe(ru)
+			}
+		}
+
+		for _, indRef := range appearanceStreams(rp.ctx, pageDict) {
+			rp.stream(indRef, nil)
+		}
+	}
+
+	return
+}
+
+// safeResources returns true if all content using a resource dict has been processed.
+func (rp *resourcePruner) safeResources(ru *resourceUsage) bool {
+	return !ru.unsafe && (ru.objNr == 0 || rp.refs[ru.objNr] == len(ru.owners))
+}
+
+// safeCategory returns true if all content using a resource category dict has been processed.
+func (rp *resourcePruner) safeCategory(cu *categoryUsage) bool {
+
+	if cu.objNr > 0 && rp.refs[cu.objNr] != len(cu.users) {
+		return false
+	}
+
+	for _, ru := range cu.users {
+		if !rp.safeResources(ru) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// defaultColorSpace returns true for color space resources used implicitly in place of device color spaces.
+func defaultColorSpace(name string) bool {
+	return name == "DefaultGray" || name == "DefaultRGB" || name == "DefaultCMYK"
+}
+
+// prune deletes unused resources and returns the number of resources deleted.
+func (rp *resourcePruner) prune() (n int) {
+
+	var all []*resourceUsage
+	for _, ru := range rp.indirect {
+		all = append(all, ru)
+	}
+	for _, ru := range rp.direct {
+		all = append(all, ru)
+	}
+
+	done := map[*categoryUsage]bool{}
+
+	for _, ru := range all {
+
+		for key, cu := range ru.categories {
+
+			if done[cu] {
+				continue
+			}
+			done[cu] = true
+
+			if !rp.safeCategory(cu) {
+				continue
+			}
+
+			for name := range cu.dict.Dict {
+				if cu.names[name] || key == "ColorSpace" && defaultColorSpace(name) {
+					continue
+				}
+				logInfoOptimize.Printf("pruneResources: removing %s resource %s\n", key, name)
+				delete(cu.dict.Dict, name)
+				n++
+			}
+
+			// Drop direct category dicts left empty.
+			if cu.objNr == 0 && len(cu.dict.Dict) == 0 {
+				for _, ru := range cu.users {
+					ru.dict.Delete(key)
+				}
+			}
+		}
+	}
+
+	return n
+}
+
+// PruneResources removes the resources not used by any content stream.
+func PruneResources(ctx *types.PDFContext) (err error) {
+
+	// Resources of forms removed may have been shared.
+	for {
+
+		rp := newResourcePruner(ctx, references(ctx))
+
+		err = rp.collect()
+		if err != nil {
+			return
+		}
+
+		if rp.prune() == 0 {
+			return nil
+		}
+	}
+}
+
+// collectReferences counts the indirect references of an object and queues objects seen for the first time.
+func collectReferences(ctx *types.PDFContext, o interface{}, refs map[int]int, queue []int) []int {
+
+	switch o := o.(type) {
+
+	case types.PDFIndirectRef:
+		if !ctx.Resolvable(o) {
+			break
+		}
+		objNr := o.ObjectNumber.Value()
+		refs[objNr]++
+		if refs[objNr] == 1 {
+			queue = append(queue, objNr)
+		}
+
+	case types.PDFDict:
+		for _, v := range o.Dict {
+			queue = collectReferences(ctx, v, refs, queue)
+		}
+
+	case types.PDFStreamDict:
+		queue = collectReferences(ctx, o.PDFDict, refs, queue)
+
+	case types.PDFArray:
+		for _, v := range o {
+			queue = collectReferences(ctx, v, refs, queue)
+		}
+	}
+
+	return queue
+}
+
+// references returns the reference counts of all objects reachable from the trailer.
+func references(ctx *types.PDFContext) map[int]int {
+
+	refs := map[int]int{}
+
+	var queue []int
+	for _, indRef := range ctx.TrailerRefs() {
+		queue = collectReferences(ctx, indRef, refs, queue)
+	}
+
+	for len(queue) > 0 {
+		objNr := queue[0]
+		queue = queue[1:]
+		queue = collectReferences(ctx, ctx.Table[objNr].Object, refs, queue)
+	}
+
+	return refs
+}
+
+func renumberedSet(s types.IntSet, newNr map[int]int) types.IntSet {
+
+	s1 := types.IntSet{}
+
+	for objNr, v := range s {
+		if i, found := newNr[objNr]; found {
+			s1[i] = v
+		}
+	}
+
+	return s1
+}
+
+// renumberOptimizationContext translates the object numbers recorded for stats.
+func renumberOptimizationContext(oc *types.OptimizationContext, newNr map[int]int) {
+
+	for i, s := range oc.PageFonts {
+		oc.PageFonts[i] = renumberedSet(s, newNr)
+	}
+
+	for i, s := range oc.PageImages {
+		oc.PageImages[i] = renumberedSet(s, newNr)
+	}
+
+	fontObjs := map[int]*types.FontObject{}
+	for objNr, fo := range oc.FontObjects {
+		if i, found := newNr[objNr]; found {
+			fontObjs[i] = fo
+		}
+	}
+	oc.FontObjects = fontObjs
+
+	for fontName, objNrs := range oc.Fonts {
+		var a []int
+		for _, objNr := range objNrs {
+			if i, found := newNr[objNr]; found {
+				a = append(a, i)
+			}
+		}
+		oc.Fonts[fontName] = a
+	}
+
+	imageObjs := map[int]*types.ImageObject{}
+	for objNr, io := range oc.ImageObjects {
+		if i, found := newNr[objNr]; found {
+			imageObjs[i] = io
+		}
+	}
+	oc.ImageObjects = imageObjs
+
+	// Duplicates are not referenced anymore and therefore gone.
+	oc.DuplicateFontObjs = types.IntSet{}
+	oc.DuplicateFonts = map[int]*types.PDFDict{}
+	oc.DuplicateImageObjs = types.IntSet{}
+	oc.DuplicateImages = map[int]*types.PDFStreamDict{}
+	oc.DuplicateInfoObjects = types.IntSet{}
+
+	if oc.Report != nil {
+		oc.Report.Renumber(newNr)
+	}
+}
+
+// renumberObjects removes all objects not reachable from the trailer
+// and numbers the remaining objects consecutively keeping their order.
+func renumberObjects(ctx *types.PDFContext) {
+
+	refs := references(ctx)
+
+	var objNrs []int
+	for objNr := range refs {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	newNr := map[int]int{}
+	for i, objNr := range objNrs {
+		newNr[objNr] = i + 1
+	}
+
+	logInfoOptimize.Printf("renumberObjects: %d objects removed, %d objects left\n", len(ctx.Table)-len(objNrs)-1, len(objNrs))
+
+	ctx.Renumber(newNr)
+
+	// Object and xref streams of the original file are gone.
+	ctx.Read.ObjectStreams = types.IntSet{}
+	ctx.Read.XRefStreams = types.IntSet{}
+	ctx.LinearizationObjs = types.IntSet{}
+
+	renumberOptimizationContext(ctx.Optimize, newNr)
+}
+
+// prune removes unused resources and objects and renumbers the remaining objects.
+func prune(ctx *types.PDFContext) (err error) {
+
+	err = PruneResources(ctx)
+	if err != nil {
+		return
+	}
+
+	renumberObjects(ctx)
+
+	return
+}
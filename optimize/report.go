@@ -0,0 +1,198 @@
+package optimize
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// streamSize returns the length of the stream data of a stream object.
+func streamSize(ctx *types.PDFContext, objNr int) int64 {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found {
+		return 0
+	}
+
+	sd, ok := entry.Object.(types.PDFStreamDict)
+	if !ok {
+		return 0
+	}
+
+	if sd.StreamLength != nil {
+		return *sd.StreamLength
+	}
+
+	return int64(len(sd.Raw))
+}
+
+// fontFileLength returns the object number and the length of the embedded font program of a font dict.
+func fontFileLength(ctx *types.PDFContext, fontDict *types.PDFDict) (int, int64) {
+
+	_, _, objNr, ok := fontProgram(ctx, fontDict)
+	if !ok {
+		return 0, 0
+	}
+
+	return objNr, streamSize(ctx, objNr)
+}
+
+// colorSpaceName returns the name of the color space family of an image.
+func colorSpaceName(ctx *types.PDFContext, sd *types.PDFStreamDict) string {
+
+	o, err := ctx.Dereference(sd.Dict["ColorSpace"])
+	if err != nil || o == nil {
+		return ""
+	}
+
+	if a, ok := o.(types.PDFArray); ok && len(a) > 0 {
+		o = a[0]
+	}
+
+	if n, ok := o.(types.PDFName); ok {
+		return n.Value()
+	}
+
+	return ""
+}
+
+// filterNames returns a comma separated list of the filters of a stream.
+func filterNames(sd *types.PDFStreamDict) string {
+
+	var ss []string
+	for _, f := range sd.FilterPipeline {
+		ss = append(ss, f.Name)
+	}
+
+	return strings.Join(ss, ",")
+}
+
+// lookupImageDict returns the image stream dict with given object number.
+func lookupImageDict(ctx *types.PDFContext, objNr int) *types.PDFStreamDict {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found {
+		return nil
+	}
+
+	sd, ok := entry.Object.(types.PDFStreamDict)
+	if !ok {
+		return nil
+	}
+
+	return &sd
+}
+
+// lookupFontDict returns the font dict with given object number.
+func lookupFontDict(ctx *types.PDFContext, objNr int) *types.PDFDict {
+
+	entry, found := ctx.FindTableEntryLight(objNr)
+	if !found {
+		return nil
+	}
+
+	d, ok := entry.Object.(types.PDFDict)
+	if !ok {
+		return nil
+	}
+
+	return &d
+}
+
+// intEntry returns the value of an integer entry or 0 if missing.
+func intEntry(d types.PDFDict, key string) int {
+
+	if i := d.IntEntry(key); i != nil {
+		return *i
+	}
+
+	return 0
+}
+
+// newReport records the source stats of all fonts and images before they get modified.
+func newReport(ctx *types.PDFContext) *types.OptimizeReport {
+
+	r := types.NewOptimizeReport(ctx)
+
+	var objNrs []int
+	for objNr := range ctx.Optimize.FontObjects {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		fo := ctx.Optimize.FontObjects[objNr]
+		f := &types.FontReport{ObjNr: objNr, Subtype: fo.SubType(), Embedded: fo.Embedded()}
+		if d := lookupFontDict(ctx, objNr); d != nil {
+			if baseFont := d.NameEntry("BaseFont"); baseFont != nil {
+				f.Name = *baseFont
+			}
+			_, f.SourceSize = fontFileLength(ctx, d)
+		}
+		r.Fonts = append(r.Fonts, f)
+	}
+
+	objNrs = nil
+	for objNr := range ctx.Optimize.ImageObjects {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		img := &types.ImageReport{ObjNr: objNr}
+		if sd := lookupImageDict(ctx, objNr); sd != nil {
+			img.SourceWidth = intEntry(sd.PDFDict, "Width")
+			img.SourceHeight = intEntry(sd.PDFDict, "Height")
+			img.SourceSize = streamSize(ctx, objNr)
+		}
+		r.Images = append(r.Images, img)
+	}
+
+	return r
+}
+
+// updateReport records the stats of all remaining fonts and images after optimization.
+func updateReport(ctx *types.PDFContext) {
+
+	r := ctx.Optimize.Report
+	d := &r.Destination
+
+	fontFiles := types.IntSet{}
+
+	for _, f := range r.Fonts {
+		if f.Removed {
+			continue
+		}
+		fd := lookupFontDict(ctx, f.ObjNr)
+		if fd == nil {
+			continue
+		}
+		if baseFont := fd.NameEntry("BaseFont"); baseFont != nil {
+			f.Name = *baseFont
+		}
+		var objNr int
+		objNr, f.Size = fontFileLength(ctx, fd)
+		if objNr > 0 && !fontFiles[objNr] {
+			fontFiles[objNr] = true
+			d.Fonts += f.Size
+		}
+	}
+
+	for _, img := range r.Images {
+		if img.Removed {
+			continue
+		}
+		sd := lookupImageDict(ctx, img.ObjNr)
+		if sd == nil {
+			continue
+		}
+		img.Width = intEntry(sd.PDFDict, "Width")
+		img.Height = intEntry(sd.PDFDict, "Height")
+		img.BitsPerComponent = intEntry(sd.PDFDict, "BitsPerComponent")
+		img.ColorSpace = colorSpaceName(ctx, sd)
+		img.Filter = filterNames(sd)
+		img.Size = streamSize(ctx, img.ObjNr)
+		d.Images += img.Size
+	}
+}
@@ -0,0 +1,1268 @@
+package optimize
+
+import (
+	"hash/fnv"
+	"io"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/content"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/font"
+	"github.com/hhrutter/pdfcpu/types"
+)
+
+// glyphCollector interprets content streams and records the character codes shown using each font.
+type glyphCollector struct {
+	ctx       *types.PDFContext
+	codes     map[int]types.IntSet // character codes by font dict object number
+	composite map[int]bool         // by font dict object number
+	unsafe    types.IntSet         // object numbers of font files that must be left alone
+	forms     map[int]types.IntSet // current fonts forms with own resources have been processed with
+	depth     int
+	failed    bool // true if some content could not be processed
+}
+
+func newGlyphCollector(ctx *types.PDFContext) *glyphCollector {
+	return &glyphCollector{
+		ctx:       ctx,
+		codes:     map[int]types.IntSet{},
+		composite: map[int]bool{},
+		unsafe:    types.IntSet{},
+		forms:     map[int]types.IntSet{},
+	}
+}
+
+// noFont is the current font of a content stream before any Tf.
+const noFont = -1
+
+// markUnsafe protects the font file of a font dict from being subset.
+func (gc *glyphCollector) markUnsafe(fontDict *types.PDFDict) {
+	if _, _, objNr, ok := fontProgram(gc.ctx, fontDict); ok {
+		gc.unsafe[objNr] = true
+	}
+}
+
+// markFontsUnsafe protects the font files of all fonts of a resource dict.
+func (gc *glyphCollector) markFontsUnsafe(resources *types.PDFDict) {
+
+	if resources == nil {
+		return
+	}
+
+	fonts, err := gc.ctx.DereferenceDict(resources.Dict["Font"])
+	if err != nil || fonts == nil {
+		return
+	}
+
+	for _, o := range fonts.Dict {
+		if fontDict, err := gc.ctx.DereferenceDict(o); err == nil && fontDict != nil {
+			gc.markUnsafe(fontDict)
+		}
+	}
+}
+
+// font returns the object number of the font dict for a font resource name or 0.
+func (gc *glyphCollector) font(name string, resources *types.PDFDict) int {
+
+	if resources == nil {
+		return 0
+	}
+
+	fonts, err := gc.ctx.DereferenceDict(resources.Dict["Font"])
+	if err != nil || fonts == nil {
+		return 0
+	}
+
+	indRef, ok := fonts.Dict[name].(types.PDFIndirectRef)
+	if !ok {
+		// Direct font dicts cannot be updated consistently.
+		if d, ok := fonts.Dict[name].(types.PDFDict); ok {
+			gc.markUnsafe(&d)
+		}
+		return 0
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+
+	if _, found := gc.codes[objNr]; !found {
+		fontDict, err := gc.ctx.DereferenceDict(indRef)
+		if err != nil || fontDict == nil {
+			return 0
+		}
+		gc.codes[objNr] = types.IntSet{}
+		s := fontDict.Subtype()
+		gc.composite[objNr] = s != nil && *s == "Type0"
+	}
+
+	return objNr
+}
+
+// fonts registers all fonts of a resource dict, even if not used for showing text.
+func (gc *glyphCollector) fonts(resources *types.PDFDict) {
+
+	if resources == nil {
+		return
+	}
+
+	fonts, err := gc.ctx.DereferenceDict(resources.Dict["Font"])
+	if err != nil || fonts == nil {
+		return
+	}
+
+	for name := range fonts.Dict {
+		gc.font(name, resources)
+	}
+}
+
+// show records the character codes of a string shown using font objNr.
+func (gc *glyphCollector) show(objNr int, o interface{}) {
+
+	b, ok := content.StringBytes(o)
+	if !ok || objNr == 0 {
+		return
+	}
+
+	// The glyphs shown are unknown.
+	if objNr == noFont {
+		gc.failed = true
+		return
+	}
+
+	n := 1
+	if gc.composite[objNr] {
+		n = 2
+	}
+
+	for i := 0; i+n <= len(b); i += n {
+		c := int(b[i])
+		if n == 2 {
+			c = c<<8 | int(b[i+1])
+		}
+		gc.codes[objNr][c] = true
+	}
+}
+
+// form processes a form XObject or tiling pattern starting with the current font of the caller.
+func (gc *glyphCollector) form(sd *types.PDFStreamDict, objNr int, resources *types.PDFDict, font int) (err error) {
+
+	// Text shown by deeper nested forms would be missing.
+	if gc.depth > 8 {
+		logInfoOptimize.Println("collectGlyphs: forms nested too deep")
+		gc.failed = true
+		return
+	}
+
+	// A form without resources inherits the resources of its parent.
+	if res, err := gc.ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && res != nil {
+		if gc.forms[objNr][font] {
+			return nil
+		}
+		if gc.forms[objNr] == nil {
+			gc.forms[objNr] = types.IntSet{}
+		}
+		gc.forms[objNr][font] = true
+		resources = res
+	}
+
+	err = filter.DecodeStream(sd)
+	if err == filter.ErrUnsupportedFilter {
+		// The fonts of this form must be left alone.
+		gc.markFontsUnsafe(resources)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	gc.depth++
+	err = gc.walk(sd.Content, resources, font)
+	gc.depth--
+
+	return
+}
+
+// xObject processes a form XObject, images are left alone.
+func (gc *glyphCollector) xObject(name string, resources *types.PDFDict, font int) (err error) {
+
+	if resources == nil {
+		return
+	}
+
+	xObjs, err := gc.ctx.DereferenceDict(resources.Dict["XObject"])
+	if err != nil || xObjs == nil {
+		return
+	}
+
+	indRef, ok := xObjs.Dict[name].(types.PDFIndirectRef)
+	if !ok {
+		return
+	}
+
+	sd, err := gc.ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return
+	}
+
+	if s := sd.Subtype(); s != nil && *s == "Form" {
+		err = gc.form(sd, indRef.ObjectNumber.Value(), resources, font)
+	}
+
+	return
+}
+
+// patterns processes the tiling patterns of a resource dict.
+func (gc *glyphCollector) patterns(resources *types.PDFDict) (err error) {
+
+	if resources == nil {
+		return
+	}
+
+	patterns, err := gc.ctx.DereferenceDict(resources.Dict["Pattern"])
+	if err != nil || patterns == nil {
+		return
+	}
+
+	for _, o := range patterns.Dict {
+
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok {
+			continue
+		}
+
+		sd, err := gc.ctx.DereferenceStreamDict(indRef)
+		if err != nil || sd == nil {
+			continue
+		}
+
+		if pt := sd.IntEntry("PatternType"); pt == nil || *pt != 1 {
+			continue
+		}
+
+		err = gc.form(sd, indRef.ObjectNumber.Value(), nil, noFont)
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// walk processes the text showing operators, forms and patterns of a content stream.
+// font is the current font at the start of the content stream.
+func (gc *glyphCollector) walk(buf []byte, resources *types.PDFDict, font int) (err error) {
+
+	gc.fonts(resources)
+
+	err = gc.patterns(resources)
+	if err != nil {
+		return
+	}
+
+	// The current font is part of the graphics state.
+	var stack []int
+
+	sc := content.NewScanner(buf)
+
+	for {
+
+		op, err := sc.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		operands := op.Operands
+
+		switch op.Operator {
+
+		case "q":
+			stack = append(stack, font)
+
+		case "Q":
+			if len(stack) > 0 {
+				font = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(types.PDFName); ok {
+					font = gc.font(string(name), resources)
+				}
+			}
+
+		case "Tj", "'", "\"":
+			if len(operands) > 0 {
+				gc.show(font, operands[len(operands)-1])
+			}
+
+		case "TJ":
+			if len(operands) == 1 {
+				if a, ok := operands[0].(types.PDFArray); ok {
+					for _, o := range a {
+						gc.show(font, o)
+					}
+				}
+			}
+
+		case "Do":
+			if len(operands) != 1 {
+				continue
+			}
+			if name, ok := operands[0].(types.PDFName); ok {
+				err = gc.xObject(string(name), resources, font)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// appearances processes the appearance streams of the annotations of a page.
+func (gc *glyphCollector) appearances(pageDict *types.PDFDict) (err error) {
+
+	for _, indRef := range appearanceStreams(gc.ctx, pageDict) {
+
+		sd, err := gc.ctx.DereferenceStreamDict(indRef)
+		if err != nil || sd == nil {
+			continue
+		}
+
+		err = gc.form(sd, indRef.ObjectNumber.Value(), nil, noFont)
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// appearanceStreams returns the appearance streams of the annotations of a page.
+func appearanceStreams(ctx *types.PDFContext, pageDict *types.PDFDict) (indRefs []types.PDFIndirectRef) {
+
+	annots, err := ctx.DereferenceArray(pageDict.Dict["Annots"])
+	if err != nil || annots == nil {
+		return
+	}
+
+	for _, o := range *annots {
+
+		annot, err := ctx.DereferenceDict(o)
+		if err != nil || annot == nil {
+			continue
+		}
+
+		ap, err := ctx.DereferenceDict(annot.Dict["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+
+		for _, key := range []string{"N", "R", "D"} {
+
+			switch o := ap.Dict[key].(type) {
+
+			case types.PDFIndirectRef:
+				if sd, err := ctx.DereferenceStreamDict(o); err == nil && sd != nil {
+					indRefs = append(indRefs, o)
+					break
+				}
+				if d, err := ctx.DereferenceDict(o); err == nil && d != nil {
+					indRefs = append(indRefs, appearanceStates(*d)...)
+				}
+
+			case types.PDFDict:
+				indRefs = append(indRefs, appearanceStates(o)...)
+			}
+		}
+	}
+
+	return
+}
+
+func appearanceStates(d types.PDFDict) (indRefs []types.PDFIndirectRef) {
+
+	for _, o := range d.Dict {
+		if indRef, ok := o.(types.PDFIndirectRef); ok {
+			indRefs = append(indRefs, indRef)
+		}
+	}
+
+	return
+}
+
+// decodable returns true if all content streams of a page use supported filters.
+func decodable(ctx *types.PDFContext, pageDict *types.PDFDict) bool {
+
+	obj, err := ctx.Dereference(pageDict.Dict["Contents"])
+	if err != nil {
+		return false
+	}
+
+	var streams []types.PDFStreamDict
+
+	switch obj := obj.(type) {
+
+	case types.PDFStreamDict:
+		streams = append(streams, obj)
+
+	case types.PDFArray:
+		for _, o := range obj {
+			sd, err := ctx.DereferenceStreamDict(o)
+			if err != nil {
+				return false
+			}
+			if sd != nil {
+				streams = append(streams, *sd)
+			}
+		}
+	}
+
+	for _, sd := range streams {
+		for _, f := range sd.FilterPipeline {
+			if _, err := filter.NewFilter(f.Name, nil, nil); err != nil {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// collectGlyphs returns the character codes shown using each font.
+func collectGlyphs(ctx *types.PDFContext) (*glyphCollector, error) {
+
+	gc := newGlyphCollector(ctx)
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, indRef := range pages {
+
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return nil, err
+		}
+
+		obj, err := ctx.InheritedPageAttr(pageDict, "Resources")
+		if err != nil {
+			return nil, err
+		}
+
+		resources, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		// Text shown by content skipped would be missing.
+		if !decodable(ctx, pageDict) {
+			logInfoOptimize.Printf("collectGlyphs: page %d: unsupported filter\n", i+1)
+			gc.failed = true
+			continue
+		}
+
+		buf, err := content.PageContent(ctx, pageDict)
+		if err != nil {
+			return nil, err
+		}
+
+		err = gc.walk(buf, resources, noFont)
+		if err == nil {
+			err = gc.appearances(pageDict)
+		}
+		if err != nil {
+			logInfoOptimize.Printf("collectGlyphs: page %d: %v\n", i+1, err)
+			gc.failed = true
+		}
+	}
+
+	return gc, nil
+}
+
+// descendantFont returns the CIDFont dict of a Type0 font.
+func descendantFont(ctx *types.PDFContext, fontDict *types.PDFDict) *types.PDFDict {
+
+	a, err := ctx.DereferenceArray(fontDict.Dict["DescendantFonts"])
+	if err != nil || a == nil || len(*a) != 1 {
+		return nil
+	}
+
+	d, err := ctx.DereferenceDict((*a)[0])
+	if err != nil {
+		return nil
+	}
+
+	return d
+}
+
+// fontProgram returns the font descriptor, the font file key and the object number of the embedded font program of a font dict.
+func fontProgram(ctx *types.PDFContext, fontDict *types.PDFDict) (fd *types.PDFDict, key string, objNr int, ok bool) {
+
+	d := fontDict
+	if s := fontDict.Subtype(); s != nil && *s == "Type0" {
+		if d = descendantFont(ctx, fontDict); d == nil {
+			return
+		}
+	}
+
+	fd, err := ctx.DereferenceDict(d.Dict["FontDescriptor"])
+	if err != nil || fd == nil {
+		return
+	}
+
+	for _, key = range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if indRef := fd.IndirectRefEntry(key); indRef != nil {
+			return fd, key, indRef.ObjectNumber.Value(), true
+		}
+	}
+
+	return
+}
+
+// Kinds of fonts supporting subsetting.
+const (
+	simpleTrueType = iota + 1
+	simpleCFF
+	cidTrueType
+	cidCFF
+)
+
+// subsetKind returns the kind of a font dict using an embedded font program or 0 if subsetting is not supported.
+func subsetKind(ctx *types.PDFContext, fontDict *types.PDFDict) int {
+
+	fd, key, _, ok := fontProgram(ctx, fontDict)
+	if !ok {
+		return 0
+	}
+
+	var program string
+	if key == "FontFile3" {
+		sd, err := ctx.DereferenceStreamDict(fd.Dict[key])
+		if err != nil || sd == nil || sd.Subtype() == nil {
+			return 0
+		}
+		program = *sd.Subtype()
+	}
+
+	s := fontDict.Subtype()
+	if s == nil {
+		return 0
+	}
+
+	switch *s {
+
+	case "TrueType":
+		if key == "FontFile2" {
+			return simpleTrueType
+		}
+
+	case "Type1", "MMType1":
+		if program == "Type1C" {
+			return simpleCFF
+		}
+
+	case "Type0":
+		// Only Identity encodings have CIDs matching the character codes.
+		if e := fontDict.NameEntry("Encoding"); e == nil || (*e != "Identity-H" && *e != "Identity-V") {
+			return 0
+		}
+		d := descendantFont(ctx, fontDict)
+		if d == nil || d.Subtype() == nil {
+			return 0
+		}
+		if *d.Subtype() == "CIDFontType2" && key == "FontFile2" {
+			return cidTrueType
+		}
+		if *d.Subtype() == "CIDFontType0" && program == "CIDFontType0C" {
+			return cidCFF
+		}
+	}
+
+	return 0
+}
+
+// embeddedFont is an embedded font program along with the fonts using it.
+type embeddedFont struct {
+	objNr int
+	key   string
+	fonts []int       // font dict object numbers
+	kinds map[int]int // by font dict object number
+}
+
+// simpleEncoding returns the Unicode code points and glyph names for the codes of a simple font.
+func simpleEncoding(ctx *types.PDFContext, fontDict *types.PDFDict) (runes font.Encoding, names map[int]string) {
+
+	runes, names = font.StandardEncoding, map[int]string{}
+
+	obj, err := ctx.Dereference(fontDict.Dict["Encoding"])
+	if err != nil || obj == nil {
+		return
+	}
+
+	var differences types.PDFArray
+
+	switch o := obj.(type) {
+
+	case types.PDFName:
+		if e, ok := font.PredefinedEncoding(o.Value()); ok {
+			runes = *e
+		}
+
+	case types.PDFDict:
+		if n := o.NameEntry("BaseEncoding"); n != nil {
+			if e, ok := font.PredefinedEncoding(*n); ok {
+				runes = *e
+			}
+		}
+		if a, err := ctx.DereferenceArray(o.Dict["Differences"]); err == nil && a != nil {
+			differences = *a
+		}
+	}
+
+	c := 0
+	for _, o := range differences {
+		switch o := o.(type) {
+		case types.PDFInteger:
+			c = o.Value()
+		case types.PDFName:
+			if c >= 0 && c < 256 {
+				names[c] = o.Value()
+				if r, ok := font.GlyphRune(o.Value()); ok {
+					runes[c] = r
+				}
+			}
+			c++
+		}
+	}
+
+	return
+}
+
+// cidToGID returns the CIDToGIDMap of a CIDFontType2 font or nil for Identity.
+func cidToGID(ctx *types.PDFContext, cidFont *types.PDFDict) []byte {
+
+	sd, err := ctx.DereferenceStreamDict(cidFont.Dict["CIDToGIDMap"])
+	if err != nil || sd == nil {
+		return nil
+	}
+
+	if err = filter.DecodeStream(sd); err != nil {
+		return nil
+	}
+
+	return sd.Content
+}
+
+// trueTypeGlyphs returns the glyph ids a viewer might select for each code shown using a simple TrueType font,
+// see 9.6.6.4 Encodings for TrueType Fonts.
+func trueTypeGlyphs(ctx *types.PDFContext, tt *font.TrueType, fontDict *types.PDFDict, codes types.IntSet) map[int]map[int]bool {
+
+	gids := map[int]map[int]bool{}
+
+	runes, _ := simpleEncoding(ctx, fontDict)
+	cmap30, cmap10, cmap31 := tt.CMap(3, 0), tt.CMap(1, 0), tt.CMap(3, 1)
+
+	for c := range codes {
+
+		gg := map[int]bool{}
+		gids[c] = gg
+
+		add := func(gid int, ok bool) {
+			if ok && gid > 0 {
+				gg[gid] = true
+			}
+		}
+
+		if cmap30 == nil && cmap10 == nil && cmap31 == nil {
+			add(c, true)
+			continue
+		}
+
+		for _, base := range []int{0, 0xF000, 0xF100, 0xF200} {
+			gid, ok := cmap30[base+c]
+			add(gid, ok)
+		}
+
+		gid, ok := cmap10[c]
+		add(gid, ok)
+
+		for _, r := range []rune{runes[c], font.WinAnsiEncoding[c]} {
+			gid, ok = cmap31[int(r)]
+			add(gid, ok && r != 0)
+		}
+	}
+
+	return gids
+}
+
+// fontSubsetter subsets an embedded font program and updates the fonts using it.
+type fontSubsetter struct {
+	ctx *types.PDFContext
+	gc  *glyphCollector
+	tt  *font.TrueType
+	cff *font.CFF
+}
+
+// glyphIDs returns the glyph ids needed for the codes shown using a font.
+// Where the glyph selection depends on the viewer all candidates are included.
+func (sf *fontSubsetter) glyphIDs(fontDict *types.PDFDict, kind int, codes types.IntSet) map[int]bool {
+
+	gids := map[int]bool{}
+
+	add := func(gid int, ok bool) {
+		if ok && gid > 0 {
+			gids[gid] = true
+		}
+	}
+
+	switch kind {
+
+	case simpleTrueType:
+		for _, gg := range trueTypeGlyphs(sf.ctx, sf.tt, fontDict, codes) {
+			for gid := range gg {
+				add(gid, true)
+			}
+		}
+
+	case simpleCFF:
+		runes, names := simpleEncoding(sf.ctx, fontDict)
+		byName, byRune := map[string]int{}, map[rune]int{}
+		for gid := sf.cff.NumGlyphs() - 1; gid > 0; gid-- {
+			name := sf.cff.GlyphName(gid)
+			byName[name] = gid
+			if r, ok := font.GlyphRune(name); ok {
+				byRune[r] = gid
+			}
+		}
+		for c := range codes {
+			gid, ok := sf.cff.Encoding()[c]
+			add(gid, ok)
+			gid, ok = byName[names[c]]
+			add(gid, ok)
+			gid, ok = byRune[runes[c]]
+			add(gid, ok && runes[c] != 0)
+		}
+
+	case cidTrueType:
+		m := cidToGID(sf.ctx, descendantFont(sf.ctx, fontDict))
+		for cid := range codes {
+			if m == nil {
+				add(cid, true)
+				continue
+			}
+			if 2*cid+1 < len(m) {
+				add(int(m[2*cid])<<8|int(m[2*cid+1]), true)
+			}
+		}
+
+	case cidCFF:
+		for cid := range codes {
+			if !sf.cff.IsCIDFont() {
+				add(cid, true)
+				continue
+			}
+			add(sf.cff.GID(cid))
+		}
+	}
+
+	return gids
+}
+
+// subsetTag returns the tag prefixed to the name of a font subset, see 9.6.4 Font Subsets.
+func subsetTag(objNr int, gids map[int]bool) string {
+
+	var ii []int
+	for gid := range gids {
+		ii = append(ii, gid)
+	}
+	sort.Ints(ii)
+
+	h := fnv.New32a()
+	h.Write([]byte{byte(objNr >> 8), byte(objNr)})
+	for _, gid := range ii {
+		h.Write([]byte{byte(gid >> 8), byte(gid)})
+	}
+
+	v := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + v%26)
+		v /= 26
+	}
+
+	return string(tag)
+}
+
+// baseFontName returns a font name without its subset tag and whether it had one.
+func baseFontName(name string) (string, bool) {
+
+	if len(name) < 8 || name[6] != '+' {
+		return name, false
+	}
+
+	for _, c := range name[:6] {
+		if c < 'A' || c > 'Z' {
+			return name, false
+		}
+	}
+
+	return name[7:], true
+}
+
+// subsetName returns a font name carrying tag.
+func subsetName(name, tag string) string {
+	name, _ = baseFontName(name)
+	return tag + "+" + name
+}
+
+func rename(d *types.PDFDict, key, tag string) {
+	if n := d.NameEntry(key); n != nil {
+		d.Update(key, types.PDFName(subsetName(*n, tag)))
+	}
+}
+
+// trimWidths restricts the widths of a simple font to the range of codes used.
+func (sf *fontSubsetter) trimWidths(fontDict *types.PDFDict, codes types.IntSet) {
+
+	first := fontDict.IntEntry("FirstChar")
+
+	a, err := sf.ctx.DereferenceArray(fontDict.Dict["Widths"])
+	if err != nil || a == nil || first == nil || len(codes) == 0 {
+		return
+	}
+
+	lo, hi := 255, 0
+	for c := range codes {
+		if c < lo {
+			lo = c
+		}
+		if c > hi {
+			hi = c
+		}
+	}
+
+	if lo < *first {
+		lo = *first
+	}
+	if hi >= *first+len(*a) {
+		hi = *first + len(*a) - 1
+	}
+	if lo > hi {
+		return
+	}
+
+	widths := make(types.PDFArray, hi-lo+1)
+	copy(widths, (*a)[lo-*first:hi-*first+1])
+
+	fontDict.Update("FirstChar", types.PDFInteger(lo))
+	fontDict.Update("LastChar", types.PDFInteger(hi))
+	fontDict.Update("Widths", widths)
+}
+
+// cidWidths returns the widths of the given CIDs as specified by the W array of a CIDFont, see 9.7.4.3 Glyph Metrics in CIDFonts.
+func cidWidths(ctx *types.PDFContext, cidFont *types.PDFDict, cids types.IntSet) (map[int]interface{}, bool) {
+
+	w, err := ctx.DereferenceArray(cidFont.Dict["W"])
+	if err != nil || w == nil {
+		return nil, false
+	}
+
+	widths := map[int]interface{}{}
+
+	// c [w1 w2 ...] or cFirst cLast w
+	for i := 0; i+1 < len(*w); {
+
+		c, ok := number((*w)[i])
+		if !ok {
+			return nil, false
+		}
+
+		o, _ := ctx.Dereference((*w)[i+1])
+
+		if ws, ok := o.(types.PDFArray); ok {
+			for j, o := range ws {
+				if cids[int(c)+j] {
+					widths[int(c)+j] = o
+				}
+			}
+			i += 2
+			continue
+		}
+
+		last, ok := number(o)
+		if !ok || i+2 >= len(*w) {
+			return nil, false
+		}
+		for cid := range cids {
+			if cid >= int(c) && cid <= int(last) {
+				widths[cid] = (*w)[i+2]
+			}
+		}
+		i += 3
+	}
+
+	return widths, true
+}
+
+// cidWidthArray returns a W array for widths by CID.
+func cidWidthArray(widths map[int]interface{}) types.PDFArray {
+
+	var cids []int
+	for cid := range widths {
+		cids = append(cids, cid)
+	}
+	sort.Ints(cids)
+
+	var a types.PDFArray
+	for i := 0; i < len(cids); {
+		j := i + 1
+		for j < len(cids) && cids[j] == cids[j-1]+1 {
+			j++
+		}
+		var ws types.PDFArray
+		for _, cid := range cids[i:j] {
+			ws = append(ws, widths[cid])
+		}
+		a = append(a, types.PDFInteger(cids[i]), ws)
+		i = j
+	}
+
+	return a
+}
+
+// trimCIDWidths restricts the widths of a CIDFont to the CIDs used.
+func (sf *fontSubsetter) trimCIDWidths(cidFont *types.PDFDict, codes types.IntSet) {
+
+	if widths, ok := cidWidths(sf.ctx, cidFont, codes); ok {
+		cidFont.Update("W", cidWidthArray(widths))
+	}
+}
+
+// trimToUnicode restricts the ToUnicode CMap of a font to the codes used.
+func (sf *fontSubsetter) trimToUnicode(fontDict *types.PDFDict, codes types.IntSet, n int) (err error) {
+
+	sd, err := sf.ctx.DereferenceStreamDict(fontDict.Dict["ToUnicode"])
+	if err != nil || sd == nil {
+		return
+	}
+
+	if err = filter.DecodeStream(sd); err != nil {
+		return
+	}
+
+	size := len(sd.Raw)
+	m := font.ParseCMap(sd.Content)
+
+	for s := range m.Chars {
+		c := 0
+		for _, b := range []byte(s) {
+			c = c<<8 | int(b)
+		}
+		if len(s) != n || !codes[c] {
+			delete(m.Chars, s)
+		}
+	}
+
+	sd, err = sf.ctx.InsertPDFStreamDict(m.Bytes())
+	if err != nil {
+		return
+	}
+
+	if err = filter.EncodeStream(sd); err != nil {
+		return
+	}
+
+	// Mappings of unused codes do no harm.
+	if len(sd.Raw) >= size {
+		return
+	}
+
+	objNr, err := sf.ctx.InsertObject(*sd)
+	if err != nil {
+		return
+	}
+
+	fontDict.Update("ToUnicode", types.NewPDFIndirectRef(objNr, 0))
+
+	return
+}
+
+// writeCIDToGIDMap replaces the CIDToGIDMap of a CIDFontType2 font after glyphs have been renumbered.
+func (sf *fontSubsetter) writeCIDToGIDMap(cidFont *types.PDFDict, codes types.IntSet, newGID map[int]int) (err error) {
+
+	m := cidToGID(sf.ctx, cidFont)
+
+	maxCID := 0
+	for cid := range codes {
+		if cid > maxCID {
+			maxCID = cid
+		}
+	}
+
+	buf := make([]byte, 2*(maxCID+1))
+
+	for cid := range codes {
+		gid := cid
+		if m != nil {
+			if 2*cid+1 >= len(m) {
+				continue
+			}
+			gid = int(m[2*cid])<<8 | int(m[2*cid+1])
+		}
+		g := newGID[gid]
+		buf[2*cid], buf[2*cid+1] = byte(g>>8), byte(g)
+	}
+
+	sd, err := sf.ctx.InsertPDFStreamDict(buf)
+	if err != nil {
+		return
+	}
+
+	if err = filter.EncodeStream(sd); err != nil {
+		return
+	}
+
+	objNr, err := sf.ctx.InsertObject(*sd)
+	if err != nil {
+		return
+	}
+
+	cidFont.Update("CIDToGIDMap", types.NewPDFIndirectRef(objNr, 0))
+
+	return
+}
+
+// fontFileStream returns a Flate encoded copy of the font file stream sd holding the font program buf.
+func fontFileStream(sd types.PDFStreamDict, key string, buf []byte) (*types.PDFStreamDict, error) {
+
+	sd2, err := flateStream(sd, buf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "FontFile2" {
+		sd2.Update("Length1", types.PDFInteger(len(buf)))
+	}
+
+	return sd2, nil
+}
+
+// subset replaces an embedded font program by a subset containing the glyphs used and updates the fonts using it.
+func (sf *fontSubsetter) subset(ef *embeddedFont) (err error) {
+
+	entry, found := sf.ctx.FindTableEntryLight(ef.objNr)
+	if !found {
+		return
+	}
+
+	sd, ok := entry.Object.(types.PDFStreamDict)
+	if !ok {
+		return
+	}
+
+	err = filter.DecodeStream(&sd)
+	if err == filter.ErrUnsupportedFilter {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if ef.key == "FontFile2" {
+		sf.tt, err = font.ParseTrueType(sd.Content)
+	} else {
+		sf.cff, err = font.ParseCFF(sd.Content)
+	}
+	if err != nil {
+		logInfoOptimize.Printf("subset: obj#%d: %v\n", ef.objNr, err)
+		return nil
+	}
+
+	fontDicts := map[int]*types.PDFDict{}
+	gids := map[int]bool{}
+
+	for _, objNr := range ef.fonts {
+		entry, _ := sf.ctx.FindTableEntryLight(objNr)
+		fontDict := entry.Object.(types.PDFDict)
+		fontDicts[objNr] = &fontDict
+		for gid := range sf.glyphIDs(&fontDict, ef.kinds[objNr], sf.gc.codes[objNr]) {
+			gids[gid] = true
+		}
+	}
+
+	// CIDFontType2 glyphs may be renumbered if the CIDToGIDMap of a single font is affected.
+	compact := len(ef.fonts) == 1 && ef.kinds[ef.fonts[0]] == cidTrueType
+
+	var (
+		buf    []byte
+		newGID map[int]int
+	)
+
+	if sf.tt != nil {
+		buf, newGID, err = sf.tt.Subset(gids, compact)
+		if err != nil {
+			logInfoOptimize.Printf("subset: obj#%d: %v\n", ef.objNr, err)
+			return nil
+		}
+	} else {
+		buf = sf.cff.Subset(gids)
+	}
+
+	sd2, err := fontFileStream(sd, ef.key, buf)
+	if err != nil {
+		return
+	}
+
+	if len(sd2.Raw) >= len(sd.Raw) {
+		logInfoOptimize.Printf("subset: obj#%d: keeping original font program\n", ef.objNr)
+		return nil
+	}
+
+	entry.Object = *sd2
+
+	logInfoOptimize.Printf("subset: obj#%d: %d glyphs, %d -> %d bytes\n", ef.objNr, len(gids), len(sd.Raw), len(sd2.Raw))
+
+	tag := subsetTag(ef.objNr, gids)
+
+	for _, objNr := range ef.fonts {
+
+		fontDict, codes := fontDicts[objNr], sf.gc.codes[objNr]
+
+		rename(fontDict, "BaseFont", tag)
+
+		fd, _, _, _ := fontProgram(sf.ctx, fontDict)
+		rename(fd, "FontName", tag)
+
+		n := 1
+
+		if kind := ef.kinds[objNr]; kind == cidTrueType || kind == cidCFF {
+			n = 2
+			cidFont := descendantFont(sf.ctx, fontDict)
+			rename(cidFont, "BaseFont", tag)
+			sf.trimCIDWidths(cidFont, codes)
+			if compact {
+				if err = sf.writeCIDToGIDMap(cidFont, codes, newGID); err != nil {
+					return
+				}
+			}
+		} else {
+			sf.trimWidths(fontDict, codes)
+		}
+
+		if err = sf.trimToUnicode(fontDict, codes, n); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// excludeFormFonts protects the fonts of the AcroForm default resources needed for filling in fields.
+func excludeFormFonts(ctx *types.PDFContext, gc *glyphCollector) {
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	acroForm, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		return
+	}
+
+	dr, err := ctx.DereferenceDict(acroForm.Dict["DR"])
+	if err != nil || dr == nil {
+		return
+	}
+
+	gc.markFontsUnsafe(dr)
+}
+
+// subsetFonts reduces embedded TrueType and CFF font programs to the glyphs actually used.
+// A font program is only subset if all fonts using it have been found in processed content.
+func subsetFonts(ctx *types.PDFContext) (err error) {
+
+	logInfoOptimize.Println("subsetFonts begin")
+
+	gc, err := collectGlyphs(ctx)
+	if err != nil {
+		return
+	}
+
+	// Glyphs of content not processed are unknown.
+	if gc.failed {
+		logInfoOptimize.Println("subsetFonts end: skipped")
+		return nil
+	}
+
+	excludeFormFonts(ctx, gc)
+
+	embeddedFonts := map[int]*embeddedFont{}
+
+	for objNr, entry := range ctx.Table {
+
+		if entry == nil || entry.Free {
+			continue
+		}
+
+		fontDict, ok := entry.Object.(types.PDFDict)
+		if !ok || fontDict.Type() == nil || *fontDict.Type() != "Font" {
+			continue
+		}
+
+		// CIDFonts are processed along with their Type0 font.
+		if s := fontDict.Subtype(); s == nil || *s == "CIDFontType0" || *s == "CIDFontType2" {
+			continue
+		}
+
+		_, key, fileObjNr, ok := fontProgram(ctx, &fontDict)
+		if !ok {
+			continue
+		}
+
+		ef := embeddedFonts[fileObjNr]
+		if ef == nil {
+			ef = &embeddedFont{objNr: fileObjNr, key: key, kinds: map[int]int{}}
+			embeddedFonts[fileObjNr] = ef
+		}
+
+		kind := subsetKind(ctx, &fontDict)
+		if _, found := gc.codes[objNr]; !found || kind == 0 {
+			gc.unsafe[fileObjNr] = true
+		}
+
+		ef.fonts = append(ef.fonts, objNr)
+		ef.kinds[objNr] = kind
+	}
+
+	var objNrs []int
+	for objNr := range embeddedFonts {
+		if gc.unsafe[objNr] {
+			logInfoOptimize.Printf("subsetFonts: obj#%d: skipping font program\n", objNr)
+			continue
+		}
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+
+		ef := embeddedFonts[objNr]
+		sort.Ints(ef.fonts)
+
+		sf := &fontSubsetter{ctx: ctx, gc: gc}
+
+		err = sf.subset(ef)
+		if err != nil {
+			return
+		}
+	}
+
+	logInfoOptimize.Println("subsetFonts end")
+
+	return
+}
@@ -17,6 +17,7 @@ const (
 	EXTRACTFONTS
 	EXTRACTPAGES
 	EXTRACTCONTENT
+	EXTRACTTEXT
 	TRIM
 	ADDATTACHMENTS
 	REMOVEATTACHMENTS
@@ -26,20 +27,60 @@ const (
 	DECRYPT
 	CHANGEUPW
 	CHANGEOPW
+	LISTANNOTATIONS
+	EXPORTANNOTATIONS
+	REMOVEANNOTATIONS
+	ADDANNOTATIONS
+	FLATTEN
+	LISTFORMFIELDS
+	FILLFORM
+	RESETFORM
+	LOCKFORM
+	REMOVEFORMFIELDS
+	EXPORTXFA
+	STRIPXFA
+	EXPORTFORMDATA
+	IMPORTFORMDATA
+	INFO
+	OBJECT
+	XREF
+	SETOBJECT
+	DELETEOBJECT
+	REPLACESTREAM
+	SPLITSPAN
+	SPLITATPAGES
+	SPLITBOOKMARKS
+	SPLITSIZE
+	LISTLAYERS
+	SHOWLAYERS
+	HIDELAYERS
+	LOCKLAYERS
+	REMOVELAYERS
 )
 
 // Command represents an execution context.
 type Command struct {
-	Mode          commandMode          // VALIDATE  OPTIMIZE  SPLIT  MERGE  EXTRACT  TRIM  LISTATT ADDATT REMATT EXTATT  ENCRYPT  DECRYPT  CHANGEUPW  CHANGEOPW
-	InFile        *string              //    *         *        *      -       *      *      *       *       *      *       *        *         *          *
-	InFiles       []string             //    -         -        -      *       -      -      -       *       *      *       -        -         -          -
-	InDir         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         -          -
-	OutFile       *string              //    -         *        -      *       -      *      -       -       -      -       *        *         *          *
-	OutDir        *string              //    -         -        *      -       *      -      -       -       -      *       -        -         -          -
-	PageSelection []string             //    -         -        -      -       *      *      -       -       -      -       -        -         -          -
-	Config        *types.Configuration //    *         *        *      *       *      *      *       *       *      *       *        *         *          *
-	PWOld         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         *          *
-	PWNew         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         *          *
+	Mode          commandMode          // VALIDATE  OPTIMIZE  SPLIT  MERGE  EXTRACT  TRIM  LISTATT ADDATT REMATT EXTATT  ENCRYPT  DECRYPT  CHANGEUPW  CHANGEOPW  LISTANN EXPANN REMANN ADDANN FLATTEN LISTFORM FILLFORM RESETFORM LOCKFORM REMFORM EXPXFA STRIPXFA EXPFDF IMPFDF INFO  OBJ  XREF  OBJSET  OBJDEL  OBJREPL  LAYERS
+	InFile        *string              //    *         *        *      -       *      *      *       *       *      *       *        *         *          *         *       *      *      *      *      *        *         *        *       *     *       *       *      *      *       *    *       *       *       *       *
+	InFiles       []string             //    -         -        -      *       -      -      -       *       *      *       -        -         -          -         -       -      -      *      -      -        *         -        -       -     -       -       -      *      -       -    -       -       -       *       -
+	InDir         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	OutFile       *string              //    -         *        -      *       -      *      -       -       -      -       *        *         *          *         -       *      *      *      *      *        *         *        *       *     -       *       *      *      -       -    -       *       *       *       *
+	OutDir        *string              //    -         -        *      -       *      -      -       -       -      *       -        -         -          -         -       -      -      -      -      -        -         -        -       -     *       -       -      -      -       -    -       -       -       -       -
+	PageSelection []string             //    -         -        -      -       *      *      -       -       -      -       -        -         -          -         *       *      *      -      *      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	Config        *types.Configuration //    *         *        *      *       *      *      *       *       *      *       *        *         *          *         *       *      *      *      *      *        *         *        *       *     *       *       *      *      *       *    *       *       *       *       *
+	PWOld         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         *          *         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	PWNew         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         *          *         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	Subtypes      []string             //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         *       *      *      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	FieldNames    []string             //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         *        *       *     -       -       -      -      -       -    -       -       -       -       -
+	JSON          bool                 //    -         -        -      -       *      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      *       -    -       -       -       -       -
+	Detail        bool                 //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      *       -    -       -       -       -       -
+	Path          *string              //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       *    -       *       *       *       -
+	Value         *string              //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       *       -       -       -
+	Decode        bool                 //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       *    -       -       -       -       -
+	Span          int                  //    -         -        *      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	PageNrs       []int                //    -         -        *      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	MaxSize       int64                //    -         -        *      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       -
+	LayerNames    []string             //    -         -        -      -       -      -      -       -       -      -       -        -         -          -         -       -      -      -      -      -        -         -        -       -     -       -       -      -      -       -    -       -       -       -       *
 }
 
 // ValidateCommand creates a new ValidateCommand.
@@ -52,6 +93,7 @@ func ValidateCommand(pdfFileName string, config *types.Configuration) Command {
 
 // OptimizeCommand creates a new OptimizeCommand.
 func OptimizeCommand(pdfFileNameIn, pdfFileNameOut string, config *types.Configuration) Command {
+	config.MergeFontSubsets = true
 	return Command{
 		Mode:    OPTIMIZE,
 		InFile:  &pdfFileNameIn,
@@ -68,6 +110,46 @@ func SplitCommand(pdfFileNameIn, dirNameOut string, config *types.Configuration)
 		Config: config}
 }
 
+// SplitSpanCommand creates a new SplitCommand for files of span pages each.
+func SplitSpanCommand(pdfFileNameIn, dirNameOut string, span int, config *types.Configuration) Command {
+	return Command{
+		Mode:   SPLITSPAN,
+		InFile: &pdfFileNameIn,
+		OutDir: &dirNameOut,
+		Span:   span,
+		Config: config}
+}
+
+// SplitAtPagesCommand creates a new SplitCommand starting a new file at each of pageNrs.
+func SplitAtPagesCommand(pdfFileNameIn, dirNameOut string, pageNrs []int, config *types.Configuration) Command {
+	return Command{
+		Mode:    SPLITATPAGES,
+		InFile:  &pdfFileNameIn,
+		OutDir:  &dirNameOut,
+		PageNrs: pageNrs,
+		Config:  config}
+}
+
+// SplitByBookmarksCommand creates a new SplitCommand starting a new file at each bookmark up to outline level.
+func SplitByBookmarksCommand(pdfFileNameIn, dirNameOut string, level int, config *types.Configuration) Command {
+	return Command{
+		Mode:   SPLITBOOKMARKS,
+		InFile: &pdfFileNameIn,
+		OutDir: &dirNameOut,
+		Span:   level,
+		Config: config}
+}
+
+// SplitBySizeCommand creates a new SplitCommand for files not exceeding maxSize bytes.
+func SplitBySizeCommand(pdfFileNameIn, dirNameOut string, maxSize int64, config *types.Configuration) Command {
+	return Command{
+		Mode:    SPLITSIZE,
+		InFile:  &pdfFileNameIn,
+		OutDir:  &dirNameOut,
+		MaxSize: maxSize,
+		Config:  config}
+}
+
 // MergeCommand creates a new MergeCommand.
 func MergeCommand(pdfFileNamesIn []string, pdfFileNameOut string, config *types.Configuration) Command {
 	return Command{
@@ -120,6 +202,18 @@ func ExtractContentCommand(pdfFileNameIn, dirNameOut string, pageSelection []str
 		Config:        config}
 }
 
+// ExtractTextCommand creates a new ExtractTextCommand.
+// The text is written as plain text or as JSON including the position, font and font size of each text run.
+func ExtractTextCommand(pdfFileNameIn, dirNameOut string, pageSelection []string, jsonOutput bool, config *types.Configuration) Command {
+	return Command{
+		Mode:          EXTRACTTEXT,
+		InFile:        &pdfFileNameIn,
+		OutDir:        &dirNameOut,
+		PageSelection: pageSelection,
+		JSON:          jsonOutput,
+		Config:        config}
+}
+
 // TrimCommand creates a new TrimCommand.
 func TrimCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *types.Configuration) Command {
 	// A slice parameter may be called with nil => empty slice.
@@ -207,6 +301,255 @@ func ChangeOwnerPWCommand(pdfFileNameIn, pdfFileNameOut string, config *types.Co
 		PWNew:   pwNew}
 }
 
+// ListAnnotationsCommand creates a new ListAnnotationsCommand.
+func ListAnnotationsCommand(pdfFileNameIn string, pageSelection, subtypes []string, config *types.Configuration) Command {
+	return Command{
+		Mode:          LISTANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		PageSelection: pageSelection,
+		Subtypes:      subtypes,
+		Config:        config}
+}
+
+// ExportAnnotationsCommand creates a new ExportAnnotationsCommand.
+func ExportAnnotationsCommand(pdfFileNameIn, jsonFileNameOut string, pageSelection, subtypes []string, config *types.Configuration) Command {
+	return Command{
+		Mode:          EXPORTANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &jsonFileNameOut,
+		PageSelection: pageSelection,
+		Subtypes:      subtypes,
+		Config:        config}
+}
+
+// RemoveAnnotationsCommand creates a new RemoveAnnotationsCommand.
+func RemoveAnnotationsCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection, subtypes []string, config *types.Configuration) Command {
+	return Command{
+		Mode:          REMOVEANNOTATIONS,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Subtypes:      subtypes,
+		Config:        config}
+}
+
+// AddAnnotationsCommand creates a new AddAnnotationsCommand.
+func AddAnnotationsCommand(pdfFileNameIn, jsonFileNameIn, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    ADDANNOTATIONS,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{jsonFileNameIn},
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// FlattenCommand creates a new FlattenCommand.
+func FlattenCommand(pdfFileNameIn, pdfFileNameOut string, pageSelection []string, config *types.Configuration) Command {
+	return Command{
+		Mode:          FLATTEN,
+		InFile:        &pdfFileNameIn,
+		OutFile:       &pdfFileNameOut,
+		PageSelection: pageSelection,
+		Config:        config}
+}
+
+// ListFormFieldsCommand creates a new ListFormFieldsCommand.
+// If jsonFileNameOut is not empty the form fields are also exported as JSON.
+func ListFormFieldsCommand(pdfFileNameIn, jsonFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    LISTFORMFIELDS,
+		InFile:  &pdfFileNameIn,
+		OutFile: &jsonFileNameOut,
+		Config:  config}
+}
+
+// FillFormCommand creates a new FillFormCommand.
+func FillFormCommand(pdfFileNameIn, jsonFileNameIn, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    FILLFORM,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{jsonFileNameIn},
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ResetFormCommand creates a new ResetFormCommand.
+func ResetFormCommand(pdfFileNameIn, pdfFileNameOut string, fieldNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       RESETFORM,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		FieldNames: fieldNames,
+		Config:     config}
+}
+
+// LockFormCommand creates a new LockFormCommand.
+func LockFormCommand(pdfFileNameIn, pdfFileNameOut string, fieldNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       LOCKFORM,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		FieldNames: fieldNames,
+		Config:     config}
+}
+
+// RemoveFormFieldsCommand creates a new RemoveFormFieldsCommand.
+func RemoveFormFieldsCommand(pdfFileNameIn, pdfFileNameOut string, fieldNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       REMOVEFORMFIELDS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		FieldNames: fieldNames,
+		Config:     config}
+}
+
+// ExportXFACommand creates a new ExportXFACommand.
+func ExportXFACommand(pdfFileNameIn, dirNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:   EXPORTXFA,
+		InFile: &pdfFileNameIn,
+		OutDir: &dirNameOut,
+		Config: config}
+}
+
+// StripXFACommand creates a new StripXFACommand.
+func StripXFACommand(pdfFileNameIn, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    STRIPXFA,
+		InFile:  &pdfFileNameIn,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ExportFormDataCommand creates a new ExportFormDataCommand.
+// The format of fdfFileNameOut (FDF or XFDF) is chosen by its extension.
+func ExportFormDataCommand(pdfFileNameIn, fdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    EXPORTFORMDATA,
+		InFile:  &pdfFileNameIn,
+		OutFile: &fdfFileNameOut,
+		Config:  config}
+}
+
+// ImportFormDataCommand creates a new ImportFormDataCommand.
+// The format of fdfFileNameIn (FDF or XFDF) is chosen by its extension.
+func ImportFormDataCommand(pdfFileNameIn, fdfFileNameIn, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    IMPORTFORMDATA,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{fdfFileNameIn},
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// InfoCommand creates a new InfoCommand.
+func InfoCommand(pdfFileNameIn string, detail, jsonOutput bool, config *types.Configuration) Command {
+	return Command{
+		Mode:   INFO,
+		InFile: &pdfFileNameIn,
+		Detail: detail,
+		JSON:   jsonOutput,
+		Config: config}
+}
+
+// ObjectCommand creates a new ObjectCommand for printing the object identified by an object number or a path like Root/Pages/Kids/0.
+func ObjectCommand(pdfFileNameIn, path string, decode bool, config *types.Configuration) Command {
+	return Command{
+		Mode:   OBJECT,
+		InFile: &pdfFileNameIn,
+		Path:   &path,
+		Decode: decode,
+		Config: config}
+}
+
+// XRefCommand creates a new XRefCommand.
+func XRefCommand(pdfFileNameIn string, config *types.Configuration) Command {
+	return Command{
+		Mode:   XREF,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// SetObjectCommand creates a new SetObjectCommand for setting the object at path to a value given in PDF syntax.
+func SetObjectCommand(pdfFileNameIn, path, value, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    SETOBJECT,
+		InFile:  &pdfFileNameIn,
+		Path:    &path,
+		Value:   &value,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// DeleteObjectCommand creates a new DeleteObjectCommand for removing the dict entry or array element at path.
+func DeleteObjectCommand(pdfFileNameIn, path, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    DELETEOBJECT,
+		InFile:  &pdfFileNameIn,
+		Path:    &path,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ReplaceStreamCommand creates a new ReplaceStreamCommand for replacing the content of the stream object at path.
+func ReplaceStreamCommand(pdfFileNameIn, path, contentFileName, pdfFileNameOut string, config *types.Configuration) Command {
+	return Command{
+		Mode:    REPLACESTREAM,
+		InFile:  &pdfFileNameIn,
+		InFiles: []string{contentFileName},
+		Path:    &path,
+		OutFile: &pdfFileNameOut,
+		Config:  config}
+}
+
+// ListLayersCommand creates a new ListLayersCommand.
+func ListLayersCommand(pdfFileNameIn string, config *types.Configuration) Command {
+	return Command{
+		Mode:   LISTLAYERS,
+		InFile: &pdfFileNameIn,
+		Config: config}
+}
+
+// ShowLayersCommand creates a new ShowLayersCommand.
+func ShowLayersCommand(pdfFileNameIn, pdfFileNameOut string, layerNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       SHOWLAYERS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		LayerNames: layerNames,
+		Config:     config}
+}
+
+// HideLayersCommand creates a new HideLayersCommand.
+func HideLayersCommand(pdfFileNameIn, pdfFileNameOut string, layerNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       HIDELAYERS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		LayerNames: layerNames,
+		Config:     config}
+}
+
+// LockLayersCommand creates a new LockLayersCommand.
+func LockLayersCommand(pdfFileNameIn, pdfFileNameOut string, layerNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       LOCKLAYERS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		LayerNames: layerNames,
+		Config:     config}
+}
+
+// RemoveLayersCommand creates a new RemoveLayersCommand.
+func RemoveLayersCommand(pdfFileNameIn, pdfFileNameOut string, layerNames []string, config *types.Configuration) Command {
+	return Command{
+		Mode:       REMOVELAYERS,
+		InFile:     &pdfFileNameIn,
+		OutFile:    &pdfFileNameOut,
+		LayerNames: layerNames,
+		Config:     config}
+}
+
 func processAttachments(cmd *Command) (out []string, err error) {
 
 	switch cmd.Mode {
@@ -227,6 +570,107 @@ func processAttachments(cmd *Command) (out []string, err error) {
 	return
 }
 
+func processAnnotations(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case LISTANNOTATIONS:
+		out, err = ListAnnotations(*cmd.InFile, cmd.PageSelection, cmd.Subtypes, cmd.Config)
+
+	case EXPORTANNOTATIONS:
+		err = ExportAnnotations(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Subtypes, cmd.Config)
+
+	case REMOVEANNOTATIONS:
+		err = RemoveAnnotations(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Subtypes, cmd.Config)
+
+	case ADDANNOTATIONS:
+		err = AddAnnotations(*cmd.InFile, cmd.InFiles[0], *cmd.OutFile, cmd.Config)
+	}
+
+	return
+}
+
+func processForm(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case LISTFORMFIELDS:
+		out, err = ListFormFields(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case FILLFORM:
+		err = FillForm(*cmd.InFile, cmd.InFiles[0], *cmd.OutFile, cmd.Config)
+
+	case RESETFORM:
+		err = ResetForm(*cmd.InFile, *cmd.OutFile, cmd.FieldNames, cmd.Config)
+
+	case LOCKFORM:
+		err = LockForm(*cmd.InFile, *cmd.OutFile, cmd.FieldNames, cmd.Config)
+
+	case REMOVEFORMFIELDS:
+		err = RemoveFormFields(*cmd.InFile, *cmd.OutFile, cmd.FieldNames, cmd.Config)
+
+	case EXPORTXFA:
+		err = ExportXFA(*cmd.InFile, *cmd.OutDir, cmd.Config)
+
+	case STRIPXFA:
+		err = StripXFA(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case EXPORTFORMDATA:
+		err = ExportFormData(*cmd.InFile, *cmd.OutFile, cmd.Config)
+
+	case IMPORTFORMDATA:
+		err = ImportFormData(*cmd.InFile, cmd.InFiles[0], *cmd.OutFile, cmd.Config)
+	}
+
+	return
+}
+
+func processObject(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case OBJECT:
+		out, err = Object(*cmd.InFile, *cmd.Path, cmd.Decode, cmd.Config)
+
+	case XREF:
+		out, err = XRef(*cmd.InFile, cmd.Config)
+
+	case SETOBJECT:
+		err = SetObject(*cmd.InFile, *cmd.OutFile, *cmd.Path, *cmd.Value, cmd.Config)
+
+	case DELETEOBJECT:
+		err = DeleteObject(*cmd.InFile, *cmd.OutFile, *cmd.Path, cmd.Config)
+
+	case REPLACESTREAM:
+		err = ReplaceStream(*cmd.InFile, *cmd.OutFile, *cmd.Path, cmd.InFiles[0], cmd.Config)
+	}
+
+	return
+}
+
+func processLayers(cmd *Command) (out []string, err error) {
+
+	switch cmd.Mode {
+
+	case LISTLAYERS:
+		out, err = ListLayers(*cmd.InFile, cmd.Config)
+
+	case SHOWLAYERS:
+		err = ShowLayers(*cmd.InFile, *cmd.OutFile, cmd.LayerNames, cmd.Config)
+
+	case HIDELAYERS:
+		err = HideLayers(*cmd.InFile, *cmd.OutFile, cmd.LayerNames, cmd.Config)
+
+	case LOCKLAYERS:
+		err = LockLayers(*cmd.InFile, *cmd.OutFile, cmd.LayerNames, cmd.Config)
+
+	case REMOVELAYERS:
+		err = RemoveLayers(*cmd.InFile, *cmd.OutFile, cmd.LayerNames, cmd.Config)
+	}
+
+	return
+}
+
 func processEncryption(cmd *Command) (err error) {
 
 	switch cmd.Mode {
@@ -256,11 +700,23 @@ func Process(cmd *Command) (out []string, err error) {
 		err = Validate(*cmd.InFile, cmd.Config)
 
 	case OPTIMIZE:
-		err = Optimize(*cmd.InFile, *cmd.OutFile, cmd.Config)
+		_, err = Optimize(*cmd.InFile, *cmd.OutFile, cmd.Config)
 
 	case SPLIT:
 		err = Split(*cmd.InFile, *cmd.OutDir, cmd.Config)
 
+	case SPLITSPAN:
+		err = SplitSpan(*cmd.InFile, *cmd.OutDir, cmd.Span, cmd.Config)
+
+	case SPLITATPAGES:
+		err = SplitAtPages(*cmd.InFile, *cmd.OutDir, cmd.PageNrs, cmd.Config)
+
+	case SPLITBOOKMARKS:
+		err = SplitByBookmarks(*cmd.InFile, *cmd.OutDir, cmd.Span, cmd.Config)
+
+	case SPLITSIZE:
+		err = SplitBySize(*cmd.InFile, *cmd.OutDir, cmd.MaxSize, cmd.Config)
+
 	case MERGE:
 		err = Merge(cmd.InFiles, *cmd.OutFile, cmd.Config)
 
@@ -276,6 +732,9 @@ func Process(cmd *Command) (out []string, err error) {
 	case EXTRACTCONTENT:
 		err = ExtractContent(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.Config)
 
+	case EXTRACTTEXT:
+		err = ExtractText(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.JSON, cmd.Config)
+
 	case TRIM:
 		err = Trim(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Config)
 
@@ -285,6 +744,25 @@ func Process(cmd *Command) (out []string, err error) {
 	case ENCRYPT, DECRYPT, CHANGEUPW, CHANGEOPW:
 		err = processEncryption(cmd)
 
+	case LISTANNOTATIONS, EXPORTANNOTATIONS, REMOVEANNOTATIONS, ADDANNOTATIONS:
+		out, err = processAnnotations(cmd)
+
+	case FLATTEN:
+		err = Flatten(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Config)
+
+	case LISTFORMFIELDS, FILLFORM, RESETFORM, LOCKFORM, REMOVEFORMFIELDS, EXPORTXFA, STRIPXFA,
+		EXPORTFORMDATA, IMPORTFORMDATA:
+		out, err = processForm(cmd)
+
+	case INFO:
+		out, err = Info(*cmd.InFile, cmd.Detail, cmd.JSON, cmd.Config)
+
+	case OBJECT, XREF, SETOBJECT, DELETEOBJECT, REPLACESTREAM:
+		out, err = processObject(cmd)
+
+	case LISTLAYERS, SHOWLAYERS, HIDELAYERS, LOCKLAYERS, REMOVELAYERS:
+		out, err = processLayers(cmd)
+
 	default:
 		err = errors.Errorf("Process: Unknown command mode %d\n", cmd.Mode)
 	}
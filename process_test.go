@@ -1,13 +1,20 @@
 package pdfcpu
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/hhrutter/pdfcpu/extract"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/info"
+	"github.com/hhrutter/pdfcpu/merge"
+	"github.com/hhrutter/pdfcpu/object"
 	"github.com/hhrutter/pdfcpu/types"
 )
 
@@ -56,6 +63,105 @@ func ExampleProcess_optimize() {
 
 }
 
+func ExampleProcess_optimizeImages() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Downsample images exceeding 150 dpi at their placement on the page.
+	config.ImageDPI = 150
+
+	// Recompress downsampled images as JPEG of quality 60.
+	config.ImageQuality = 60
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
+func ExampleProcess_optimizeFonts() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Reduce embedded TrueType and CFF fonts to the glyphs used.
+	config.SubsetFonts = true
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
+func ExampleProcess_optimizePrune() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Remove unused resources and unreachable objects.
+	config.Prune = true
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
+func ExampleProcess_optimizeStreams() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Recompress streams at best Flate compression and minify content streams.
+	config.Recompress = true
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
+func ExampleProcess_optimizeLinearize() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Write a linearized file for incremental loading (Fast Web View).
+	config.Linearize = true
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
+func ExampleProcess_optimizeReport() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Write a JSON report about sizes of images and fonts and duplicates removed.
+	config.ReportFileName = "report.json"
+
+	cmd := OptimizeCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+
+}
+
 func ExampleProcess_merge() {
 
 	// Concatenate this sequence of PDF files:
@@ -147,6 +253,19 @@ func ExampleProcess_extractImages() {
 
 }
 
+func ExampleProcess_extractText() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Extract the text of page 1 including text positions, fonts and font sizes.
+	cmd := ExtractTextCommand("in.pdf", "dirOut", []string{"1"}, true, config)
+
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+}
+
 func ExampleProcess_listAttachments() {
 
 	config := types.NewDefaultConfiguration()
@@ -234,14 +353,12 @@ func ExampleProcess_extractAttachments() {
 	}
 }
 
-func ExampleProcess_encrypt() {
+func ExampleProcess_removeAnnotations() {
 
 	config := types.NewDefaultConfiguration()
 
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-
-	cmd := EncryptCommand("in.pdf", "out.pdf", config)
+	// Remove all Link and Highlight annotations of the first two pages.
+	cmd := RemoveAnnotationsCommand("in.pdf", "out.pdf", []string{"-2"}, []string{"Link", "Highlight"}, config)
 
 	_, err := Process(&cmd)
 	if err != nil {
@@ -249,14 +366,12 @@ func ExampleProcess_encrypt() {
 	}
 }
 
-func ExampleProcess_decrypt() {
+func ExampleProcess_addAnnotations() {
 
 	config := types.NewDefaultConfiguration()
 
-	config.UserPW = "upw"
-	config.OwnerPW = "opw"
-
-	cmd := DecryptCommand("in.pdf", "out.pdf", config)
+	// Add the annotations described in annots.json.
+	cmd := AddAnnotationsCommand("in.pdf", "annots.json", "out.pdf", config)
 
 	_, err := Process(&cmd)
 	if err != nil {
@@ -264,17 +379,12 @@ func ExampleProcess_decrypt() {
 	}
 }
 
-func ExampleProcess_changeUserPW() {
+func ExampleProcess_flatten() {
 
 	config := types.NewDefaultConfiguration()
 
-	// supply existing owner pw like so
-	config.OwnerPW = "opw"
-
-	pwOld := "pwOld"
-	pwNew := "pwNew"
-
-	cmd := ChangeUserPWCommand("in.pdf", "out.pdf", config, &pwOld, &pwNew)
+	// Flatten all annotations and form fields of in.pdf.
+	cmd := FlattenCommand("in.pdf", "out.pdf", nil, config)
 
 	_, err := Process(&cmd)
 	if err != nil {
@@ -282,18 +392,12 @@ func ExampleProcess_changeUserPW() {
 	}
 }
 
-func ExampleProcess_changeOwnerPW() {
+func ExampleProcess_fillForm() {
 
 	config := types.NewDefaultConfiguration()
 
-	// supply existing user pw like so
-	config.UserPW = "upw"
-
-	// old and new owner pw
-	pwOld := "pwOld"
-	pwNew := "pwNew"
-
-	cmd := ChangeOwnerPWCommand("in.pdf", "out.pdf", config, &pwOld, &pwNew)
+	// Fill the form fields of in.pdf with the values found in form.json.
+	cmd := FillFormCommand("in.pdf", "form.json", "out.pdf", config)
 
 	_, err := Process(&cmd)
 	if err != nil {
@@ -301,488 +405,2652 @@ func ExampleProcess_changeOwnerPW() {
 	}
 }
 
-func TestMain(m *testing.M) {
-	os.Mkdir(outputDir, 0777)
+func ExampleProcess_removeFormFields() {
 
-	exitCode := m.Run()
+	config := types.NewDefaultConfiguration()
 
-	err := os.RemoveAll(outputDir)
+	// Remove the form field "signature" and all fields below "address".
+	cmd := RemoveFormFieldsCommand("in.pdf", "out.pdf", []string{"signature", "address"}, config)
+
+	_, err := Process(&cmd)
 	if err != nil {
-		fmt.Printf("%v", err)
-		os.Exit(1)
+		return
 	}
-
-	os.Exit(exitCode)
 }
 
-// Validate all PDFs in testdata.
-func TestValidateCommand(t *testing.T) {
-
-	files, err := ioutil.ReadDir("testdata")
-	if err != nil {
-		t.Fatalf("TestValidateCommand: %v\n", err)
-	}
+func ExampleProcess_stripXFA() {
 
 	config := types.NewDefaultConfiguration()
-	config.SetValidationRelaxed()
 
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			cmd := ValidateCommand("testdata/"+file.Name(), config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestValidateCommand: %v\n", err)
-			}
-		}
-	}
+	// Remove the XFA form of in.pdf so viewers use the AcroForm fields.
+	cmd := StripXFACommand("in.pdf", "out.pdf", config)
 
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
 }
 
-func TestValidateOneFile(t *testing.T) {
+func ExampleProcess_exportFormData() {
 
 	config := types.NewDefaultConfiguration()
-	config.SetValidationRelaxed()
 
-	cmd := ValidateCommand("testdata/gobook.0.pdf", config)
+	// Export the form field values and markup annotations of in.pdf as XFDF.
+	cmd := ExportFormDataCommand("in.pdf", "data.xfdf", config)
+
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestValidateOneFile: %v\n", err)
+		return
 	}
-
 }
 
-func BenchmarkValidateCommand(b *testing.B) {
+func ExampleProcess_importFormData() {
 
 	config := types.NewDefaultConfiguration()
-	config.SetValidationRelaxed()
-
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		cmd := ValidateCommand("testdata/gobook.0.pdf", config)
-		_, err := Process(&cmd)
-		if err != nil {
-			b.Fatalf("BenchmarkValidateCommand: %v\n", err)
-		}
-	}
-}
 
-// Optimize all PDFs in testdata and write with (default) end of line sequence "\n".
-func TestOptimizeCommandWithLF(t *testing.T) {
+	// Apply the form field values and markup annotations of data.fdf to in.pdf.
+	cmd := ImportFormDataCommand("in.pdf", "data.fdf", "out.pdf", config)
 
-	files, err := ioutil.ReadDir("testdata")
+	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+		return
 	}
+}
 
-	config := types.NewDefaultConfiguration()
-
-	// this is not necessary but to make it clearer.
-	config.Eol = types.EolLF
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestOptimizeCommand: %v\n", err)
-			}
-		}
-	}
+func ExampleProcess_info() {
 
-}
+	config := types.NewDefaultConfiguration()
 
-// Optimize all PDFs in testdata and write with end of line sequence "\r".
-func TestOptimizeCommandWithCR(t *testing.T) {
+	// Print page boxes, fonts, images and attachments in addition to the summary.
+	cmd := InfoCommand("in.pdf", true, false, config)
 
-	files, err := ioutil.ReadDir("testdata")
+	out, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+		return
 	}
 
-	config := types.NewDefaultConfiguration()
-	config.Eol = types.EolCR
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestOptimizeCommand: %v\n", err)
-			}
-		}
+	for _, s := range out {
+		fmt.Println(s)
 	}
 
 }
 
-// Optimize all PDFs in testdata and write with end of line sequence "\r".
-// This test writes out the cross reference table the old way without using object streams and an xref stream.
-func TestOptimizeCommandWithCRAndNoXrefStream(t *testing.T) {
+func ExampleProcess_object() {
 
-	files, err := ioutil.ReadDir("testdata")
+	config := types.NewDefaultConfiguration()
+
+	// Print the content stream of the first page decoded.
+	cmd := ObjectCommand("in.pdf", "Root/Pages/Kids/0/Contents", true, config)
+
+	out, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+		return
 	}
 
-	config := types.NewDefaultConfiguration()
-	config.Eol = types.EolCR
-	config.WriteObjectStream = false
-	config.WriteXRefStream = false
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestOptimizeCommand: %v\n", err)
-			}
-		}
+	for _, s := range out {
+		fmt.Println(s)
 	}
 
 }
 
-// Optimize all PDFs in testdata and write with end of line sequence "\r\n".
-func TestOptimizeCommandWithCRLF(t *testing.T) {
+func ExampleProcess_xref() {
 
-	files, err := ioutil.ReadDir("testdata")
+	config := types.NewDefaultConfiguration()
+
+	cmd := XRefCommand("in.pdf", config)
+
+	out, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+		return
 	}
 
-	config := types.NewDefaultConfiguration()
-	config.Eol = types.EolCRLF
-	config.StatsFileName = outputDir + "/testStats.csv"
-
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestOptimizeCommand: %v\n", err)
-			}
-		}
+	for _, s := range out {
+		fmt.Println(s)
 	}
 
 }
 
-// Split a test PDF file up into single page PDFs.
-func TestSplitCommand(t *testing.T) {
+func ExampleProcess_setObject() {
 
-	cmd := SplitCommand("testdata/Acroforms2.pdf", outputDir, types.NewDefaultConfiguration())
+	config := types.NewDefaultConfiguration()
+
+	// Set a missing MediaBox of the first page.
+	cmd := SetObjectCommand("in.pdf", "Root/Pages/Kids/0/MediaBox", "[0 0 595 842]", "out.pdf", config)
 
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestSplitCommand: %v\n", err)
+		return
 	}
 }
 
-// Merge all PDFs in testdir into out/test.pdf.
-func TestMergeCommand(t *testing.T) {
+func ExampleProcess_deleteObject() {
 
-	files, err := ioutil.ReadDir("testdata")
-	if err != nil {
-		t.Fatalf("TestMergeCommmand: %v\n", err)
-	}
+	config := types.NewDefaultConfiguration()
 
-	inFiles := []string{}
-	for _, file := range files {
-		if strings.HasSuffix(file.Name(), "pdf") {
-			inFiles = append(inFiles, "testdata/"+file.Name())
-		}
-	}
+	cmd := DeleteObjectCommand("in.pdf", "Root/OpenAction", "out.pdf", config)
 
-	cmd := MergeCommand(inFiles, outputDir+"/test.pdf", types.NewDefaultConfiguration())
-	_, err = Process(&cmd)
+	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestMergeCommand: %v\n", err)
+		return
 	}
-
 }
 
-// Trim test PDF file so that only the first two pages are rendered.
-func TestTrimCommand(t *testing.T) {
+func ExampleProcess_replaceStream() {
 
-	cmd := TrimCommand("testdata/pike-stanford.pdf", outputDir+"/test.pdf", []string{"-2"}, types.NewDefaultConfiguration())
+	config := types.NewDefaultConfiguration()
+
+	// Replace the content stream of the first page with the content of content.txt.
+	cmd := ReplaceStreamCommand("in.pdf", "Root/Pages/Kids/0/Contents", "content.txt", "out.pdf", config)
 
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestTrimCommand: %v\n", err)
+		return
 	}
-
 }
 
-func TestExtractImagesCommand(t *testing.T) {
+func ExampleProcess_listLayers() {
+
+	config := types.NewDefaultConfiguration()
+
+	cmd := ListLayersCommand("in.pdf", config)
 
-	cmd := ExtractImagesCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestExtractImageCommand: %v\n", err)
+		return
 	}
-
 }
 
-func TestExtractFontsCommand(t *testing.T) {
+func ExampleProcess_hideLayers() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Turn off the layer "Watermark" in the default configuration.
+	cmd := HideLayersCommand("in.pdf", "out.pdf", []string{"Watermark"}, config)
 
-	cmd := ExtractFontsCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestExtractFontsCommand: %v\n", err)
+		return
 	}
-
 }
 
-func TestExtractContentCommand(t *testing.T) {
+func ExampleProcess_removeLayers() {
+
+	config := types.NewDefaultConfiguration()
+
+	// Remove the layer "Draft" along with its content.
+	cmd := RemoveLayersCommand("in.pdf", "out.pdf", []string{"Draft"}, config)
 
-	cmd := ExtractContentCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestExtractContentCommand: %v\n", err)
+		return
 	}
-
 }
 
-func TestExtractPagesCommand(t *testing.T) {
+func ExampleProcess_encrypt() {
+
+	config := types.NewDefaultConfiguration()
+
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	cmd := EncryptCommand("in.pdf", "out.pdf", config)
 
-	cmd := ExtractPagesCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, []string{"1"}, types.NewDefaultConfiguration())
 	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+		return
 	}
-
 }
 
-func TestEncryptDecrypt(t *testing.T) {
+func ExampleProcess_decrypt() {
 
-	files, err := ioutil.ReadDir("testdata")
+	config := types.NewDefaultConfiguration()
+
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	cmd := DecryptCommand("in.pdf", "out.pdf", config)
+
+	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestEncryptDecrypt: %v\n", err)
+		return
 	}
+}
 
-	for _, file := range files {
+func ExampleProcess_changeUserPW() {
 
-		if strings.HasSuffix(file.Name(), "pdf") {
+	config := types.NewDefaultConfiguration()
 
-			fin := "testdata/" + file.Name()
-			fmt.Println("\n" + fin)
-			f := outputDir + "/test.pdf"
+	// supply existing owner pw like so
+	config.OwnerPW = "opw"
 
-			// Encrypt
-			//fmt.Println("\nEncrypt")
-			config := types.NewDefaultConfiguration()
-			config.UserPW = "upw"
-			config.OwnerPW = "opw"
-			cmd := EncryptCommand(fin, f, config)
-			_, err := Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", err, f)
-			}
+	pwOld := "pwOld"
+	pwNew := "pwNew"
 
-			// Validate
-			//fmt.Println("\nValidate")
-			config = types.NewDefaultConfiguration()
-			config.UserPW = "upw"
-			config.OwnerPW = "opw"
-			cmd = ValidateCommand(f, config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", err, f)
-			}
+	cmd := ChangeUserPWCommand("in.pdf", "out.pdf", config, &pwOld, &pwNew)
 
-			// ChangeUserPW
-			//fmt.Println("\nChangeUserPW")
-			config = types.NewDefaultConfiguration()
-			config.OwnerPW = "opw"
-			pwOld := "upw"
-			pwNew := "upwNew"
-			cmd = ChangeUserPWCommand(f, f, config, &pwOld, &pwNew)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryption - change userPW: %v\n", err)
-			}
+	_, err := Process(&cmd)
+	if err != nil {
+		return
+	}
+}
 
-			// Validate
-			//fmt.Println("\nValidate")
-			config = types.NewDefaultConfiguration()
-			config.UserPW = "upwNew"
-			config.OwnerPW = "opw"
-			cmd = ValidateCommand(f, config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryption - validate: %v\n", err)
-			}
+func ExampleProcess_changeOwnerPW() {
 
-			// ChangeOwnerPW
-			//fmt.Println("\nChangeOwnerPW")
-			config = types.NewDefaultConfiguration()
-			config.UserPW = "upwNew"
-			pwOld = "opw"
-			pwNew = "opwNew"
-			cmd = ChangeOwnerPWCommand(f, f, config, &pwOld, &pwNew)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryption - change ownerPW: %v\n", err)
-			}
+	config := types.NewDefaultConfiguration()
 
-			// Validate
-			//fmt.Println("\nValidate")
-			config = types.NewDefaultConfiguration()
-			config.UserPW = "upwNew"
-			config.OwnerPW = "opwNew"
-			cmd = ValidateCommand(f, config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryption - validate: %v\n", err)
-			}
+	// supply existing user pw like so
+	config.UserPW = "upw"
 
-			// Decrypt
-			//fmt.Println("\nDecrypt")
-			config = types.NewDefaultConfiguration()
-			config.UserPW = "upwNew"
-			config.OwnerPW = "opwNew"
-			cmd = DecryptCommand(f, f, config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", err, f)
-			}
+	// old and new owner pw
+	pwOld := "pwOld"
+	pwNew := "pwNew"
 
-			// Validate
-			//fmt.Println("\nValidate")
-			config = types.NewDefaultConfiguration()
-			cmd = ValidateCommand(f, config)
-			_, err = Process(&cmd)
-			if err != nil {
-				t.Fatalf("TestEncryption - validate %s: %v\n", err, f)
-			}
+	cmd := ChangeOwnerPWCommand("in.pdf", "out.pdf", config, &pwOld, &pwNew)
 
-		}
+	_, err := Process(&cmd)
+	if err != nil {
+		return
 	}
 }
 
-func copyFile(srcFileName, destFileName string) (err error) {
+func TestMain(m *testing.M) {
+	os.Mkdir(outputDir, 0777)
 
-	from, err := os.Open(srcFileName)
-	if err != nil {
-		return
-	}
-	defer from.Close()
+	exitCode := m.Run()
 
-	to, err := os.OpenFile(destFileName, os.O_RDWR|os.O_CREATE, 0666)
+	err := os.RemoveAll(outputDir)
 	if err != nil {
-		return
+		fmt.Printf("%v", err)
+		os.Exit(1)
 	}
-	defer to.Close()
-
-	_, err = io.Copy(to, from)
 
-	return
+	os.Exit(exitCode)
 }
 
-func prepareForAttachmentTest(testDir string) (err error) {
+// Validate all PDFs in testdata.
+func TestValidateCommand(t *testing.T) {
 
-	testFile := testDir + "/go.pdf"
-	err = copyFile(testFile, outputDir+"/go.pdf")
+	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
-		return
+		t.Fatalf("TestValidateCommand: %v\n", err)
 	}
 
-	testFile = testDir + "/golang.pdf"
-	err = copyFile(testFile, outputDir+"/golang.pdf")
-	if err != nil {
-		return
-	}
+	config := types.NewDefaultConfiguration()
+	config.SetValidationRelaxed()
 
-	testFile = testDir + "/T4.pdf"
-	err = copyFile(testFile, outputDir+"/T4.pdf")
-	if err != nil {
-		return
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			cmd := ValidateCommand("testdata/"+file.Name(), config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestValidateCommand: %v\n", err)
+			}
+		}
 	}
 
-	return
 }
-func TestAttachments(t *testing.T) {
-
-	testDir := "testdata"
 
-	err := prepareForAttachmentTest(testDir)
-	if err != nil {
-		t.Fatalf("prepare for attachments: %v\n", err)
-	}
+func TestValidateOneFile(t *testing.T) {
 
 	config := types.NewDefaultConfiguration()
+	config.SetValidationRelaxed()
 
-	fileName := outputDir + "/go.pdf"
-
-	// attach list must be 0
-	cmd := ListAttachmentsCommand(fileName, config)
-	list, err := Process(&cmd)
+	cmd := ValidateCommand("testdata/gobook.0.pdf", config)
+	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
-	}
-	if len(list) > 0 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+		t.Fatalf("TestValidateOneFile: %v\n", err)
 	}
 
-	// attach add 2 files
+}
+
+func BenchmarkValidateCommand(b *testing.B) {
+
+	config := types.NewDefaultConfiguration()
+	config.SetValidationRelaxed()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		cmd := ValidateCommand("testdata/gobook.0.pdf", config)
+		_, err := Process(&cmd)
+		if err != nil {
+			b.Fatalf("BenchmarkValidateCommand: %v\n", err)
+		}
+	}
+}
+
+// Optimize all PDFs in testdata and write with (default) end of line sequence "\n".
+func TestOptimizeCommandWithLF(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+
+	// this is not necessary but to make it clearer.
+	config.Eol = types.EolLF
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeCommand: %v\n", err)
+			}
+		}
+	}
+
+}
+
+// Optimize all PDFs in testdata and write with end of line sequence "\r".
+func TestOptimizeCommandWithCR(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+	config.Eol = types.EolCR
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeCommand: %v\n", err)
+			}
+		}
+	}
+
+}
+
+// Optimize all PDFs in testdata and write with end of line sequence "\r".
+// This test writes out the cross reference table the old way without using object streams and an xref stream.
+func TestOptimizeCommandWithCRAndNoXrefStream(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+	config.Eol = types.EolCR
+	config.WriteObjectStream = false
+	config.WriteXRefStream = false
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeCommand: %v\n", err)
+			}
+		}
+	}
+
+}
+
+// Optimize all PDFs in testdata and write with end of line sequence "\r\n".
+func TestOptimizeCommandWithCRLF(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestOptimizeCommmand: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+	config.Eol = types.EolCRLF
+	config.StatsFileName = outputDir + "/testStats.csv"
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			cmd := OptimizeCommand("testdata/"+file.Name(), outputDir+"/test.pdf", config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeCommand: %v\n", err)
+			}
+		}
+	}
+
+}
+
+// Downsample images of test PDFs and make sure the results get smaller and validate.
+func TestOptimizeImages(t *testing.T) {
+
+	for _, quality := range []int{0, 60} {
+
+		for _, fileName := range []string{"gobook.0.pdf", "TheGoProgrammingLanguageCh1_1.pdf"} {
+
+			config := types.NewDefaultConfiguration()
+			config.ImageDPI = 100
+			config.ImageQuality = quality
+
+			fileIn, fileOut := "testdata/"+fileName, outputDir+"/test.pdf"
+
+			cmd := OptimizeCommand(fileIn, fileOut, config)
+			_, err := Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeImages: %v\n", err)
+			}
+
+			fi, err := os.Stat(fileIn)
+			if err != nil {
+				t.Fatalf("TestOptimizeImages: %v\n", err)
+			}
+
+			fo, err := os.Stat(fileOut)
+			if err != nil {
+				t.Fatalf("TestOptimizeImages: %v\n", err)
+			}
+
+			if fo.Size() >= fi.Size()/2 {
+				t.Fatalf("TestOptimizeImages: %s: expected significant savings, got %d -> %d bytes\n", fileName, fi.Size(), fo.Size())
+			}
+
+			cmd = ValidateCommand(fileOut, types.NewDefaultConfiguration())
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestOptimizeImages: %v\n", err)
+			}
+		}
+	}
+}
+
+func plainText(t *testing.T, fileName string) []string {
+
+	ctx, err := Read(fileName, types.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("plainText: %v\n", err)
+	}
+
+	// The page count is only known after validation.
+	pages, err := ctx.PageList()
+	if err != nil {
+		t.Fatalf("plainText: %v\n", err)
+	}
+
+	var text []string
+
+	for i := 1; i <= len(pages); i++ {
+		runs, err := extract.PageTextRuns(ctx, i)
+		if err != nil {
+			t.Fatalf("plainText: %v\n", err)
+		}
+		text = append(text, extract.PlainText(runs))
+	}
+
+	if len(text) == 0 {
+		t.Fatalf("plainText: %s: no pages\n", fileName)
+	}
+
+	return text
+}
+
+func TestOptimizeFonts(t *testing.T) {
+
+	for _, fileName := range []string{"go.pdf", "The_Go_Language_Gigon-Odienne-Wartel.pdf", "RA_CI.pdf", "xdp_2.0.pdf"} {
+
+		fileIn := "testdata/" + fileName
+		fileOut, fileOutSubset := outputDir+"/test.pdf", outputDir+"/testSubset.pdf"
+
+		cmd := OptimizeCommand(fileIn, fileOut, types.NewDefaultConfiguration())
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFonts: %v\n", err)
+		}
+
+		config := types.NewDefaultConfiguration()
+		config.SubsetFonts = true
+
+		cmd = OptimizeCommand(fileIn, fileOutSubset, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFonts: %v\n", err)
+		}
+
+		fo, err := os.Stat(fileOut)
+		if err != nil {
+			t.Fatalf("TestOptimizeFonts: %v\n", err)
+		}
+
+		fs, err := os.Stat(fileOutSubset)
+		if err != nil {
+			t.Fatalf("TestOptimizeFonts: %v\n", err)
+		}
+
+		if fs.Size() >= fo.Size() {
+			t.Fatalf("TestOptimizeFonts: %s: expected savings, got %d -> %d bytes\n", fileName, fo.Size(), fs.Size())
+		}
+
+		cmd = ValidateCommand(fileOutSubset, types.NewDefaultConfiguration())
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFonts: %v\n", err)
+		}
+
+		// Subsetting must not affect the text of the document.
+		text, textSubset := plainText(t, fileIn), plainText(t, fileOutSubset)
+		for i := range text {
+			if text[i] != textSubset[i] {
+				t.Fatalf("TestOptimizeFonts: %s: text of page %d differs\n", fileName, i+1)
+			}
+		}
+	}
+}
+
+// fontCount returns the number of font dicts for a font regardless of any subset tag.
+func fontCount(t *testing.T, fileName, baseFont string) (n int) {
+
+	ctx, err := Read(fileName, types.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("fontCount: %v\n", err)
+	}
+
+	for _, entry := range ctx.Table {
+
+		if entry == nil || entry.Free {
+			continue
+		}
+
+		d, ok := entry.Object.(types.PDFDict)
+		if !ok || d.Type() == nil || *d.Type() != "Font" {
+			continue
+		}
+
+		if name := d.NameEntry("BaseFont"); name != nil && strings.HasSuffix(*name, "+"+baseFont) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Text shown by a form using the font set before invoking the form must survive subsetting.
+func TestOptimizeFontsInheritedByForm(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+	fileIn, fileOut := outputDir+"/testForm.pdf", outputDir+"/testFormSubset.pdf"
+
+	cmd := TrimCommand("testdata/RA_CI.pdf", fileIn, []string{"1"}, config)
+	if _, err := Process(&cmd); err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	pageDict, _, err := ctx.PageDict(1)
+	if err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	stream := func(d types.PDFDict, content string) types.PDFIndirectRef {
+		sd := types.NewPDFStreamDict(d, 0, nil, nil, nil)
+		if err := filter.ReplaceContent(&sd, []byte(content)); err != nil {
+			t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+		}
+		objNr, err := ctx.InsertObject(sd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+		}
+		return types.NewPDFIndirectRef(objNr, 0)
+	}
+
+	// The form shows w (119) using TT0 set by the page.
+	form := types.NewPDFDict()
+	form.Insert("Type", types.PDFName("XObject"))
+	form.Insert("Subtype", types.PDFName("Form"))
+	form.Insert("BBox", types.PDFArray{types.PDFInteger(0), types.PDFInteger(0), types.PDFInteger(612), types.PDFInteger(792)})
+	form.Insert("Resources", types.NewPDFDict())
+
+	resources, err := ctx.DereferenceDict(pageDict.Dict["Resources"])
+	if err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	xObjs, err := ctx.DereferenceDict(resources.Dict["XObject"])
+	if err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+	xObjs.Insert("FmT", stream(form, "BT 72 700 Td (w) Tj ET"))
+
+	pageDict.Update("Contents", stream(types.NewPDFDict(), "BT /TT0 12 Tf ET /FmT Do"))
+
+	ctx.Write.DirName = outputDir + "/"
+	ctx.Write.FileName = "testForm.pdf"
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	config = types.NewDefaultConfiguration()
+	config.SubsetFonts = true
+
+	cmd = OptimizeCommand(fileIn, fileOut, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	if ctx, err = Read(fileOut, config); err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	obj, _, err := object.Resolve(ctx, "Root/Pages/Kids/0/Resources/Font/TT0")
+	if err != nil {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: %v\n", err)
+	}
+
+	fontDict := obj.(types.PDFDict)
+	if first, last := fontDict.IntEntry("FirstChar"), fontDict.IntEntry("LastChar"); first == nil || *first != 119 || last == nil || *last != 119 {
+		t.Fatalf("TestOptimizeFontsInheritedByForm: glyph missing: %v\n", fontDict)
+	}
+}
+
+func TestOptimizeFontSubsets(t *testing.T) {
+
+	for _, tc := range []struct {
+		fileName, baseFont string
+	}{
+		{"pike-stanford.pdf", "Monaco"},
+		{"GoForOptimization.pdf", "Consolas"},
+	} {
+
+		fileIn := "testdata/" + tc.fileName
+		fileOut := outputDir + "/test.pdf"
+
+		if fontCount(t, fileIn, tc.baseFont) < 2 {
+			t.Fatalf("TestOptimizeFontSubsets: %s: expected several subsets of %s\n", tc.fileName, tc.baseFont)
+		}
+
+		cmd := OptimizeCommand(fileIn, fileOut, types.NewDefaultConfiguration())
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFontSubsets: %v\n", err)
+		}
+
+		if n := fontCount(t, fileOut, tc.baseFont); n != 1 {
+			t.Fatalf("TestOptimizeFontSubsets: %s: expected a single font %s, got %d\n", tc.fileName, tc.baseFont, n)
+		}
+
+		cmd = ValidateCommand(fileOut, types.NewDefaultConfiguration())
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeFontSubsets: %v\n", err)
+		}
+
+		// Merging subsets must not affect the text of the document.
+		text, textOut := plainText(t, fileIn), plainText(t, fileOut)
+		for i := range text {
+			if text[i] != textOut[i] {
+				t.Fatalf("TestOptimizeFontSubsets: %s: text of page %d differs\n", tc.fileName, i+1)
+			}
+		}
+	}
+}
+
+func TestOptimizePrune(t *testing.T) {
+
+	for _, fileName := range []string{"pike-stanford.pdf", "TheGoProgrammingLanguageCh1_1.pdf", "form.pdf"} {
+
+		fileIn := "testdata/" + fileName
+		fileOut, fileOutPruned := outputDir+"/test.pdf", outputDir+"/testPruned.pdf"
+
+		cmd := OptimizeCommand(fileIn, fileOut, types.NewDefaultConfiguration())
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+
+		config := types.NewDefaultConfiguration()
+		config.Prune = true
+
+		cmd = OptimizeCommand(fileIn, fileOutPruned, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+
+		fo, err := os.Stat(fileOut)
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+
+		fp, err := os.Stat(fileOutPruned)
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+
+		if fp.Size() >= fo.Size() {
+			t.Fatalf("TestOptimizePrune: %s: expected savings, got %d -> %d bytes\n", fileName, fo.Size(), fp.Size())
+		}
+
+		cmd = ValidateCommand(fileOutPruned, types.NewDefaultConfiguration())
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+
+		// Remaining objects are numbered consecutively.
+		ctx, err := Read(fileOutPruned, types.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestOptimizePrune: %v\n", err)
+		}
+		for i := 1; i < *ctx.Size; i++ {
+			if entry, found := ctx.Find(i); !found || entry.Free {
+				t.Fatalf("TestOptimizePrune: %s: missing obj#%d\n", fileName, i)
+			}
+		}
+
+		// Pruning must not affect the text of the document.
+		text, textPruned := plainText(t, fileIn), plainText(t, fileOutPruned)
+		for i := range text {
+			if text[i] != textPruned[i] {
+				t.Fatalf("TestOptimizePrune: %s: text of page %d differs\n", fileName, i+1)
+			}
+		}
+	}
+}
+
+func TestOptimizeStreams(t *testing.T) {
+
+	for _, fileName := range []string{"annotTest.pdf", "pike-stanford.pdf", "Acroforms2.pdf"} {
+
+		fileIn := "testdata/" + fileName
+		fileOut, fileOutRecompressed := outputDir+"/test.pdf", outputDir+"/testRecompressed.pdf"
+
+		cmd := OptimizeCommand(fileIn, fileOut, types.NewDefaultConfiguration())
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeStreams: %v\n", err)
+		}
+
+		config := types.NewDefaultConfiguration()
+		config.Recompress = true
+
+		cmd = OptimizeCommand(fileIn, fileOutRecompressed, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeStreams: %v\n", err)
+		}
+
+		fo, err := os.Stat(fileOut)
+		if err != nil {
+			t.Fatalf("TestOptimizeStreams: %v\n", err)
+		}
+
+		fr, err := os.Stat(fileOutRecompressed)
+		if err != nil {
+			t.Fatalf("TestOptimizeStreams: %v\n", err)
+		}
+
+		if fr.Size() >= fo.Size() {
+			t.Fatalf("TestOptimizeStreams: %s: expected savings, got %d -> %d bytes\n", fileName, fo.Size(), fr.Size())
+		}
+
+		cmd = ValidateCommand(fileOutRecompressed, types.NewDefaultConfiguration())
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeStreams: %v\n", err)
+		}
+
+		// Minifying content streams must not affect the text of the document.
+		text, textRecompressed := plainText(t, fileIn), plainText(t, fileOutRecompressed)
+		for i := range text {
+			if text[i] != textRecompressed[i] {
+				t.Fatalf("TestOptimizeStreams: %s: text of page %d differs\n", fileName, i+1)
+			}
+		}
+	}
+}
+
+func TestOptimizeLinearize(t *testing.T) {
+
+	for _, fileName := range []string{"annotTest.pdf", "pike-stanford.pdf", "Acroforms2.pdf"} {
+
+		fileIn := "testdata/" + fileName
+		fileOut := outputDir + "/testLinearized.pdf"
+
+		config := types.NewDefaultConfiguration()
+		config.Linearize = true
+
+		cmd := OptimizeCommand(fileIn, fileOut, config)
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeLinearize: %v\n", err)
+		}
+
+		cmd = ValidateCommand(fileOut, types.NewDefaultConfiguration())
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestOptimizeLinearize: %v\n", err)
+		}
+
+		ctx, err := Read(fileOut, types.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("TestOptimizeLinearize: %v\n", err)
+		}
+
+		if !ctx.Read.Linearized || len(ctx.LinearizationObjs) != 1 {
+			t.Fatalf("TestOptimizeLinearize: %s: missing linearization dict\n", fileName)
+		}
+
+		var d *types.PDFDict
+		for objNr := range ctx.LinearizationObjs {
+			d, err = ctx.DereferenceDict(types.NewPDFIndirectRef(objNr, 0))
+			if err != nil {
+				t.Fatalf("TestOptimizeLinearize: %v\n", err)
+			}
+		}
+
+		pages, err := ctx.PageList()
+		if err != nil {
+			t.Fatalf("TestOptimizeLinearize: %v\n", err)
+		}
+
+		if *d.IntEntry("L") != int(ctx.Read.FileSize) ||
+			*d.IntEntry("N") != len(pages) ||
+			*d.IntEntry("O") != pages[0].ObjectNumber.Value() {
+			t.Fatalf("TestOptimizeLinearize: %s: corrupt linearization dict: %s\n", fileName, d)
+		}
+
+		text, textLinearized := plainText(t, fileIn), plainText(t, fileOut)
+		for i := range text {
+			if text[i] != textLinearized[i] {
+				t.Fatalf("TestOptimizeLinearize: %s: text of page %d differs\n", fileName, i+1)
+			}
+		}
+	}
+}
+
+func TestOptimizeReport(t *testing.T) {
+
+	fileIn := "testdata/OptimizeTest.pdf"
+	fileOut := outputDir + "/testReport.pdf"
+	fileReport := outputDir + "/testReport.json"
+
+	config := types.NewDefaultConfiguration()
+	config.ImageDPI = 72
+	config.Prune = true
+	config.ReportFileName = fileReport
+
+	report, err := Optimize(fileIn, fileOut, config)
+	if err != nil {
+		t.Fatalf("TestOptimizeReport: %v\n", err)
+	}
+
+	bb, err := ioutil.ReadFile(fileReport)
+	if err != nil {
+		t.Fatalf("TestOptimizeReport: %v\n", err)
+	}
+
+	var r types.OptimizeReport
+	err = json.Unmarshal(bb, &r)
+	if err != nil {
+		t.Fatalf("TestOptimizeReport: %v\n", err)
+	}
+
+	if !reflect.DeepEqual(r, *report) {
+		t.Fatalf("TestOptimizeReport: report file differs from returned report\n")
+	}
+
+	for _, f := range []struct {
+		fileName string
+		fr       types.FileReport
+	}{
+		{fileIn, r.Source},
+		{fileOut, r.Destination},
+	} {
+		fi, err := os.Stat(f.fileName)
+		if err != nil {
+			t.Fatalf("TestOptimizeReport: %v\n", err)
+		}
+		if f.fr.Size != fi.Size() || f.fr.Images+f.fr.Fonts+f.fr.Other != f.fr.Size {
+			t.Fatalf("TestOptimizeReport: %s: wrong sizes: %+v\n", f.fileName, f.fr)
+		}
+	}
+
+	if r.Duplicates.Images == 0 || !r.Source.XRefStream || r.Source.ObjectStreams == 0 {
+		t.Fatalf("TestOptimizeReport: missing source stats: %+v %+v\n", r.Source, r.Duplicates)
+	}
+
+	if len(r.Fonts) == 0 || len(r.Images) == 0 {
+		t.Fatalf("TestOptimizeReport: missing fonts or images\n")
+	}
+
+	var size int64
+	for _, img := range r.Images {
+		if img.Removed {
+			continue
+		}
+		if img.Width >= img.SourceWidth || img.Size >= img.SourceSize {
+			t.Fatalf("TestOptimizeReport: image %d not downsampled: %+v\n", img.ObjNr, img)
+		}
+		size += img.Size
+	}
+
+	if size != r.Destination.Images {
+		t.Fatalf("TestOptimizeReport: image sizes %d don't add up to %d\n", size, r.Destination.Images)
+	}
+}
+
+// Split a test PDF file up into single page PDFs.
+func TestSplitCommand(t *testing.T) {
+
+	cmd := SplitCommand("testdata/Acroforms2.pdf", outputDir, types.NewDefaultConfiguration())
+
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestSplitCommand: %v\n", err)
+	}
+}
+
+// splitFiles returns the page counts of the files in dir by file name.
+func splitFiles(t *testing.T, dir string) map[string]int {
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("splitFiles: %v\n", err)
+	}
+
+	m := map[string]int{}
+	for _, f := range files {
+		ctx, err := Read(dir+"/"+f.Name(), types.NewDefaultConfiguration())
+		if err != nil {
+			t.Fatalf("splitFiles: %v\n", err)
+		}
+		obj, _, err := object.Resolve(ctx, "Root/Pages/Count")
+		if err != nil {
+			t.Fatalf("splitFiles: %v\n", err)
+		}
+		m[f.Name()] = int(obj.(types.PDFInteger))
+	}
+
+	return m
+}
+
+// Split a test PDF file by page count, at page numbers, by bookmarks and by file size.
+func TestSplitModes(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+	fileIn := "testdata/adobe_errata.pdf"
+	dir := outputDir + "/split"
+
+	for _, tt := range []struct {
+		cmd  Command
+		want map[string]int
+	}{
+		{SplitSpanCommand(fileIn, dir, 5, config),
+			map[string]int{"adobe_errata_1-5.pdf": 5, "adobe_errata_6-10.pdf": 5, "adobe_errata_11-15.pdf": 5, "adobe_errata_16-18.pdf": 3}},
+		{SplitAtPagesCommand(fileIn, dir, []int{10, 3}, config),
+			map[string]int{"adobe_errata_1-2.pdf": 2, "adobe_errata_3-9.pdf": 7, "adobe_errata_10-18.pdf": 9}},
+		{SplitByBookmarksCommand(fileIn, dir, 1, config),
+			map[string]int{"adobe_errata_1-2.pdf": 2, "Errata for the PDF Reference, sixth edition, version 1.7.pdf": 16}},
+	} {
+		os.RemoveAll(dir)
+		os.MkdirAll(dir, 0777)
+
+		if _, err := Process(&tt.cmd); err != nil {
+			t.Fatalf("TestSplitModes: %v\n", err)
+		}
+
+		if got := splitFiles(t, dir); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("TestSplitModes: got %v, want %v\n", got, tt.want)
+		}
+	}
+
+	// Outline level 3 files are named after the section titles.
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0777)
+
+	cmd := SplitByBookmarksCommand(fileIn, dir, 3, config)
+	if _, err := Process(&cmd); err != nil {
+		t.Fatalf("TestSplitModes: %v\n", err)
+	}
+
+	if _, found := splitFiles(t, dir)["3.5, Encryption.pdf"]; !found {
+		t.Fatalf("TestSplitModes: missing bookmark file\n")
+	}
+
+	// Split by size.
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0777)
+
+	maxSize := int64(60 * types.KB)
+
+	cmd = SplitBySizeCommand(fileIn, dir, maxSize, config)
+	if _, err := Process(&cmd); err != nil {
+		t.Fatalf("TestSplitModes: %v\n", err)
+	}
+
+	pageCount := 0
+	for fileName, n := range splitFiles(t, dir) {
+		fi, err := os.Stat(dir + "/" + fileName)
+		if err != nil {
+			t.Fatalf("TestSplitModes: %v\n", err)
+		}
+		if n > 1 && fi.Size() > maxSize {
+			t.Fatalf("TestSplitModes: %s: %d bytes exceeds %d bytes\n", fileName, fi.Size(), maxSize)
+		}
+		pageCount += n
+	}
+
+	if pageCount != 18 {
+		t.Fatalf("TestSplitModes: expected 18 pages, got %d\n", pageCount)
+	}
+
+	// Layers and the annotations of the pages split off are kept.
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0777)
+
+	for _, fileName := range []string{"CenterOfWhy.pdf", "BuildingWebappsWithGo.pdf"} {
+		cmd = SplitSpanCommand("testdata/"+fileName, dir, 10, config)
+		if _, err := Process(&cmd); err != nil {
+			t.Fatalf("TestSplitModes: %v\n", err)
+		}
+	}
+
+	cmd = ListLayersCommand(dir+"/CenterOfWhy_1-10.pdf", config)
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestSplitModes: %v\n", err)
+	}
+
+	if len(out) != 1 || !strings.Contains(out[0], "Headers/Footers") {
+		t.Fatalf("TestSplitModes: layers: %v\n", out)
+	}
+
+	cmd = ListAnnotationsCommand(dir+"/BuildingWebappsWithGo_1-10.pdf", nil, nil, config)
+	if out, err = Process(&cmd); err != nil {
+		t.Fatalf("TestSplitModes: %v\n", err)
+	}
+
+	if len(out) == 0 || !strings.Contains(strings.Join(out, "\n"), "https://golang.org") {
+		t.Fatalf("TestSplitModes: annotations: %v\n", out)
+	}
+
+	for fileName, n := range splitFiles(t, dir) {
+		if m := pageDictCount(t, dir+"/"+fileName); m != n {
+			t.Fatalf("TestSplitModes: %s: %d pages, %d page dicts\n", fileName, n, m)
+		}
+	}
+}
+
+// Merge all PDFs in testdir into out/test.pdf.
+func TestMergeCommand(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestMergeCommmand: %v\n", err)
+	}
+
+	inFiles := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), "pdf") {
+			inFiles = append(inFiles, "testdata/"+file.Name())
+		}
+	}
+
+	cmd := MergeCommand(inFiles, outputDir+"/test.pdf", types.NewDefaultConfiguration())
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestMergeCommand: %v\n", err)
+	}
+
+}
+
+// Merge files with forms, named destinations and page labels into themselves.
+func TestMergeCatalogs(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+	fileOut := outputDir + "/testMergeCatalogs.pdf"
+
+	cmd := MergeCommand([]string{"testdata/form.pdf", "testdata/form.pdf"}, fileOut, config)
+	if _, err := Process(&cmd); err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	cmd = ListFormFieldsCommand(fileOut, "", config)
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	fields := strings.Join(out, "\n")
+	if len(out) != 16 || !strings.Contains(fields, "name_2 (text)") || !strings.Contains(fields, "address_2.zip (text)") {
+		t.Fatalf("TestMergeCatalogs: fields missing:\n%s\n", fields)
+	}
+
+	cmd = MergeCommand([]string{"testdata/adobe_errata.pdf", "testdata/adobe_errata.pdf"}, fileOut, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	ctx, err := Read(fileOut, config)
+	if err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	obj, _, err := object.Resolve(ctx, "Root/Names/Dests/Names")
+	if err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	names := fmt.Sprintf("%v", obj)
+	if !strings.Contains(names, "(F1) ") || !strings.Contains(names, "(F1_2) ") {
+		t.Fatalf("TestMergeCatalogs: named destinations missing: %s\n", names)
+	}
+
+	obj, _, err = object.Resolve(ctx, "Root/PageLabels/Nums")
+	if err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	nums := obj.(types.PDFArray)
+	if len(nums) != 4 || nums[2] != types.PDFInteger(18) {
+		t.Fatalf("TestMergeCatalogs: page labels: %v\n", nums)
+	}
+
+	fileHidden := outputDir + "/testMergeCatalogsHidden.pdf"
+	cmd = HideLayersCommand("testdata/CenterOfWhy.pdf", fileHidden, nil, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	cmd = MergeCommand([]string{"testdata/xdp_2.0.pdf", fileHidden}, fileOut, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	cmd = ListLayersCommand(fileOut, config)
+	if out, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergeCatalogs: %v\n", err)
+	}
+
+	layers := strings.Join(out, "\n")
+	if len(out) != 2 || !strings.Contains(layers, "HeaderFooter (on)") || !strings.Contains(layers, "Headers/Footers (off)") {
+		t.Fatalf("TestMergeCatalogs: layers:\n%s\n", layers)
+	}
+}
+
+func TestMergePageSelections(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+	fileOut := outputDir + "/testMergePageSelections.pdf"
+
+	filesIn := []string{"testdata/adobe_errata.pdf:5,7-", "testdata/form.pdf", "testdata/adobe_errata.pdf:-2"}
+
+	cmd := MergeCommand(filesIn, fileOut, config)
+	if _, err := Process(&cmd); err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	ctx, err := Read(fileOut, config)
+	if err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	obj, _, err := object.Resolve(ctx, "Root/Pages/Count")
+	if err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	if obj != types.PDFInteger(16) {
+		t.Fatalf("TestMergePageSelections: expected 16 pages, got %v\n", obj)
+	}
+
+	// Label ranges start at the first page of each input and where the selection skips a page.
+	obj, _, err = object.Resolve(ctx, "Root/PageLabels/Nums")
+	if err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	nums := obj.(types.PDFArray)
+	if len(nums) != 8 || nums[2] != types.PDFInteger(1) || nums[4] != types.PDFInteger(13) || nums[6] != types.PDFInteger(14) {
+		t.Fatalf("TestMergePageSelections: page labels: %v\n", nums)
+	}
+
+	// Form fields and named destinations of dropped pages get removed.
+	fileForms := outputDir + "/testMergePageSelectionsForms.pdf"
+	cmd = MergeCommand([]string{"testdata/form.pdf", "testdata/form.pdf"}, fileForms, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	cmd = MergeCommand([]string{fileForms + ":2", "testdata/adobe_errata.pdf:-2"}, fileOut, config)
+	if _, err = Process(&cmd); err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	cmd = ListFormFieldsCommand(fileOut, "", config)
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	fields := strings.Join(out, "\n")
+	if len(out) != 8 || !strings.Contains(fields, "name_2 (text)") {
+		t.Fatalf("TestMergePageSelections: fields:\n%s\n", fields)
+	}
+
+	if ctx, err = Read(fileOut, config); err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	if obj, _, err = object.Resolve(ctx, "Root/Names/Dests/Names"); err != nil {
+		t.Fatalf("TestMergePageSelections: %v\n", err)
+	}
+
+	names := fmt.Sprintf("%v", obj)
+	if !strings.Contains(names, "(P.2) ") || strings.Contains(names, "(P.3) ") {
+		t.Fatalf("TestMergePageSelections: named destinations: %s\n", names)
+	}
+
+	// Outline items, links and article threads must not keep dropped pages.
+	for _, fileName := range []string{"testdata/adobe_errata.pdf", "testdata/BuildingWebappsWithGo.pdf", "testdata/CenterOfWhy.pdf"} {
+
+		if ctx, err = Read(fileName, config); err != nil {
+			t.Fatalf("TestMergePageSelections: %v\n", err)
+		}
+
+		// Read does not count pages.
+		pages, err := ctx.PageList()
+		if err != nil {
+			t.Fatalf("TestMergePageSelections: %v\n", err)
+		}
+		ctx.PageCount = len(pages)
+
+		if err = merge.SelectPages(ctx, types.IntSet{2: true, 3: true}, false); err != nil {
+			t.Fatalf("TestMergePageSelections: %v\n", err)
+		}
+
+		// The structure tree gets dropped on merge.
+		root, err := ctx.Catalog()
+		if err != nil {
+			t.Fatalf("TestMergePageSelections: %v\n", err)
+		}
+		root.Delete("StructTreeRoot")
+
+		ctx.Write.DirName = outputDir + "/"
+		ctx.Write.FileName = "testMergePageSelections.pdf"
+		if err = Write(ctx); err != nil {
+			t.Fatalf("TestMergePageSelections: %v\n", err)
+		}
+
+		if n := pageDictCount(t, fileOut); n != 2 {
+			t.Fatalf("TestMergePageSelections: %s: expected 2 page dicts, got %d\n", fileName, n)
+		}
+
+		cmd = ValidateCommand(fileOut, config)
+		if _, err = Process(&cmd); err != nil {
+			t.Fatalf("TestMergePageSelections: %s: %v\n", fileName, err)
+		}
+	}
+
+	cmd = MergeCommand([]string{"testdata/form.pdf:2-", "testdata/form.pdf"}, fileOut, config)
+	if _, err = Process(&cmd); err == nil {
+		t.Fatal("TestMergePageSelections: expected error for empty page selection\n")
+	}
+}
+
+// pageDictCount returns the number of page dicts written to fileName.
+func pageDictCount(t *testing.T, fileName string) (n int) {
+
+	ctx, err := Read(fileName, types.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("pageDictCount: %v\n", err)
+	}
+
+	for _, entry := range ctx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		if d, ok := entry.Object.(types.PDFDict); ok && d.Type() != nil && *d.Type() == "Page" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// pageContentSizes returns the raw content stream size of each page of fileName for identifying pages.
+func pageContentSizes(t *testing.T, fileName string) []int {
+
+	ctx, err := Read(fileName, types.NewDefaultConfiguration())
+	if err != nil {
+		t.Fatalf("pageContentSizes: %v\n", err)
+	}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		t.Fatalf("pageContentSizes: %v\n", err)
+	}
+
+	var sizes []int
+	for _, indRef := range pages {
+		pageDict, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			t.Fatalf("pageContentSizes: %v\n", err)
+		}
+		sd, err := ctx.DereferenceStreamDict(*pageDict.IndirectRefEntry("Contents"))
+		if err != nil {
+			t.Fatalf("pageContentSizes: %v\n", err)
+		}
+		sizes = append(sizes, len(sd.Raw))
+	}
+
+	return sizes
+}
+
+func TestMergeInterleave(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+	fileOut := outputDir + "/testMergeInterleave.pdf"
+
+	s := pageContentSizes(t, "testdata/adobe_errata.pdf")
+
+	for _, tt := range []struct {
+		mode    int
+		filesIn []string
+		want    []int
+	}{
+		{types.MergeInterleave,
+			[]string{"testdata/adobe_errata.pdf:1-3", "testdata/adobe_errata.pdf:4-5"},
+			[]int{s[0], s[3], s[1], s[4], s[2]}},
+		{types.MergeInterleave,
+			[]string{"testdata/adobe_errata.pdf:1-3", "testdata/adobe_errata.pdf:r4-6"},
+			[]int{s[0], s[5], s[1], s[4], s[2], s[3]}},
+		{types.MergeZip,
+			[]string{"testdata/adobe_errata.pdf:1-3", "testdata/adobe_errata.pdf:4-6"},
+			[]int{s[0], s[5], s[1], s[4], s[2], s[3]}},
+		{types.MergeAppend,
+			[]string{"testdata/adobe_errata.pdf:r1-2", "testdata/adobe_errata.pdf:4"},
+			[]int{s[1], s[0], s[3]}},
+	} {
+		config.MergeMode = tt.mode
+		cmd := MergeCommand(tt.filesIn, fileOut, config)
+		if _, err := Process(&cmd); err != nil {
+			t.Fatalf("TestMergeInterleave %s %v: %v\n", config.MergeModeString(), tt.filesIn, err)
+		}
+
+		if got := pageContentSizes(t, fileOut); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("TestMergeInterleave %s %v: got %v, want %v\n", config.MergeModeString(), tt.filesIn, got, tt.want)
+		}
+	}
+}
+
+// Trim test PDF file so that only the first two pages are rendered.
+func TestTrimCommand(t *testing.T) {
+
+	cmd := TrimCommand("testdata/pike-stanford.pdf", outputDir+"/test.pdf", []string{"-2"}, types.NewDefaultConfiguration())
+
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestTrimCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractImagesCommand(t *testing.T) {
+
+	cmd := ExtractImagesCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestExtractImageCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractFontsCommand(t *testing.T) {
+
+	cmd := ExtractFontsCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestExtractFontsCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractContentCommand(t *testing.T) {
+
+	cmd := ExtractContentCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, types.NewDefaultConfiguration())
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestExtractContentCommand: %v\n", err)
+	}
+
+}
+
+func TestExtractTextCommand(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	for _, jsonOutput := range []bool{false, true} {
+
+		cmd := ExtractTextCommand("testdata/hoare_1978.pdf", outputDir, []string{"1"}, jsonOutput, config)
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestExtractTextCommand: %v\n", err)
+		}
+	}
+
+	bb, err := ioutil.ReadFile(outputDir + "/text_p1.txt")
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	if !strings.Contains(string(bb), "Communicating \nSequential Processes") {
+		t.Fatalf("TestExtractTextCommand: missing title in:\n%s\n", bb)
+	}
+
+	bb, err = ioutil.ReadFile(outputDir + "/text_p1.json")
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	if !strings.Contains(string(bb), `"fontSize"`) {
+		t.Fatalf("TestExtractTextCommand: missing font size in:\n%s\n", bb)
+	}
+
+	cmd := ExtractTextCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, nil, false, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	// A page tree node referring to itself must not recurse forever.
+	ctx, err := Read("testdata/hoare_1978.pdf", config)
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	indRef, err := ctx.Pages()
+	if err != nil || indRef == nil {
+		t.Fatalf("TestExtractTextCommand: missing page tree: %v\n", err)
+	}
+
+	pagesDict, err := ctx.DereferenceDict(*indRef)
+	if err != nil {
+		t.Fatalf("TestExtractTextCommand: %v\n", err)
+	}
+
+	kids := pagesDict.PDFArrayEntry("Kids")
+	pagesDict.Update("Kids", append(*kids, *indRef))
+
+	if _, err = ctx.PageList(); err == nil {
+		t.Fatalf("TestExtractTextCommand: page tree cycle should fail\n")
+	}
+}
+
+func TestExtractPagesCommand(t *testing.T) {
+
+	cmd := ExtractPagesCommand("testdata/TheGoProgrammingLanguageCh1.pdf", outputDir, []string{"1"}, types.NewDefaultConfiguration())
+	_, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestExtractPagesCommand: %v\n", err)
+	}
+
+}
+
+func TestInfo(t *testing.T) {
+
+	fileName := "testdata/form.pdf"
+
+	cmd := InfoCommand(fileName, false, false, types.NewDefaultConfiguration())
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	if !strings.Contains(strings.Join(out, "\n"), "Form: yes") {
+		t.Fatalf("TestInfo: form missing:\n%s\n", strings.Join(out, "\n"))
+	}
+
+	cmd = InfoCommand(fileName, true, true, types.NewDefaultConfiguration())
+	out, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	var pdfInfo info.Info
+	err = json.Unmarshal([]byte(strings.Join(out, "")), &pdfInfo)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	if pdfInfo.PageCount == 0 || len(pdfInfo.Pages) != pdfInfo.PageCount || pdfInfo.Pages[0].MediaBox == nil {
+		t.Fatalf("TestInfo: corrupt pages: %+v\n", pdfInfo)
+	}
+
+	if len(pdfInfo.Fonts) != pdfInfo.FontCount || !pdfInfo.Form || pdfInfo.Encryption != nil {
+		t.Fatalf("TestInfo: %+v\n", pdfInfo)
+	}
+
+	fileOut := outputDir + "/testInfoEnc.pdf"
+
+	config := types.NewDefaultConfiguration()
+	config.UserPW = "upw"
+	config.OwnerPW = "opw"
+
+	cmd = EncryptCommand(fileName, fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	config = types.NewDefaultConfiguration()
+	config.UserPW = "upw"
+
+	cmd = InfoCommand(fileOut, false, true, config)
+	out, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	pdfInfo = info.Info{}
+	err = json.Unmarshal([]byte(strings.Join(out, "")), &pdfInfo)
+	if err != nil {
+		t.Fatalf("TestInfo: %v\n", err)
+	}
+
+	if pdfInfo.Encryption == nil || len(pdfInfo.Encryption.Permissions) == 0 || pdfInfo.Pages != nil {
+		t.Fatalf("TestInfo: %+v\n", pdfInfo)
+	}
+}
+
+func TestObject(t *testing.T) {
+
+	fileName := "testdata/annotTest.pdf"
+	config := types.NewDefaultConfiguration()
+
+	cmd := ObjectCommand(fileName, "Root/Pages/Kids/0/Resources", false, config)
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestObject: %v\n", err)
+	}
+
+	if len(out) != 2 || !strings.Contains(out[1], "ProcSet") {
+		t.Fatalf("TestObject: resources missing:\n%s\n", strings.Join(out, "\n"))
+	}
+
+	// The first page of annotTest.pdf is object 2.
+	for _, path := range []string{"2/Contents", "Root/Pages/Kids/0/Contents"} {
+		cmd = ObjectCommand(fileName, path, true, config)
+		out, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestObject %s: %v\n", path, err)
+		}
+		if len(out) != 5 || !strings.Contains(out[2], "FlateDecode") || !strings.Contains(out[4], " re ") {
+			t.Fatalf("TestObject %s: decoded content missing:\n%s\n", path, strings.Join(out, "\n"))
+		}
+	}
+
+	for _, path := range []string{"", "Root/Missing", "Root/Pages/Kids/99", "Trailer", "999999"} {
+		cmd = ObjectCommand(fileName, path, false, config)
+		if _, err = Process(&cmd); err == nil {
+			t.Fatalf("TestObject: %s should fail\n", path)
+		}
+	}
+}
+
+func TestXRef(t *testing.T) {
+
+	cmd := XRefCommand("testdata/GoForOptimization.pdf", types.NewDefaultConfiguration())
+	out, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXRef: %v\n", err)
+	}
+
+	var free, inUse, compressed int
+	for _, s := range out[1:] {
+		switch strings.Fields(s)[1] {
+		case "f":
+			free++
+		case "n":
+			inUse++
+		case "c":
+			compressed++
+		}
+	}
+
+	if free == 0 || inUse == 0 || compressed == 0 || free+inUse+compressed != len(out)-1 {
+		t.Fatalf("TestXRef: free=%d inUse=%d compressed=%d\n%s\n", free, inUse, compressed, strings.Join(out, "\n"))
+	}
+}
+
+func TestObjectEdit(t *testing.T) {
+
+	fileName := "testdata/annotTest.pdf"
+	fileOut := outputDir + "/testObjectEdit.pdf"
+	contentFile := outputDir + "/testObjectEdit.txt"
+	config := types.NewDefaultConfiguration()
+
+	content := "q 1 0 0 RG 10 10 100 100 re S Q\n"
+	err := ioutil.WriteFile(contentFile, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestObjectEdit: %v\n", err)
+	}
+
+	for _, cmd := range []Command{
+		SetObjectCommand(fileName, "Root/Pages/Kids/0/MediaBox", "[0 0 500 700]", fileOut, config),
+		SetObjectCommand(fileOut, "Root/Pages/Kids/0/Rotate", "90", fileOut, config),
+		DeleteObjectCommand(fileOut, "Root/Pages/Kids/0/Annots/0", fileOut, config),
+		ReplaceStreamCommand(fileOut, "Root/Pages/Kids/0/Contents", contentFile, fileOut, config),
+		ValidateCommand(fileOut, config),
+	} {
+		if _, err = Process(&cmd); err != nil {
+			t.Fatalf("TestObjectEdit %d: %v\n", cmd.Mode, err)
+		}
+	}
+
+	ctx, err := Read(fileOut, config)
+	if err != nil {
+		t.Fatalf("TestObjectEdit: %v\n", err)
+	}
+
+	for path, want := range map[string]string{
+		"Root/Pages/Kids/0/MediaBox": "[0 0 500 700]",
+		"Root/Pages/Kids/0/Rotate":   "90",
+	} {
+		obj, _, err := object.Resolve(ctx, path)
+		if err != nil {
+			t.Fatalf("TestObjectEdit %s: %v\n", path, err)
+		}
+		if got := fmt.Sprintf("%v", obj); got != want {
+			t.Fatalf("TestObjectEdit %s: got %s, want %s\n", path, got, want)
+		}
+	}
+
+	obj, _, err := object.Resolve(ctx, "Root/Pages/Kids/0/Annots")
+	if err != nil || len(obj.(types.PDFArray)) != 20 {
+		t.Fatalf("TestObjectEdit: annotation not deleted: %v %v\n", obj, err)
+	}
+
+	cmd := ObjectCommand(fileOut, "Root/Pages/Kids/0/Contents", true, config)
+	out, err := Process(&cmd)
+	if err != nil || len(out) != 5 || out[4] != content {
+		t.Fatalf("TestObjectEdit: stream not replaced: %v %v\n", out, err)
+	}
+
+	for _, cmd := range []Command{
+		SetObjectCommand(fileName, "Root/X", "[1 2", fileOut, config),
+		SetObjectCommand(fileName, "Root/X", "999 0 R", fileOut, config),
+		SetObjectCommand(fileName, "Root/Missing/X", "1", fileOut, config),
+		DeleteObjectCommand(fileName, "Root/Missing", fileOut, config),
+		DeleteObjectCommand(fileName, "5", fileOut, config),
+		ReplaceStreamCommand(fileName, "Root", contentFile, fileOut, config),
+	} {
+		if _, err = Process(&cmd); err == nil {
+			t.Fatalf("TestObjectEdit %d %s: should fail\n", cmd.Mode, *cmd.Path)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("TestEncryptDecrypt: %v\n", err)
+	}
+
+	for _, file := range files {
+
+		if strings.HasSuffix(file.Name(), "pdf") {
+
+			fin := "testdata/" + file.Name()
+			fmt.Println("\n" + fin)
+			f := outputDir + "/test.pdf"
+
+			// Encrypt
+			//fmt.Println("\nEncrypt")
+			config := types.NewDefaultConfiguration()
+			config.UserPW = "upw"
+			config.OwnerPW = "opw"
+			cmd := EncryptCommand(fin, f, config)
+			_, err := Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryptDecrypt - encrypt %s: %v\n", err, f)
+			}
+
+			// Validate
+			//fmt.Println("\nValidate")
+			config = types.NewDefaultConfiguration()
+			config.UserPW = "upw"
+			config.OwnerPW = "opw"
+			cmd = ValidateCommand(f, config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryptDecrypt - validate %s: %v\n", err, f)
+			}
+
+			// ChangeUserPW
+			//fmt.Println("\nChangeUserPW")
+			config = types.NewDefaultConfiguration()
+			config.OwnerPW = "opw"
+			pwOld := "upw"
+			pwNew := "upwNew"
+			cmd = ChangeUserPWCommand(f, f, config, &pwOld, &pwNew)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryption - change userPW: %v\n", err)
+			}
+
+			// Validate
+			//fmt.Println("\nValidate")
+			config = types.NewDefaultConfiguration()
+			config.UserPW = "upwNew"
+			config.OwnerPW = "opw"
+			cmd = ValidateCommand(f, config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryption - validate: %v\n", err)
+			}
+
+			// ChangeOwnerPW
+			//fmt.Println("\nChangeOwnerPW")
+			config = types.NewDefaultConfiguration()
+			config.UserPW = "upwNew"
+			pwOld = "opw"
+			pwNew = "opwNew"
+			cmd = ChangeOwnerPWCommand(f, f, config, &pwOld, &pwNew)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryption - change ownerPW: %v\n", err)
+			}
+
+			// Validate
+			//fmt.Println("\nValidate")
+			config = types.NewDefaultConfiguration()
+			config.UserPW = "upwNew"
+			config.OwnerPW = "opwNew"
+			cmd = ValidateCommand(f, config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryption - validate: %v\n", err)
+			}
+
+			// Decrypt
+			//fmt.Println("\nDecrypt")
+			config = types.NewDefaultConfiguration()
+			config.UserPW = "upwNew"
+			config.OwnerPW = "opwNew"
+			cmd = DecryptCommand(f, f, config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryptDecrypt - decrypt %s: %v\n", err, f)
+			}
+
+			// Validate
+			//fmt.Println("\nValidate")
+			config = types.NewDefaultConfiguration()
+			cmd = ValidateCommand(f, config)
+			_, err = Process(&cmd)
+			if err != nil {
+				t.Fatalf("TestEncryption - validate %s: %v\n", err, f)
+			}
+
+		}
+	}
+}
+
+func copyFile(srcFileName, destFileName string) (err error) {
+
+	from, err := os.Open(srcFileName)
+	if err != nil {
+		return
+	}
+	defer from.Close()
+
+	to, err := os.OpenFile(destFileName, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	defer to.Close()
+
+	_, err = io.Copy(to, from)
+
+	return
+}
+
+func prepareForAttachmentTest(testDir string) (err error) {
+
+	testFile := testDir + "/go.pdf"
+	err = copyFile(testFile, outputDir+"/go.pdf")
+	if err != nil {
+		return
+	}
+
+	testFile = testDir + "/golang.pdf"
+	err = copyFile(testFile, outputDir+"/golang.pdf")
+	if err != nil {
+		return
+	}
+
+	testFile = testDir + "/T4.pdf"
+	err = copyFile(testFile, outputDir+"/T4.pdf")
+	if err != nil {
+		return
+	}
+
+	return
+}
+func TestAttachments(t *testing.T) {
+
+	testDir := "testdata"
+
+	err := prepareForAttachmentTest(testDir)
+	if err != nil {
+		t.Fatalf("prepare for attachments: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+
+	fileName := outputDir + "/go.pdf"
+
+	// attach list must be 0
+	cmd := ListAttachmentsCommand(fileName, config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) > 0 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+
+	// attach add 2 files
 	cmd = AddAttachmentsCommand(fileName, []string{outputDir + "/golang.pdf", outputDir + "/T4.pdf"}, config)
 	_, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - add attachments to %s: %v\n", fileName, err)
+		t.Fatalf("TestAttachments - add attachments to %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 2
+	cmd = ListAttachmentsCommand(fileName, config)
+	list, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+
+	// attach extract all
+	cmd = ExtractAttachmentsCommand(fileName, ".", nil, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - extract all attachments from %s to %s: %v\n", fileName, ".", err)
+	}
+
+	// attach extract 1 file
+	cmd = ExtractAttachmentsCommand(fileName, ".", []string{outputDir + "/golang.pdf"}, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - extract 1 attachment from %s to %s: %v\n", fileName, ".", err)
+	}
+
+	// attach remove 1 file
+	cmd = RemoveAttachmentsCommand(fileName, []string{outputDir + "/golang.pdf"}, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - remove attachment from %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 1
+	cmd = ListAttachmentsCommand(fileName, config)
+	list, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+
+	// attach remove all
+	cmd = RemoveAttachmentsCommand(fileName, nil, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - remove all attachment from %s: %v\n", fileName, err)
+	}
+
+	// attach list must be 0.
+	cmd = ListAttachmentsCommand(fileName, config)
+	list, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+	}
+	if len(list) > 0 {
+		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+
+	fileName := outputDir + "/annotTest.pdf"
+
+	err := copyFile("testdata/annotTest.pdf", fileName)
+	if err != nil {
+		t.Fatalf("prepare for annotations: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+
+	// annotations list must not be empty.
+	cmd := ListAnnotationsCommand(fileName, nil, nil, config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - list annotations %s: %v\n", fileName, err)
+	}
+	if len(list) == 0 {
+		t.Fatalf("TestAnnotations - list annotations %s: should have annotations\n", fileName)
+	}
+	count := len(list)
+
+	// annotations export
+	cmd = ExportAnnotationsCommand(fileName, outputDir+"/annotTest.json", nil, nil, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - export annotations %s: %v\n", fileName, err)
+	}
+
+	// annotations remove Text annotations of page 1 including their popups.
+	cmd = RemoveAnnotationsCommand(fileName, fileName, []string{"1"}, []string{"Text"}, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - remove Text annotations %s: %v\n", fileName, err)
+	}
+
+	// annotations list must have shrunk.
+	cmd = ListAnnotationsCommand(fileName, nil, nil, config)
+	list, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - list annotations %s: %v\n", fileName, err)
+	}
+	if len(list) >= count {
+		t.Fatalf("TestAnnotations - list annotations %s: should have less than %d annotations\n", fileName, count)
+	}
+
+	// annotations remove all
+	cmd = RemoveAnnotationsCommand(fileName, fileName, nil, nil, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - remove annotations %s: %v\n", fileName, err)
+	}
+
+	// annotations list must be empty.
+	cmd = ListAnnotationsCommand(fileName, nil, nil, config)
+	list, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAnnotations - list annotations %s: %v\n", fileName, err)
+	}
+	if len(list) > 0 {
+		t.Fatalf("TestAnnotations - list annotations %s: should have 0 annotations\n", fileName)
+	}
+}
+
+func TestAddAnnotations(t *testing.T) {
+
+	fileName := outputDir + "/annotAdd.pdf"
+	jsonFile := outputDir + "/annotAdd.json"
+
+	json := `{
+	"annotations": [
+		{"page": 1, "subtype": "Text", "rect": [50, 700, 70, 720], "contents": "A note", "author": "pdfcpu"},
+		{"page": 1, "subtype": "Link", "rect": [50, 650, 200, 670], "uri": "https://golang.org/search?q=(go)\\x", "borderWidth": 1},
+		{"page": 1, "subtype": "Link", "rect": [50, 620, 200, 640], "destPage": 2},
+		{"page": 1, "subtype": "Highlight", "quadPoints": [50, 600, 300, 600, 50, 586, 300, 586], "color": [1, 1, 0]},
+		{"page": 1, "subtype": "Underline", "quadPoints": [50, 570, 300, 570, 50, 556, 300, 556]},
+		{"page": 1, "subtype": "StrikeOut", "quadPoints": [50, 540, 300, 540, 50, 526, 300, 526]},
+		{"page": 2, "subtype": "Square", "rect": [50, 400, 150, 450], "color": [1, 0, 0], "interiorColor": [0, 0, 1], "borderWidth": 2},
+		{"page": 2, "subtype": "Circle", "rect": [200, 400, 300, 450], "color": [0, 1, 0]},
+		{"page": 2, "subtype": "FreeText", "rect": [50, 200, 250, 300], "contents": "Grüße from a wrapped free text annotation.", "fontSize": 14, "quadding": 1},
+		{"page": 2, "subtype": "FileAttachment", "rect": [300, 200, 320, 220], "fileName": "testdata/go.pdf"}
+	]
+}`
+
+	err := ioutil.WriteFile(jsonFile, []byte(json), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for add annotations: %v\n", err)
+	}
+
+	config := types.NewDefaultConfiguration()
+
+	cmd := AddAnnotationsCommand("testdata/go.pdf", jsonFile, fileName, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAddAnnotations - add annotations: %v\n", err)
+	}
+
+	cmd = ValidateCommand(fileName, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAddAnnotations - validate %s: %v\n", fileName, err)
+	}
+
+	cmd = ListAnnotationsCommand(fileName, nil, nil, config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestAddAnnotations - list annotations %s: %v\n", fileName, err)
+	}
+	if len(list) != 10 {
+		t.Fatalf("TestAddAnnotations - list annotations %s: want 10 annotations, got %d\n", fileName, len(list))
+	}
+
+	// Delimiters within strings must be escaped.
+	if !strings.Contains(strings.Join(list, "\n"), "https://golang.org/search?q=(go)\\x") {
+		t.Fatalf("TestAddAnnotations - list annotations %s: URI missing:\n%s\n", fileName, strings.Join(list, "\n"))
+	}
+
+	// Invalid specs must be rejected.
+	err = ioutil.WriteFile(jsonFile, []byte(`{"annotations": [{"page": 1, "subtype": "Link", "rect": [0, 0, 10, 10]}]}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for add annotations: %v\n", err)
+	}
+
+	cmd = AddAnnotationsCommand("testdata/go.pdf", jsonFile, fileName, config)
+	_, err = Process(&cmd)
+	if err == nil {
+		t.Fatalf("TestAddAnnotations - add annotations: Link without target should fail\n")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	// Prepare a file with generated appearance streams.
+	jsonFile := outputDir + "/flatten.json"
+	json := `{
+	"annotations": [
+		{"page": 1, "subtype": "Square", "rect": [50, 400, 150, 450], "interiorColor": [0, 0, 1]},
+		{"page": 1, "subtype": "FreeText", "rect": [50, 200, 250, 300], "contents": "Flattened"},
+		{"page": 1, "subtype": "Link", "rect": [50, 650, 200, 670], "uri": "https://golang.org"}
+	]
+}`
+	err := ioutil.WriteFile(jsonFile, []byte(json), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for flatten: %v\n", err)
+	}
+
+	cmd := AddAnnotationsCommand("testdata/go.pdf", jsonFile, outputDir+"/flatten.pdf", config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("prepare for flatten: %v\n", err)
+	}
+
+	for _, fileIn := range []string{"testdata/annotTest.pdf", outputDir + "/flatten.pdf"} {
+
+		fileOut := outputDir + "/flat.pdf"
+
+		cmd := FlattenCommand(fileIn, fileOut, nil, config)
+		_, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFlatten - flatten %s: %v\n", fileIn, err)
+		}
+
+		cmd = ValidateCommand(fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFlatten - validate %s: %v\n", fileOut, err)
+		}
+
+		// Only links survive flattening.
+		cmd = ListAnnotationsCommand(fileOut, nil, nil, config)
+		list, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFlatten - list annotations %s: %v\n", fileOut, err)
+		}
+		for _, s := range list {
+			if !strings.Contains(s, " Link ") {
+				t.Fatalf("TestFlatten - %s: unexpected annotation: %s\n", fileOut, s)
+			}
+		}
+	}
+}
+
+func TestForm(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	fileIn := "testdata/form.pdf"
+	fileOut := outputDir + "/formFilled.pdf"
+	jsonFile := outputDir + "/form.json"
+
+	// form list must show all terminal fields.
+	cmd := ListFormFieldsCommand(fileIn, jsonFile, config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestForm - list form fields %s: %v\n", fileIn, err)
+	}
+	if len(list) != 8 {
+		t.Fatalf("TestForm - list form fields %s: want 8 fields, got %d\n", fileIn, len(list))
+	}
+
+	json := `{
+	"fields": [
+		{"name": "name", "value": "Jürgen"},
+		{"name": "agree", "value": "true"},
+		{"name": "gender", "value": "female"},
+		{"name": "country", "value": "Italy"},
+		{"name": "colors", "values": ["g", "b"]},
+		{"name": "address.street", "value": "Main Street 1\nSpringfield"},
+		{"name": "address.zip", "value": "12345"}
+	]
+}`
+	err = ioutil.WriteFile(jsonFile, []byte(json), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for fill form: %v\n", err)
+	}
+
+	cmd = FillFormCommand(fileIn, jsonFile, fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestForm - fill form %s: %v\n", fileIn, err)
 	}
 
-	// attach list must be 2
-	cmd = ListAttachmentsCommand(fileName, config)
+	cmd = ValidateCommand(fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestForm - validate %s: %v\n", fileOut, err)
+	}
+
+	cmd = ListFormFieldsCommand(fileOut, "", config)
 	list, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+		t.Fatalf("TestForm - list form fields %s: %v\n", fileOut, err)
 	}
-	if len(list) != 2 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+
+	for _, want := range []string{
+		`name (text) = "Jürgen"`,
+		`agree (checkbox) = "Yes"`,
+		`gender (radio) = "female"`,
+		`country (choice) = "Italy"`,
+		`colors (choice) = g, b`,
+		`address.zip (text) = "12345"`,
+	} {
+		found := false
+		for _, s := range list {
+			if strings.HasPrefix(s, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("TestForm - list form fields %s: missing %s\n", fileOut, want)
+		}
 	}
 
-	// attach extract all
-	cmd = ExtractAttachmentsCommand(fileName, ".", nil, config)
+	// Regenerated appearances must survive flattening.
+	cmd = FlattenCommand(fileOut, outputDir+"/formFlattened.pdf", nil, config)
 	_, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - extract all attachments from %s to %s: %v\n", fileName, ".", err)
+		t.Fatalf("TestForm - flatten %s: %v\n", fileOut, err)
 	}
 
-	// attach extract 1 file
-	cmd = ExtractAttachmentsCommand(fileName, ".", []string{outputDir + "/golang.pdf"}, config)
+	cmd = ValidateCommand(outputDir+"/formFlattened.pdf", config)
 	_, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - extract 1 attachment from %s to %s: %v\n", fileName, ".", err)
+		t.Fatalf("TestForm - validate %s: %v\n", outputDir+"/formFlattened.pdf", err)
 	}
 
-	// attach remove 1 file
-	cmd = RemoveAttachmentsCommand(fileName, []string{outputDir + "/golang.pdf"}, config)
+	// Invalid values must be rejected.
+	err = ioutil.WriteFile(jsonFile, []byte(`{"fields": [{"name": "country", "value": "Spain"}]}`), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for fill form: %v\n", err)
+	}
+
+	cmd = FillFormCommand(fileIn, jsonFile, fileOut, config)
 	_, err = Process(&cmd)
+	if err == nil {
+		t.Fatalf("TestForm - fill form %s: invalid choice should fail\n", fileIn)
+	}
+}
+
+func TestModifyForm(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	fileIn := outputDir + "/formModifyIn.pdf"
+	fileOut := outputDir + "/formModified.pdf"
+
+	// Start from a filled form.
+	jsonFile := outputDir + "/formModify.json"
+	err := ioutil.WriteFile(jsonFile, []byte(`{"fields": [{"name": "name", "value": "Jane"}, {"name": "agree", "value": "Yes"}]}`), os.ModePerm)
 	if err != nil {
-		t.Fatalf("TestAttachments - remove attachment from %s: %v\n", fileName, err)
+		t.Fatalf("prepare for fill form: %v\n", err)
 	}
 
-	// attach list must be 1
-	cmd = ListAttachmentsCommand(fileName, config)
-	list, err = Process(&cmd)
+	cmd := FillFormCommand("testdata/form.pdf", jsonFile, fileIn, config)
+	_, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+		t.Fatalf("TestModifyForm - fill form: %v\n", err)
 	}
-	if len(list) != 1 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+
+	listFields := func(fileName string) []string {
+		cmd := ListFormFieldsCommand(fileName, "", config)
+		list, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestModifyForm - list form fields %s: %v\n", fileName, err)
+		}
+		return list
 	}
 
-	// attach remove all
-	cmd = RemoveAttachmentsCommand(fileName, nil, config)
+	contains := func(list []string, prefix string) bool {
+		for _, s := range list {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, tt := range []struct {
+		cmd       Command
+		want      []string
+		wantCount int
+	}{
+		// Reset restores the default values.
+		{ResetFormCommand(fileIn, fileOut, []string{"name", "agree"}, config), []string{`name (text)`, `agree (checkbox) = "Off"`}, 8},
+		// Lock sets the read only flag of all fields below address.
+		{LockFormCommand(fileIn, fileOut, []string{"address"}, config), []string{`address.street (text) read only`, `address.zip (text) read only`}, 8},
+		// Remove deletes fields along with their widgets.
+		{RemoveFormFieldsCommand(fileIn, fileOut, []string{"gender", "address"}, config), []string{`name (text) = "Jane"`}, 5},
+	} {
+
+		_, err = Process(&tt.cmd)
+		if err != nil {
+			t.Fatalf("TestModifyForm - mode %d: %v\n", tt.cmd.Mode, err)
+		}
+
+		cmd = ValidateCommand(fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestModifyForm - mode %d: validate %s: %v\n", tt.cmd.Mode, fileOut, err)
+		}
+
+		list := listFields(fileOut)
+		if len(list) != tt.wantCount {
+			t.Fatalf("TestModifyForm - mode %d: want %d fields, got %d\n", tt.cmd.Mode, tt.wantCount, len(list))
+		}
+
+		for _, want := range tt.want {
+			if !contains(list, want) {
+				t.Fatalf("TestModifyForm - mode %d: missing %s in %v\n", tt.cmd.Mode, want, list)
+			}
+		}
+	}
+
+	// Removing all fields removes the form.
+	cmd = RemoveFormFieldsCommand(fileIn, fileOut, nil, config)
 	_, err = Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - remove all attachment from %s: %v\n", fileName, err)
+		t.Fatalf("TestModifyForm - remove all fields: %v\n", err)
 	}
 
-	// attach list must be 0.
-	cmd = ListAttachmentsCommand(fileName, config)
-	list, err = Process(&cmd)
+	if list := listFields(fileOut); len(list) != 0 {
+		t.Fatalf("TestModifyForm - remove all fields: want 0 fields, got %d\n", len(list))
+	}
+
+	// Unknown fields must be rejected.
+	cmd = LockFormCommand(fileIn, fileOut, []string{"unknown"}, config)
+	_, err = Process(&cmd)
+	if err == nil {
+		t.Fatalf("TestModifyForm - lock unknown field should fail\n")
+	}
+}
+
+func TestXFA(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	fileIn := "testdata/xfaForm.pdf"
+	fileOut := outputDir + "/xfaStripped.pdf"
+
+	cmd := ExportXFACommand(fileIn, outputDir, config)
+	_, err := Process(&cmd)
 	if err != nil {
-		t.Fatalf("TestAttachments - list attachments %s: %v\n", fileName, err)
+		t.Fatalf("TestXFA - export %s: %v\n", fileIn, err)
 	}
-	if len(list) > 0 {
-		t.Fatalf("TestAttachments - list attachments %s: should have 0 attachments\n", fileName)
+
+	for _, packet := range []string{"config", "template", "datasets"} {
+		fileName := outputDir + "/xfaForm_" + packet + ".xml"
+		bb, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf("TestXFA - export %s: %v\n", fileIn, err)
+		}
+		if !strings.Contains(string(bb), packet) {
+			t.Fatalf("TestXFA - export %s: unexpected content in %s\n", fileIn, fileName)
+		}
+	}
+
+	cmd = StripXFACommand(fileIn, fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXFA - strip %s: %v\n", fileIn, err)
+	}
+
+	cmd = ValidateCommand(fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXFA - validate %s: %v\n", fileOut, err)
+	}
+
+	// The AcroForm fields are kept.
+	cmd = ListFormFieldsCommand(fileOut, "", config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXFA - list form fields %s: %v\n", fileOut, err)
+	}
+	if len(list) != 8 {
+		t.Fatalf("TestXFA - list form fields %s: want 8 fields, got %d\n", fileOut, len(list))
+	}
+
+	// Nothing left to export.
+	cmd = ExportXFACommand(fileOut, outputDir, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXFA - export %s: %v\n", fileOut, err)
+	}
+
+	if _, err = os.Stat(outputDir + "/xfaStripped_template.xml"); err == nil {
+		t.Fatalf("TestXFA - export %s: no XFA expected\n", fileOut)
+	}
+
+	// Packet names must not escape the output dir.
+	ctx, err := Read(fileIn, config)
+	if err != nil {
+		t.Fatalf("TestXFA - read %s: %v\n", fileIn, err)
+	}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		t.Fatalf("TestXFA - catalog %s: %v\n", fileIn, err)
+	}
+
+	acroForm, err := ctx.DereferenceDict(rootDict.Dict["AcroForm"])
+	if err != nil || acroForm == nil {
+		t.Fatalf("TestXFA - acroform %s: %v\n", fileIn, err)
+	}
+
+	xfa, err := ctx.DereferenceArray(acroForm.Dict["XFA"])
+	if err != nil || xfa == nil {
+		t.Fatalf("TestXFA - xfa %s: %v\n", fileIn, err)
+	}
+
+	for i, o := range *xfa {
+		if s, ok := o.(types.PDFStringLiteral); ok && s.Value() == "config" {
+			(*xfa)[i] = types.PDFStringLiteral("../config")
+		}
+	}
+
+	ctx.Write.DirName = outputDir + "/"
+	ctx.Write.FileName = "xfaTraversal.pdf"
+	if err = Write(ctx); err != nil {
+		t.Fatalf("TestXFA - write %s: %v\n", ctx.Write.FileName, err)
+	}
+
+	fileIn = outputDir + "/xfaTraversal.pdf"
+	cmd = ExportXFACommand(fileIn, outputDir, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestXFA - export %s: %v\n", fileIn, err)
+	}
+
+	if _, err = os.Stat(outputDir + "/xfaTraversal_.._config.xml"); err != nil {
+		t.Fatalf("TestXFA - export %s: %v\n", fileIn, err)
+	}
+}
+
+func TestFormData(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	fileIn := "testdata/form.pdf"
+	fileSrc := outputDir + "/formDataSrc.pdf"
+	fileOut := outputDir + "/formDataImported.pdf"
+	jsonFile := outputDir + "/formData.json"
+
+	// Prepare a filled form with some markup annotations.
+	json := `{
+	"fields": [
+		{"name": "name", "value": "Jürgen"},
+		{"name": "agree", "value": "Yes"},
+		{"name": "colors", "values": ["g", "b"]},
+		{"name": "address.zip", "value": "12345"}
+	]
+}`
+	err := ioutil.WriteFile(jsonFile, []byte(json), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for form data: %v\n", err)
+	}
+
+	cmd := FillFormCommand(fileIn, jsonFile, fileSrc, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestFormData - fill form: %v\n", err)
+	}
+
+	json = `{
+	"annotations": [
+		{"page": 1, "subtype": "Highlight", "quadPoints": [50, 700, 100, 700, 50, 690, 100, 690], "color": [1, 1, 0], "contents": "Check (this)"},
+		{"page": 1, "subtype": "FreeText", "rect": [400, 700, 550, 750], "contents": "Hello", "quadding": 1},
+		{"page": 1, "subtype": "Square", "rect": [400, 600, 450, 650], "color": [1, 0, 0], "borderWidth": 2}
+	]
+}`
+	err = ioutil.WriteFile(jsonFile, []byte(json), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for form data: %v\n", err)
+	}
+
+	cmd = AddAnnotationsCommand(fileSrc, jsonFile, fileSrc, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestFormData - add annotations: %v\n", err)
+	}
+
+	for _, fdfFile := range []string{outputDir + "/formData.fdf", outputDir + "/formData.xfdf"} {
+
+		cmd = ExportFormDataCommand(fileSrc, fdfFile, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - export %s: %v\n", fdfFile, err)
+		}
+
+		cmd = ImportFormDataCommand(fileIn, fdfFile, fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - import %s: %v\n", fdfFile, err)
+		}
+
+		// Importing twice must not duplicate annotations.
+		cmd = ImportFormDataCommand(fileOut, fdfFile, fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - import %s: %v\n", fdfFile, err)
+		}
+
+		cmd = ValidateCommand(fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - validate %s: %v\n", fileOut, err)
+		}
+
+		cmd = ListFormFieldsCommand(fileOut, "", config)
+		list, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - list form fields %s: %v\n", fileOut, err)
+		}
+
+		for _, want := range []string{
+			`name (text) = "Jürgen"`,
+			`agree (checkbox) = "Yes"`,
+			`colors (choice) = g, b`,
+			`address.zip (text) = "12345"`,
+		} {
+			found := false
+			for _, s := range list {
+				if strings.HasPrefix(s, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("TestFormData - import %s: missing %s\n", fdfFile, want)
+			}
+		}
+
+		cmd = ListAnnotationsCommand(fileOut, nil, []string{"Highlight", "FreeText", "Square"}, config)
+		list, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestFormData - list annotations %s: %v\n", fileOut, err)
+		}
+		if len(list) != 3 {
+			t.Fatalf("TestFormData - import %s: want 3 annotations, got %d\n", fdfFile, len(list))
+		}
+	}
+
+	// Stream data must not be mistaken for objects.
+	fdfFile := outputDir + "/formDataStream.fdf"
+	data := "1 0 obj\n<</Empty true>>\nendobj\n"
+	fdf := "%FDF-1.2\n" +
+		"1 0 obj\n<</FDF <</Fields [<</T (name) /V (Bob)>>]>>>>\nendobj\n" +
+		fmt.Sprintf("2 0 obj\n<</Length %d>>\nstream\n%sendstream\nendobj\n", len(data), data) +
+		"trailer\n<</Root 1 0 R>>\n%%EOF\n"
+	err = ioutil.WriteFile(fdfFile, []byte(fdf), os.ModePerm)
+	if err != nil {
+		t.Fatalf("prepare for form data: %v\n", err)
+	}
+
+	cmd = ImportFormDataCommand(fileIn, fdfFile, fileOut, config)
+	_, err = Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestFormData - import %s: %v\n", fdfFile, err)
+	}
+
+	cmd = ListFormFieldsCommand(fileOut, "", config)
+	list, err := Process(&cmd)
+	if err != nil {
+		t.Fatalf("TestFormData - list form fields %s: %v\n", fileOut, err)
+	}
+
+	found := false
+	for _, s := range list {
+		if strings.HasPrefix(s, `name (text) = "Bob"`) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("TestFormData - import %s: missing name Bob\n", fdfFile)
+	}
+
+	// Unsupported file extension.
+	cmd = ExportFormDataCommand(fileSrc, outputDir+"/formData.txt", config)
+	_, err = Process(&cmd)
+	if err == nil {
+		t.Fatalf("TestFormData - export with invalid extension should fail\n")
+	}
+}
+
+func TestLayers(t *testing.T) {
+
+	config := types.NewDefaultConfiguration()
+
+	fileIn := "testdata/CenterOfWhy.pdf"
+	fileOut := outputDir + "/layers.pdf"
+
+	listLayers := func(fileName string) []string {
+		cmd := ListLayersCommand(fileName, config)
+		list, err := Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestLayers - list layers %s: %v\n", fileName, err)
+		}
+		return list
+	}
+
+	for _, tt := range []struct {
+		cmd  Command
+		want string
+	}{
+		{HideLayersCommand(fileIn, fileOut, nil, config), "obj#2866 Headers/Footers (off)"},
+		{LockLayersCommand(fileOut, fileOut, []string{"Headers/Footers"}, config), "obj#2866 Headers/Footers (off, locked)"},
+		{ShowLayersCommand(fileOut, fileOut, nil, config), "obj#2866 Headers/Footers (on, locked)"},
+	} {
+
+		_, err := Process(&tt.cmd)
+		if err != nil {
+			t.Fatalf("TestLayers - mode %d: %v\n", tt.cmd.Mode, err)
+		}
+
+		cmd := ValidateCommand(fileOut, config)
+		_, err = Process(&cmd)
+		if err != nil {
+			t.Fatalf("TestLayers - mode %d: validate %s: %v\n", tt.cmd.Mode, fileOut, err)
+		}
+
+		if list := listLayers(fileOut); len(list) != 1 || list[0] != tt.want {
+			t.Fatalf("TestLayers - mode %d: want %s, got %v\n", tt.cmd.Mode, tt.want, list)
+		}
+	}
+
+	// Unknown layers must be rejected.
+	cmd := HideLayersCommand(fileIn, fileOut, []string{"unknown"}, config)
+	_, err := Process(&cmd)
+	if err == nil {
+		t.Fatalf("TestLayers - hide unknown layer should fail\n")
+	}
+
+	// Put some text and a line into the layer and remove it.
+	// The state set within the layer still applies to the line following.
+	contentFile := outputDir + "/layers.txt"
+	content := "BT /TT0 12 Tf 72 700 Td (visible) Tj ET\n" +
+		"/OC /MC0 BDC 1 0 0 RG 2 w BT /TT0 12 Tf 72 680 Td (secret) Tj ET 10 10 m 20 20 l S EMC\n" +
+		"30 30 m 40 40 l S\n"
+	err = ioutil.WriteFile(contentFile, []byte(content), os.ModePerm)
+	if err != nil {
+		t.Fatalf("TestLayers: %v\n", err)
+	}
+
+	page := "Root/Pages/Kids/0/Kids/1"
+
+	for _, cmd := range []Command{
+		SetObjectCommand(fileIn, page+"/Resources/Properties", "<</MC0 2866 0 R>>", fileOut, config),
+		ReplaceStreamCommand(fileOut, page+"/Contents", contentFile, fileOut, config),
+		RemoveLayersCommand(fileOut, fileOut, []string{"Headers/Footers"}, config),
+		ValidateCommand(fileOut, config),
+	} {
+		if _, err = Process(&cmd); err != nil {
+			t.Fatalf("TestLayers - remove layer %d: %v\n", cmd.Mode, err)
+		}
+	}
+
+	cmd = ObjectCommand(fileOut, page+"/Contents", true, config)
+	out, err := Process(&cmd)
+	if err != nil || len(out) != 5 {
+		t.Fatalf("TestLayers - remove layer: %v %v\n", out, err)
+	}
+
+	for _, s := range []string{"(visible)", "1 0 0 RG", "2 w", "/TT0 12 Tf", "30 30 m"} {
+		if !strings.Contains(out[4], s) {
+			t.Fatalf("TestLayers - remove layer: missing %s in %s\n", s, out[4])
+		}
+	}
+
+	for _, s := range []string{"(secret)", "10 10 m", "BDC", "EMC"} {
+		if strings.Contains(out[4], s) {
+			t.Fatalf("TestLayers - remove layer: marked content not removed: %s\n", out[4])
+		}
+	}
+
+	ctx, err := Read(fileOut, config)
+	if err != nil {
+		t.Fatalf("TestLayers: %v\n", err)
+	}
+
+	for _, path := range []string{"Root/OCProperties", page + "/Resources/Properties"} {
+		if _, _, err = object.Resolve(ctx, path); err == nil {
+			t.Fatalf("TestLayers - remove layer: %s not removed\n", path)
+		}
 	}
 }
@@ -643,11 +643,11 @@ func parseNumericOrIndRef(line *string) (interface{}, error) {
 
 	if len(l) == 0 {
 		// only whitespace
-		l = l1
+		*line = l1
 		return types.PDFInteger(i), nil
 	}
 
-	if l[0] == 'R' {
+	if l[0] == 'R' && (len(l) == 1 || unicode.IsSpace(rune(l[1])) || delimiter(l[1])) {
 		// We have all 3 components to create an indirect reference.
 		*line = forwardParseBuf(l, 1)
 		return types.NewPDFIndirectRef(iref1, iref2), nil
@@ -785,6 +785,16 @@ func parseObject(line *string) (interface{}, error) {
 	return value, nil
 }
 
+// ParseObject parses the PDF object at the beginning of s.
+func ParseObject(s string) (interface{}, error) {
+	return parseObject(&s)
+}
+
+// ParseNextObject parses the PDF object at the beginning of *s and advances *s behind it.
+func ParseNextObject(s *string) (interface{}, error) {
+	return parseObject(s)
+}
+
 // parseXRefStreamDict creates a PDFXRefStreamDict out of a PDFStreamDict.
 func parseXRefStreamDict(pdfStreamDict types.PDFStreamDict) (*types.PDFXRefStreamDict, error) {
 
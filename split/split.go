@@ -0,0 +1,374 @@
+// Package split provides for splitting a PDF file into files of consecutive pages.
+//
+// A split is planned as a sequence of page spans, each span is written to a separate file
+// keeping only the resources used by its pages.
+package split
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hhrutter/pdfcpu/merge"
+	"github.com/hhrutter/pdfcpu/optimize"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var logDebugSplit *log.Logger
+
+func init() {
+	logDebugSplit = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+}
+
+// Verbose controls logging output.
+func Verbose(verbose bool) {
+	if verbose {
+		logDebugSplit = log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		logDebugSplit = log.New(ioutil.Discard, "DEBUG: ", log.Ldate|log.Ltime|log.Lshortfile)
+	}
+}
+
+// Span represents the consecutive pages From thru Thru written to one file.
+// Title is the title of the bookmark the span starts with.
+type Span struct {
+	From, Thru int
+	Title      string
+}
+
+// EveryN returns spans of n pages each, the last span may be shorter.
+func EveryN(pageCount, n int) ([]Span, error) {
+
+	if n < 1 {
+		return nil, errors.Errorf("invalid span: %d", n)
+	}
+
+	var spans []Span
+	for from := 1; from <= pageCount; from += n {
+		thru := from + n - 1
+		if thru > pageCount {
+			thru = pageCount
+		}
+		spans = append(spans, Span{From: from, Thru: thru})
+	}
+
+	return spans, nil
+}
+
+// AtPages returns the spans resulting from splitting before each of the given page numbers.
+func AtPages(pageCount int, pageNrs []int) ([]Span, error) {
+
+	starts := []int{1}
+	for _, pageNr := range pageNrs {
+		if pageNr < 1 || pageNr > pageCount {
+			return nil, errors.Errorf("invalid page number: %d (pageCount=%d)", pageNr, pageCount)
+		}
+		starts = append(starts, pageNr)
+	}
+
+	return spans(pageCount, starts, nil), nil
+}
+
+// spans returns the spans beginning at the given page numbers.
+// Duplicate page numbers are skipped keeping the first title.
+func spans(pageCount int, starts []int, titles []string) []Span {
+
+	type start struct {
+		pageNr int
+		title  string
+	}
+
+	var ss []start
+	for i, pageNr := range starts {
+		var title string
+		if titles != nil {
+			title = titles[i]
+		}
+		ss = append(ss, start{pageNr, title})
+	}
+
+	sort.SliceStable(ss, func(i, j int) bool { return ss[i].pageNr < ss[j].pageNr })
+
+	var spans []Span
+	for _, s := range ss {
+		if len(spans) > 0 && spans[len(spans)-1].From == s.pageNr {
+			continue
+		}
+		if len(spans) > 0 {
+			spans[len(spans)-1].Thru = s.pageNr - 1
+		}
+		spans = append(spans, Span{From: s.pageNr, Thru: pageCount, Title: s.title})
+	}
+
+	return spans
+}
+
+// textString returns the text string value of o.
+func textString(ctx *types.PDFContext, o interface{}) string {
+
+	o, err := ctx.Dereference(o)
+	if err != nil {
+		return ""
+	}
+
+	var s string
+
+	switch str := o.(type) {
+	case types.PDFStringLiteral:
+		s, err = types.StringLiteralToString(str.Value())
+	case types.PDFHexLiteral:
+		s, err = types.HexLiteralToString(str.Value())
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	return s
+}
+
+// lookupName returns the value for key in the name tree rooted at o.
+func lookupName(ctx *types.PDFContext, o interface{}, key string) (interface{}, error) {
+
+	d, err := ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	if arr, err := ctx.DereferenceArray(d.Dict["Names"]); err == nil && arr != nil {
+		for i := 0; i+1 < len(*arr); i += 2 {
+			if textString(ctx, (*arr)[i]) == key {
+				return (*arr)[i+1], nil
+			}
+		}
+	}
+
+	kids, err := ctx.DereferenceArray(d.Dict["Kids"])
+	if err != nil || kids == nil {
+		return nil, err
+	}
+
+	for _, kid := range *kids {
+		v, err := lookupName(ctx, kid, key)
+		if err != nil || v != nil {
+			return v, err
+		}
+	}
+
+	return nil, nil
+}
+
+// namedDest returns the destination for a name or string.
+func namedDest(ctx *types.PDFContext, o interface{}) (interface{}, error) {
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	if name, ok := o.(types.PDFName); ok {
+		d, err := ctx.DereferenceDict(root.Dict["Dests"])
+		if err != nil || d == nil {
+			return nil, err
+		}
+		return d.Dict[name.Value()], nil
+	}
+
+	names, err := ctx.DereferenceDict(root.Dict["Names"])
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	tree, found := names.Find("Dests")
+	if !found {
+		return nil, nil
+	}
+
+	return lookupName(ctx, tree, textString(ctx, o))
+}
+
+// destPage returns the number of the page an outline item points to or 0.
+func destPage(ctx *types.PDFContext, item *types.PDFDict, pageNrs map[int]int) (int, error) {
+
+	dest, found := item.Find("Dest")
+
+	if !found {
+		a, err := ctx.DereferenceDict(item.Dict["A"])
+		if err != nil || a == nil {
+			return 0, err
+		}
+		if s := a.NameEntry("S"); s == nil || *s != "GoTo" {
+			return 0, nil
+		}
+		dest = a.Dict["D"]
+	}
+
+	dest, err := ctx.Dereference(dest)
+	if err != nil {
+		return 0, err
+	}
+
+	switch dest.(type) {
+	case types.PDFName, types.PDFStringLiteral, types.PDFHexLiteral:
+		dest, err = namedDest(ctx, dest)
+		if err != nil {
+			return 0, err
+		}
+		dest, err = ctx.Dereference(dest)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// A named destination may be a dict holding the destination in D.
+	if d, ok := dest.(types.PDFDict); ok {
+		dest, err = ctx.Dereference(d.Dict["D"])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	arr, ok := dest.(types.PDFArray)
+	if !ok || len(arr) == 0 {
+		return 0, nil
+	}
+
+	switch p := arr[0].(type) {
+
+	case types.PDFIndirectRef:
+		return pageNrs[p.ObjectNumber.Value()], nil
+
+	case types.PDFInteger:
+		// Some writers use a page index like for remote destinations.
+		if p.Value() >= 0 && p.Value() < len(pageNrs) {
+			return p.Value() + 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// bookmarks collects the page numbers and titles of the outline items up to level.
+func bookmarks(ctx *types.PDFContext, first interface{}, level int, pageNrs map[int]int, starts *[]int, titles *[]string) error {
+
+	visited := types.IntSet{}
+
+	for o := first; o != nil; {
+
+		indRef, ok := o.(types.PDFIndirectRef)
+		if !ok || visited[indRef.ObjectNumber.Value()] {
+			break
+		}
+		visited[indRef.ObjectNumber.Value()] = true
+
+		item, err := ctx.DereferenceDict(indRef)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			break
+		}
+
+		pageNr, err := destPage(ctx, item, pageNrs)
+		if err != nil {
+			return err
+		}
+
+		if pageNr > 0 {
+			title := textString(ctx, item.Dict["Title"])
+			logDebugSplit.Printf("bookmarks: page %d: %s\n", pageNr, title)
+			*starts = append(*starts, pageNr)
+			*titles = append(*titles, title)
+		}
+
+		if level > 1 {
+			if kid, found := item.Find("First"); found {
+				err = bookmarks(ctx, kid, level-1, pageNrs, starts, titles)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		o = item.Dict["Next"]
+	}
+
+	return nil
+}
+
+// ByBookmarks returns the spans starting at the pages of the outline items up to level.
+// Level 1 refers to the top level outline items.
+// The span covering the pages before the first bookmark has no title.
+func ByBookmarks(ctx *types.PDFContext, level int) ([]Span, error) {
+
+	if level < 1 {
+		return nil, errors.Errorf("invalid outline level: %d", level)
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	outlines, err := ctx.DereferenceDict(root.Dict["Outlines"])
+	if err != nil {
+		return nil, err
+	}
+
+	if outlines == nil {
+		return nil, errors.New("missing outlines")
+	}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	pageNrs := map[int]int{}
+	for i, indRef := range pages {
+		pageNrs[indRef.ObjectNumber.Value()] = i + 1
+	}
+
+	starts, titles := []int{1}, []string{""}
+
+	err = bookmarks(ctx, outlines.Dict["First"], level, pageNrs, &starts, &titles)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(starts) == 1 {
+		return nil, errors.New("no bookmarks pointing to pages")
+	}
+
+	return spans(ctx.PageCount, starts, titles), nil
+}
+
+// Reduce reduces ctx to the pages of span.
+// Form fields, named destinations, outline items and links targeting other pages get removed,
+// the structure tree gets dropped as do all resources not used by the remaining pages.
+func Reduce(ctx *types.PDFContext, span Span) (err error) {
+
+	logDebugSplit.Printf("Reduce: pages %d-%d\n", span.From, span.Thru)
+
+	pages := types.IntSet{}
+	for i := span.From; i <= span.Thru; i++ {
+		pages[i] = true
+	}
+
+	err = merge.SelectPages(ctx, pages, false)
+	if err != nil {
+		return
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return
+	}
+
+	// The structure tree refers to all pages.
+	root.Delete("StructTreeRoot")
+
+	return optimize.PruneResources(ctx)
+}
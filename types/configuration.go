@@ -8,6 +8,15 @@ const (
 	// ValidationRelaxed ensures PDF compliance based on frequently encountered validation errors.
 	ValidationRelaxed = 1
 
+	// MergeAppend concatenates the merged files.
+	MergeAppend = 0
+
+	// MergeInterleave takes one page of each merged file at a time.
+	MergeInterleave = 1
+
+	// MergeZip interleaves the merged files taking the pages of all but the first file in reverse order.
+	MergeZip = 2
+
 	// StatsFileNameDefault is the standard stats filename.
 	StatsFileNameDefault = "stats.csv"
 )
@@ -24,6 +33,9 @@ type Configuration struct {
 	// Validate against ISO-32000: strict or relaxed
 	ValidationMode int
 
+	// Merge: append, interleave or zip
+	MergeMode int
+
 	// End of line char sequence for writing.
 	Eol string
 
@@ -42,6 +54,32 @@ type Configuration struct {
 	// A CSV-filename holding the statistics.
 	StatsFileName string
 
+	// A JSON-filename for the optimize report.
+	ReportFileName string
+
+	// Target resolution for optimizing images.
+	// Images exceeding this resolution at their placement get downsampled, 0 turns off downsampling.
+	ImageDPI int
+
+	// JPEG quality (1-100) for downsampled images.
+	// 0 recompresses JPEG images at default quality and all other images using Flate.
+	ImageQuality int
+
+	// Combine different subsets of the same font into one font, turned on by the optimize command.
+	MergeFontSubsets bool
+
+	// Reduce embedded TrueType and CFF fonts to the glyphs used.
+	SubsetFonts bool
+
+	// Remove unused resources and unreachable objects and renumber the remaining objects.
+	Prune bool
+
+	// Recompress streams using Flate at best compression and minify content streams.
+	Recompress bool
+
+	// Write a linearized file optimized for incremental loading (Fast Web View).
+	Linearize bool
+
 	// Supplied user password
 	UserPW    string
 	UserPWNew *string
@@ -90,3 +128,18 @@ func (c *Configuration) SetValidationStrict() {
 func (c *Configuration) SetValidationRelaxed() {
 	c.ValidationMode = ValidationRelaxed
 }
+
+// MergeModeString returns a string rep for the merge mode in effect.
+func (c *Configuration) MergeModeString() string {
+
+	switch c.MergeMode {
+	case MergeAppend:
+		return "append"
+	case MergeInterleave:
+		return "interleave"
+	case MergeZip:
+		return "zip"
+	}
+
+	return ""
+}
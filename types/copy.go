@@ -0,0 +1,186 @@
+package types
+
+// copyObject returns a deep copy of o.
+// Stream data is shared since it gets replaced but never modified in place.
+func copyObject(o interface{}) interface{} {
+
+	switch o := o.(type) {
+
+	case PDFDict:
+		return copyDict(o)
+
+	case PDFArray:
+		return copyArray(o)
+
+	case PDFStreamDict:
+		return copyStreamDict(o)
+
+	case PDFObjectStreamDict:
+		o.PDFStreamDict = copyStreamDict(o.PDFStreamDict)
+		o.ObjArray = copyArray(o.ObjArray)
+		return o
+
+	case PDFXRefStreamDict:
+		o.PDFStreamDict = copyStreamDict(o.PDFStreamDict)
+		o.Objects = append([]int(nil), o.Objects...)
+		return o
+	}
+
+	return o
+}
+
+func copyDict(d PDFDict) PDFDict {
+
+	d1 := NewPDFDict()
+
+	for k, v := range d.Dict {
+		d1.Dict[k] = copyObject(v)
+	}
+
+	return d1
+}
+
+func copyArray(a PDFArray) PDFArray {
+
+	if a == nil {
+		return nil
+	}
+
+	a1 := make(PDFArray, len(a))
+	for i, v := range a {
+		a1[i] = copyObject(v)
+	}
+
+	return a1
+}
+
+func copyStreamDict(sd PDFStreamDict) PDFStreamDict {
+
+	sd.PDFDict = copyDict(sd.PDFDict)
+
+	if sd.FilterPipeline != nil {
+		fp := make([]PDFFilter, len(sd.FilterPipeline))
+		for i, f := range sd.FilterPipeline {
+			if f.DecodeParms != nil {
+				d := copyDict(*f.DecodeParms)
+				f.DecodeParms = &d
+			}
+			fp[i] = f
+		}
+		sd.FilterPipeline = fp
+	}
+
+	return sd
+}
+
+func copyIntSet(s IntSet) IntSet {
+
+	if s == nil {
+		return nil
+	}
+
+	s1 := IntSet{}
+	for k, v := range s {
+		s1[k] = v
+	}
+
+	return s1
+}
+
+// Copy returns a deep copy of the cross reference table and its objects.
+func (xRefTable *XRefTable) Copy() *XRefTable {
+
+	xt := *xRefTable
+
+	xt.Table = map[int]*XRefTableEntry{}
+	for objNr, entry := range xRefTable.Table {
+		e := *entry
+		e.Object = copyObject(entry.Object)
+		xt.Table[objNr] = &e
+	}
+
+	// The catalog gets resolved from the copied objects.
+	xt.RootDict = nil
+
+	if xRefTable.E != nil {
+		e := *xRefTable.E
+		xt.E = &e
+	}
+
+	if xRefTable.ID != nil {
+		id := copyArray(*xRefTable.ID)
+		xt.ID = &id
+	}
+
+	xt.LinearizationObjs = copyIntSet(xRefTable.LinearizationObjs)
+	xt.AdditionalStreams = append([]PDFIndirectRef(nil), xRefTable.AdditionalStreams...)
+
+	return &xt
+}
+
+// Copy returns a copy of the optimization context.
+// Font and image objects are shared.
+func (oc *OptimizationContext) Copy() *OptimizationContext {
+
+	oc1 := *oc
+
+	oc1.PageFonts = make([]IntSet, len(oc.PageFonts))
+	for i, s := range oc.PageFonts {
+		oc1.PageFonts[i] = copyIntSet(s)
+	}
+
+	oc1.PageImages = make([]IntSet, len(oc.PageImages))
+	for i, s := range oc.PageImages {
+		oc1.PageImages[i] = copyIntSet(s)
+	}
+
+	oc1.FontObjects = map[int]*FontObject{}
+	for k, v := range oc.FontObjects {
+		oc1.FontObjects[k] = v
+	}
+
+	oc1.Fonts = map[string][]int{}
+	for k, v := range oc.Fonts {
+		oc1.Fonts[k] = append([]int(nil), v...)
+	}
+
+	oc1.DuplicateFontObjs = copyIntSet(oc.DuplicateFontObjs)
+
+	oc1.DuplicateFonts = map[int]*PDFDict{}
+	for k, v := range oc.DuplicateFonts {
+		oc1.DuplicateFonts[k] = v
+	}
+
+	oc1.ImageObjects = map[int]*ImageObject{}
+	for k, v := range oc.ImageObjects {
+		oc1.ImageObjects[k] = v
+	}
+
+	oc1.DuplicateImageObjs = copyIntSet(oc.DuplicateImageObjs)
+
+	oc1.DuplicateImages = map[int]*PDFStreamDict{}
+	for k, v := range oc.DuplicateImages {
+		oc1.DuplicateImages[k] = v
+	}
+
+	oc1.DuplicateInfoObjects = copyIntSet(oc.DuplicateInfoObjects)
+	oc1.NonReferencedObjs = append([]int(nil), oc.NonReferencedObjs...)
+
+	// The report belongs to the optimization of the original.
+	oc1.Report = nil
+
+	return &oc1
+}
+
+// Copy returns a copy of ctx that may be modified and written without affecting ctx.
+// The configuration and the read context are shared, the write context gets reset.
+func (ctx *PDFContext) Copy() *PDFContext {
+
+	return &PDFContext{
+		Configuration: ctx.Configuration,
+		XRefTable:     ctx.XRefTable.Copy(),
+		Read:          ctx.Read,
+		Optimize:      ctx.Optimize.Copy(),
+		Write:         NewWriteContext(ctx.Write.Eol),
+	}
+}
@@ -25,6 +25,8 @@ type OptimizationContext struct {
 	DuplicateInfoObjects IntSet // Possible result of manual info dict modification.
 
 	NonReferencedObjs []int // Objects that are not referenced.
+
+	Report *OptimizeReport // Summary of the optimization, nil until optimized.
 }
 
 func newOptimizationContext() *OptimizationContext {
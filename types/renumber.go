@@ -0,0 +1,135 @@
+package types
+
+// Resolvable returns true if an indirect reference points to an object in use.
+func (xRefTable *XRefTable) Resolvable(indRef PDFIndirectRef) bool {
+	entry, found := xRefTable.FindTableEntry(indRef.ObjectNumber.Value(), indRef.GenerationNumber.Value())
+	return found && !entry.Free
+}
+
+// TrailerRefs returns the indirect references held by the trailer.
+// The encrypt dict is only included if the document gets encrypted.
+func (xRefTable *XRefTable) TrailerRefs() (indRefs []PDFIndirectRef) {
+
+	for _, indRef := range []*PDFIndirectRef{xRefTable.Root, xRefTable.Info} {
+		if indRef != nil {
+			indRefs = append(indRefs, *indRef)
+		}
+	}
+
+	if xRefTable.Encrypt != nil && xRefTable.EncKey != nil {
+		indRefs = append(indRefs, *xRefTable.Encrypt)
+	}
+
+	return append(indRefs, xRefTable.AdditionalStreams...)
+}
+
+// Renumbered returns a copy of an object with all indirect references renumbered as given by newNr.
+// References to objects not in use or missing in newNr become null.
+func (xRefTable *XRefTable) Renumbered(o interface{}, newNr map[int]int) interface{} {
+
+	switch o := o.(type) {
+
+	case PDFIndirectRef:
+		objNr, found := newNr[o.ObjectNumber.Value()]
+		if !found || !xRefTable.Resolvable(o) {
+			return nil
+		}
+		return NewPDFIndirectRef(objNr, 0)
+
+	case PDFDict:
+		return xRefTable.renumberedDict(o, newNr)
+
+	case PDFStreamDict:
+		o.PDFDict = xRefTable.renumberedDict(o.PDFDict, newNr)
+		if o.StreamLengthObjNr != nil {
+			objNr, found := newNr[*o.StreamLengthObjNr]
+			o.StreamLengthObjNr = nil
+			if found {
+				o.StreamLengthObjNr = &objNr
+			}
+		}
+		return o
+
+	case PDFArray:
+		a := make(PDFArray, len(o))
+		for i, v := range o {
+			a[i] = xRefTable.Renumbered(v, newNr)
+		}
+		return a
+	}
+
+	return o
+}
+
+func (xRefTable *XRefTable) renumberedDict(d PDFDict, newNr map[int]int) PDFDict {
+
+	d1 := NewPDFDict()
+
+	for k, v := range d.Dict {
+		// A null value is equivalent to an absent entry.
+		if v1 := xRefTable.Renumbered(v, newNr); v1 != nil {
+			d1.Dict[k] = v1
+		}
+	}
+
+	return d1
+}
+
+func renumberedRef(indRef *PDFIndirectRef, newNr map[int]int) *PDFIndirectRef {
+
+	if indRef == nil {
+		return nil
+	}
+
+	objNr, found := newNr[indRef.ObjectNumber.Value()]
+	if !found {
+		return nil
+	}
+
+	ir := NewPDFIndirectRef(objNr, 0)
+
+	return &ir
+}
+
+// Renumber replaces the cross reference table by the objects of newNr renumbered accordingly
+// and updates the references held by the trailer. All other objects are dropped.
+func (xRefTable *XRefTable) Renumber(newNr map[int]int) {
+
+	var offset int64
+	gen := FreeHeadGeneration
+
+	table := map[int]*XRefTableEntry{
+		0: {Free: true, Offset: &offset, Generation: &gen},
+	}
+
+	for objNr, i := range newNr {
+		table[i] = NewXRefTableEntryGen0(xRefTable.Renumbered(xRefTable.Table[objNr].Object, newNr))
+	}
+
+	xRefTable.Table = table
+	size := len(table)
+	xRefTable.Size = &size
+
+	xRefTable.Root = renumberedRef(xRefTable.Root, newNr)
+	xRefTable.Info = renumberedRef(xRefTable.Info, newNr)
+	xRefTable.Encrypt = renumberedRef(xRefTable.Encrypt, newNr)
+
+	var additionalStreams []PDFIndirectRef
+	for _, indRef := range xRefTable.AdditionalStreams {
+		if ir := renumberedRef(&indRef, newNr); ir != nil {
+			additionalStreams = append(additionalStreams, *ir)
+		}
+	}
+	xRefTable.AdditionalStreams = additionalStreams
+
+	if xRefTable.EmbeddedFiles != nil {
+		ir := renumberedRef(&xRefTable.EmbeddedFiles.PDFIndirectRef, newNr)
+		xRefTable.EmbeddedFiles = nil
+		if ir != nil {
+			xRefTable.EmbeddedFiles = NewNameTree(*ir)
+		}
+	}
+
+	// The catalog has been replaced.
+	xRefTable.RootDict = nil
+}
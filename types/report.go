@@ -0,0 +1,138 @@
+package types
+
+import "encoding/json"
+
+// FileReport represents the sizes and the structure of the source or destination file of an optimize run.
+// Other counts everything but images and fonts: content streams and any non stream data.
+type FileReport struct {
+	FileName      string `json:"fileName"`
+	Size          int64  `json:"size"`
+	Images        int64  `json:"images"`
+	Fonts         int64  `json:"fonts"`
+	Other         int64  `json:"other"`
+	Objects       int    `json:"objects"`
+	ObjectStreams int    `json:"objectStreams"`
+	XRefStream    bool   `json:"xrefStream"`
+}
+
+// DuplicatesReport represents the number of duplicate objects removed.
+type DuplicatesReport struct {
+	Fonts  int `json:"fonts"`
+	Images int `json:"images"`
+	Infos  int `json:"infos"`
+}
+
+// FontReport represents an embedded or referenced font.
+// Sizes are the lengths of the embedded font program.
+type FontReport struct {
+	ObjNr      int    `json:"objNr"`
+	Name       string `json:"name"`
+	Subtype    string `json:"subtype"`
+	Embedded   bool   `json:"embedded"`
+	SourceSize int64  `json:"sourceSize"`
+	Size       int64  `json:"size"`
+	Removed    bool   `json:"removed,omitempty"`
+}
+
+// ImageReport represents an image XObject.
+type ImageReport struct {
+	ObjNr            int    `json:"objNr"`
+	SourceWidth      int    `json:"sourceWidth"`
+	SourceHeight     int    `json:"sourceHeight"`
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	BitsPerComponent int    `json:"bitsPerComponent,omitempty"`
+	ColorSpace       string `json:"colorSpace,omitempty"`
+	Filter           string `json:"filter,omitempty"`
+	SourceSize       int64  `json:"sourceSize"`
+	Size             int64  `json:"size"`
+	Removed          bool   `json:"removed,omitempty"`
+}
+
+// OptimizeReport is a machine readable summary of an optimize run.
+// Object numbers refer to the destination file, removed fonts and images have object number 0.
+type OptimizeReport struct {
+	Source      FileReport       `json:"source"`
+	Destination FileReport       `json:"destination"`
+	Duplicates  DuplicatesReport `json:"duplicatesRemoved"`
+	Fonts       []*FontReport    `json:"fonts"`
+	Images      []*ImageReport   `json:"images"`
+}
+
+// NewOptimizeReport returns a new report for a read file initialized with the source file stats.
+func NewOptimizeReport(ctx *PDFContext) *OptimizeReport {
+
+	rc := ctx.Read
+
+	images := rc.BinaryImageSize + rc.BinaryImageDuplSize
+	fonts := rc.BinaryFontSize + rc.BinaryFontDuplSize
+
+	oc := ctx.Optimize
+
+	return &OptimizeReport{
+		Source: FileReport{
+			FileName:      rc.FileName,
+			Size:          rc.FileSize,
+			Images:        images,
+			Fonts:         fonts,
+			Other:         rc.FileSize - images - fonts,
+			Objects:       *ctx.Size,
+			ObjectStreams: len(rc.ObjectStreams),
+			XRefStream:    rc.UsingXRefStreams,
+		},
+		Duplicates: DuplicatesReport{
+			Fonts:  len(oc.DuplicateFontObjs),
+			Images: len(oc.DuplicateImageObjs),
+			Infos:  len(oc.DuplicateInfoObjects),
+		},
+		Fonts:  []*FontReport{},
+		Images: []*ImageReport{},
+	}
+}
+
+// Renumber translates the object numbers of all fonts and images.
+// Objects missing in newNr have been removed.
+func (r *OptimizeReport) Renumber(newNr map[int]int) {
+
+	for _, f := range r.Fonts {
+		if f.Removed {
+			continue
+		}
+		objNr, found := newNr[f.ObjNr]
+		f.ObjNr, f.Removed = objNr, !found
+	}
+
+	for _, img := range r.Images {
+		if img.Removed {
+			continue
+		}
+		objNr, found := newNr[img.ObjNr]
+		img.ObjNr, img.Removed = objNr, !found
+	}
+}
+
+// SetDestination completes the destination file stats after the file has been written.
+// The sizes of images and fonts are expected to be set already.
+func (r *OptimizeReport) SetDestination(ctx *PDFContext) {
+
+	d := &r.Destination
+
+	d.FileName = ctx.Write.DirName + ctx.Write.FileName
+	d.Size = ctx.Write.FileSize
+	d.Other = d.Size - d.Images - d.Fonts
+	d.Objects = *ctx.Size
+	d.XRefStream = ctx.WriteXRefStream
+
+	objStreams := IntSet{}
+	for _, entry := range ctx.Table {
+		if entry.Compressed && entry.ObjectStream != nil {
+			objStreams[*entry.ObjectStream] = true
+		}
+	}
+	d.ObjectStreams = len(objStreams)
+}
+
+// JSON returns the indented JSON representation of this report.
+func (r *OptimizeReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "\t")
+}
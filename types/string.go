@@ -2,8 +2,10 @@ package types
 
 import (
 	"bytes"
+	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -148,3 +150,20 @@ func Unescape(s string) ([]byte, error) {
 
 	return b.Bytes(), nil
 }
+
+// DateString returns a PDF date string for t.
+func DateString(t time.Time) string {
+
+	_, tz := t.Zone()
+
+	sign := '+'
+	if tz < 0 {
+		sign = '-'
+		tz = -tz
+	}
+
+	return fmt.Sprintf("D:%d%02d%02d%02d%02d%02d%c%02d'%02d'",
+		t.Year(), t.Month(), t.Day(),
+		t.Hour(), t.Minute(), t.Second(),
+		sign, tz/60/60, tz/60%60)
+}
@@ -126,6 +126,42 @@ func DecodeUTF16String(s string) (string, error) {
 	return decodeUTF16String([]byte(s))
 }
 
+// EncodeUTF16String encodes s as UTF16BE prefixed by the byte order mark.
+func EncodeUTF16String(s string) string {
+
+	b := []byte{0xFE, 0xFF}
+
+	for _, v := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(v>>8), byte(v&0xFF))
+	}
+
+	return string(b)
+}
+
+func isASCII(s string) bool {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TextStringLiteral returns an escaped string literal for a text string.
+// Non ASCII text gets UTF16BE encoded.
+func TextStringLiteral(s string) PDFStringLiteral {
+
+	if !isASCII(s) {
+		s = EncodeUTF16String(s)
+	}
+
+	s1, _ := Escape(s)
+
+	return PDFStringLiteral(*s1)
+}
+
 // StringLiteralToString returns the best possible string rep for a string literal.
 func StringLiteralToString(s string) (string, error) {
 
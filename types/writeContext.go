@@ -47,16 +47,23 @@ func (wc *WriteContext) HasWriteOffset(objNumber int) bool {
 	return found
 }
 
-// ReducedFeatureSet returns true for Split,Trim,Merge,ExtractPages.
+// ReducedFeatureSet returns true for Split,Trim,ExtractPages.
 // Don't confuse with pdfcpu commands, these are internal triggers.
+// Merge keeps annotations, forms and name trees, see MergedFeatureSet.
 func (wc *WriteContext) ReducedFeatureSet() bool {
 	switch wc.Command {
-	case "Split", "Trim", "Merge":
+	case "Split", "Trim":
 		return true
 	}
 	return false
 }
 
+// MergedFeatureSet returns true for Merge.
+// Root entries bound to the page tree of the first file are not written.
+func (wc *WriteContext) MergedFeatureSet() bool {
+	return wc.Command == "Merge"
+}
+
 // ExtractPage returns true if page i needs to be generated.
 func (wc *WriteContext) ExtractPage(i int) bool {
 
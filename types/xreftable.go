@@ -871,6 +871,136 @@ func (xRefTable *XRefTable) Pages() (*PDFIndirectRef, error) {
 	return rootDict.IndirectRefEntry("Pages"), nil
 }
 
+// collectPages appends the pages of the page tree node indRef.
+// visited holds the object numbers of the page tree nodes processed so far and protects against cycles.
+func (xRefTable *XRefTable) collectPages(indRef PDFIndirectRef, pages *[]PDFIndirectRef, visited IntSet) (err error) {
+
+	objNr := indRef.ObjectNumber.Value()
+	if visited[objNr] {
+		return errors.Errorf("collectPages: cycle in page tree at obj#%d", objNr)
+	}
+	visited[objNr] = true
+
+	dict, err := xRefTable.DereferenceDict(indRef)
+	if err != nil {
+		return
+	}
+
+	if dict == nil {
+		return errors.New("collectPages: pageNodeDict is null")
+	}
+
+	kids := dict.PDFArrayEntry("Kids")
+	if kids == nil {
+		return errors.New("collectPages: corrupt \"Kids\" entry")
+	}
+
+	for _, obj := range *kids {
+
+		if obj == nil {
+			continue
+		}
+
+		kidIndRef, ok := obj.(PDFIndirectRef)
+		if !ok {
+			return errors.New("collectPages: missing indirect reference for kid")
+		}
+
+		pageNodeDict, err := xRefTable.DereferenceDict(kidIndRef)
+		if err != nil {
+			return err
+		}
+
+		if pageNodeDict == nil {
+			return errors.New("collectPages: pageNodeDict is null")
+		}
+
+		dictType := pageNodeDict.Type()
+		if dictType == nil {
+			return errors.New("collectPages: missing pageNodeDict type")
+		}
+
+		switch *dictType {
+
+		case "Pages":
+			err = xRefTable.collectPages(kidIndRef, pages, visited)
+			if err != nil {
+				return err
+			}
+
+		case "Page":
+			*pages = append(*pages, kidIndRef)
+
+		default:
+			return errors.Errorf("collectPages: unexpected dict type: %s", *dictType)
+		}
+
+	}
+
+	return
+}
+
+// PageList returns the indirect references of all page dicts in page tree order.
+// The page with page number i is located at index i-1.
+func (xRefTable *XRefTable) PageList() (pages []PDFIndirectRef, err error) {
+
+	indRef, err := xRefTable.Pages()
+	if err != nil {
+		return
+	}
+
+	if indRef == nil {
+		return nil, errors.New("PageList: missing \"Pages\" entry")
+	}
+
+	err = xRefTable.collectPages(*indRef, &pages, IntSet{})
+
+	return
+}
+
+// PageDict returns the page dict for a page number along with its indirect reference.
+func (xRefTable *XRefTable) PageDict(pageNr int) (dict *PDFDict, indRef *PDFIndirectRef, err error) {
+
+	pages, err := xRefTable.PageList()
+	if err != nil {
+		return
+	}
+
+	if pageNr < 1 || pageNr > len(pages) {
+		return nil, nil, errors.Errorf("PageDict: invalid page number: %d", pageNr)
+	}
+
+	indRef = &pages[pageNr-1]
+
+	dict, err = xRefTable.DereferenceDict(*indRef)
+
+	return
+}
+
+// InheritedPageAttr returns the value of an inheritable page attribute like Resources or MediaBox.
+// The page tree is searched upwards starting at pageDict.
+func (xRefTable *XRefTable) InheritedPageAttr(pageDict *PDFDict, key string) (obj interface{}, err error) {
+
+	for d := pageDict; d != nil; {
+
+		if obj, found := d.Find(key); found {
+			return obj, nil
+		}
+
+		parent, found := d.Find("Parent")
+		if !found {
+			return nil, nil
+		}
+
+		d, err = xRefTable.DereferenceDict(parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
 // MissingObjects returns the number of objects that were not written
 // plus the corresponding comma separated string representation.
 func (xRefTable *XRefTable) MissingObjects() (int, *string) {
@@ -386,8 +386,8 @@ func validateAnnotationDictLink(xRefTable *types.XRefTable, dict *types.PDFDict,
 		return
 	}
 
-	// QuadPoints, optional, number array, len=8, since V1.6
-	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "QuadPoints", OPTIONAL, types.V16, func(a types.PDFArray) bool { return len(a) == 8 })
+	// QuadPoints, optional, number array, len: multiple of 8, since V1.6
+	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "QuadPoints", OPTIONAL, types.V16, func(a types.PDFArray) bool { return len(a) > 0 && len(a)%8 == 0 })
 	if err != nil {
 		return
 	}
@@ -783,8 +783,8 @@ func validateTextMarkupAnnotation(xRefTable *types.XRefTable, dict *types.PDFDic
 		return
 	}
 
-	// QuadPoints, required, number array, len:8
-	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "QuadPoints", REQUIRED, types.V10, func(a types.PDFArray) bool { return len(a) == 8 })
+	// QuadPoints, required, number array, len: multiple of 8
+	_, err = validateNumberArrayEntry(xRefTable, dict, dictName, "QuadPoints", REQUIRED, types.V10, func(a types.PDFArray) bool { return len(a) > 0 && len(a)%8 == 0 })
 	if err != nil {
 		return
 	}
@@ -1,7 +1,6 @@
 package write
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
@@ -12,13 +11,7 @@ import (
 
 func date() (string, error) {
 
-	now := time.Now()
-	_, tz := now.Zone()
-
-	dateStr := fmt.Sprintf("D:%d%02d%02d%02d%02d%02d+%02d'%02d'",
-		now.Year(), now.Month(), now.Day(),
-		now.Hour(), now.Minute(), now.Second(),
-		tz/60/60, tz/60%60)
+	dateStr := types.DateString(time.Now())
 
 	if !validate.Date(dateStr) {
 		return "", errors.Errorf("date: invalid dateString: %s\n", dateStr)
@@ -0,0 +1,952 @@
+package write
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/hhrutter/pdfcpu/crypto"
+	"github.com/hhrutter/pdfcpu/filter"
+	"github.com/hhrutter/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// A linearized file (Annex F) is organized as follows:
+//
+//	1  header
+//	2  linearization parameter dict
+//	3  first-page cross reference table and trailer
+//	4  document catalog and document-level objects
+//	5  primary hint stream
+//	6  first-page section
+//	7  remaining pages
+//	8  shared objects of all pages except the first
+//	9  other objects
+//	10 main cross reference table and trailer
+//
+// Objects of parts 2 to 6 are numbered after the objects of parts 7 to 9.
+
+// reservedInt is a placeholder wide enough for any offset or length to be patched later.
+const reservedInt int64 = 9999999999
+
+// documentKeys lists the catalog entries needed for opening a document.
+var documentKeys = []string{"ViewerPreferences", "PageMode", "Threads", "OpenAction", "AcroForm"}
+
+// inheritedPageAttrs lists the page attributes that may be inherited from the page tree.
+var inheritedPageAttrs = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// linearization represents the object layout of a linearized file.
+type linearization struct {
+	ctx        *types.PDFContext
+	pageTree   types.IntSet // page dicts and page tree nodes
+	doc        []int        // document-level objects (part 4)
+	pages      [][]int      // first-page section (part 6) followed by the objects private to each remaining page (part 7)
+	sharedRefs [][]int      // shared objects referenced by each page
+	shared     []int        // shared objects section (part 8)
+	other      []int        // other objects (part 9)
+	linNr      int          // linearization parameter dict
+	hintNr     int          // primary hint stream
+}
+
+// refs appends the objects referenced by o and not seen before to objNrs.
+// Page dicts and page tree nodes are not followed.
+func (l *linearization) refs(o interface{}, seen types.IntSet, objNrs []int) []int {
+
+	switch o := o.(type) {
+
+	case types.PDFIndirectRef:
+		objNr := o.ObjectNumber.Value()
+		if seen[objNr] || l.pageTree[objNr] || !l.ctx.Resolvable(o) {
+			break
+		}
+		seen[objNr] = true
+		objNrs = append(objNrs, objNr)
+
+	case types.PDFDict:
+		var keys []string
+		for k := range o.Dict {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			objNrs = l.refs(o.Dict[k], seen, objNrs)
+		}
+
+	case types.PDFStreamDict:
+		// Stream lengths are written as direct objects.
+		d := types.NewPDFDict()
+		for k, v := range o.Dict {
+			if k != "Length" {
+				d.Dict[k] = v
+			}
+		}
+		objNrs = l.refs(d, seen, objNrs)
+
+	case types.PDFArray:
+		for _, v := range o {
+			objNrs = l.refs(v, seen, objNrs)
+		}
+	}
+
+	return objNrs
+}
+
+// reachable returns objNrs followed by all objects reachable from objNrs and o in breadth first order.
+func (l *linearization) reachable(seen types.IntSet, objNrs []int, o ...interface{}) []int {
+
+	for _, objNr := range objNrs {
+		seen[objNr] = true
+	}
+
+	for _, o := range o {
+		objNrs = l.refs(o, seen, objNrs)
+	}
+
+	for i := 0; i < len(objNrs); i++ {
+		objNrs = l.refs(l.ctx.Table[objNrs[i]].Object, seen, objNrs)
+	}
+
+	return objNrs
+}
+
+// pageObjects returns a page dict followed by all objects it uses including inherited attributes.
+func (l *linearization) pageObjects(indRef types.PDFIndirectRef, seen types.IntSet) ([]int, error) {
+
+	pageDict, err := l.ctx.DereferenceDict(indRef)
+	if err != nil || pageDict == nil {
+		return nil, errors.Errorf("linearize: corrupt page dict for obj#%d", indRef.ObjectNumber)
+	}
+
+	var attrs []interface{}
+	for _, key := range inheritedPageAttrs {
+		o, err := l.ctx.InheritedPageAttr(pageDict, key)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, o)
+	}
+
+	return l.reachable(seen, []int{indRef.ObjectNumber.Value()}, attrs...), nil
+}
+
+// collectPageTree records all page dicts and their ancestors.
+func (l *linearization) collectPageTree(pages []types.PDFIndirectRef) {
+
+	for _, indRef := range pages {
+
+		var o interface{} = indRef
+
+		for o != nil {
+
+			indRef, ok := o.(types.PDFIndirectRef)
+			if !ok || l.pageTree[indRef.ObjectNumber.Value()] {
+				break
+			}
+			l.pageTree[indRef.ObjectNumber.Value()] = true
+
+			d, err := l.ctx.DereferenceDict(indRef)
+			if err != nil || d == nil {
+				break
+			}
+
+			o, _ = d.Find("Parent")
+		}
+	}
+}
+
+// newLinearization assigns all objects reachable from the trailer to the parts of a linearized file.
+func newLinearization(ctx *types.PDFContext) (*linearization, error) {
+
+	l := &linearization{ctx: ctx, pageTree: types.IntSet{}}
+
+	pages, err := ctx.PageList()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pages) == 0 {
+		return nil, errors.New("linearize: missing pages")
+	}
+
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	l.collectPageTree(pages)
+
+	// The document catalog and the encryption dict always go first.
+	doc := types.IntSet{ctx.Root.ObjectNumber.Value(): true}
+	l.doc = []int{ctx.Root.ObjectNumber.Value()}
+	if ctx.Encrypt != nil && ctx.EncKey != nil {
+		doc[ctx.Encrypt.ObjectNumber.Value()] = true
+		l.doc = append(l.doc, ctx.Encrypt.ObjectNumber.Value())
+	}
+
+	seen := func() types.IntSet {
+		s := types.IntSet{}
+		for _, objNr := range l.doc {
+			s[objNr] = true
+		}
+		return s
+	}
+
+	// The first page takes all objects it uses.
+	first, err := l.pageObjects(pages[0], seen())
+	if err != nil {
+		return nil, err
+	}
+
+	inFirst := types.IntSet{}
+	for _, objNr := range first {
+		inFirst[objNr] = true
+	}
+
+	l.pages = [][]int{first}
+	l.sharedRefs = [][]int{nil}
+
+	keys := documentKeys
+	if pm := catalog.NameEntry("PageMode"); pm != nil && *pm == "UseOutlines" {
+		keys = append(keys, "Outlines")
+	}
+
+	var o []interface{}
+	for _, key := range keys {
+		o = append(o, catalog.Dict[key])
+	}
+
+	for _, objNr := range l.reachable(seen(), nil, o...) {
+		if !inFirst[objNr] {
+			doc[objNr] = true
+			l.doc = append(l.doc, objNr)
+		}
+	}
+
+	// Count the pages using each remaining object.
+	users := map[int]int{}
+	var pageObjs [][]int
+
+	for _, indRef := range pages[1:] {
+
+		objNrs, err := l.pageObjects(indRef, types.IntSet{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, objNr := range objNrs {
+			users[objNr]++
+		}
+
+		pageObjs = append(pageObjs, objNrs)
+	}
+
+	shared := types.IntSet{}
+
+	for _, objNrs := range pageObjs {
+
+		var private, sharedRefs []int
+
+		for _, objNr := range objNrs {
+
+			switch {
+
+			case inFirst[objNr]:
+				sharedRefs = append(sharedRefs, objNr)
+
+			case doc[objNr]:
+
+			case users[objNr] == 1:
+				private = append(private, objNr)
+
+			default:
+				if !shared[objNr] {
+					shared[objNr] = true
+					l.shared = append(l.shared, objNr)
+				}
+				sharedRefs = append(sharedRefs, objNr)
+			}
+		}
+
+		l.pages = append(l.pages, private)
+		l.sharedRefs = append(l.sharedRefs, sharedRefs)
+	}
+
+	// Anything else like the page tree, outlines or the document info dict goes last.
+	assigned := seen()
+	for _, objNr := range l.shared {
+		assigned[objNr] = true
+	}
+	for _, objNrs := range l.pages {
+		for _, objNr := range objNrs {
+			assigned[objNr] = true
+		}
+	}
+
+	l.pageTree = types.IntSet{}
+
+	var roots []interface{}
+	for _, indRef := range ctx.TrailerRefs() {
+		roots = append(roots, indRef)
+	}
+
+	for _, objNr := range l.reachable(types.IntSet{}, nil, roots...) {
+		if !assigned[objNr] {
+			l.other = append(l.other, objNr)
+		}
+	}
+
+	sort.Ints(l.other)
+
+	return l, nil
+}
+
+func renumberedNrs(objNrs []int, newNr map[int]int) []int {
+
+	a := make([]int, len(objNrs))
+	for i, objNr := range objNrs {
+		a[i] = newNr[objNr]
+	}
+
+	return a
+}
+
+// mainObjects returns the objects listed in the main cross reference table in file order.
+func (l *linearization) mainObjects() (objNrs []int) {
+
+	for _, a := range l.pages[1:] {
+		objNrs = append(objNrs, a...)
+	}
+
+	objNrs = append(objNrs, l.shared...)
+
+	return append(objNrs, l.other...)
+}
+
+// renumber numbers all objects in file order and replaces the cross reference table.
+// The first-page section is numbered last starting with the linearization parameter dict and ending with the hint stream.
+func (l *linearization) renumber() {
+
+	ctx := l.ctx
+
+	newNr := map[int]int{}
+
+	i := 1
+	for _, objNr := range l.mainObjects() {
+		newNr[objNr] = i
+		i++
+	}
+
+	l.linNr = i
+	i++
+
+	for _, objNr := range append(append([]int{}, l.doc...), l.pages[0]...) {
+		newNr[objNr] = i
+		i++
+	}
+
+	l.hintNr = i
+
+	ctx.Renumber(newNr)
+
+	// Stream lengths are written as direct objects.
+	for _, i := range newNr {
+		if sd, ok := ctx.Table[i].Object.(types.PDFStreamDict); ok {
+			sd.Update("Length", types.PDFInteger(len(sd.Raw)))
+			sd.StreamLengthObjNr = nil
+			ctx.Table[i].Object = sd
+		}
+	}
+
+	ctx.Table[l.linNr] = types.NewXRefTableEntryGen0(types.NewPDFDict())
+	ctx.Table[l.hintNr] = types.NewXRefTableEntryGen0(nil)
+
+	size := len(ctx.Table)
+	ctx.Size = &size
+
+	logInfoWriter.Printf("linearize: %d objects\n", size)
+
+	if ctx.Optimize.Report != nil {
+		ctx.Optimize.Report.Renumber(newNr)
+	}
+
+	l.doc = renumberedNrs(l.doc, newNr)
+	for i := range l.pages {
+		l.pages[i] = renumberedNrs(l.pages[i], newNr)
+		l.sharedRefs[i] = renumberedNrs(l.sharedRefs[i], newNr)
+	}
+	l.shared = renumberedNrs(l.shared, newNr)
+	l.other = renumberedNrs(l.other, newNr)
+}
+
+// bitWriter packs unsigned integers most significant bit first as needed for hint tables.
+type bitWriter struct {
+	buf   []byte
+	cur   byte
+	nbits uint
+}
+
+func (w *bitWriter) write(v int64, nbits int) {
+
+	for i := nbits - 1; i >= 0; i-- {
+		w.cur = w.cur<<1 | byte(v>>uint(i)&1)
+		w.nbits++
+		if w.nbits == 8 {
+			w.buf = append(w.buf, w.cur)
+			w.cur, w.nbits = 0, 0
+		}
+	}
+}
+
+// flush pads the current byte with zero bits.
+func (w *bitWriter) flush() {
+
+	if w.nbits > 0 {
+		w.buf = append(w.buf, w.cur<<(8-w.nbits))
+		w.cur, w.nbits = 0, 0
+	}
+}
+
+// bitsNeeded returns the number of bits needed to represent v.
+func bitsNeeded(v int64) (n int) {
+
+	for ; v > 0; v >>= 1 {
+		n++
+	}
+
+	return n
+}
+
+// minMax returns the least and the greatest value of a.
+func minMax(a []int64) (min, max int64) {
+
+	for i, v := range a {
+		if i == 0 || v < min {
+			min = v
+		}
+		if i == 0 || v > max {
+			max = v
+		}
+	}
+
+	return min, max
+}
+
+// pageOffsetHintTable writes the page offset hint table (F.4.1).
+// Offsets are given as if the hint stream was not present.
+func (l *linearization) pageOffsetHintTable(w *bitWriter, offset, length map[int]int64, sharedID map[int]int) {
+
+	n := len(l.pages)
+
+	objs := make([]int64, n)
+	lens := make([]int64, n)
+	var maxShared, maxID int64
+
+	for i, objNrs := range l.pages {
+		objs[i] = int64(len(objNrs))
+		for _, objNr := range objNrs {
+			lens[i] += length[objNr]
+		}
+		if int64(len(l.sharedRefs[i])) > maxShared {
+			maxShared = int64(len(l.sharedRefs[i]))
+		}
+		for _, objNr := range l.sharedRefs[i] {
+			if int64(sharedID[objNr]) > maxID {
+				maxID = int64(sharedID[objNr])
+			}
+		}
+	}
+
+	minObjs, maxObjs := minMax(objs)
+	minLen, maxLen := minMax(lens)
+
+	objBits := bitsNeeded(maxObjs - minObjs)
+	lenBits := bitsNeeded(maxLen - minLen)
+	sharedBits := bitsNeeded(maxShared)
+	idBits := bitsNeeded(maxID)
+
+	// Header
+	w.write(minObjs, 32)
+	w.write(offset[l.pages[0][0]], 32)
+	w.write(int64(objBits), 16)
+	w.write(minLen, 32)
+	w.write(int64(lenBits), 16)
+	// Content streams are considered to span the whole page.
+	w.write(0, 32)
+	w.write(0, 16)
+	w.write(minLen, 32)
+	w.write(int64(lenBits), 16)
+	w.write(int64(sharedBits), 16)
+	w.write(int64(idBits), 16)
+	// Fractional positions of shared objects are not used.
+	w.write(0, 16)
+	w.write(1, 16)
+
+	// Each item is written for all pages in turn.
+	for i := 0; i < n; i++ {
+		w.write(objs[i]-minObjs, objBits)
+	}
+	w.flush()
+
+	for i := 0; i < n; i++ {
+		w.write(lens[i]-minLen, lenBits)
+	}
+	w.flush()
+
+	for i := 0; i < n; i++ {
+		w.write(int64(len(l.sharedRefs[i])), sharedBits)
+	}
+	w.flush()
+
+	for i := 0; i < n; i++ {
+		for _, objNr := range l.sharedRefs[i] {
+			w.write(int64(sharedID[objNr]), idBits)
+		}
+	}
+	w.flush()
+
+	// Numerators and content stream offsets take 0 bits.
+	w.flush()
+	w.flush()
+
+	for i := 0; i < n; i++ {
+		w.write(lens[i]-minLen, lenBits)
+	}
+	w.flush()
+}
+
+// sharedObjectHintTable writes the shared object hint table (F.4.2).
+// The first entries stand for the objects of the first-page section followed by the shared objects section.
+func (l *linearization) sharedObjectHintTable(w *bitWriter, offset, length map[int]int64) {
+
+	objNrs := append(append([]int{}, l.pages[0]...), l.shared...)
+
+	lens := make([]int64, len(objNrs))
+	for i, objNr := range objNrs {
+		lens[i] = length[objNr]
+	}
+
+	minLen, maxLen := minMax(lens)
+	lenBits := bitsNeeded(maxLen - minLen)
+
+	var firstObjNr int
+	var firstOffset int64
+	if len(l.shared) > 0 {
+		firstObjNr = l.shared[0]
+		firstOffset = offset[firstObjNr]
+	}
+
+	// Header
+	w.write(int64(firstObjNr), 32)
+	w.write(firstOffset, 32)
+	w.write(int64(len(l.pages[0])), 32)
+	w.write(int64(len(objNrs)), 32)
+	// Each group consists of a single object.
+	w.write(0, 16)
+	w.write(minLen, 32)
+	w.write(int64(lenBits), 16)
+
+	for _, v := range lens {
+		w.write(v-minLen, lenBits)
+	}
+	w.flush()
+
+	// No MD5 signatures.
+	for range lens {
+		w.write(0, 1)
+	}
+	w.flush()
+}
+
+// hintStream returns the primary hint stream holding the page offset and shared object hint tables.
+func (l *linearization) hintStream(offset, length map[int]int64) (*types.PDFStreamDict, error) {
+
+	sharedID := map[int]int{}
+	for i, objNr := range append(append([]int{}, l.pages[0]...), l.shared...) {
+		sharedID[objNr] = i
+	}
+
+	w := &bitWriter{}
+
+	l.pageOffsetHintTable(w, offset, length, sharedID)
+	s := len(w.buf)
+
+	l.sharedObjectHintTable(w, offset, length)
+
+	sd := types.PDFStreamDict{
+		PDFDict:        types.NewPDFDict(),
+		Content:        w.buf,
+		FilterPipeline: []types.PDFFilter{{Name: "FlateDecode"}},
+	}
+	sd.Insert("Filter", types.PDFName("FlateDecode"))
+	sd.Insert("S", types.PDFInteger(s))
+
+	err := filter.EncodeStream(&sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sd, nil
+}
+
+// buffered returns the bytes written by f.
+func buffered(ctx *types.PDFContext, f func() error) ([]byte, error) {
+
+	var b bytes.Buffer
+
+	w := ctx.Write.Writer
+	ctx.Write.Writer = bufio.NewWriter(&b)
+	defer func() { ctx.Write.Writer = w }()
+
+	err := f()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.Write.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeObject writes a single object without following its references.
+func writeObject(ctx *types.PDFContext, objNr int) (err error) {
+
+	o := ctx.Table[objNr].Object
+
+	// The encryption dict itself is never encrypted.
+	if ctx.Encrypt != nil && ctx.Encrypt.ObjectNumber.Value() == objNr {
+		d, ok := o.(types.PDFDict)
+		if !ok {
+			return errors.New("linearize: corrupt encrypt dict")
+		}
+		return writePDFObject(ctx, objNr, 0, d.PDFString())
+	}
+
+	switch o := o.(type) {
+
+	case nil:
+		err = writePDFNullObject(ctx, objNr, 0)
+
+	case types.PDFDict:
+		err = writePDFDictObject(ctx, objNr, 0, o)
+
+	case types.PDFStreamDict:
+		if ctx.EncKey != nil {
+			_, err = crypto.EncryptDeepObject(o, objNr, 0, ctx.EncKey, ctx.AES4Strings)
+			if err != nil {
+				return
+			}
+		}
+		err = writePDFStreamDictObject(ctx, objNr, 0, o)
+
+	case types.PDFArray:
+		err = writePDFArrayObject(ctx, objNr, 0, o)
+
+	case types.PDFInteger:
+		err = writePDFIntegerObject(ctx, objNr, 0, o)
+
+	case types.PDFFloat:
+		err = writePDFFloatObject(ctx, objNr, 0, o)
+
+	case types.PDFStringLiteral:
+		err = writePDFStringLiteralObject(ctx, objNr, 0, o)
+
+	case types.PDFHexLiteral:
+		err = writePDFHexLiteralObject(ctx, objNr, 0, o)
+
+	case types.PDFBoolean:
+		err = writePDFBooleanObject(ctx, objNr, 0, o)
+
+	case types.PDFName:
+		err = writePDFNameObject(ctx, objNr, 0, o)
+
+	default:
+		err = errors.Errorf("linearize: undefined PDF object #%d\n", objNr)
+	}
+
+	return
+}
+
+// serialized returns the serialized objects for objNrs.
+func serialized(ctx *types.PDFContext, objNrs []int) (bufs [][]byte, err error) {
+
+	for _, objNr := range objNrs {
+
+		buf, err := buffered(ctx, func() error { return writeObject(ctx, objNr) })
+		if err != nil {
+			return nil, err
+		}
+
+		bufs = append(bufs, buf)
+	}
+
+	return bufs, nil
+}
+
+// padded appends blanks to s up to the length of the same string holding placeholders.
+func padded(s, placeholder string) string {
+	return s + strings.Repeat(" ", len(placeholder)-len(s))
+}
+
+// writeParmDict writes the linearization parameter dict taking up the space needed for the largest values.
+func (l *linearization) writeParmDict(fileLen, hintOffset, hintLen, firstPageEnd, mainXRefOffset int64) error {
+
+	d := types.NewPDFDict()
+	d.Insert("Linearized", types.PDFInteger(1))
+	d.Insert("L", types.PDFInteger(fileLen))
+	d.Insert("H", types.PDFArray{types.PDFInteger(hintOffset), types.PDFInteger(hintLen)})
+	d.Insert("O", types.PDFInteger(l.pages[0][0]))
+	d.Insert("E", types.PDFInteger(firstPageEnd))
+	d.Insert("N", types.PDFInteger(len(l.pages)))
+	d.Insert("T", types.PDFInteger(mainXRefOffset))
+
+	l.ctx.Table[l.linNr].Object = d
+
+	// Some readers expect the Linearized entry to come first.
+	format := "<</Linearized 1/L %d/H[%d %d]/O %d/E %d/N %d/T %d>>"
+	s := fmt.Sprintf(format, fileLen, hintOffset, hintLen, l.pages[0][0], firstPageEnd, len(l.pages), mainXRefOffset)
+	r := reservedInt
+	placeholder := fmt.Sprintf(format, r, r, r, r, r, r, r)
+
+	return writePDFObject(l.ctx, l.linNr, 0, padded(s, placeholder))
+}
+
+// writeFirstPageXRef writes the cross reference table and trailer for the objects of the first-page section.
+func (l *linearization) writeFirstPageXRef(mainXRefOffset int64) (err error) {
+
+	ctx := l.ctx
+	w := ctx.Write
+
+	_, err = w.WriteString("xref" + w.Eol)
+	if err != nil {
+		return
+	}
+
+	err = writeXRefSubsection(ctx, l.linNr, l.hintNr-l.linNr+1)
+	if err != nil {
+		return
+	}
+
+	d := trailerDict(ctx)
+	d.Insert("Prev", types.PDFInteger(mainXRefOffset))
+
+	// Leave room for the largest offset.
+	pad := strings.Repeat(" ", len(fmt.Sprint(reservedInt))-len(fmt.Sprint(mainXRefOffset)))
+
+	_, err = w.WriteString("trailer" + w.Eol + d.PDFString() + pad + w.Eol)
+	if err != nil {
+		return
+	}
+
+	// Readers start at the first-page cross reference table anyway.
+	_, err = w.WriteString("startxref" + w.Eol + "0" + w.Eol + "%%EOF" + w.Eol)
+
+	return
+}
+
+// writeMainXRef writes the cross reference table and trailer for all objects not in the first-page section.
+func (l *linearization) writeMainXRef(firstPageXRefOffset int64) (err error) {
+
+	ctx := l.ctx
+	w := ctx.Write
+
+	_, err = w.WriteString("xref" + w.Eol)
+	if err != nil {
+		return
+	}
+
+	err = writeXRefSubsection(ctx, 0, l.linNr)
+	if err != nil {
+		return
+	}
+
+	d := types.NewPDFDict()
+	d.Insert("Size", types.PDFInteger(l.linNr))
+
+	_, err = w.WriteString("trailer" + w.Eol + d.PDFString() + w.Eol)
+	if err != nil {
+		return
+	}
+
+	_, err = w.WriteString(fmt.Sprintf("startxref%s%d%s", w.Eol, firstPageXRefOffset, w.Eol))
+	if err != nil {
+		return
+	}
+
+	_, err = writeTrailer(w)
+
+	return
+}
+
+// prepareLinearizedFile applies the changes made to a document during writing, like updating the info dict, without writing anything.
+func prepareLinearizedFile(ctx *types.PDFContext) (err error) {
+
+	encKey, w := ctx.EncKey, ctx.Write.Writer
+
+	ctx.EncKey = nil
+	ctx.Write.Writer = bufio.NewWriter(ioutil.Discard)
+
+	defer func() {
+		ctx.EncKey, ctx.Write.Writer = encKey, w
+		ctx.Write.Table = map[int]int64{}
+		ctx.Write.Offset = 0
+		ctx.Write.BinaryTotalSize = 0
+	}()
+
+	err = writeRootObject(ctx)
+	if err != nil {
+		return
+	}
+
+	return writeDocumentInfoDict(ctx)
+}
+
+// writeLinearizedFile writes a linearized PDF file optimized for incremental loading (Fast Web View).
+func writeLinearizedFile(ctx *types.PDFContext) (err error) {
+
+	// Linearized files are written using cross reference tables.
+	ctx.WriteObjectStream = false
+	ctx.WriteXRefStream = false
+
+	err = prepareLinearizedFile(ctx)
+	if err != nil {
+		return
+	}
+
+	l, err := newLinearization(ctx)
+	if err != nil {
+		return
+	}
+
+	l.renumber()
+
+	// Linearization has been introduced with PDF V1.2.
+	v := ctx.Version()
+	if v < types.V12 {
+		v = types.V12
+	}
+
+	header, err := buffered(ctx, func() error { return writeHeader(ctx.Write, v) })
+	if err != nil {
+		return
+	}
+
+	// Parameter dict and first-page cross reference table have a fixed size.
+	parmDict, err := buffered(ctx, func() error { return l.writeParmDict(0, 0, 0, 0, 0) })
+	if err != nil {
+		return
+	}
+
+	firstPageXRef, err := buffered(ctx, func() error { return l.writeFirstPageXRef(0) })
+	if err != nil {
+		return
+	}
+
+	mainObjs := l.mainObjects()
+
+	var bufs [3][][]byte
+	for i, objNrs := range [][]int{l.doc, l.pages[0], mainObjs} {
+		bufs[i], err = serialized(ctx, objNrs)
+		if err != nil {
+			return
+		}
+	}
+
+	// Lay out all objects as if there was no hint stream.
+	offset, length := map[int]int64{}, map[int]int64{}
+	off := int64(len(header) + len(parmDict) + len(firstPageXRef))
+
+	var hintOffset, firstPageEnd int64
+
+	for i, objNrs := range [][]int{l.doc, l.pages[0], mainObjs} {
+		if i == 1 {
+			hintOffset = off
+		}
+		for j, objNr := range objNrs {
+			offset[objNr] = off
+			length[objNr] = int64(len(bufs[i][j]))
+			off += length[objNr]
+		}
+		if i == 1 {
+			firstPageEnd = off
+		}
+	}
+
+	mainXRefOffset := off
+
+	sd, err := l.hintStream(offset, length)
+	if err != nil {
+		return
+	}
+
+	ctx.Table[l.hintNr].Object = *sd
+
+	hint, err := buffered(ctx, func() error { return writeObject(ctx, l.hintNr) })
+	if err != nil {
+		return
+	}
+
+	// Now insert the hint stream.
+	hintLen := int64(len(hint))
+
+	for _, objNrs := range [][]int{l.pages[0], mainObjs} {
+		for _, objNr := range objNrs {
+			offset[objNr] += hintLen
+		}
+	}
+
+	firstPageEnd += hintLen
+	mainXRefOffset += hintLen
+	offset[l.hintNr] = hintOffset
+
+	ctx.Write.Table = offset
+
+	firstPageXRefOffset := int64(len(header) + len(parmDict))
+
+	mainXRef, err := buffered(ctx, func() error { return l.writeMainXRef(firstPageXRefOffset) })
+	if err != nil {
+		return
+	}
+
+	fileLen := mainXRefOffset + int64(len(mainXRef))
+
+	// The offset of the line end preceding the first entry of the main cross reference table.
+	t := mainXRefOffset + int64(len(fmt.Sprintf("xref%s%d %d", ctx.Write.Eol, 0, l.linNr)))
+
+	ctx.Write.Offset = int64(len(header))
+
+	parmDict, err = buffered(ctx, func() error { return l.writeParmDict(fileLen, hintOffset, hintLen, firstPageEnd, t) })
+	if err != nil {
+		return
+	}
+
+	firstPageXRef, err = buffered(ctx, func() error { return l.writeFirstPageXRef(mainXRefOffset) })
+	if err != nil {
+		return
+	}
+
+	parts := [][]byte{header, parmDict, firstPageXRef}
+	parts = append(parts, bufs[0]...)
+	parts = append(parts, hint)
+	parts = append(parts, bufs[1]...)
+	parts = append(parts, bufs[2]...)
+	parts = append(parts, mainXRef)
+
+	for _, b := range parts {
+		_, err = ctx.Write.Write(b)
+		if err != nil {
+			return
+		}
+	}
+
+	ctx.Write.Offset = fileLen
+
+	logInfoWriter.Printf("writeLinearizedFile: first page section ends at %d, hint stream: %d bytes\n", firstPageEnd, hintLen)
+
+	return
+}
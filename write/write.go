@@ -157,6 +157,13 @@ func writeRootObject(ctx *types.PDFContext) (err error) {
 		dict.Delete("OCProperties")
 	}
 
+	if ctx.Write.MergedFeatureSet() {
+		logDebugWriter.Println("writeRootObject: exclude outlines, open action and structure tree on merge.")
+		dict.Delete("Outlines")
+		dict.Delete("OpenAction")
+		dict.Delete("StructTreeRoot")
+	}
+
 	err = writePDFDictObject(ctx, objNumber, genNumber, *dict)
 	if err != nil {
 		return
@@ -233,23 +240,11 @@ func writeRootObject(ctx *types.PDFContext) (err error) {
 	return
 }
 
-func writeTrailerDict(ctx *types.PDFContext) (err error) {
-
-	logInfoWriter.Printf("writeTrailerDict begin\n")
+// trailerDict returns the trailer dict for the cross reference table contained in PDFContext.
+func trailerDict(ctx *types.PDFContext) types.PDFDict {
 
-	w := ctx.Write
 	xRefTable := ctx.XRefTable
 
-	_, err = w.WriteString("trailer")
-	if err != nil {
-		return
-	}
-
-	err = w.WriteEol()
-	if err != nil {
-		return
-	}
-
 	dict := types.NewPDFDict()
 	dict.Insert("Size", types.PDFInteger(*xRefTable.Size))
 	dict.Insert("Root", *xRefTable.Root)
@@ -266,6 +261,27 @@ func writeTrailerDict(ctx *types.PDFContext) (err error) {
 		dict.Insert("ID", *xRefTable.ID)
 	}
 
+	return dict
+}
+
+func writeTrailerDict(ctx *types.PDFContext) (err error) {
+
+	logInfoWriter.Printf("writeTrailerDict begin\n")
+
+	w := ctx.Write
+
+	_, err = w.WriteString("trailer")
+	if err != nil {
+		return
+	}
+
+	err = w.WriteEol()
+	if err != nil {
+		return
+	}
+
+	dict := trailerDict(ctx)
+
 	_, err = w.WriteString(dict.PDFString())
 	if err != nil {
 		return
@@ -375,7 +391,8 @@ func deleteRedundantObjects(ctx *types.PDFContext) (err error) {
 
 			// Since there is no type entry for stream dicts associated with linearization dicts
 			// we have to check every PDFStreamDict that has not been written.
-			if _, ok := entry.Object.(types.PDFStreamDict); ok {
+			// Objects added since reading have no offset.
+			if _, ok := entry.Object.(types.PDFStreamDict); ok && entry.Offset != nil {
 
 				if *entry.Offset == *xRefTable.OffsetPrimaryHintTable {
 					xRefTable.LinearizationObjs[i] = true
@@ -900,39 +917,8 @@ func setFileSizeOfWrittenFile(w *types.WriteContext, f *os.File) (err error) {
 	return
 }
 
-// PDFFile generates a PDF file for the cross reference table contained in PDFContext.
-func PDFFile(ctx *types.PDFContext) (err error) {
-
-	fileName := ctx.Write.DirName + ctx.Write.FileName
-
-	logInfoWriter.Printf("writing to %s...\n", fileName)
-
-	file, err := os.Create(fileName)
-	if err != nil {
-		return errors.Wrapf(err, "can't create %s\n%s", fileName, err)
-	}
-
-	ctx.Write.Writer = bufio.NewWriter(file)
-
-	defer func() {
-
-		// The underlying bufio.Writer has already been flushed.
-
-		// Processing error takes precedence.
-		if err != nil {
-			file.Close()
-			return
-		}
-
-		// Do not miss out on closing errors.
-		err = file.Close()
-
-	}()
-
-	err = handleEncryption(ctx)
-	if err != nil {
-		return
-	}
+// writeFile writes header, body, cross reference table and trailer of a PDF file.
+func writeFile(ctx *types.PDFContext) (err error) {
 
 	// Write a PDF file header stating the version of the used conforming writer.
 	// This has to be the source version or any version higher.
@@ -997,6 +983,52 @@ func PDFFile(ctx *types.PDFContext) (err error) {
 		return
 	}
 
+	return
+}
+
+// PDFFile generates a PDF file for the cross reference table contained in PDFContext.
+func PDFFile(ctx *types.PDFContext) (err error) {
+
+	fileName := ctx.Write.DirName + ctx.Write.FileName
+
+	logInfoWriter.Printf("writing to %s...\n", fileName)
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return errors.Wrapf(err, "can't create %s\n%s", fileName, err)
+	}
+
+	ctx.Write.Writer = bufio.NewWriter(file)
+
+	defer func() {
+
+		// The underlying bufio.Writer has already been flushed.
+
+		// Processing error takes precedence.
+		if err != nil {
+			file.Close()
+			return
+		}
+
+		// Do not miss out on closing errors.
+		err = file.Close()
+
+	}()
+
+	err = handleEncryption(ctx)
+	if err != nil {
+		return
+	}
+
+	if ctx.Linearize {
+		err = writeLinearizedFile(ctx)
+	} else {
+		err = writeFile(ctx)
+	}
+	if err != nil {
+		return
+	}
+
 	err = setFileSizeOfWrittenFile(ctx.Write, file)
 	if err != nil {
 		return